@@ -2,6 +2,7 @@ package app
 
 import (
 	ipban "ipBanSystem/ipBan/BanService"
+	"ipBanSystem/ipBan/control"
 	"ipBanSystem/ipBan/logger/accumulatorLogs"
 	"ipBanSystem/ipBan/logger/analyzerLogs"
 	"ipBanSystem/ipBan/logger/initLogs"
@@ -26,6 +27,11 @@ func Run() {
 	if err := initLogs.InitBannedUsersLogger(ipban.BANNED_USERS_LOG_PATH); err != nil {
 		log.Fatalf("Ошибка инициализации логгера забаненных пользователей: %v", err)
 	}
+	if ipban.LOG_VIOLATIONS {
+		if err := initLogs.InitViolationsLogger(ipban.VIOLATIONS_LOG_PATH); err != nil {
+			log.Fatalf("Ошибка инициализации журнала нарушений: %v", err)
+		}
+	}
 
 	initLogs.LogIPBanInfo("Запуск IP Ban сервиса...")
 
@@ -44,14 +50,36 @@ func Run() {
 	cfg := env.MustLoad()
 
 	configManager := panel.NewConfigManager(
-		cfg.PanelURL,
+		panel.JoinBasePath(cfg.PanelURL, cfg.PanelBasePath),
 		cfg.PanelUser,
 		cfg.PanelPass,
 		cfg.InboundID,
 	)
 
-	// Авторизация в панели для получения сессионной куки
-	if err := auth.Login(configManager); err != nil {
+	configManager.TwoFactorSecret = cfg.PanelTOTPSecret
+	configManager.LoginSecret = cfg.PanelLoginSecret
+	configManager.SessionPath = cfg.PanelSessionPath
+	// Снимки inbound перед изменениями: откат прерванных операций и ручное восстановление
+	configManager.SnapshotDir = ipban.SNAPSHOT_DIR
+	configManager.SnapshotKeep = ipban.SNAPSHOT_KEEP
+	if err := configManager.ConfigureTLS(panel.TLSOptions{
+		CAFile:             cfg.PanelCAFile,
+		PinnedSHA256:       cfg.PanelCertSHA256,
+		InsecureSkipVerify: cfg.PanelInsecureSkipVerify,
+	}); err != nil {
+		initLogs.LogIPBanError("Ошибка настройки TLS панели: %v", err)
+		return
+	}
+	if cfg.PanelInsecureSkipVerify && cfg.PanelCertSHA256 == "" {
+		initLogs.LogIPBanWarning("Проверка сертификата панели отключена (PANEL_INSECURE_SKIP_VERIFY)")
+	}
+
+	// Авторизация в панели для получения сессионной куки; при истечении сессии вход повторяется автоматически.
+	// Сохранённая сессия используется без входа: панель ограничивает частоту входов
+	configManager.Relogin = auth.Login
+	if auth.RestoreSession(configManager) {
+		initLogs.LogIPBanInfo("Сессия панели восстановлена из %s", configManager.SessionPath)
+	} else if err := auth.Login(configManager); err != nil {
 		initLogs.LogIPBanError("Ошибка авторизации в панели: %v", err)
 		return
 	}
@@ -61,28 +89,82 @@ func Run() {
 		initLogs.LogIPBanError("Ошибка автокоррекции VLESS настроек: %v", err)
 		// продолжаем работу, чтобы не блокировать сервис
 	} else {
-		initLogs.LogIPBanInfo("VLESS настройки проверены: decryption=none")
+		initLogs.LogIPBanInfo("Настройки inbound проверены (decryption=none для VLESS)")
+	}
+
+	// Открываем хранилище банов, выбранное в конфигурации (file/bolt/redis)
+	banStore, err := ipban.OpenBanStore()
+	if err != nil {
+		initLogs.LogIPBanError("Ошибка открытия хранилища банов: %v", err)
+		return
 	}
+	defer banStore.Close()
+	initLogs.LogIPBanInfo("Хранилище банов: %s", ipban.BAN_STORE_BACKEND)
 
-	banManager := ipban.NewBanManager("/var/log/ip_bans.json")
-	iptablesManager := ipban.NewIPTablesManager()
+	// Таблица идентичностей: баны привязаны к SubID/UUID, а не к изменяемому email
+	identities := ipban.NewIdentityMap(ipban.IDENTITY_MAP_PATH)
+	banManager := ipban.NewBanManager(banStore, identities)
+	// Область блокировки: порт inbound, все inbound или весь хост (FIREWALL_SCOPE)
+	scope, err := ipban.ResolveFirewallScope(configManager)
+	if err != nil {
+		// Без области не расширяем блокировку до всего хоста: оставляем уже настроенные правила входа
+		initLogs.LogIPBanWarning("Не удалось определить область блокировки (%s): %v", ipban.FIREWALL_SCOPE, err)
+		scope = nil
+	}
+	// Файрвол для блокировки IP: iptables (старые хосты), nftables или jail fail2ban, по конфигурации
+	firewall, err := ipban.NewFirewall(scope)
+	if err != nil {
+		if ipban.FirewallRequired() {
+			initLogs.LogIPBanError("Ошибка инициализации файрвола %s: %v", ipban.FIREWALL_BACKEND, err)
+			return
+		}
+		// Бан конфига выполняется через панель: без файрвола сервис работает, но не блокирует IP
+		initLogs.LogIPBanWarning("Файрвол %s не инициализирован: %v — продолжаем без блокировки IP", ipban.FIREWALL_BACKEND, err)
+		firewall = ipban.NewUnavailableFirewall(err)
+	}
+
+	// Ограничитель скорости нужен только режимам throttle и escalate
+	var throttler ipban.Throttler
+	if ipban.ENFORCEMENT_MODE == ipban.EnforcementThrottle || ipban.ENFORCEMENT_MODE == ipban.EnforcementEscalate {
+		throttler, err = ipban.NewThrottler(scope)
+		if err != nil {
+			initLogs.LogIPBanError("Ошибка инициализации троттлинга: %v", err)
+			return
+		}
+	}
 
 	// Создаем и запускаем сервис
 	service := ipban.NewIPBanService(
 		analyzer,
 		configManager,
 		banManager,
-		iptablesManager,
+		firewall,
 		ipban.MAX_IPS_PER_CONFIG,
 		time.Duration(ipban.IP_CHECK_INTERVAL)*time.Minute,
 		time.Duration(ipban.IP_BAN_GRACE_PERIOD)*time.Minute,
 	)
 
+	service.Throttler = throttler
+	service.Offenses = ipban.NewOffenseTracker(ipban.OFFENSE_TRACKER_PATH, time.Duration(ipban.OFFENSE_WINDOW)*time.Minute)
+	service.Traffic = ipban.NewTrafficTracker(time.Duration(ipban.TRAFFIC_WINDOW) * time.Minute)
+	service.Disables = ipban.NewDisableLedger(ipban.DISABLE_LEDGER_PATH)
+	if ipban.KILL_CONNECTIONS_ON_BAN {
+		service.Killer = ipban.NewConnectionKiller(scope)
+	}
+
 	if err := service.Start(); err != nil {
 		initLogs.LogIPBanError("Ошибка запуска IP Ban сервиса: %v", err)
 		return
 	}
 
+	// Управляющее API для CLI-команд (ban/unban/bans/users/firewall)
+	controlServer := control.NewServer(ipban.CONTROL_SOCKET_PATH, service)
+	if err := controlServer.Start(); err != nil {
+		initLogs.LogIPBanError("Ошибка запуска управляющего API: %v", err)
+		// продолжаем работу: CLI сможет править хранилище напрямую
+	}
+	defer controlServer.Stop()
+
 	// Ожидаем сигнала для завершения работы
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -0,0 +1,377 @@
+package flags
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	ipban "ipBanSystem/ipBan/BanService"
+	"ipBanSystem/ipBan/control"
+)
+
+// HandleCommand выполняет административную подкоманду (ban, unban, bans, users, firewall, snapshots).
+// Команда отправляется запущенному демону; если демон не запущен — правится хранилище банов напрямую.
+// Возвращает true, если аргументы были подкомандой и программу надо завершить.
+func HandleCommand(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+
+	var err error
+	switch args[0] {
+	case "ban":
+		err = runBan(args[1:])
+	case "unban":
+		err = runUnban(args[1:])
+	case "bans":
+		err = runBans(args[1:])
+	case "users":
+		err = runUsers(args[1:])
+	case "firewall":
+		err = runFirewall(args[1:])
+	case "snapshots":
+		err = runSnapshots(args[1:])
+	default:
+		return false
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		os.Exit(1)
+	}
+	return true
+}
+
+// runBan: ban <email> [--duration 2h] [--reason "..."]
+func runBan(args []string) error {
+	fs := flag.NewFlagSet("ban", flag.ContinueOnError)
+	duration := fs.Duration("duration", 0, "Длительность бана (например 90m, 2h); по умолчанию IP_BAN_DURATION")
+	reason := fs.String("reason", "", "Причина бана")
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return fmt.Errorf("использование: ban <email> [--duration 2h] [--reason \"...\"]")
+	}
+	email := positional[0]
+
+	ctl := control.NewClient(ipban.CONTROL_SOCKET_PATH)
+	ban, err := ctl.Ban(email, *duration, *reason)
+	if errors.Is(err, control.ErrDaemonUnavailable) {
+		fmt.Println("ℹ️  Демон не запущен — бан записывается в хранилище напрямую")
+		ban, err = offlineBan(email, *duration, *reason)
+		if err == nil {
+			fmt.Println("ℹ️  Конфиг будет отключен в панели на первом цикле проверки после запуска демона")
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("🚫 Пользователь %s забанен\n", ban.Email)
+	printBan(ban)
+	return nil
+}
+
+// runUnban: unban <email>
+func runUnban(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("использование: unban <email>")
+	}
+	email := args[0]
+
+	ctl := control.NewClient(ipban.CONTROL_SOCKET_PATH)
+	result, err := ctl.Unban(email)
+	if errors.Is(err, control.ErrDaemonUnavailable) {
+		fmt.Println("ℹ️  Демон не запущен — бан удаляется из хранилища напрямую")
+		if err := offlineUnban(email); err != nil {
+			return err
+		}
+		fmt.Printf("✅ Бан пользователя %s удалён\n", email)
+		fmt.Println("ℹ️  Конфиг будет включен в панели на первом цикле проверки после запуска демона")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if !result.WasBanned {
+		fmt.Printf("ℹ️  Пользователь %s не был забанен\n", email)
+	}
+	fmt.Printf("✅ Пользователь %s разбанен (разблокировано IP: %d, конфиг включен: %v)\n",
+		email, result.UnblockedIPs, result.Enabled)
+	return nil
+}
+
+// runBans: bans list | bans show <email>
+func runBans(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("использование: bans list | bans show <email>")
+	}
+	ctl := control.NewClient(ipban.CONTROL_SOCKET_PATH)
+
+	switch args[0] {
+	case "list":
+		bans, err := ctl.ListBans()
+		if errors.Is(err, control.ErrDaemonUnavailable) {
+			bans, err = offlineListBans()
+		}
+		if err != nil {
+			return err
+		}
+		if len(bans) == 0 {
+			fmt.Println("📝 Активных банов нет")
+			return nil
+		}
+		keys := make([]string, 0, len(bans))
+		for k := range bans {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		fmt.Printf("🚫 Активных банов: %d\n", len(bans))
+		for _, k := range keys {
+			ban := bans[k]
+			fmt.Printf("  %s [%s] — до %s, IP: %d, причина: %s\n",
+				ban.Email, k, ban.ExpiresAt.Format("15:04:05 02.01.2006"), len(ban.IPAddresses), ban.Reason)
+		}
+		return nil
+	case "show":
+		if len(args) != 2 {
+			return fmt.Errorf("использование: bans show <email>")
+		}
+		ban, err := ctl.ShowBan(args[1])
+		if errors.Is(err, control.ErrDaemonUnavailable) {
+			ban, err = offlineShowBan(args[1])
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Printf("🚫 Бан пользователя %s\n", ban.Email)
+		printBan(ban)
+		return nil
+	default:
+		return fmt.Errorf("неизвестная команда bans %s (ожидается list или show)", args[0])
+	}
+}
+
+// usersUsage — справка по командам users
+const usersUsage = `использование:
+  users show <email>
+  users link <email> [--host example.com] [--sub]
+  users extend <email>... (--days 30 | --duration 36h)   (отрицательное значение сокращает срок)
+  users limit <email>... --gb 50                          (0 — без лимита)
+  users rename <email> <новый email> [<email> <новый email>...]
+  users delete <email>... --yes`
+
+// runUsers: users show|link|extend|limit|rename|delete
+func runUsers(args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "link":
+			return runUserLink(args[1:])
+		case "extend":
+			return runUserExtend(args[1:])
+		case "limit":
+			return runUserLimit(args[1:])
+		case "rename":
+			return runUserRename(args[1:])
+		case "delete":
+			return runUserDelete(args[1:])
+		}
+	}
+	if len(args) != 2 || args[0] != "show" {
+		return errors.New(usersUsage)
+	}
+	email := args[1]
+
+	ctl := control.NewClient(ipban.CONTROL_SOCKET_PATH)
+	report, err := ctl.ShowUser(email)
+	if errors.Is(err, control.ErrDaemonUnavailable) {
+		fmt.Println("ℹ️  Демон не запущен — доступны только данные хранилища банов")
+		ban, err := offlineShowBan(email)
+		if err != nil {
+			return err
+		}
+		if ban == nil {
+			fmt.Printf("✅ Пользователь %s не забанен\n", email)
+			return nil
+		}
+		fmt.Printf("🚫 Пользователь %s забанен\n", email)
+		printBan(ban)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("👤 Пользователь %s\n", report.Email)
+	if report.Key != "" {
+		fmt.Printf("  Ключ идентичности: %s\n", report.Key)
+	}
+	if report.Client != nil {
+		c := report.Client
+		fmt.Printf("  Включен в панели: %v\n", c.Enable)
+		fmt.Printf("  ID: %s, SubID: %s\n", c.ID, c.SubID)
+		if c.ExpiryTime > 0 {
+			fmt.Printf("  Истекает: %s\n", time.UnixMilli(c.ExpiryTime).Format("15:04:05 02.01.2006"))
+		}
+		if d := report.ServiceDisable; d != nil && !c.Enable {
+			fmt.Printf("  Отключен сервисом %s: %s\n", d.DisabledAt.Format("15:04:05 02.01.2006"), d.Reason)
+		}
+	} else {
+		fmt.Printf("  Панель: %s\n", report.PanelError)
+	}
+	if t := report.Traffic; t != nil {
+		limit := "без лимита"
+		if t.Total > 0 {
+			limit = fmt.Sprintf("из %.2f ГБ", float64(t.Total)/(1<<30))
+		}
+		fmt.Printf("  Трафик: %.2f ГБ %s (↑ %.2f ГБ, ↓ %.2f ГБ)\n",
+			float64(t.Used())/(1<<30), limit, float64(t.Up)/(1<<30), float64(t.Down)/(1<<30))
+	}
+	if u := report.Usage; u != nil {
+		fmt.Printf("  Расход: %.2f ГБ за последний цикл (%v), %.2f ГБ за %v\n",
+			float64(u.Delta)/(1<<30), u.Interval.Round(time.Second), float64(u.Window)/(1<<30), u.Span.Round(time.Minute))
+	}
+	fmt.Printf("  IP адресов: %d (лимит: %d)\n", len(report.IPs), report.MaxIPs)
+	for _, ip := range report.IPs {
+		fmt.Printf("    📍 %s\n", ip)
+	}
+	if report.Ban != nil {
+		fmt.Println("  🚫 Забанен:")
+		printBan(report.Ban)
+	} else {
+		fmt.Println("  ✅ Не забанен")
+	}
+	return nil
+}
+
+// runUserLink: users link <email> [--host example.com] [--sub] — ссылки подключения клиента (нужен демон)
+func runUserLink(args []string) error {
+	fs := flag.NewFlagSet("users link", flag.ContinueOnError)
+	host := fs.String("host", "", "Адрес сервера в ссылках; по умолчанию SHARE_LINK_HOST или адрес панели")
+	sub := fs.Bool("sub", false, "Вывести содержимое подписки (base64) вместо ссылок")
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return fmt.Errorf("использование: users link <email> [--host example.com] [--sub]")
+	}
+
+	result, err := control.NewClient(ipban.CONTROL_SOCKET_PATH).UserLinks(positional[0], *host)
+	if errors.Is(err, control.ErrDaemonUnavailable) {
+		return fmt.Errorf("демон не запущен: ссылки строятся по настройкам панели, доступным только демону")
+	}
+	if err != nil {
+		return err
+	}
+	if *sub {
+		fmt.Println(result.Subscription)
+		return nil
+	}
+	for _, link := range result.Links {
+		fmt.Println(link)
+	}
+	return nil
+}
+
+// runFirewall: firewall sync [--dry-run]
+func runFirewall(args []string) error {
+	if len(args) == 0 || args[0] != "sync" {
+		return fmt.Errorf("использование: firewall sync [--dry-run]")
+	}
+	fs := flag.NewFlagSet("firewall sync", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "Только показать расхождения, ничего не меняя")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	ctl := control.NewClient(ipban.CONTROL_SOCKET_PATH)
+	drift, err := ctl.SyncFirewall(*dryRun)
+	if errors.Is(err, control.ErrDaemonUnavailable) {
+		fmt.Println("ℹ️  Демон не запущен — сверка выполняется напрямую")
+		drift, err = offlineSyncFirewall(!*dryRun)
+	}
+	if drift != nil {
+		printDrift(drift)
+	}
+	return err
+}
+
+// printDrift выводит отчёт о расхождениях файрвола
+func printDrift(drift *ipban.FirewallDrift) {
+	fmt.Printf("🧱 Файрвол: %s\n", drift.Backend)
+	fmt.Printf("  Под управлением (активный бан): %d\n", len(drift.Adopted))
+	printIPGroup("Без активного бана", drift.Stale)
+	printIPGroup("Потеряны (бан активен)", drift.Missing)
+	printIPGroup("Дубли правил", drift.Duplicates)
+	printIPGroup("Правила старых версий в INPUT", drift.Legacy)
+	switch {
+	case !drift.HasDrift():
+		fmt.Println("✅ Расхождений нет")
+	case drift.Applied:
+		fmt.Println("✅ Расхождения исправлены")
+	default:
+		fmt.Println("ℹ️  Режим --dry-run: ничего не изменено")
+	}
+	// Чужие правила — не расхождение: сервис их не ставил и не удаляет, они выводятся для сведения
+	if len(drift.Foreign) > 0 {
+		fmt.Printf("ℹ️  Чужие правила DROP в INPUT (поставлены не сервисом, не трогаются): %d\n", len(drift.Foreign))
+		for _, ip := range drift.Foreign {
+			fmt.Printf("    📍 %s\n", ip)
+		}
+	}
+}
+
+// printIPGroup выводит группу IP с заголовком (пустые группы пропускаются)
+func printIPGroup(title string, ips []string) {
+	if len(ips) == 0 {
+		return
+	}
+	fmt.Printf("  %s: %d\n", title, len(ips))
+	for _, ip := range ips {
+		fmt.Printf("    📍 %s\n", ip)
+	}
+}
+
+// printBan выводит подробности бана
+func printBan(ban *ipban.BanInfo) {
+	fmt.Printf("    Забанен: %s\n", ban.BannedAt.Format("15:04:05 02.01.2006"))
+	fmt.Printf("    До: %s\n", ban.ExpiresAt.Format("15:04:05 02.01.2006"))
+	fmt.Printf("    Причина: %s\n", ban.Reason)
+	if len(ban.IPAddresses) > 0 {
+		fmt.Printf("    IP: %s\n", strings.Join(ban.IPAddresses, ", "))
+	}
+	if len(ban.ThrottledIPs) > 0 {
+		fmt.Printf("    Ограничена скорость: %s\n", strings.Join(ban.ThrottledIPs, ", "))
+	}
+	if len(ban.BlockedIPs) > 0 {
+		fmt.Printf("    Заблокированы на файрволе: %s\n", strings.Join(ban.BlockedIPs, ", "))
+	}
+	if ban.KilledConnections > 0 {
+		fmt.Printf("    Разорвано соединений: %d\n", ban.KilledConnections)
+	}
+}
+
+// parseInterspersed разбирает флаги, стоящие и до, и после позиционных аргументов
+// (стандартный flag останавливается на первом позиционном аргументе)
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
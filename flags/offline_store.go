@@ -0,0 +1,104 @@
+package flags
+
+import (
+	"fmt"
+	"time"
+
+	ipban "ipBanSystem/ipBan/BanService"
+	"ipBanSystem/ipBan/logger/initLogs"
+)
+
+// withOfflineBanManager открывает хранилище банов напрямую (когда демон не запущен) и закрывает его после действия
+func withOfflineBanManager(action func(bm *ipban.BanManager) error) error {
+	// Офлайн-изменения пишутся в те же логи, что и действия демона
+	if err := initLogs.InitIPBanLogger(ipban.IP_BAN_LOG_PATH); err != nil {
+		fmt.Printf("⚠️  Не удалось открыть лог %s: %v\n", ipban.IP_BAN_LOG_PATH, err)
+	}
+	if err := initLogs.InitBannedUsersLogger(ipban.BANNED_USERS_LOG_PATH); err != nil {
+		fmt.Printf("⚠️  Не удалось открыть лог %s: %v\n", ipban.BANNED_USERS_LOG_PATH, err)
+	}
+
+	store, err := ipban.OpenBanStore()
+	if err != nil {
+		return fmt.Errorf("ошибка открытия хранилища банов: %v", err)
+	}
+	defer store.Close()
+	// Таблица идентичностей читается из файла, который ведёт демон: email сводится к тому же ключу
+	return action(ipban.NewBanManager(store, ipban.NewIdentityMap(ipban.IDENTITY_MAP_PATH)))
+}
+
+// offlineBan записывает бан в хранилище без участия демона
+func offlineBan(email string, duration time.Duration, reason string) (*ipban.BanInfo, error) {
+	if duration <= 0 {
+		duration = time.Duration(ipban.IP_BAN_DURATION) * time.Minute
+	}
+	if reason == "" {
+		reason = "Ручной бан администратором"
+	}
+
+	var ban *ipban.BanInfo
+	err := withOfflineBanManager(func(bm *ipban.BanManager) error {
+		var err error
+		ban, err = bm.BanUserFor(email, reason, nil, duration)
+		return err
+	})
+	return ban, err
+}
+
+// offlineUnban удаляет бан из хранилища без участия демона
+func offlineUnban(email string) error {
+	return withOfflineBanManager(func(bm *ipban.BanManager) error {
+		if bm.GetBanInfo(email) == nil {
+			return fmt.Errorf("пользователь %s не забанен", email)
+		}
+		return bm.UnbanUser(email)
+	})
+}
+
+// offlineListBans читает активные баны из хранилища
+func offlineListBans() (map[string]*ipban.BanInfo, error) {
+	var bans map[string]*ipban.BanInfo
+	err := withOfflineBanManager(func(bm *ipban.BanManager) error {
+		bans = bm.GetActiveBans()
+		return nil
+	})
+	return bans, err
+}
+
+// offlineShowBan читает активный бан пользователя из хранилища (nil — не забанен).
+// Хранилище читается напрямую: ошибка чтения возвращается, а не выдаётся за отсутствие бана.
+func offlineShowBan(email string) (*ipban.BanInfo, error) {
+	var ban *ipban.BanInfo
+	err := withOfflineBanManager(func(bm *ipban.BanManager) error {
+		key := bm.KeyFor(email)
+		stored, err := bm.Store.Get(key)
+		if err != nil {
+			return fmt.Errorf("ошибка чтения бана %s из хранилища: %v", email, err)
+		}
+		if stored == nil || time.Now().After(stored.ExpiresAt) {
+			return nil
+		}
+		// Показываем актуальный email, даже если клиента переименовали после бана
+		if current := bm.Identities.CurrentEmail(key); current != "" {
+			stored.Email = current
+		}
+		ban = stored
+		return nil
+	})
+	return ban, err
+}
+
+// offlineSyncFirewall сверяет файрвол хоста с хранилищем банов без участия демона
+func offlineSyncFirewall(apply bool) (*ipban.FirewallDrift, error) {
+	var drift *ipban.FirewallDrift
+	err := withOfflineBanManager(func(bm *ipban.BanManager) error {
+		// Панель офлайн не опрашивается: область блокировки остаётся той, что настроил демон
+		fw, err := ipban.NewFirewall(nil)
+		if err != nil {
+			return fmt.Errorf("ошибка инициализации файрвола %s: %v", ipban.FIREWALL_BACKEND, err)
+		}
+		drift, err = ipban.ReconcileFirewall(fw, bm, apply)
+		return err
+	})
+	return drift, err
+}
@@ -0,0 +1,118 @@
+package flags
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	ipban "ipBanSystem/ipBan/BanService"
+	"ipBanSystem/ipBan/control"
+	"ipBanSystem/ipBan/panel/snapshot"
+)
+
+// snapshotsUsage — справка по командам snapshots
+const snapshotsUsage = `использование:
+  snapshots list
+  snapshots diff <id> [<id2>]     (без id2 — сравнение с текущим inbound)
+  snapshots restore <id> --yes`
+
+// runSnapshots: snapshots list | diff <id> [<id2>] | restore <id> --yes
+func runSnapshots(args []string) error {
+	if len(args) == 0 {
+		return errors.New(snapshotsUsage)
+	}
+	ctl := control.NewClient(ipban.CONTROL_SOCKET_PATH)
+
+	switch args[0] {
+	case "list":
+		list, err := ctl.ListSnapshots()
+		if errors.Is(err, control.ErrDaemonUnavailable) {
+			// Снимки — локальные файлы: список читается и без демона
+			list, err = snapshot.List(ipban.SNAPSHOT_DIR)
+		}
+		if err != nil {
+			return err
+		}
+		if len(list) == 0 {
+			fmt.Println("📝 Снимков inbound нет")
+			return nil
+		}
+		fmt.Printf("📸 Снимков inbound: %d\n", len(list))
+		for _, s := range list {
+			clients := ""
+			if len(s.Clients) > 0 {
+				clients = " (клиенты: " + strings.Join(s.Clients, ", ") + ")"
+			}
+			fmt.Printf("  %s — %s, inbound %d: %s%s\n", s.ID, s.TakenAt.Format("15:04:05 02.01.2006"), s.InboundID, s.Operation, clients)
+		}
+		return nil
+	case "diff":
+		if len(args) < 2 || len(args) > 3 {
+			return errors.New(snapshotsUsage)
+		}
+		with := ""
+		if len(args) == 3 {
+			with = args[2]
+		}
+		lines, err := ctl.DiffSnapshot(args[1], with)
+		if errors.Is(err, control.ErrDaemonUnavailable) {
+			if with == "" {
+				return fmt.Errorf("демон не запущен: сравнение с текущим inbound требует панели, укажите второй снимок")
+			}
+			lines, err = offlineDiffSnapshots(args[1], with)
+		}
+		if err != nil {
+			return err
+		}
+		if len(lines) == 0 {
+			fmt.Println("✅ Отличий нет")
+			return nil
+		}
+		for _, line := range lines {
+			fmt.Println(line)
+		}
+		return nil
+	case "restore":
+		fs := flag.NewFlagSet("snapshots restore", flag.ContinueOnError)
+		yes := fs.Bool("yes", false, "Подтвердить восстановление")
+		positional, err := parseInterspersed(fs, args[1:])
+		if err != nil {
+			return err
+		}
+		if len(positional) != 1 {
+			return errors.New(snapshotsUsage)
+		}
+		if !*yes {
+			return fmt.Errorf("восстановление заменит настройки inbound: проверьте изменения командой \"snapshots diff %s\" и повторите с --yes", positional[0])
+		}
+		snap, err := ctl.RestoreSnapshot(positional[0])
+		if errors.Is(err, control.ErrDaemonUnavailable) {
+			return fmt.Errorf("демон не запущен: inbound восстанавливается через сессию панели демона")
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Printf("✅ Inbound восстановлен из снимка %s (%s, %s)\n", snap.ID, snap.TakenAt.Format("15:04:05 02.01.2006"), snap.Operation)
+		fmt.Println("ℹ️  Состояние до восстановления сохранено новым снимком")
+		return nil
+	default:
+		return fmt.Errorf("неизвестная команда snapshots %s (ожидается list, diff или restore)", args[0])
+	}
+}
+
+// offlineDiffSnapshots сравнивает два снимка из каталога снимков без участия демона
+func offlineDiffSnapshots(id, with string) ([]string, error) {
+	from, err := snapshot.Load(ipban.SNAPSHOT_DIR, id)
+	if err != nil {
+		return nil, err
+	}
+	to, err := snapshot.Load(ipban.SNAPSHOT_DIR, with)
+	if err != nil {
+		return nil, err
+	}
+	if from.Inbound == nil || to.Inbound == nil {
+		return nil, fmt.Errorf("снимок без состояния inbound")
+	}
+	return snapshot.Diff(from.Inbound, to.Inbound), nil
+}
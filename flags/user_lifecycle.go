@@ -0,0 +1,112 @@
+package flags
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"time"
+
+	ipban "ipBanSystem/ipBan/BanService"
+	"ipBanSystem/ipBan/control"
+	"ipBanSystem/ipBan/panel/client/lifecycle"
+)
+
+// errLifecycleOffline — управление клиентами идёт через сессию панели, которую держит демон
+var errLifecycleOffline = errors.New("демон не запущен: клиенты панели меняются только через демон")
+
+// runUserExtend: users extend <email>... (--days 30 | --duration 36h)
+func runUserExtend(args []string) error {
+	fs := flag.NewFlagSet("users extend", flag.ContinueOnError)
+	days := fs.Int("days", 0, "На сколько дней сдвинуть срок (отрицательное — сократить)")
+	duration := fs.Duration("duration", 0, "Сдвиг срока длительностью (например 36h, -12h)")
+	emails, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	d := time.Duration(*days)*24*time.Hour + *duration
+	if len(emails) == 0 || d == 0 {
+		return fmt.Errorf("использование: users extend <email>... (--days 30 | --duration 36h)")
+	}
+
+	results, err := control.NewClient(ipban.CONTROL_SOCKET_PATH).ExtendUsers(emails, d)
+	return printLifecycle(fmt.Sprintf("срок сдвинут на %v", d), results, err)
+}
+
+// runUserLimit: users limit <email>... --gb 50
+func runUserLimit(args []string) error {
+	fs := flag.NewFlagSet("users limit", flag.ContinueOnError)
+	gb := fs.Float64("gb", -1, "Лимит трафика в ГБ (0 — без лимита)")
+	emails, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(emails) == 0 || *gb < 0 {
+		return fmt.Errorf("использование: users limit <email>... --gb 50 (0 — без лимита)")
+	}
+
+	limit := int64(math.Round(*gb * (1 << 30)))
+	done := fmt.Sprintf("лимит %.2f ГБ", *gb)
+	if limit == 0 {
+		done = "лимит снят"
+	}
+	results, err := control.NewClient(ipban.CONTROL_SOCKET_PATH).LimitUsers(emails, limit)
+	return printLifecycle(done, results, err)
+}
+
+// runUserRename: users rename <email> <новый email> [<email> <новый email>...]
+func runUserRename(args []string) error {
+	if len(args) == 0 || len(args)%2 != 0 {
+		return fmt.Errorf("использование: users rename <email> <новый email> [<email> <новый email>...]")
+	}
+	renames := make([]lifecycle.Renaming, 0, len(args)/2)
+	for i := 0; i < len(args); i += 2 {
+		renames = append(renames, lifecycle.Renaming{From: args[i], To: args[i+1]})
+	}
+
+	results, err := control.NewClient(ipban.CONTROL_SOCKET_PATH).RenameUsers(renames)
+	return printLifecycle("переименован (SubID сохранён)", results, err)
+}
+
+// runUserDelete: users delete <email>... --yes
+func runUserDelete(args []string) error {
+	fs := flag.NewFlagSet("users delete", flag.ContinueOnError)
+	yes := fs.Bool("yes", false, "Подтвердить удаление")
+	emails, err := parseInterspersed(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(emails) == 0 {
+		return fmt.Errorf("использование: users delete <email>... --yes")
+	}
+	if !*yes {
+		return fmt.Errorf("удаление клиентов необратимо: повторите команду с --yes")
+	}
+
+	results, err := control.NewClient(ipban.CONTROL_SOCKET_PATH).DeleteUsers(emails)
+	return printLifecycle("удалён", results, err)
+}
+
+// printLifecycle выводит итог операции по каждому клиенту; ошибка — если хотя бы один клиент не изменён из-за сбоя
+func printLifecycle(done string, results []lifecycle.Result, err error) error {
+	if errors.Is(err, control.ErrDaemonUnavailable) {
+		return errLifecycleOffline
+	}
+	if err != nil {
+		return err
+	}
+	for _, r := range results {
+		switch {
+		case r.Error != "":
+			fmt.Printf("❌ %s: %s\n", r.Email, r.Error)
+		case r.Changed:
+			fmt.Printf("✅ %s: %s\n", r.Email, done)
+		default:
+			fmt.Printf("ℹ️  %s: без изменений\n", r.Email)
+		}
+	}
+	if failed := lifecycle.Failed(results); failed > 0 {
+		return fmt.Errorf("не выполнено для %d из %d клиентов", failed, len(results))
+	}
+	return nil
+}
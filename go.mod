@@ -4,4 +4,9 @@ go 1.22
 
 require github.com/google/uuid v1.6.0
 
-require github.com/joho/godotenv v1.5.1
+require (
+	github.com/joho/godotenv v1.5.1
+	go.etcd.io/bbolt v1.3.11
+)
+
+require golang.org/x/sys v0.4.0 // indirect
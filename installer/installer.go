@@ -4,8 +4,9 @@ import (
 	"fmt"
 	"log"
 	"os"
-	"os/exec"
 	"strings"
+
+	"ipBanSystem/ipBan/runner"
 )
 
 const (
@@ -15,24 +16,59 @@ const (
 	binaryPath         = "/usr/local/bin/ipBanService"
 )
 
+// Installer выполняет шаги установки и удаления systemd-сервиса.
+// Команды (go build, systemctl) идут через Runner, пути — из полей, поэтому шаги можно проверить на фейке
+// во временной директории без root и systemd.
+type Installer struct {
+	Runner          runner.CommandRunner
+	ServiceFilePath string
+	BinaryPath      string
+	// WorkingDir — рабочая директория сервиса (пустая — текущая директория)
+	WorkingDir string
+}
+
+// New создаёт установщик со стандартными путями и настоящим запуском команд
+func New() *Installer {
+	return &Installer{
+		Runner:          runner.ExecRunner{},
+		ServiceFilePath: serviceFilePath,
+		BinaryPath:      binaryPath,
+	}
+}
+
 // InstallService устанавливает и запускает systemd-сервис ipBanService
 func InstallService() {
+	if err := New().Install(); err != nil {
+		log.Fatalf("%v", err)
+	}
+}
+
+// UninstallService останавливает и удаляет systemd-сервис ipBanService
+func UninstallService() {
+	New().Uninstall()
+}
+
+// Install собирает бинарный файл, создаёт файл сервиса, включает и запускает сервис.
+// Останавливается на первом неудачном шаге.
+func (in *Installer) Install() error {
 	fmt.Println("Установка сервиса ipBanService...")
 
 	// Шаг 1: Сборка бинарного файла
 	fmt.Println("Шаг 1: Сборка бинарного файла...")
-	cmd := exec.Command("go", "build", "-o", binaryPath, ".")
-	if err := runCommand(cmd); err != nil {
-		log.Fatalf("Ошибка сборки бинарного файла: %v", err)
+	if err := in.runCommand("go", "build", "-o", in.BinaryPath, "."); err != nil {
+		return fmt.Errorf("Ошибка сборки бинарного файла: %v", err)
 	}
-	fmt.Println("Бинарный файл успешно собран в", binaryPath)
+	fmt.Println("Бинарный файл успешно собран в", in.BinaryPath)
 
 	// Шаг 2: Создание файла сервиса systemd
 	fmt.Println("Шаг 2: Создание файла сервиса systemd...")
-	// Исправление: обработка ошибки, которая раньше игнорировалась (err был присвоен _)
-	workingDir, err := os.Getwd()
-	if err != nil {
-		log.Fatalf("Ошибка получения текущей директории: %v", err)
+	workingDir := in.WorkingDir
+	if workingDir == "" {
+		// Исправление: обработка ошибки, которая раньше игнорировалась (err был присвоен _)
+		var err error
+		if workingDir, err = os.Getwd(); err != nil {
+			return fmt.Errorf("Ошибка получения текущей директории: %v", err)
+		}
 	}
 	serviceFileContent := fmt.Sprintf(`[Unit]
 Description=%s
@@ -49,41 +85,43 @@ RestartSec=5s
 [Install]
 WantedBy=multi-user.target
 `,
-		serviceDescription, binaryPath, workingDir)
+		serviceDescription, in.BinaryPath, workingDir)
 
-	if err := os.WriteFile(serviceFilePath, []byte(serviceFileContent), 0644); err != nil {
-		log.Fatalf("Ошибка создания файла сервиса: %v", err)
+	if err := os.WriteFile(in.ServiceFilePath, []byte(serviceFileContent), 0644); err != nil {
+		return fmt.Errorf("Ошибка создания файла сервиса: %v", err)
 	}
-	fmt.Println("Файл сервиса успешно создан в", serviceFilePath)
+	fmt.Println("Файл сервиса успешно создан в", in.ServiceFilePath)
 
 	// Шаг 3: Перезагрузка, включение и запуск сервиса
 	fmt.Println("Шаг 3: Перезагрузка, включение и запуск сервиса...")
-	if err := runCommand(exec.Command("systemctl", "daemon-reload")); err != nil {
-		log.Fatalf("Ошибка перезагрузки демона systemd: %v", err)
+	if err := in.runCommand("systemctl", "daemon-reload"); err != nil {
+		return fmt.Errorf("Ошибка перезагрузки демона systemd: %v", err)
 	}
-	if err := runCommand(exec.Command("systemctl", "enable", serviceName)); err != nil {
-		log.Fatalf("Ошибка включения сервиса: %v", err)
+	if err := in.runCommand("systemctl", "enable", serviceName); err != nil {
+		return fmt.Errorf("Ошибка включения сервиса: %v", err)
 	}
-	if err := runCommand(exec.Command("systemctl", "start", serviceName)); err != nil {
-		log.Fatalf("Ошибка запуска сервиса: %v", err)
+	if err := in.runCommand("systemctl", "start", serviceName); err != nil {
+		return fmt.Errorf("Ошибка запуска сервиса: %v", err)
 	}
 
 	fmt.Println("\n✅ Сервис ipBanService успешно установлен и запущен!")
 	fmt.Println("Для проверки статуса используйте: systemctl status", serviceName)
+	return nil
 }
 
-// UninstallService останавливает и удаляет systemd-сервис ipBanService
-func UninstallService() {
+// Uninstall останавливает сервис и удаляет его файлы.
+// Шаги выполняются до конца даже при ошибках (сервис может быть удалён частично); ошибки только логируются.
+func (in *Installer) Uninstall() {
 	fmt.Println("Удаление сервиса ipBanService...")
 
 	// Шаг 1: Остановка и отключение сервиса
 	fmt.Println("Шаг 1: Остановка и отключение сервиса...")
-	runCommand(exec.Command("systemctl", "stop", serviceName))    // Игнорируем ошибку, если сервис не запущен
-	runCommand(exec.Command("systemctl", "disable", serviceName)) // Игнорируем ошибку, если сервис не включен
+	in.runCommand("systemctl", "stop", serviceName)    // Игнорируем ошибку, если сервис не запущен
+	in.runCommand("systemctl", "disable", serviceName) // Игнорируем ошибку, если сервис не включен
 
 	// Шаг 2: Удаление файла сервиса
 	fmt.Println("Шаг 2: Удаление файла сервиса...")
-	if err := os.Remove(serviceFilePath); err != nil {
+	if err := os.Remove(in.ServiceFilePath); err != nil {
 		log.Printf("Ошибка удаления файла сервиса (возможно, он уже удален): %v", err)
 	} else {
 		fmt.Println("Файл сервиса удален.")
@@ -91,13 +129,13 @@ func UninstallService() {
 
 	// Шаг 3: Перезагрузка демона systemd
 	fmt.Println("Шаг 3: Перезагрузка демона systemd...")
-	if err := runCommand(exec.Command("systemctl", "daemon-reload")); err != nil {
+	if err := in.runCommand("systemctl", "daemon-reload"); err != nil {
 		log.Printf("Ошибка перезагрузки демона systemd: %v", err)
 	}
 
 	// Шаг 4: Удаление бинарного файла
 	fmt.Println("Шаг 4: Удаление бинарного файла...")
-	if err := os.Remove(binaryPath); err != nil {
+	if err := os.Remove(in.BinaryPath); err != nil {
 		log.Printf("Ошибка удаления бинарного файла (возможно, он уже удален): %v", err)
 	} else {
 		fmt.Println("Бинарный файл удален.")
@@ -107,12 +145,10 @@ func UninstallService() {
 }
 
 // runCommand запускает команду и возвращает ошибку с выводом при неуспехе
-func runCommand(cmd *exec.Cmd) error {
-	var out strings.Builder
-	cmd.Stdout = &out
-	cmd.Stderr = &out
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("команда '%s' завершилась с ошибкой: %v\nВывод: %s", cmd.String(), err, out.String())
+func (in *Installer) runCommand(name string, args ...string) error {
+	out, err := runner.Combined(in.Runner, name, args...)
+	if err != nil {
+		return fmt.Errorf("команда '%s' завершилась с ошибкой: %v\nВывод: %s", runner.CommandLine(name, args...), err, strings.TrimSpace(string(out)))
 	}
 	return nil
 }
@@ -0,0 +1,138 @@
+// Проверки порядка шагов установщика на фейковом исполнителе команд.
+// Файлы сервиса и бинарника создаются во временной директории, systemd и go build не вызываются.
+package installer_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"ipBanSystem/installer"
+	"ipBanSystem/ipBan/runner"
+)
+
+// TestInstaller прогоняет набор проверок установки и удаления сервиса
+func TestInstaller(t *testing.T) {
+	t.Run("InstallStepOrder", testInstallStepOrder)
+	t.Run("BuildFailureStops", testBuildFailureStops)
+	t.Run("EnableFailureStops", testEnableFailureStops)
+	t.Run("UninstallStepOrder", testUninstallStepOrder)
+	t.Run("UninstallContinuesOnErrors", testUninstallContinuesOnErrors)
+}
+
+// newInstaller создаёт установщик с путями во временной директории
+func newInstaller(t *testing.T, r runner.CommandRunner) *installer.Installer {
+	dir := t.TempDir()
+	return &installer.Installer{
+		Runner:          r,
+		ServiceFilePath: filepath.Join(dir, "ipBanService.service"),
+		BinaryPath:      filepath.Join(dir, "ipBanService"),
+		WorkingDir:      "/opt/ipBanSystem",
+	}
+}
+
+func testInstallStepOrder(t *testing.T) {
+	fake := runner.NewRecordingRunner()
+	in := newInstaller(t, fake)
+
+	if err := in.Install(); err != nil {
+		t.Fatalf("Install: %v", err)
+	}
+	want := []string{
+		"go build -o " + in.BinaryPath + " .",
+		"systemctl daemon-reload",
+		"systemctl enable ipBanService",
+		"systemctl start ipBanService",
+	}
+	if got := fake.Commands(); !reflect.DeepEqual(got, want) {
+		t.Errorf("команды:\n%v\nожидались:\n%v", got, want)
+	}
+
+	unit, err := os.ReadFile(in.ServiceFilePath)
+	if err != nil {
+		t.Fatalf("файл сервиса не создан: %v", err)
+	}
+	for _, line := range []string{"ExecStart=" + in.BinaryPath, "WorkingDirectory=/opt/ipBanSystem", "Restart=on-failure"} {
+		if !strings.Contains(string(unit), line) {
+			t.Errorf("в файле сервиса нет строки %q", line)
+		}
+	}
+}
+
+func testBuildFailureStops(t *testing.T) {
+	fake := runner.NewScriptedRunner()
+	fake.Fail("go build", 1, "main.go:1:1: expected 'package', found 'EOF'")
+	in := newInstaller(t, fake)
+
+	err := in.Install()
+	if err == nil {
+		t.Fatal("ожидалась ошибка сборки")
+	}
+	if !strings.Contains(err.Error(), "expected 'package'") {
+		t.Errorf("в ошибке нет вывода go build: %v", err)
+	}
+	if len(fake.Calls()) != 1 {
+		t.Errorf("после неудачной сборки выполнены команды: %v", fake.Commands())
+	}
+	if _, err := os.Stat(in.ServiceFilePath); !os.IsNotExist(err) {
+		t.Error("файл сервиса создан после неудачной сборки")
+	}
+}
+
+func testEnableFailureStops(t *testing.T) {
+	fake := runner.NewScriptedRunner()
+	fake.Fail("systemctl enable", 1, "Failed to enable unit")
+	in := newInstaller(t, fake)
+
+	if err := in.Install(); err == nil {
+		t.Fatal("ожидалась ошибка включения сервиса")
+	}
+	if fake.Count("systemctl start") != 0 {
+		t.Errorf("сервис запущен после ошибки включения: %v", fake.Commands())
+	}
+}
+
+func testUninstallStepOrder(t *testing.T) {
+	fake := runner.NewRecordingRunner()
+	in := newInstaller(t, fake)
+	for _, path := range []string{in.ServiceFilePath, in.BinaryPath} {
+		if err := os.WriteFile(path, []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	in.Uninstall()
+	want := []string{
+		"systemctl stop ipBanService",
+		"systemctl disable ipBanService",
+		"systemctl daemon-reload",
+	}
+	if got := fake.Commands(); !reflect.DeepEqual(got, want) {
+		t.Errorf("команды:\n%v\nожидались:\n%v", got, want)
+	}
+	for _, path := range []string{in.ServiceFilePath, in.BinaryPath} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s не удалён", path)
+		}
+	}
+}
+
+func testUninstallContinuesOnErrors(t *testing.T) {
+	fake := runner.NewScriptedRunner()
+	fake.Default = runner.Response{ExitCode: 5, Stderr: "Unit ipBanService.service not loaded."}
+	in := newInstaller(t, fake)
+	if err := os.WriteFile(in.BinaryPath, []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Файла сервиса нет, systemctl завершается с ошибкой — удаление всё равно доходит до бинарника
+	in.Uninstall()
+	if len(fake.Calls()) != 3 {
+		t.Errorf("выполнены не все шаги: %v", fake.Commands())
+	}
+	if _, err := os.Stat(in.BinaryPath); !os.IsNotExist(err) {
+		t.Error("бинарный файл не удалён")
+	}
+}
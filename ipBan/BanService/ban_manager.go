@@ -1,72 +1,104 @@
 package ipban
 
 import (
-	"encoding/json"
 	"fmt"
 	"ipBanSystem/ipBan/logger/initLogs"
-	"os"
 	"sync"
 	"time"
 )
 
 // BanInfo содержит информацию о бане пользователя
 type BanInfo struct {
+	// Key — стабильный ключ идентичности (sub:<subId> / id:<uuid>), под которым бан хранится
+	Key string `json:"key,omitempty"`
+	// SubID — идентификатор подписки на момент бана
+	SubID       string    `json:"sub_id,omitempty"`
 	Email       string    `json:"email"`
 	BannedAt    time.Time `json:"banned_at"`
 	ExpiresAt   time.Time `json:"expires_at"`
 	Reason      string    `json:"reason"`
 	IPAddresses []string  `json:"ip_addresses"`
+	// Enforcement — способ применения бана: EnforcementAccount (отключение конфига) или EnforcementPerIP
+	Enforcement string `json:"enforcement,omitempty"`
+	// BlockedIPs — IP, заблокированные на файрволе по этому бану (снимаются вместе с баном)
+	BlockedIPs []string `json:"blocked_ips,omitempty"`
+	// ThrottledIPs — IP, для которых ограничена скорость (наказание EnforcementThrottle)
+	ThrottledIPs []string `json:"throttled_ips,omitempty"`
+	// KilledConnections — сколько установленных соединений разорвано при применении бана
+	KilledConnections int `json:"killed_connections,omitempty"`
 }
 
 // BanManager управляет банами пользователей
-// mutex добавлен для предотвращения гонок при одновременном доступе к карте Bans из разных горутин
+// mutex добавлен для предотвращения гонок при одновременных операциях "прочитать-изменить-записать" из разных горутин
+// Сами записи живут в BanStore (JSON-файл, bbolt или Redis), выбранном в конфигурации
+// Баны хранятся под стабильным ключом идентичности, а не под email:
+// переименованный клиент (или клиент с временным "-reset" email) остаётся забаненным.
 type BanManager struct {
-	BansFile string
-	Bans     map[string]*BanInfo
-	mutex    sync.RWMutex // Мьютекс для синхронизации доступа к карте Bans
+	Store      BanStore
+	Identities *IdentityMap
+	mutex      sync.RWMutex // Мьютекс для синхронизации составных операций над хранилищем
 }
 
-// NewBanManager создает новый менеджер банов
-func NewBanManager(bansFile string) *BanManager {
-	bm := &BanManager{
-		BansFile: bansFile,
-		Bans:     make(map[string]*BanInfo),
+// NewBanManager создает новый менеджер банов поверх выбранного хранилища и таблицы идентичностей
+func NewBanManager(store BanStore, identities *IdentityMap) *BanManager {
+	return &BanManager{
+		Store:      store,
+		Identities: identities,
 	}
-	bm.loadBans()
-	return bm
 }
 
-// loadBans загружает баны из файла
-func (bm *BanManager) loadBans() {
-	data, err := os.ReadFile(bm.BansFile)
-	if err != nil {
-		// Файл не существует или пуст - это нормально
-		return
+// KeyFor возвращает ключ хранилища для email (через таблицу идентичностей)
+func (bm *BanManager) KeyFor(email string) string {
+	if bm.Identities == nil {
+		return email
 	}
+	return bm.Identities.Resolve(email)
+}
 
-	if err := json.Unmarshal(data, &bm.Bans); err != nil {
-		fmt.Printf("BAN_MANAGER: Ошибка загрузки банов: %v\n", err)
-		bm.Bans = make(map[string]*BanInfo)
+// getBan читает бан из хранилища, ошибки хранилища логируются и трактуются как отсутствие бана
+func (bm *BanManager) getBan(key string) (*BanInfo, bool) {
+	ban, err := bm.Store.Get(key)
+	if err != nil {
+		initLogs.LogIPBanError("Ошибка чтения бана %s из хранилища: %v", key, err)
+		return nil, false
 	}
+	if ban == nil {
+		return nil, false
+	}
+	// Показываем актуальный email, даже если клиента переименовали после бана
+	if bm.Identities != nil {
+		if current := bm.Identities.CurrentEmail(key); current != "" {
+			ban.Email = current
+		}
+	}
+	return ban, true
 }
 
-// saveBans сохраняет баны в файл
-func (bm *BanManager) saveBans() error {
-	data, err := json.MarshalIndent(bm.Bans, "", "  ")
+// listBans читает все баны из хранилища, при ошибке возвращает пустую карту
+func (bm *BanManager) listBans() map[string]*BanInfo {
+	bans, err := bm.Store.List()
 	if err != nil {
-		return fmt.Errorf("ошибка сериализации банов: %v", err)
+		initLogs.LogIPBanError("Ошибка чтения банов из хранилища: %v", err)
+		return make(map[string]*BanInfo)
 	}
+	return bans
+}
 
-	return os.WriteFile(bm.BansFile, data, 0o644)
+// deleteBan удаляет бан из хранилища с логированием ошибки
+func (bm *BanManager) deleteBan(key string) {
+	if err := bm.Store.Delete(key); err != nil {
+		initLogs.LogIPBanError("Ошибка удаления бана %s из хранилища: %v", key, err)
+	}
 }
 
 // IsBanned проверяет, забанен ли пользователь
-// Использует RWMutex для защиты от гонок при одновременном доступе к карте Bans
+// Использует RWMutex для защиты от гонок при одновременном доступе к хранилищу банов
 func (bm *BanManager) IsBanned(email string) bool {
+	key := bm.KeyFor(email)
 	bm.mutex.RLock() // Блокировка на чтение
-	ban, exists := bm.Bans[email]
+	ban, exists := bm.getBan(key)
 	bm.mutex.RUnlock()
-	
+
 	if !exists {
 		return false
 	}
@@ -79,10 +111,9 @@ func (bm *BanManager) IsBanned(email string) bool {
 		initLogs.LogIPBanInfo("Пользователь %s автоматически разбанен при проверке (бан истек: %s)",
 			email, ban.ExpiresAt.Format("2006-01-02 15:04:05"))
 
-		// При удалении элемента из карты нужна блокировка на запись
+		// При удалении записи из хранилища нужна блокировка на запись
 		bm.mutex.Lock()
-		delete(bm.Bans, email)
-		bm.saveBans()
+		bm.deleteBan(key)
 		bm.mutex.Unlock()
 		return false
 	}
@@ -90,27 +121,39 @@ func (bm *BanManager) IsBanned(email string) bool {
 	return true
 }
 
-// BanUser банит пользователя
-// Использует mutex.Lock() для обеспечения атомарности операции добавления нового бана
+// BanUser банит пользователя на длительность из конфигурации (IP_BAN_DURATION)
 func (bm *BanManager) BanUser(email string, reason string, ipAddresses []string) error {
 	banDuration := time.Duration(IP_BAN_DURATION) * time.Minute
 	if IP_BAN_DURATION <= 0 {
 		banDuration = 0 // Бесконечный бан
 	}
+	_, err := bm.BanUserFor(email, reason, ipAddresses, banDuration)
+	return err
+}
 
+// BanUserFor банит пользователя на заданную длительность (используется и ручными командами CLI)
+// Использует mutex.Lock() для обеспечения атомарности операции добавления нового бана
+func (bm *BanManager) BanUserFor(email string, reason string, ipAddresses []string, banDuration time.Duration) (*BanInfo, error) {
+	key := bm.KeyFor(email)
 	now := time.Now()
 	ban := &BanInfo{
+		Key:         key,
 		Email:       email,
 		BannedAt:    now,
 		ExpiresAt:   now.Add(banDuration),
 		Reason:      reason,
 		IPAddresses: ipAddresses,
+		Enforcement: EnforcementAccount,
 	}
 
-	// Блокировка на запись при модификации карты Bans
+	// Блокировка на запись при модификации хранилища
 	bm.mutex.Lock()
-	bm.Bans[email] = ban
-	err := bm.saveBans()
+	if bm.Identities != nil {
+		if ident := bm.Identities.Get(key); ident != nil {
+			ban.SubID = ident.SubID
+		}
+	}
+	err := bm.Store.Put(key, ban)
 	bm.mutex.Unlock()
 
 	// Логируем в bot.log: банирование пользователя
@@ -127,18 +170,84 @@ func (bm *BanManager) BanUser(email string, reason string, ipAddresses []string)
 		initLogs.LogBannedUser(ban.Email, ban.IPAddresses, ban.Reason, ban.ExpiresAt)
 	}
 
-	return err
+	return ban, err
+}
+
+// BanIPs записывает наказание без отключения конфига: enforcement — EnforcementPerIP (affected блокируются
+// на файрволе и добавляются в BlockedIPs) или EnforcementThrottle (ограничиваются, ThrottledIPs).
+// Если наказание того же вида уже есть, его срок продлевается до now+banDuration.
+func (bm *BanManager) BanIPs(email, enforcement, reason string, ipAddresses, affected []string, banDuration time.Duration) (*BanInfo, error) {
+	key := bm.KeyFor(email)
+	now := time.Now()
+
+	bm.mutex.Lock()
+	ban, exists := bm.getBan(key)
+	if !exists || now.After(ban.ExpiresAt) || ban.Enforcement != enforcement {
+		ban = &BanInfo{
+			Key:         key,
+			Email:       email,
+			BannedAt:    now,
+			Enforcement: enforcement,
+		}
+		if bm.Identities != nil {
+			if ident := bm.Identities.Get(key); ident != nil {
+				ban.SubID = ident.SubID
+			}
+		}
+	}
+	ban.Reason = reason
+	ban.IPAddresses = ipAddresses
+	target := &ban.BlockedIPs
+	if enforcement == EnforcementThrottle {
+		target = &ban.ThrottledIPs
+	}
+	for _, ip := range affected {
+		if !containsString(*target, ip) {
+			*target = append(*target, ip)
+		}
+	}
+	if expiresAt := now.Add(banDuration); expiresAt.After(ban.ExpiresAt) {
+		ban.ExpiresAt = expiresAt
+	}
+	err := bm.Store.Put(key, ban)
+	bm.mutex.Unlock()
+
+	// Логируем в bot.log: блокировка или ограничение IP пользователя
+	action := "ЗАБЛОКИРОВАНЫ_ЛИШНИЕ_IP"
+	if enforcement == EnforcementThrottle {
+		action = "ОГРАНИЧЕНА_СКОРОСТЬ"
+	}
+	initLogs.LogIPBanAction(action, email, len(affected), affected)
+	initLogs.LogIPBanInfo("Причина: %s", reason)
+	initLogs.LogIPBanInfo("Действует до: %s", ban.ExpiresAt.Format("2006-01-02 15:04:05"))
+
+	if LOG_BANNED_USERS {
+		initLogs.LogBannedUser(ban.Email, affected, ban.Reason, ban.ExpiresAt)
+	}
+
+	return ban, err
+}
+
+// containsString проверяет, есть ли строка в срезе
+func containsString(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
 }
 
 // UnbanUser разбанивает пользователя
-// Использует синхронизацию для безопасного удаления элемента из общей карты
+// Использует синхронизацию для безопасного удаления записи из хранилища
 func (bm *BanManager) UnbanUser(email string) error {
 	// Получаем информацию о бане перед удалением
-	// Используем RLock при чтении из карты
+	// Используем RLock при чтении из хранилища
+	key := bm.KeyFor(email)
 	bm.mutex.RLock()
-	banInfo, exists := bm.Bans[email]
+	banInfo, exists := bm.getBan(key)
 	bm.mutex.RUnlock()
-	
+
 	if exists {
 		// Логируем в bot.log: разбанирование пользователя с деталями
 		initLogs.LogIPBanAction("РАЗБАНЕН", email, len(banInfo.IPAddresses), banInfo.IPAddresses)
@@ -151,20 +260,33 @@ func (bm *BanManager) UnbanUser(email string) error {
 		// Логируем в bot.log: попытка разбанить несуществующего пользователя
 	}
 
-	// Блокировка на запись при удалении из карты Bans
+	// Блокировка на запись при удалении из хранилища
 	bm.mutex.Lock()
-	delete(bm.Bans, email)
-	err := bm.saveBans()
+	err := bm.Store.Delete(key)
 	bm.mutex.Unlock()
 	return err
 }
 
+// AddKilledConnections учитывает в бане пользователя разорванные соединения
+func (bm *BanManager) AddKilledConnections(email string, n int) (*BanInfo, error) {
+	key := bm.KeyFor(email)
+	bm.mutex.Lock()
+	defer bm.mutex.Unlock()
+	ban, exists := bm.getBan(key)
+	if !exists {
+		return nil, fmt.Errorf("бан пользователя %s не найден", email)
+	}
+	ban.KilledConnections += n
+	return ban, bm.Store.Put(key, ban)
+}
+
 // GetBanInfo возвращает информацию о бане пользователя
 func (bm *BanManager) GetBanInfo(email string) *BanInfo {
+	key := bm.KeyFor(email)
 	bm.mutex.RLock()
-	ban, exists := bm.Bans[email]
+	ban, exists := bm.getBan(key)
 	bm.mutex.RUnlock()
-	
+
 	if !exists {
 		return nil
 	}
@@ -177,8 +299,7 @@ func (bm *BanManager) GetBanInfo(email string) *BanInfo {
 			email, ban.ExpiresAt.Format("2006-01-02 15:04:05"))
 
 		bm.mutex.Lock()
-		delete(bm.Bans, email)
-		bm.saveBans()
+		bm.deleteBan(key)
 		bm.mutex.Unlock()
 		return nil
 	}
@@ -192,22 +313,21 @@ func (bm *BanManager) CleanupExpiredBans() {
 	expiredCount := 0
 
 	bm.mutex.Lock()
-	for email, ban := range bm.Bans {
+	for key, ban := range bm.listBans() {
 		if now.After(ban.ExpiresAt) {
 			// Логируем в bot.log: автоматическое разбанирование по истечении срока
-			initLogs.LogIPBanAction("АВТО_РАЗБАНЕН", email, len(ban.IPAddresses), ban.IPAddresses)
+			initLogs.LogIPBanAction("АВТО_РАЗБАНЕН", ban.Email, len(ban.IPAddresses), ban.IPAddresses)
 			initLogs.LogIPBanInfo("Пользователь %s автоматически разбанен (бан истек: %s, был забанен: %s)",
-				email,
+				ban.Email,
 				ban.ExpiresAt.Format("2006-01-02 15:04:05"),
 				ban.BannedAt.Format("2006-01-02 15:04:05"))
 
-			delete(bm.Bans, email)
+			bm.deleteBan(key)
 			expiredCount++
 		}
 	}
 
 	if expiredCount > 0 {
-		bm.saveBans()
 		fmt.Printf("BAN_MANAGER: Удалено %d истекших банов\n", expiredCount)
 		// Логируем в bot.log: общая статистика очистки
 		initLogs.LogIPBanInfo("Очистка истекших банов: удалено %d пользователей", expiredCount)
@@ -228,25 +348,24 @@ func (bm *BanManager) CleanupOldBans(retentionMinutes int) {
 	fmt.Printf("BAN_MANAGER: Очистка старых банов: удаляются баны, истекшие дольше %d минут назад\n", retentionMinutes)
 
 	bm.mutex.Lock()
-	for email, ban := range bm.Bans {
+	for key, ban := range bm.listBans() {
 		// Удаляем баны, которые истекли дольше retentionMinutes назад
 		if ban.ExpiresAt.Before(cutoffTime) {
 			// Логируем в bot.log: удаление старого бана
 			initLogs.LogIPBanInfo("Удаление старого бана для %s (истёк: %s, был забанен: %s)",
-				email,
+				ban.Email,
 				ban.ExpiresAt.Format("2006-01-02 15:04:05"),
 				ban.BannedAt.Format("2006-01-02 15:04:05"))
 
-			delete(bm.Bans, email)
+			bm.deleteBan(key)
 			oldBansCount++
 			fmt.Printf("BAN_MANAGER: Удален старый бан для %s (истёк: %s)\n",
-				email, ban.ExpiresAt.Format("15:04:05 02.01.2006"))
+				ban.Email, ban.ExpiresAt.Format("15:04:05 02.01.2006"))
 		}
 	}
 
 	if oldBansCount > 0 {
-		bm.saveBans()
-		fmt.Printf("BAN_MANAGER: Удалено %d старых банов из файла\n", oldBansCount)
+		fmt.Printf("BAN_MANAGER: Удалено %d старых банов из хранилища\n", oldBansCount)
 		// Логируем в bot.log: общая статистика очистки старых банов
 		initLogs.LogIPBanInfo("Очистка старых банов: удалено %d пользователей (старше %d минут)", oldBansCount, retentionMinutes)
 	}
@@ -256,16 +375,12 @@ func (bm *BanManager) CleanupOldBans(retentionMinutes int) {
 // GetActiveBans возвращает список активных банов
 func (bm *BanManager) GetActiveBans() map[string]*BanInfo {
 	bm.CleanupExpiredBans() // Очищаем истекшие баны
-	
+
 	bm.mutex.RLock()
 	defer bm.mutex.RUnlock()
-	
-	// Создаем копию карты, чтобы избежать race condition при возврате
-	activeBans := make(map[string]*BanInfo)
-	for k, v := range bm.Bans {
-		activeBans[k] = v
-	}
-	return activeBans
+
+	// Хранилище возвращает копии записей, поэтому race condition при возврате исключён
+	return bm.listBans()
 }
 
 // GetBanStats возвращает статистику банов
@@ -273,11 +388,12 @@ func (bm *BanManager) GetBanStats() map[string]interface{} {
 	bm.CleanupExpiredBans()
 
 	bm.mutex.RLock()
-	totalBans := len(bm.Bans)
+	bans := bm.listBans()
+	totalBans := len(bans)
 	expiredSoon := 0
 	now := time.Now()
 
-	for _, ban := range bm.Bans {
+	for _, ban := range bans {
 		if ban.ExpiresAt.Sub(now) < time.Hour {
 			expiredSoon++
 		}
@@ -291,3 +407,41 @@ func (bm *BanManager) GetBanStats() map[string]interface{} {
 		"unlimited_bans": IP_BAN_DURATION <= 0,
 	}
 }
+
+// MigrateKeys переносит баны, сохранённые под email (до появления стабильных ключей)
+// или под запасным ключем "email:...", на стабильный ключ идентичности.
+// Вызывается после синхронизации таблицы идентичностей с панелью.
+func (bm *BanManager) MigrateKeys() {
+	if bm.Identities == nil {
+		return
+	}
+
+	bm.mutex.Lock()
+	defer bm.mutex.Unlock()
+
+	for oldKey, ban := range bm.listBans() {
+		if IsStableKey(oldKey) {
+			continue
+		}
+		newKey := bm.Identities.Resolve(ban.Email)
+		if !IsStableKey(newKey) {
+			continue // клиент пока неизвестен панели — оставляем как есть
+		}
+
+		ban.Key = newKey
+		if ident := bm.Identities.Get(newKey); ident != nil {
+			ban.SubID = ident.SubID
+		}
+		// Если под стабильным ключом уже есть бан, оставляем тот, что истекает позже
+		if existing, ok := bm.getBan(newKey); ok && existing.ExpiresAt.After(ban.ExpiresAt) {
+			bm.deleteBan(oldKey)
+			continue
+		}
+		if err := bm.Store.Put(newKey, ban); err != nil {
+			initLogs.LogIPBanError("Ошибка переноса бана %s -> %s: %v", oldKey, newKey, err)
+			continue
+		}
+		bm.deleteBan(oldKey)
+		initLogs.LogIPBanInfo("Бан %s перенесён на стабильный ключ %s", oldKey, newKey)
+	}
+}
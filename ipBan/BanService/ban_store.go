@@ -0,0 +1,60 @@
+package ipban
+
+import (
+	"fmt"
+)
+
+// BanStore абстрагирует хранилище банов от BanManager.
+// Реализации обязаны быть безопасными для одновременного вызова из разных горутин
+// и возвращать копии записей, чтобы вызывающий код мог менять их без гонок.
+type BanStore interface {
+	// Get возвращает бан по ключу; если записи нет — (nil, nil)
+	Get(key string) (*BanInfo, error)
+	// Put создаёт или перезаписывает бан по ключу
+	Put(key string, ban *BanInfo) error
+	// Delete удаляет бан по ключу; отсутствие записи ошибкой не считается
+	Delete(key string) error
+	// List возвращает все сохранённые баны (включая истекшие)
+	List() (map[string]*BanInfo, error)
+	// Close освобождает ресурсы хранилища (файлы, соединения)
+	Close() error
+}
+
+// Поддерживаемые бэкенды хранилища банов (значения BAN_STORE_BACKEND)
+const (
+	BanStoreFile  = "file"
+	BanStoreBolt  = "bolt"
+	BanStoreRedis = "redis"
+)
+
+// OpenBanStore открывает хранилище банов, выбранное в конфигурации (BAN_STORE_BACKEND)
+func OpenBanStore() (BanStore, error) {
+	switch BAN_STORE_BACKEND {
+	case BanStoreFile, "":
+		return NewFileBanStore(BAN_STORE_FILE_PATH)
+	case BanStoreBolt:
+		return NewBoltBanStore(BAN_STORE_BOLT_PATH)
+	case BanStoreRedis:
+		return NewRedisBanStore(BAN_STORE_REDIS_ADDR, BAN_STORE_REDIS_PASSWORD, BAN_STORE_REDIS_DB, BAN_STORE_REDIS_KEY)
+	default:
+		return nil, fmt.Errorf("неизвестный бэкенд хранилища банов: %q", BAN_STORE_BACKEND)
+	}
+}
+
+// cloneBanInfo возвращает независимую копию записи о бане
+func cloneBanInfo(ban *BanInfo) *BanInfo {
+	if ban == nil {
+		return nil
+	}
+	c := *ban
+	if ban.IPAddresses != nil {
+		c.IPAddresses = append([]string(nil), ban.IPAddresses...)
+	}
+	if ban.BlockedIPs != nil {
+		c.BlockedIPs = append([]string(nil), ban.BlockedIPs...)
+	}
+	if ban.ThrottledIPs != nil {
+		c.ThrottledIPs = append([]string(nil), ban.ThrottledIPs...)
+	}
+	return &c
+}
@@ -0,0 +1,104 @@
+package ipban
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// boltBansBucket — имя bucket'а, в котором лежат баны (ключ -> JSON BanInfo)
+var boltBansBucket = []byte("bans")
+
+// BoltBanStore хранит баны во встроенной базе bbolt.
+// Каждая операция — отдельная транзакция, поэтому запись не требует перезаписи всего файла.
+type BoltBanStore struct {
+	Path string
+	db   *bolt.DB
+}
+
+// NewBoltBanStore открывает базу bbolt и создаёт bucket банов при необходимости.
+// Файл базы блокируется процессом: второй процесс получит ошибку через секунду ожидания.
+func NewBoltBanStore(path string) (*BoltBanStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("ошибка создания директории для базы банов: %v", err)
+	}
+
+	db, err := bolt.Open(path, 0o644, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("ошибка открытия базы банов %s: %v", path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(boltBansBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("ошибка создания bucket банов: %v", err)
+	}
+
+	return &BoltBanStore{Path: path, db: db}, nil
+}
+
+// Get читает бан по ключу
+func (bs *BoltBanStore) Get(key string) (*BanInfo, error) {
+	var ban *BanInfo
+	err := bs.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(boltBansBucket).Get([]byte(key))
+		if data == nil {
+			return nil
+		}
+		ban = &BanInfo{}
+		return json.Unmarshal(data, ban)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения бана %s: %v", key, err)
+	}
+	return ban, nil
+}
+
+// Put сохраняет бан по ключу
+func (bs *BoltBanStore) Put(key string, ban *BanInfo) error {
+	data, err := json.Marshal(ban)
+	if err != nil {
+		return fmt.Errorf("ошибка сериализации бана: %v", err)
+	}
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBansBucket).Put([]byte(key), data)
+	})
+}
+
+// Delete удаляет бан по ключу
+func (bs *BoltBanStore) Delete(key string) error {
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBansBucket).Delete([]byte(key))
+	})
+}
+
+// List возвращает все баны из базы
+func (bs *BoltBanStore) List() (map[string]*BanInfo, error) {
+	result := make(map[string]*BanInfo)
+	err := bs.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBansBucket).ForEach(func(k, v []byte) error {
+			ban := &BanInfo{}
+			if err := json.Unmarshal(v, ban); err != nil {
+				return fmt.Errorf("повреждённая запись %s: %v", string(k), err)
+			}
+			result[string(k)] = ban
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения банов: %v", err)
+	}
+	return result, nil
+}
+
+// Close закрывает базу и снимает файловую блокировку
+func (bs *BoltBanStore) Close() error {
+	return bs.db.Close()
+}
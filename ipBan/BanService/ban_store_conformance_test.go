@@ -0,0 +1,233 @@
+// Общий набор проверок для реализаций ipban.BanStore.
+// Каждая реализация (file, bolt, redis) должна проходить runConformance без исключений —
+// так BanManager может работать с любым бэкендом одинаково.
+package ipban_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	ipban "ipBanSystem/ipBan/BanService"
+)
+
+// storeFactory создаёт новое пустое хранилище для очередной проверки.
+// Повторный вызов reopen должен открыть то же самое хранилище заново (проверка персистентности);
+// если бэкенд не персистентный между открытиями, reopen может вернуть nil.
+type storeFactory func(t *testing.T) (store ipban.BanStore, reopen func() ipban.BanStore)
+
+// runConformance прогоняет набор проверок контракта BanStore
+func runConformance(t *testing.T, newStore storeFactory) {
+	t.Run("GetMissing", func(t *testing.T) { testGetMissing(t, newStore) })
+	t.Run("PutGet", func(t *testing.T) { testPutGet(t, newStore) })
+	t.Run("Overwrite", func(t *testing.T) { testOverwrite(t, newStore) })
+	t.Run("Delete", func(t *testing.T) { testDelete(t, newStore) })
+	t.Run("List", func(t *testing.T) { testList(t, newStore) })
+	t.Run("ReturnsCopies", func(t *testing.T) { testReturnsCopies(t, newStore) })
+	t.Run("Persistence", func(t *testing.T) { testPersistence(t, newStore) })
+	t.Run("Concurrent", func(t *testing.T) { testConcurrent(t, newStore) })
+}
+
+// sampleBan возвращает запись о бане с детерминированными полями
+func sampleBan(email string) *ipban.BanInfo {
+	bannedAt := time.Date(2025, 9, 4, 10, 17, 3, 0, time.UTC)
+	return &ipban.BanInfo{
+		Email:       email,
+		BannedAt:    bannedAt,
+		ExpiresAt:   bannedAt.Add(2 * time.Hour),
+		Reason:      "Превышение лимита IP адресов: 13 (максимум: 12)",
+		IPAddresses: []string{"10.0.0.1", "10.0.0.2", "2001:db8::1"},
+	}
+}
+
+// assertBanEqual сравнивает записи о бане по значимым полям
+func assertBanEqual(t *testing.T, got, want *ipban.BanInfo) {
+	t.Helper()
+	if got == nil {
+		t.Fatalf("бан не найден, ожидался %+v", want)
+	}
+	if got.Email != want.Email || got.Reason != want.Reason {
+		t.Fatalf("бан отличается: got %+v, want %+v", got, want)
+	}
+	if !got.BannedAt.Equal(want.BannedAt) || !got.ExpiresAt.Equal(want.ExpiresAt) {
+		t.Fatalf("время бана отличается: got %v/%v, want %v/%v", got.BannedAt, got.ExpiresAt, want.BannedAt, want.ExpiresAt)
+	}
+	if fmt.Sprint(got.IPAddresses) != fmt.Sprint(want.IPAddresses) {
+		t.Fatalf("IP адреса отличаются: got %v, want %v", got.IPAddresses, want.IPAddresses)
+	}
+}
+
+func testGetMissing(t *testing.T, newStore storeFactory) {
+	store, _ := newStore(t)
+	ban, err := store.Get("nobody@example")
+	if err != nil {
+		t.Fatalf("Get отсутствующего ключа вернул ошибку: %v", err)
+	}
+	if ban != nil {
+		t.Fatalf("Get отсутствующего ключа вернул %+v, ожидался nil", ban)
+	}
+}
+
+func testPutGet(t *testing.T, newStore storeFactory) {
+	store, _ := newStore(t)
+	want := sampleBan("user@name")
+	if err := store.Put(want.Email, want); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	got, err := store.Get(want.Email)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	assertBanEqual(t, got, want)
+}
+
+func testOverwrite(t *testing.T, newStore storeFactory) {
+	store, _ := newStore(t)
+	first := sampleBan("user@name")
+	if err := store.Put(first.Email, first); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	second := sampleBan("user@name")
+	second.Reason = "ручной бан"
+	second.ExpiresAt = second.ExpiresAt.Add(time.Hour)
+	if err := store.Put(second.Email, second); err != nil {
+		t.Fatalf("Put (перезапись): %v", err)
+	}
+	got, err := store.Get(second.Email)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	assertBanEqual(t, got, second)
+}
+
+func testDelete(t *testing.T, newStore storeFactory) {
+	store, _ := newStore(t)
+	ban := sampleBan("user@name")
+	if err := store.Put(ban.Email, ban); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if err := store.Delete(ban.Email); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	got, err := store.Get(ban.Email)
+	if err != nil {
+		t.Fatalf("Get после Delete: %v", err)
+	}
+	if got != nil {
+		t.Fatalf("бан остался после Delete: %+v", got)
+	}
+	// Повторное удаление не должно быть ошибкой
+	if err := store.Delete(ban.Email); err != nil {
+		t.Fatalf("повторный Delete вернул ошибку: %v", err)
+	}
+}
+
+func testList(t *testing.T, newStore storeFactory) {
+	store, _ := newStore(t)
+	emails := []string{"a@x", "b@x", "c@x"}
+	for _, email := range emails {
+		if err := store.Put(email, sampleBan(email)); err != nil {
+			t.Fatalf("Put %s: %v", email, err)
+		}
+	}
+	if err := store.Delete("b@x"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	bans, err := store.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(bans) != 2 {
+		t.Fatalf("List вернул %d записей, ожидалось 2: %v", len(bans), bans)
+	}
+	assertBanEqual(t, bans["a@x"], sampleBan("a@x"))
+	assertBanEqual(t, bans["c@x"], sampleBan("c@x"))
+}
+
+func testReturnsCopies(t *testing.T, newStore storeFactory) {
+	store, _ := newStore(t)
+	ban := sampleBan("user@name")
+	if err := store.Put(ban.Email, ban); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	// Изменение исходной записи после Put не должно влиять на хранилище
+	ban.Reason = "изменено"
+	ban.IPAddresses[0] = "192.0.2.1"
+
+	got, err := store.Get("user@name")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	assertBanEqual(t, got, sampleBan("user@name"))
+
+	// Изменение полученной записи тоже не должно влиять на хранилище
+	got.IPAddresses[0] = "192.0.2.2"
+	again, err := store.Get("user@name")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	assertBanEqual(t, again, sampleBan("user@name"))
+}
+
+func testPersistence(t *testing.T, newStore storeFactory) {
+	store, reopen := newStore(t)
+	if reopen == nil {
+		t.Skip("бэкенд не поддерживает повторное открытие")
+	}
+	ban := sampleBan("user@name")
+	if err := store.Put(ban.Email, ban); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	reopened := reopen()
+	defer reopened.Close()
+	got, err := reopened.Get(ban.Email)
+	if err != nil {
+		t.Fatalf("Get после повторного открытия: %v", err)
+	}
+	assertBanEqual(t, got, ban)
+}
+
+func testConcurrent(t *testing.T, newStore storeFactory) {
+	store, _ := newStore(t)
+	const workers = 8
+	const perWorker = 10
+
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*perWorker)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				email := fmt.Sprintf("user-%d-%d@x", w, i)
+				if err := store.Put(email, sampleBan(email)); err != nil {
+					errs <- err
+					return
+				}
+				if _, err := store.Get(email); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("ошибка при параллельной работе: %v", err)
+	}
+
+	bans, err := store.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(bans) != workers*perWorker {
+		t.Fatalf("List вернул %d записей, ожидалось %d", len(bans), workers*perWorker)
+	}
+}
@@ -0,0 +1,104 @@
+package ipban
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// FileBanStore хранит баны в одном JSON-файле (исходный формат /var/log/ip_bans.json).
+// Содержимое файла кэшируется в памяти, каждая модификация перезаписывает файл целиком.
+type FileBanStore struct {
+	Path  string
+	bans  map[string]*BanInfo
+	mutex sync.RWMutex // Мьютекс для синхронизации доступа к карте bans
+}
+
+// NewFileBanStore открывает (или создаёт при первой записи) JSON-файл банов
+func NewFileBanStore(path string) (*FileBanStore, error) {
+	fs := &FileBanStore{
+		Path: path,
+		bans: make(map[string]*BanInfo),
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			// Файл не существует - это нормально
+			return fs, nil
+		}
+		return nil, fmt.Errorf("ошибка чтения файла банов %s: %v", path, err)
+	}
+	if len(data) == 0 {
+		return fs, nil
+	}
+
+	if err := json.Unmarshal(data, &fs.bans); err != nil {
+		// Повреждённый файл не должен останавливать сервис: начинаем с пустого списка
+		fmt.Printf("BAN_STORE: Ошибка загрузки банов из %s: %v\n", path, err)
+		fs.bans = make(map[string]*BanInfo)
+	}
+	return fs, nil
+}
+
+// Get возвращает копию бана по ключу
+func (fs *FileBanStore) Get(key string) (*BanInfo, error) {
+	fs.mutex.RLock()
+	defer fs.mutex.RUnlock()
+	return cloneBanInfo(fs.bans[key]), nil
+}
+
+// Put сохраняет бан и перезаписывает файл
+func (fs *FileBanStore) Put(key string, ban *BanInfo) error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	fs.bans[key] = cloneBanInfo(ban)
+	return fs.save()
+}
+
+// Delete удаляет бан и перезаписывает файл, если запись существовала
+func (fs *FileBanStore) Delete(key string) error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	if _, exists := fs.bans[key]; !exists {
+		return nil
+	}
+	delete(fs.bans, key)
+	return fs.save()
+}
+
+// List возвращает копию всех банов
+func (fs *FileBanStore) List() (map[string]*BanInfo, error) {
+	fs.mutex.RLock()
+	defer fs.mutex.RUnlock()
+	result := make(map[string]*BanInfo, len(fs.bans))
+	for k, v := range fs.bans {
+		result[k] = cloneBanInfo(v)
+	}
+	return result, nil
+}
+
+// Close ничего не делает: файл не держится открытым между операциями
+func (fs *FileBanStore) Close() error {
+	return nil
+}
+
+// save записывает карту банов во временный файл и атомарно заменяет основной
+// Вызывается под fs.mutex
+func (fs *FileBanStore) save() error {
+	data, err := json.MarshalIndent(fs.bans, "", "  ")
+	if err != nil {
+		return fmt.Errorf("ошибка сериализации банов: %v", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(fs.Path), 0o755); err != nil {
+		return fmt.Errorf("ошибка создания директории для файла банов: %v", err)
+	}
+	tmp := fs.Path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("ошибка записи файла банов: %v", err)
+	}
+	return os.Rename(tmp, fs.Path)
+}
@@ -0,0 +1,291 @@
+package ipban
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RedisBanStore хранит баны в Redis-совместимом сервере (Redis, KeyDB, Valkey и т.п.),
+// что позволяет нескольким нодам разделять один список банов.
+// Все баны лежат в одном хэше: поле = ключ бана, значение = JSON BanInfo.
+// Клиент минимальный (протокол RESP2), одно соединение, переподключение при ошибке.
+type RedisBanStore struct {
+	Addr     string
+	Password string
+	DB       int
+	HashKey  string
+
+	conn   net.Conn
+	reader *bufio.Reader
+	mutex  sync.Mutex // Мьютекс сериализует команды в единственном соединении
+}
+
+// redisNil — ответ сервера "нет значения" ($-1)
+var redisNil = errors.New("redis: nil")
+
+// NewRedisBanStore подключается к серверу и проверяет соединение командой PING
+func NewRedisBanStore(addr, password string, db int, hashKey string) (*RedisBanStore, error) {
+	rs := &RedisBanStore{
+		Addr:     addr,
+		Password: password,
+		DB:       db,
+		HashKey:  hashKey,
+	}
+
+	rs.mutex.Lock()
+	defer rs.mutex.Unlock()
+	if _, err := rs.do("PING"); err != nil {
+		rs.closeConn()
+		return nil, fmt.Errorf("ошибка подключения к Redis %s: %v", addr, err)
+	}
+	return rs, nil
+}
+
+// Get читает бан из хэша
+func (rs *RedisBanStore) Get(key string) (*BanInfo, error) {
+	rs.mutex.Lock()
+	reply, err := rs.do("HGET", rs.HashKey, key)
+	rs.mutex.Unlock()
+	if err == redisNil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения бана %s: %v", key, err)
+	}
+
+	data, ok := reply.(string)
+	if !ok {
+		return nil, fmt.Errorf("неожиданный ответ Redis на HGET: %T", reply)
+	}
+	ban := &BanInfo{}
+	if err := json.Unmarshal([]byte(data), ban); err != nil {
+		return nil, fmt.Errorf("повреждённая запись %s: %v", key, err)
+	}
+	return ban, nil
+}
+
+// Put сохраняет бан в хэш
+func (rs *RedisBanStore) Put(key string, ban *BanInfo) error {
+	data, err := json.Marshal(ban)
+	if err != nil {
+		return fmt.Errorf("ошибка сериализации бана: %v", err)
+	}
+	rs.mutex.Lock()
+	defer rs.mutex.Unlock()
+	if _, err := rs.do("HSET", rs.HashKey, key, string(data)); err != nil {
+		return fmt.Errorf("ошибка записи бана %s: %v", key, err)
+	}
+	return nil
+}
+
+// Delete удаляет поле из хэша
+func (rs *RedisBanStore) Delete(key string) error {
+	rs.mutex.Lock()
+	defer rs.mutex.Unlock()
+	if _, err := rs.do("HDEL", rs.HashKey, key); err != nil {
+		return fmt.Errorf("ошибка удаления бана %s: %v", key, err)
+	}
+	return nil
+}
+
+// List читает все баны через HGETALL
+func (rs *RedisBanStore) List() (map[string]*BanInfo, error) {
+	rs.mutex.Lock()
+	reply, err := rs.do("HGETALL", rs.HashKey)
+	rs.mutex.Unlock()
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения банов: %v", err)
+	}
+
+	items, ok := reply.([]interface{})
+	if !ok || len(items)%2 != 0 {
+		return nil, fmt.Errorf("неожиданный ответ Redis на HGETALL: %v", reply)
+	}
+
+	result := make(map[string]*BanInfo, len(items)/2)
+	for i := 0; i < len(items); i += 2 {
+		key, _ := items[i].(string)
+		data, _ := items[i+1].(string)
+		ban := &BanInfo{}
+		if err := json.Unmarshal([]byte(data), ban); err != nil {
+			return nil, fmt.Errorf("повреждённая запись %s: %v", key, err)
+		}
+		result[key] = ban
+	}
+	return result, nil
+}
+
+// Close закрывает соединение с сервером
+func (rs *RedisBanStore) Close() error {
+	rs.mutex.Lock()
+	defer rs.mutex.Unlock()
+	return rs.closeConn()
+}
+
+// do выполняет команду, при сетевой ошибке один раз переподключается и повторяет
+// (соединение после неудачной попытки уже закрыто roundTrip). Вызывается под rs.mutex.
+func (rs *RedisBanStore) do(args ...string) (interface{}, error) {
+	reply, err := rs.roundTrip(args)
+	if err == nil || err == redisNil || isRedisServerError(err) {
+		return reply, err
+	}
+
+	// Сетевая ошибка: соединение могло быть разорвано сервером — пробуем заново
+	return rs.roundTrip(args)
+}
+
+// roundTrip отправляет команду и читает один ответ.
+// При любой ошибке, кроме ответа сервера, соединение закрывается: команда могла уйти не целиком,
+// а ответ — прийти позже или прочитаться наполовину, и его остаток был бы принят за ответ следующей команды.
+func (rs *RedisBanStore) roundTrip(args []string) (interface{}, error) {
+	if err := rs.ensureConn(); err != nil {
+		return nil, err
+	}
+	rs.conn.SetDeadline(time.Now().Add(10 * time.Second))
+	if _, err := rs.conn.Write(encodeRedisCommand(args)); err != nil {
+		rs.closeConn()
+		return nil, err
+	}
+	reply, err := readRedisReply(rs.reader)
+	if err != nil && err != redisNil && !isRedisServerError(err) {
+		rs.closeConn()
+	}
+	return reply, err
+}
+
+// ensureConn устанавливает соединение, выполняя AUTH и SELECT при необходимости
+func (rs *RedisBanStore) ensureConn() error {
+	if rs.conn != nil {
+		return nil
+	}
+
+	conn, err := net.DialTimeout("tcp", rs.Addr, 5*time.Second)
+	if err != nil {
+		return err
+	}
+	rs.conn = conn
+	rs.reader = bufio.NewReader(conn)
+
+	if rs.Password != "" {
+		if _, err := rs.roundTrip([]string{"AUTH", rs.Password}); err != nil {
+			rs.closeConn()
+			return fmt.Errorf("ошибка AUTH: %v", err)
+		}
+	}
+	if rs.DB != 0 {
+		if _, err := rs.roundTrip([]string{"SELECT", strconv.Itoa(rs.DB)}); err != nil {
+			rs.closeConn()
+			return fmt.Errorf("ошибка SELECT %d: %v", rs.DB, err)
+		}
+	}
+	return nil
+}
+
+// closeConn закрывает текущее соединение (если есть)
+func (rs *RedisBanStore) closeConn() error {
+	if rs.conn == nil {
+		return nil
+	}
+	err := rs.conn.Close()
+	rs.conn = nil
+	rs.reader = nil
+	return err
+}
+
+// redisServerError — ошибка, которую вернул сам сервер (ответ "-ERR ...")
+type redisServerError string
+
+func (e redisServerError) Error() string { return string(e) }
+
+func isRedisServerError(err error) bool {
+	_, ok := err.(redisServerError)
+	return ok
+}
+
+// encodeRedisCommand кодирует команду как RESP-массив bulk-строк
+func encodeRedisCommand(args []string) []byte {
+	buf := make([]byte, 0, 64)
+	buf = append(buf, '*')
+	buf = strconv.AppendInt(buf, int64(len(args)), 10)
+	buf = append(buf, '\r', '\n')
+	for _, arg := range args {
+		buf = append(buf, '$')
+		buf = strconv.AppendInt(buf, int64(len(arg)), 10)
+		buf = append(buf, '\r', '\n')
+		buf = append(buf, arg...)
+		buf = append(buf, '\r', '\n')
+	}
+	return buf
+}
+
+// readRedisReply читает один RESP-ответ: строку, число, bulk-строку или массив.
+// Ответ сервера об ошибке (в том числе элемент массива) возвращается как redisServerError после того,
+// как ответ прочитан целиком; другие ошибки означают, что ответ прочитан не полностью.
+func readRedisReply(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("некорректный ответ Redis: %q", line)
+	}
+	payload := line[1 : len(line)-2]
+
+	switch line[0] {
+	case '+':
+		return payload, nil
+	case '-':
+		return nil, redisServerError(payload)
+	case ':':
+		return strconv.ParseInt(payload, 10, 64)
+	case '$':
+		size, err := strconv.Atoi(payload)
+		if err != nil {
+			return nil, err
+		}
+		if size < 0 {
+			return nil, redisNil
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		return string(data[:size]), nil
+	case '*':
+		count, err := strconv.Atoi(payload)
+		if err != nil {
+			return nil, err
+		}
+		if count < 0 {
+			return nil, redisNil
+		}
+		items := make([]interface{}, 0, count)
+		var serverErr error
+		for i := 0; i < count; i++ {
+			item, err := readRedisReply(r)
+			switch {
+			case isRedisServerError(err):
+				// Остальные элементы всё равно дочитываем, чтобы не сбить поток ответов
+				if serverErr == nil {
+					serverErr = err
+				}
+			case err != nil && err != redisNil:
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		if serverErr != nil {
+			return nil, serverErr
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("неизвестный тип ответа Redis: %q", line)
+	}
+}
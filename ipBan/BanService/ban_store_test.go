@@ -0,0 +1,80 @@
+package ipban_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	ipban "ipBanSystem/ipBan/BanService"
+)
+
+func TestFileBanStore(t *testing.T) { runConformance(t, fileFactory) }
+
+func TestBoltBanStore(t *testing.T) { runConformance(t, boltFactory) }
+
+// Redis проверяется на локальном Redis-совместимом сервере в памяти (redisStandIn): внешний Redis не нужен
+func TestRedisBanStore(t *testing.T) { runConformance(t, redisFactory) }
+
+// Испорченный ответ и на повторе: соединение закрывается, и хвост ответа не читается как ответ следующей команды
+func TestRedisBanStoreDropsBrokenConnection(t *testing.T) {
+	srv, err := newRedisStandIn("")
+	if err != nil {
+		t.Fatalf("newRedisStandIn: %v", err)
+	}
+	t.Cleanup(func() { srv.Close() })
+	srv.mutex.Lock()
+	srv.BrokenReplies = 2
+	srv.mutex.Unlock()
+
+	store, err := ipban.NewRedisBanStore(srv.Addr, "", 0, "ipban:bans")
+	if err != nil {
+		t.Fatalf("NewRedisBanStore: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	if _, err := store.Get("sub:a"); err == nil {
+		t.Fatal("ожидалась ошибка испорченного ответа")
+	}
+	if ban, err := store.Get("sub:a"); err != nil || ban != nil {
+		t.Errorf("следующая команда получила хвост прежнего ответа: %v, %v", ban, err)
+	}
+}
+
+// fileFactory создаёт FileBanStore во временной директории теста
+func fileFactory(t *testing.T) (ipban.BanStore, func() ipban.BanStore) {
+	path := filepath.Join(t.TempDir(), "ip_bans.json")
+	return openStore(t, func() (ipban.BanStore, error) { return ipban.NewFileBanStore(path) })
+}
+
+// boltFactory создаёт BoltBanStore во временной директории теста
+func boltFactory(t *testing.T) (ipban.BanStore, func() ipban.BanStore) {
+	path := filepath.Join(t.TempDir(), "ip_bans.db")
+	return openStore(t, func() (ipban.BanStore, error) { return ipban.NewBoltBanStore(path) })
+}
+
+// redisFactory создаёт RedisBanStore поверх локального redisStandIn.
+// Каждый вызов получает свой сервер, поэтому хранилища проверок не пересекаются.
+func redisFactory(t *testing.T) (ipban.BanStore, func() ipban.BanStore) {
+	srv, err := newRedisStandIn("secret")
+	if err != nil {
+		t.Fatalf("newRedisStandIn: %v", err)
+	}
+	t.Cleanup(func() { srv.Close() })
+	return openStore(t, func() (ipban.BanStore, error) {
+		return ipban.NewRedisBanStore(srv.Addr, srv.Password, 1, "ipban:bans")
+	})
+}
+
+// openStore открывает хранилище через open, закрывает его по завершении проверки
+// и возвращает вместе с функцией повторного открытия (для проверки сохранности данных)
+func openStore(t *testing.T, open func() (ipban.BanStore, error)) (ipban.BanStore, func() ipban.BanStore) {
+	reopen := func() ipban.BanStore {
+		store, err := open()
+		if err != nil {
+			t.Fatalf("открытие хранилища: %v", err)
+		}
+		return store
+	}
+	store := reopen()
+	t.Cleanup(func() { store.Close() })
+	return store, reopen
+}
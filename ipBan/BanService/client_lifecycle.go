@@ -0,0 +1,37 @@
+package ipban
+
+import (
+	"ipBanSystem/ipBan/logger/initLogs"
+	"ipBanSystem/ipBan/panel"
+	"ipBanSystem/ipBan/panel/client"
+	"ipBanSystem/ipBan/panel/client/lifecycle"
+)
+
+// ManageClients выполняет операцию жизненного цикла клиентов (продление, лимит, переименование, удаление)
+// под блокировкой цикла проверки, чтобы её запись не пересекалась с набором изменений цикла.
+// После изменений таблица идентичностей сверяется сразу: бан переименованного клиента следует за ним по SubID.
+func (s *IPBanService) ManageClients(action string, op func(cm *panel.ConfigManager) []lifecycle.Result) []lifecycle.Result {
+	s.cycleMutex.Lock()
+	defer s.cycleMutex.Unlock()
+
+	results := op(s.ConfigManager)
+	changed := 0
+	for _, r := range results {
+		switch {
+		case r.Error != "":
+			initLogs.LogIPBanError("%s %s: %s", action, r.Email, r.Error)
+		case r.Changed:
+			changed++
+			initLogs.LogIPBanInfo("%s %s: выполнено", action, r.Email)
+		}
+	}
+
+	if changed > 0 && s.BanManager.Identities != nil {
+		if clients, err := client.All(s.ConfigManager); err == nil {
+			s.BanManager.Identities.Sync(clients)
+		} else {
+			initLogs.LogIPBanWarning("Не удалось обновить таблицу идентичностей после изменения клиентов: %v", err)
+		}
+	}
+	return results
+}
@@ -0,0 +1,36 @@
+package ipban
+
+import (
+	"ipBanSystem/ipBan/runner"
+)
+
+// commandRunner выполняет команды файрвола (iptables, ipset, nft, conntrack, ss).
+// По умолчанию — настоящие процессы; проверки подменяют его фейком через SetCommandRunner.
+var commandRunner runner.CommandRunner = runner.ExecRunner{}
+
+// SetCommandRunner подменяет исполнитель команд файрвола и возвращает функцию восстановления прежнего
+func SetCommandRunner(r runner.CommandRunner) (restore func()) {
+	prev := commandRunner
+	commandRunner = r
+	return func() { commandRunner = prev }
+}
+
+// runCommand выполняет команду и возвращает stdout и stderr одним блоком
+func runCommand(name string, args ...string) ([]byte, error) {
+	return runner.Combined(commandRunner, name, args...)
+}
+
+// runCommandInput выполняет команду со стандартным вводом и возвращает stdout и stderr одним блоком
+func runCommandInput(stdin string, name string, args ...string) ([]byte, error) {
+	return runner.CombinedInput(commandRunner, []byte(stdin), name, args...)
+}
+
+// runCommandOutput выполняет команду и возвращает только stdout (для машинно-читаемого вывода)
+func runCommandOutput(name string, args ...string) ([]byte, error) {
+	return runner.Output(commandRunner, name, args...)
+}
+
+// commandSucceeds сообщает, завершилась ли команда с нулевым кодом (проверки вида iptables -C)
+func commandSucceeds(name string, args ...string) bool {
+	return runner.Check(commandRunner, name, args...) == nil
+}
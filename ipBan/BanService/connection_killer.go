@@ -0,0 +1,179 @@
+package ipban
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"ipBanSystem/ipBan/logger/initLogs"
+)
+
+// Способы разрыва соединений (значения CONNECTION_KILL_METHODS)
+const (
+	// KillConntrack — удаление записей conntrack для IP (conntrack -D)
+	KillConntrack = "conntrack"
+	// KillSockets — закрытие сокетов сервера с IP (ss -K), в том числе сокетов Xray
+	KillSockets = "ss"
+)
+
+// KillResult — итог разрыва соединений
+type KillResult struct {
+	// Conntrack — удалено записей conntrack
+	Conntrack int `json:"conntrack"`
+	// Sockets — закрыто сокетов
+	Sockets int `json:"sockets"`
+	// Errors — ошибки отдельных команд (разрыв продолжается по остальным IP и способам)
+	Errors []string `json:"errors,omitempty"`
+}
+
+// Total возвращает общее число разорванных соединений
+func (r *KillResult) Total() int {
+	return r.Conntrack + r.Sockets
+}
+
+// ConnectionKiller разрывает уже установленные соединения IP.
+// Отключение клиента в панели и правило DROP не трогают открытые потоки: они живут до таймаута.
+// Разрыв ограничен портами области блокировки (Scope), чтобы не рвать, например, SSH администратора.
+type ConnectionKiller struct {
+	Scope   *FirewallScope
+	Methods []string
+}
+
+// NewConnectionKiller создаёт разрыватель соединений со способами из конфигурации (CONNECTION_KILL_METHODS)
+func NewConnectionKiller(scope *FirewallScope) *ConnectionKiller {
+	var methods []string
+	for _, m := range strings.Split(CONNECTION_KILL_METHODS, ",") {
+		if m = strings.TrimSpace(m); m != "" {
+			methods = append(methods, m)
+		}
+	}
+	return &ConnectionKiller{Scope: scope, Methods: methods}
+}
+
+// Kill разрывает соединения перечисленных IP всеми настроенными способами
+func (k *ConnectionKiller) Kill(ipAddresses []string) *KillResult {
+	result := &KillResult{}
+	v4, v6, err := splitIPFamilies(ipAddresses)
+	if err != nil {
+		result.Errors = append(result.Errors, err.Error())
+		return result
+	}
+
+	for _, ip := range append(v4, v6...) {
+		for _, method := range k.Methods {
+			var n int
+			var err error
+			switch method {
+			case KillConntrack:
+				n, err = k.killConntrack(ip)
+				result.Conntrack += n
+			case KillSockets:
+				n, err = k.killSockets(ip)
+				result.Sockets += n
+			default:
+				err = fmt.Errorf("неизвестный способ разрыва соединений: %q", method)
+			}
+			if err != nil {
+				result.Errors = append(result.Errors, fmt.Sprintf("%s %s: %v", method, ip, err))
+			}
+		}
+	}
+
+	if result.Total() > 0 {
+		initLogs.LogIPBanInfo("   ✂️  Разорвано соединений: %d (conntrack: %d, сокетов: %d)", result.Total(), result.Conntrack, result.Sockets)
+	}
+	for _, e := range result.Errors {
+		initLogs.LogIPBanWarning("Ошибка разрыва соединений: %s", e)
+	}
+	return result
+}
+
+// killConnections разрывает соединения IP пользователя после бана и учитывает их число в бане.
+// Возвращает число разорванных соединений (0, если разрыв отключен).
+func (s *IPBanService) killConnections(email string, ipAddresses []string) int {
+	if s.Killer == nil || len(ipAddresses) == 0 {
+		return 0
+	}
+	result := s.Killer.Kill(ipAddresses)
+	killed := result.Total()
+	if killed > 0 {
+		if _, err := s.BanManager.AddKilledConnections(email, killed); err != nil {
+			initLogs.LogIPBanError("Ошибка учёта разорванных соединений %s: %v", email, err)
+		}
+	}
+	return killed
+}
+
+// conntrackDeletedRe — итог conntrack -D: "conntrack v1.4.6 (conntrack-tools): 3 flow entries have been deleted."
+var conntrackDeletedRe = regexp.MustCompile(`(\d+) flow entries have been deleted`)
+
+// killConntrack удаляет записи conntrack с источником ip (по портам области или все)
+func (k *ConnectionKiller) killConntrack(ip string) (int, error) {
+	var filters [][]string
+	if k.Scope == nil || k.Scope.HostWide() {
+		filters = [][]string{nil}
+	} else {
+		for _, r := range k.Scope.Ports {
+			filters = append(filters, []string{"-p", r.Proto, "--dport", strconv.Itoa(r.Port)})
+		}
+	}
+
+	total := 0
+	for _, filter := range filters {
+		args := append([]string{"-D", "-s", ip}, filter...)
+		out, err := runCommand("conntrack", args...)
+		m := conntrackDeletedRe.FindStringSubmatch(string(out))
+		if m == nil {
+			if err != nil {
+				return total, fmt.Errorf("%v: %s", err, strings.TrimSpace(string(out)))
+			}
+			continue
+		}
+		// conntrack завершается с кодом 1, если удалять было нечего — это не ошибка
+		n, _ := strconv.Atoi(m[1])
+		total += n
+	}
+	return total, nil
+}
+
+// killSockets закрывает TCP-сокеты сервера с удалённым адресом ip (ss -K)
+func (k *ConnectionKiller) killSockets(ip string) (int, error) {
+	args := []string{"-K", "-n", "-t", "dst", ip}
+	if k.Scope != nil && !k.Scope.HostWide() {
+		var ports []string
+		for _, r := range k.Scope.Ports {
+			if r.Proto == "tcp" {
+				ports = append(ports, "sport", "=", ":"+strconv.Itoa(r.Port))
+			}
+		}
+		if len(ports) == 0 {
+			// Inbound только на UDP: TCP-сокетов для разрыва нет
+			return 0, nil
+		}
+		// Фильтр вида: dst <ip> and ( sport = :443 or sport = :8443 )
+		args = append(args, "and", "(")
+		for n := 0; n < len(ports); n += 3 {
+			if n > 0 {
+				args = append(args, "or")
+			}
+			args = append(args, ports[n:n+3]...)
+		}
+		args = append(args, ")")
+	}
+
+	out, err := runCommand("ss", args...)
+	if err != nil {
+		return 0, fmt.Errorf("%v: %s", err, strings.TrimSpace(string(out)))
+	}
+	// ss печатает заголовок и по строке на каждый закрытый сокет
+	killed := 0
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || fields[0] == "State" || fields[0] == "Recv-Q" || fields[0] == "Netid" {
+			continue
+		}
+		killed++
+	}
+	return killed, nil
+}
@@ -0,0 +1,94 @@
+package ipban
+
+import (
+	"strings"
+
+	"ipBanSystem/ipBan/logger/initLogs"
+	"ipBanSystem/ipBan/panel/client"
+)
+
+// Действия с клиентами, которые цикл проверки копит в наборе изменений (они же — описания для журнала)
+const (
+	actionEnableIdle       = "включение конфига без активности"
+	actionEnableNormal     = "включение нормального конфига"
+	actionEnableAfterUnban = "включение после разбана"
+	actionResetDepleted    = "снятие статуса 'исчерпано'"
+	actionAddDayAfterUnban = "+1 день после разбана"
+)
+
+// cycleChanges — изменения панели одного цикла проверки.
+// Решения принимаются по снимку клиентов цикла (client.All), а изменения копятся в наборе
+// и записываются в конце цикла одной записью inbound (или пакетами по PANEL_BATCH_SIZE).
+type cycleChanges struct {
+	set     *client.ChangeSet
+	done    []func(client.ChangeResult) // Обработчики итога в порядке добавления изменений
+	enabled map[string]bool             // email (нижний регистр) -> enable по снимку цикла
+	traffic map[string]client.Traffic   // email (нижний регистр) -> трафик по снимку цикла
+}
+
+// beginCycleChanges начинает накопление изменений панели по снимку клиентов и их трафика
+func (s *IPBanService) beginCycleChanges(snapshot []client.Client, traffic map[string]client.Traffic) {
+	enabled := make(map[string]bool, len(snapshot))
+	for _, c := range snapshot {
+		enabled[strings.ToLower(c.Email)] = c.Enable
+	}
+	s.cycle = &cycleChanges{set: client.NewChangeSet(), enabled: enabled, traffic: traffic}
+}
+
+// flushCycleChanges записывает накопленные изменения, сообщает итог по каждому клиенту
+// и возвращает число фактически изменённых клиентов по действиям
+func (s *IPBanService) flushCycleChanges() map[string]int {
+	cycle := s.cycle
+	s.cycle = nil
+	changed := make(map[string]int)
+	if cycle == nil || cycle.set.Len() == 0 {
+		return changed
+	}
+
+	initLogs.LogIPBanInfo("Запись изменений клиентов в панель: %d", cycle.set.Len())
+	for i, r := range cycle.set.Apply(s.ConfigManager, PANEL_BATCH_SIZE) {
+		if r.Changed {
+			changed[r.Action]++
+		}
+		if cycle.done[i] != nil {
+			cycle.done[i](r)
+		}
+	}
+	return changed
+}
+
+// changeClient изменяет клиента в панели: в цикле проверки — добавляет изменение в набор цикла,
+// вне цикла (ручные команды) — применяет сразу. done получает итог (в цикле — после записи набора).
+func (s *IPBanService) changeClient(email, action string, patch func(m map[string]interface{}) error, done func(client.ChangeResult)) {
+	if s.cycle != nil {
+		s.cycle.set.Add(client.MatchEmail(email), action, patch)
+		s.cycle.done = append(s.cycle.done, done)
+		return
+	}
+
+	set := client.NewChangeSet()
+	set.Add(client.MatchEmail(email), action, patch)
+	result := set.Apply(s.ConfigManager, PANEL_BATCH_SIZE)[0]
+	if done != nil {
+		done(result)
+	}
+}
+
+// clientEnabled возвращает статус клиента: из снимка цикла проверки или запросом к панели
+func (s *IPBanService) clientEnabled(email string) (bool, error) {
+	if s.cycle != nil {
+		if enabled, ok := s.cycle.enabled[strings.ToLower(email)]; ok {
+			return enabled, nil
+		}
+	}
+	return client.Status(s.ConfigManager, email)
+}
+
+// cycleTraffic возвращает трафик клиента из снимка цикла проверки (false — вне цикла или нет статистики)
+func (s *IPBanService) cycleTraffic(email string) (client.Traffic, bool) {
+	if s.cycle == nil {
+		return client.Traffic{}, false
+	}
+	t, ok := s.cycle.traffic[strings.ToLower(email)]
+	return t, ok
+}
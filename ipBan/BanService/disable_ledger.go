@@ -0,0 +1,129 @@
+package ipban
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"ipBanSystem/ipBan/logger/initLogs"
+)
+
+// DisableRecord — отключение конфига, выполненное сервисом
+type DisableRecord struct {
+	// Email — email клиента на момент отключения (для журнала; запись ищется по ключу идентичности)
+	Email string `json:"email"`
+	// Reason — за что сервис отключил конфиг
+	Reason string `json:"reason"`
+	// DisabledAt — когда конфиг отключен
+	DisabledAt time.Time `json:"disabled_at"`
+}
+
+// DisableLedger помнит, какие конфиги отключил сам сервис, по стабильному ключу идентичности.
+// При MANUAL_CHANGES_PRECEDENCE сервис включает обратно только их: конфиг, отключенный
+// администратором в панели (неоплата, истёкшая подписка), остаётся отключенным.
+// Сохраняется в JSON-файл; запись удаляется, когда конфиг снова включен.
+type DisableLedger struct {
+	Path     string
+	disables map[string]*DisableRecord
+	fresh    bool // файла журнала не было: сервис обновлён с версии без журнала, журнал ещё не заполнен (Seed)
+	mutex    sync.Mutex
+}
+
+// NewDisableLedger загружает журнал отключений из файла (или создаёт пустой)
+func NewDisableLedger(path string) *DisableLedger {
+	dl := &DisableLedger{
+		Path:     path,
+		disables: make(map[string]*DisableRecord),
+	}
+	data, err := os.ReadFile(path)
+	dl.fresh = os.IsNotExist(err)
+	if err == nil && len(data) > 0 {
+		if err := json.Unmarshal(data, &dl.disables); err != nil {
+			initLogs.LogIPBanError("Ошибка загрузки журнала отключений %s: %v", path, err)
+			dl.disables = make(map[string]*DisableRecord)
+		}
+	}
+	return dl
+}
+
+// Record фиксирует, что сервис отключил конфиг клиента
+func (dl *DisableLedger) Record(key, email, reason string) {
+	dl.mutex.Lock()
+	defer dl.mutex.Unlock()
+
+	dl.disables[key] = &DisableRecord{Email: email, Reason: reason, DisabledAt: time.Now()}
+	if err := dl.saveLocked(); err != nil {
+		initLogs.LogIPBanError("Ошибка сохранения журнала отключений: %v", err)
+	}
+}
+
+// Seed заполняет новый журнал отключениями прежней версии сервиса (records — по ключу идентичности).
+// Срабатывает один раз, если файла журнала не было; существующий журнал не меняется.
+// Возвращает число добавленных записей.
+func (dl *DisableLedger) Seed(records map[string]*DisableRecord) int {
+	dl.mutex.Lock()
+	defer dl.mutex.Unlock()
+
+	if !dl.fresh {
+		return 0
+	}
+	dl.fresh = false
+	added := 0
+	for key, rec := range records {
+		if _, ok := dl.disables[key]; !ok {
+			copied := *rec
+			dl.disables[key] = &copied
+			added++
+		}
+	}
+	// Файл создаётся и без записей: при следующем запуске журнал уже не считается новым
+	if err := dl.saveLocked(); err != nil {
+		initLogs.LogIPBanError("Ошибка сохранения журнала отключений: %v", err)
+	}
+	return added
+}
+
+// Get возвращает запись об отключении сервисом (nil — сервис конфиг не отключал)
+func (dl *DisableLedger) Get(key string) *DisableRecord {
+	dl.mutex.Lock()
+	defer dl.mutex.Unlock()
+
+	if rec := dl.disables[key]; rec != nil {
+		copied := *rec
+		return &copied
+	}
+	return nil
+}
+
+// Forget удаляет запись: конфиг включен (сервисом или администратором)
+func (dl *DisableLedger) Forget(key string) {
+	dl.mutex.Lock()
+	defer dl.mutex.Unlock()
+
+	if _, ok := dl.disables[key]; !ok {
+		return
+	}
+	delete(dl.disables, key)
+	if err := dl.saveLocked(); err != nil {
+		initLogs.LogIPBanError("Ошибка сохранения журнала отключений: %v", err)
+	}
+}
+
+// saveLocked атомарно записывает журнал в файл (через временный файл и rename)
+func (dl *DisableLedger) saveLocked() error {
+	data, err := json.MarshalIndent(dl.disables, "", "  ")
+	if err != nil {
+		return fmt.Errorf("ошибка сериализации журнала отключений: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(dl.Path), 0o755); err != nil {
+		return fmt.Errorf("ошибка создания директории: %v", err)
+	}
+	tmp := dl.Path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, dl.Path)
+}
@@ -0,0 +1,519 @@
+// Сквозные проверки IPBanService против поддельной панели 3x-ui (fakepanel).
+// Цикл проверки читает фикстуру access.log, принимает решения и меняет клиентов в панели;
+// проверяется итоговое состояние панели и банов. Файрвол работает на фейковом исполнителе команд.
+package ipban_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	ipban "ipBanSystem/ipBan/BanService"
+	"ipBanSystem/ipBan/logger/analyzerLogs"
+	"ipBanSystem/ipBan/panel"
+	"ipBanSystem/ipBan/panel/client"
+	"ipBanSystem/ipBan/panel/client/lifecycle"
+	"ipBanSystem/ipBan/panel/fakepanel"
+	"ipBanSystem/ipBan/runner"
+)
+
+// maxIPs — лимит IP на конфиг в проверках
+const maxIPs = 2
+
+// TestE2E прогоняет сквозные проверки цикла мониторинга
+func TestE2E(t *testing.T) {
+	t.Run("SuspiciousClientDisabledAndRotated", testSuspiciousClientDisabled)
+	t.Run("LegacyPanelFallback", testLegacyPanelFallback)
+	t.Run("IdleClientsEnabledInOneWrite", testIdleClientsEnabledInOneWrite)
+	t.Run("UnbanRestoresClient", testUnbanRestoresClient)
+	t.Run("ExpiredSessionRelogin", testExpiredSessionRelogin)
+	t.Run("PanelUnavailable", testPanelUnavailable)
+	t.Run("TrafficAbuseBans", testTrafficAbuseBans)
+	t.Run("DepletedStaysDisabledAfterUnban", testDepletedStaysDisabled)
+	t.Run("BanFollowsRename", testBanFollowsRename)
+	t.Run("InterruptedResetFinished", testInterruptedResetFinished)
+	t.Run("InterruptedResetUndone", testInterruptedResetUndone)
+	t.Run("MismatchedDepletedFlags", testMismatchedDepletedFlags)
+	t.Run("AdminDisabledStaysDisabled", testAdminDisabledStaysDisabled)
+	t.Run("ServiceDisableReenabledAfterBanExpiry", testServiceDisableReenabled)
+	t.Run("ManualChangesPrecedenceOff", testManualChangesPrecedenceOff)
+	t.Run("LedgerSeededFromExistingBans", testLedgerSeededFromExistingBans)
+}
+
+// env — окружение сквозной проверки
+type env struct {
+	panel   *fakepanel.Server
+	inbound int
+	service *ipban.IPBanService
+	logPath string
+}
+
+// newEnv запускает поддельную панель с клиентами и собирает сервис поверх неё
+func newEnv(t *testing.T, legacy bool, clients ...map[string]interface{}) *env {
+	t.Helper()
+	dir := t.TempDir()
+
+	fake, cm := fakepanel.Start(t, "vless", 443, clients...)
+	fake.Legacy = legacy
+
+	store, err := ipban.NewFileBanStore(filepath.Join(dir, "bans.json"))
+	if err != nil {
+		t.Fatalf("NewFileBanStore: %v", err)
+	}
+	bans := ipban.NewBanManager(store, ipban.NewIdentityMap(filepath.Join(dir, "identities.json")))
+
+	// Файрвол iptables на фейковом исполнителе: все команды успешны
+	restore := ipban.SetCommandRunner(runner.NewScriptedRunner())
+	t.Cleanup(restore)
+	firewall, err := ipban.NewIPTablesManager("IPBAN", ipban.HostScope())
+	if err != nil {
+		t.Fatalf("NewIPTablesManager: %v", err)
+	}
+
+	logPath := filepath.Join(dir, "access.log")
+	analyzer := analyzerLogs.NewLogAnalyzer(logPath, 20, logPath)
+	service := ipban.NewIPBanService(analyzer, cm, bans, firewall, maxIPs, time.Minute, 0)
+	service.Traffic = ipban.NewTrafficTracker(time.Hour)
+	service.Disables = ipban.NewDisableLedger(filepath.Join(dir, "disables.json"))
+	fake.ResetRequests()
+	return &env{panel: fake, inbound: cm.InboundID, service: service, logPath: logPath}
+}
+
+// vlessClient — JSON клиента vless для AddInbound
+func vlessClient(id, email string, enable bool) map[string]interface{} {
+	return map[string]interface{}{
+		"id": id, "email": email, "enable": enable, "flow": "", "limitIp": 0,
+		"totalGB": 0, "expiryTime": 0, "subId": "sub-" + id,
+	}
+}
+
+// disabledByService отмечает клиентов в журнале отключений, как если бы их отключил сервис
+// (таблица идентичностей сверяется заранее — как в цикле проверки до отключения)
+func (e *env) disabledByService(t *testing.T, emails ...string) {
+	t.Helper()
+	clients, err := client.All(e.service.ConfigManager)
+	if err != nil {
+		t.Fatalf("client.All: %v", err)
+	}
+	e.service.BanManager.Identities.Sync(clients)
+	e.panel.ResetRequests()
+	for _, email := range emails {
+		e.service.Disables.Record(e.service.BanManager.KeyFor(email), email, "проверка")
+	}
+}
+
+// writeAccessLog пишет фикстуру access.log: для каждого email — по строке на IP, со свежими отметками времени
+func (e *env) writeAccessLog(t *testing.T, ipsByEmail map[string][]string) {
+	t.Helper()
+	now := time.Now().Add(-time.Minute)
+	var lines []string
+	for email, ips := range ipsByEmail {
+		for i, ip := range ips {
+			ts := now.Add(time.Duration(i) * time.Second).Format("2006/01/02 15:04:05.000000")
+			lines = append(lines, fmt.Sprintf("%s from %s:%d accepted tcp:example.com:443 [inbound-443 >> direct] email: %s",
+				ts, ip, 40000+i, email))
+		}
+	}
+	if err := os.WriteFile(e.logPath, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatalf("запись access.log: %v", err)
+	}
+}
+
+// client возвращает клиента из панели или завершает проверку
+func (e *env) client(t *testing.T, email string) map[string]interface{} {
+	t.Helper()
+	c := e.panel.Client(e.inbound, email)
+	if c == nil {
+		t.Fatalf("клиент %s отсутствует в панели: %v", email, e.panel.Clients(e.inbound))
+	}
+	return c
+}
+
+func testSuspiciousClientDisabled(t *testing.T) {
+	e := newEnv(t, false,
+		vlessClient("11111111-1111-1111-1111-111111111111", "abuser", true),
+		vlessClient("22222222-2222-2222-2222-222222222222", "normal", true))
+	e.writeAccessLog(t, map[string][]string{
+		"abuser": {"203.0.113.1", "203.0.113.2", "203.0.113.3"},
+		"normal": {"198.51.100.1"},
+	})
+
+	e.service.CheckNow()
+
+	abuser := e.client(t, "abuser")
+	if abuser["enable"] != false {
+		t.Errorf("нарушитель не отключен: %v", abuser)
+	}
+	if abuser["id"] == "11111111-1111-1111-1111-111111111111" {
+		t.Errorf("UUID нарушителя не сменён: %v", abuser)
+	}
+	if abuser["depleted"] != true || abuser["subId"] != "sub-11111111-1111-1111-1111-111111111111" {
+		t.Errorf("ожидались depleted=true и сохранённый subId: %v", abuser)
+	}
+	if ban := e.service.GetBan("abuser"); ban == nil {
+		t.Errorf("бан нарушителя не создан")
+	}
+	if normal := e.client(t, "normal"); normal["enable"] != true || normal["id"] != "22222222-2222-2222-2222-222222222222" {
+		t.Errorf("нормальный клиент изменён: %v", normal)
+	}
+	// С API клиентов полные обновления inbound не нужны
+	if n := e.panel.Count("POST /panel/api/inbounds/update/"); n != 0 {
+		t.Errorf("полных обновлений inbound: %d, ожидалось 0: %v", n, e.panel.Requests())
+	}
+}
+
+func testLegacyPanelFallback(t *testing.T) {
+	e := newEnv(t, true, vlessClient("11111111-1111-1111-1111-111111111111", "abuser", true))
+	e.writeAccessLog(t, map[string][]string{"abuser": {"203.0.113.1", "203.0.113.2", "203.0.113.3"}})
+
+	e.service.CheckNow()
+
+	abuser := e.client(t, "abuser")
+	if abuser["enable"] != false || abuser["id"] == "11111111-1111-1111-1111-111111111111" {
+		t.Errorf("нарушитель не отключен или UUID не сменён: %v", abuser)
+	}
+	if e.panel.Count("POST /panel/api/inbounds/update/") == 0 {
+		t.Errorf("старая панель: ожидалось полное обновление inbound: %v", e.panel.Requests())
+	}
+}
+
+func testIdleClientsEnabledInOneWrite(t *testing.T) {
+	e := newEnv(t, false,
+		vlessClient("11111111-1111-1111-1111-111111111111", "idle1", false),
+		vlessClient("22222222-2222-2222-2222-222222222222", "idle2", false),
+		vlessClient("33333333-3333-3333-3333-333333333333", "idle3", false))
+	e.disabledByService(t, "idle1", "idle2", "idle3")
+	e.writeAccessLog(t, map[string][]string{})
+
+	e.service.CheckNow()
+
+	for _, email := range []string{"idle1", "idle2", "idle3"} {
+		if c := e.client(t, email); c["enable"] != true {
+			t.Errorf("клиент %s не включен: %v", email, c)
+		}
+	}
+	// Одна запись изменений и два обновления жёсткого ресета Remark
+	if n := e.panel.Count("POST /panel/api/inbounds/update/"); n != 3 {
+		t.Errorf("обновлений inbound: %d, ожидалось 3 (запись + ресет): %v", n, e.panel.Requests())
+	}
+	if n := e.panel.Count("POST /panel/api/inbounds/updateClient/"); n != 0 {
+		t.Errorf("пакет не должен идти поклиентно: %d запросов updateClient", n)
+	}
+}
+
+func testUnbanRestoresClient(t *testing.T) {
+	c := vlessClient("11111111-1111-1111-1111-111111111111", "user", false)
+	c["depleted"], c["exhausted"] = true, true
+	e := newEnv(t, false, c)
+	if _, err := e.service.BanManager.BanUserFor("user", "проверка", []string{"203.0.113.1"}, time.Hour); err != nil {
+		t.Fatalf("BanUserFor: %v", err)
+	}
+	e.writeAccessLog(t, map[string][]string{"user": {"203.0.113.1"}})
+
+	e.service.CheckNow()
+
+	if ban := e.service.GetBan("user"); ban != nil {
+		t.Errorf("бан не снят: %+v", ban)
+	}
+	got := e.client(t, "user")
+	if got["enable"] != true || got["depleted"] != false || got["exhausted"] != false {
+		t.Errorf("клиент не восстановлен после разбана: %v", got)
+	}
+	// +1 день к подписке записывается тем же циклом, а не отложенным таймером
+	if expiry, _ := got["expiryTime"].(float64); time.UnixMilli(int64(expiry)).Before(time.Now().Add(23 * time.Hour)) {
+		t.Errorf("+1 день не добавлен после разбана: expiryTime=%v", got["expiryTime"])
+	}
+}
+
+func testExpiredSessionRelogin(t *testing.T) {
+	e := newEnv(t, false, vlessClient("11111111-1111-1111-1111-111111111111", "idle", false))
+	e.disabledByService(t, "idle")
+	e.writeAccessLog(t, map[string][]string{})
+	e.panel.ExpireSessions()
+
+	e.service.CheckNow()
+
+	if e.panel.Count("POST /login") != 1 {
+		t.Errorf("ожидался один повторный вход: %v", e.panel.Requests())
+	}
+	if c := e.client(t, "idle"); c["enable"] != true {
+		t.Errorf("клиент не включен после повторного входа: %v", c)
+	}
+}
+
+func testPanelUnavailable(t *testing.T) {
+	e := newEnv(t, false, vlessClient("11111111-1111-1111-1111-111111111111", "abuser", true))
+	e.writeAccessLog(t, map[string][]string{"abuser": {"203.0.113.1", "203.0.113.2", "203.0.113.3"}})
+	e.panel.Fail("GET /panel/api/inbounds/", 503, 0)
+
+	e.service.CheckNow()
+
+	// Без списка клиентов цикл прерывается до решений: ни бана, ни изменений
+	if ban := e.service.GetBan("abuser"); ban != nil {
+		t.Errorf("бан создан при недоступной панели: %+v", ban)
+	}
+	if c := e.client(t, "abuser"); c["enable"] != true {
+		t.Errorf("клиент изменён при недоступной панели: %v", c)
+	}
+	if n := e.panel.Count("GET /panel/api/inbounds/"); n < 2 {
+		t.Errorf("ожидались повторы запроса при 503, было %d", n)
+	}
+}
+
+func testTrafficAbuseBans(t *testing.T) {
+	prev := ipban.TRAFFIC_ABUSE_GB
+	ipban.TRAFFIC_ABUSE_GB = 1
+	t.Cleanup(func() { ipban.TRAFFIC_ABUSE_GB = prev })
+
+	e := newEnv(t, false,
+		vlessClient("11111111-1111-1111-1111-111111111111", "heavy", true),
+		vlessClient("22222222-2222-2222-2222-222222222222", "light", true))
+	e.writeAccessLog(t, map[string][]string{"heavy": {"203.0.113.1"}, "light": {"198.51.100.1"}})
+
+	// Первый цикл — исходные показания; расход считается со второго
+	e.panel.AddTraffic(e.inbound, "heavy", 0, 5<<30)
+	e.service.CheckNow()
+	if ban := e.service.GetBan("heavy"); ban != nil {
+		t.Fatalf("бан по трафику без предыдущего показания: %+v", ban)
+	}
+
+	e.panel.AddTraffic(e.inbound, "heavy", 1<<29, 2<<30)
+	e.panel.AddTraffic(e.inbound, "light", 0, 100<<20)
+	e.service.CheckNow()
+
+	ban := e.service.GetBan("heavy")
+	if ban == nil || !strings.Contains(ban.Reason, "трафика") {
+		t.Fatalf("ожидался бан за расход трафика: %+v", ban)
+	}
+	if c := e.client(t, "heavy"); c["enable"] != false {
+		t.Errorf("клиент с подозрительным расходом не отключен: %v", c)
+	}
+	if ban := e.service.GetBan("light"); ban != nil {
+		t.Errorf("клиент с обычным расходом забанен: %+v", ban)
+	}
+	usage, ok := e.service.Traffic.Usage(e.service.BanManager.KeyFor("light"))
+	if !ok || usage.Delta != 100<<20 || usage.Window != 100<<20 {
+		t.Errorf("расход light: %+v, %v", usage, ok)
+	}
+
+	// Пока расход за окно выше порога, нормальное число IP не снимает бан
+	e.service.CheckNow()
+	if ban := e.service.GetBan("heavy"); ban == nil {
+		t.Errorf("бан за трафик снят досрочно")
+	}
+}
+
+func testDepletedStaysDisabled(t *testing.T) {
+	c := vlessClient("11111111-1111-1111-1111-111111111111", "capped", false)
+	c["totalGB"] = 1 << 30
+	c["depleted"] = true
+	e := newEnv(t, false, c)
+	e.panel.AddTraffic(e.inbound, "capped", 0, 1<<30)
+	if _, err := e.service.BanManager.BanUserFor("capped", "проверка", []string{"203.0.113.1"}, time.Hour); err != nil {
+		t.Fatalf("BanUserFor: %v", err)
+	}
+	e.writeAccessLog(t, map[string][]string{"capped": {"203.0.113.1"}})
+
+	e.service.CheckNow()
+
+	if ban := e.service.GetBan("capped"); ban != nil {
+		t.Errorf("бан не снят: %+v", ban)
+	}
+	if got := e.client(t, "capped"); got["enable"] != false || got["depleted"] != true {
+		t.Errorf("исчерпавший трафик клиент включен после разбана: %v", got)
+	}
+}
+
+func testBanFollowsRename(t *testing.T) {
+	e := newEnv(t, false, vlessClient("11111111-1111-1111-1111-111111111111", "abuser", true))
+	if _, err := e.service.ManualBan("abuser", time.Hour, "проверка"); err != nil {
+		t.Fatalf("ManualBan: %v", err)
+	}
+
+	results := e.service.ManageClients("Переименование", func(cm *panel.ConfigManager) []lifecycle.Result {
+		return lifecycle.RenameMany(cm, []lifecycle.Renaming{{From: "abuser", To: "renamed"}}, 0)
+	})
+	if lifecycle.Failed(results) != 0 || !results[0].Changed {
+		t.Fatalf("переименование: %+v", results)
+	}
+	if c := e.client(t, "renamed"); c["subId"] != "sub-11111111-1111-1111-1111-111111111111" {
+		t.Errorf("subId изменился: %v", c)
+	}
+	// Таблица идентичностей обновлена сразу, без цикла проверки: бан виден под новым email
+	if ban := e.service.GetBan("renamed"); ban == nil {
+		t.Errorf("бан не последовал за переименованием")
+	}
+}
+
+// resetLeftover — клиент, оставшийся после фазы A агрессивного сброса: "-reset" email, отключен, "исчерпан"
+func resetLeftover(id, email string) map[string]interface{} {
+	c := vlessClient(id, email+"-reset", false)
+	c["depleted"], c["exhausted"] = true, true
+	c["subId"] = "sub-" + email
+	return c
+}
+
+func testInterruptedResetFinished(t *testing.T) {
+	e := newEnv(t, false, resetLeftover("11111111-1111-1111-1111-111111111111", "abuser"))
+	if _, err := e.service.BanManager.BanUserFor("abuser", "проверка", nil, time.Hour); err != nil {
+		t.Fatalf("BanUserFor: %v", err)
+	}
+
+	if n := e.service.RecoverInterruptedResets(); n != 1 {
+		t.Fatalf("исправлено: %d, ожидался 1", n)
+	}
+	c := e.client(t, "abuser")
+	if c["enable"] != false || c["depleted"] != true || c["exhausted"] != true || c["subId"] != "sub-abuser" {
+		t.Errorf("сброс забаненного клиента не завершён: %v", c)
+	}
+	if e.panel.Client(e.inbound, "abuser-reset") != nil {
+		t.Errorf("-reset email остался")
+	}
+	if e.service.GetBan("abuser") == nil {
+		t.Errorf("бан должен сохраниться")
+	}
+}
+
+func testInterruptedResetUndone(t *testing.T) {
+	e := newEnv(t, false,
+		resetLeftover("11111111-1111-1111-1111-111111111111", "user"),
+		vlessClient("22222222-2222-2222-2222-222222222222", "taken", true),
+		resetLeftover("33333333-3333-3333-3333-333333333333", "taken"))
+
+	// Цикл проверки исправляет следы сброса до анализа: бана нет — сброс отменяется
+	e.writeAccessLog(t, map[string][]string{})
+	e.service.CheckNow()
+
+	c := e.client(t, "user")
+	if c["depleted"] != false || c["exhausted"] != false {
+		t.Errorf("статус 'исчерпано' не снят: %v", c)
+	}
+	// Исходный email занят другим клиентом — такой клиент не трогается
+	if e.panel.Client(e.inbound, "taken-reset") == nil {
+		t.Errorf("клиент с занятым исходным email не должен переименовываться: %v", e.panel.Clients(e.inbound))
+	}
+}
+
+func testMismatchedDepletedFlags(t *testing.T) {
+	banned := vlessClient("11111111-1111-1111-1111-111111111111", "banned", false)
+	banned["depleted"], banned["exhausted"] = true, false
+	unbanned := vlessClient("22222222-2222-2222-2222-222222222222", "unbanned", true)
+	unbanned["depleted"] = true
+	// Флаги выставила сама панель (или ProxyMaster): сервис клиента не трогал
+	panelSet := vlessClient("33333333-3333-3333-3333-333333333333", "panelset", true)
+	panelSet["exhausted"] = true
+	e := newEnv(t, false, banned, unbanned, panelSet)
+	e.disabledByService(t, "unbanned")
+	if _, err := e.service.BanManager.BanUserFor("banned", "проверка", nil, time.Hour); err != nil {
+		t.Fatalf("BanUserFor: %v", err)
+	}
+
+	if n := e.service.RecoverInterruptedResets(); n != 2 {
+		t.Fatalf("исправлено: %d, ожидалось 2", n)
+	}
+	if c := e.client(t, "banned"); c["depleted"] != true || c["exhausted"] != true || c["enable"] != false {
+		t.Errorf("флаги забаненного клиента не выровнены: %v", c)
+	}
+	if c := e.client(t, "unbanned"); c["depleted"] != false || c["exhausted"] != false || c["enable"] != true {
+		t.Errorf("флаги клиента без бана не сняты: %v", c)
+	}
+	if c := e.client(t, "panelset"); c["depleted"] != nil || c["exhausted"] != true {
+		t.Errorf("сняты флаги, которые сервис не ставил: %v", c)
+	}
+}
+
+func testAdminDisabledStaysDisabled(t *testing.T) {
+	expired := vlessClient("33333333-3333-3333-3333-333333333333", "expired", false)
+	expired["expiryTime"] = time.Now().Add(-time.Hour).UnixMilli()
+	e := newEnv(t, false,
+		vlessClient("11111111-1111-1111-1111-111111111111", "unpaid", false),
+		vlessClient("22222222-2222-2222-2222-222222222222", "active", false),
+		expired)
+	// expired отключил сервис, но срок истёк — включать его нельзя
+	e.disabledByService(t, "expired")
+	e.writeAccessLog(t, map[string][]string{"active": {"198.51.100.1"}})
+
+	e.service.CheckNow()
+
+	for _, email := range []string{"unpaid", "active", "expired"} {
+		if c := e.client(t, email); c["enable"] != false {
+			t.Errorf("клиент %s включен сервисом: %v", email, c)
+		}
+	}
+	if n := e.panel.Count("POST /panel/api/inbounds/update"); n != 0 {
+		t.Errorf("ожидалось ни одной записи в панель: %v", e.panel.Requests())
+	}
+}
+
+func testServiceDisableReenabled(t *testing.T) {
+	e := newEnv(t, false, vlessClient("11111111-1111-1111-1111-111111111111", "abuser", true))
+	if _, err := e.service.ManualBan("abuser", time.Hour, "проверка"); err != nil {
+		t.Fatalf("ManualBan: %v", err)
+	}
+	report := e.service.UserReport("abuser")
+	if report.ServiceDisable == nil || report.ServiceDisable.Reason != "проверка" {
+		t.Fatalf("отключение сервисом не записано: %+v", report.ServiceDisable)
+	}
+
+	// Бан истёк и удалён до фазы разбана: конфиг включается как отключенный сервисом
+	if err := e.service.BanManager.UnbanUser("abuser"); err != nil {
+		t.Fatalf("UnbanUser: %v", err)
+	}
+	e.writeAccessLog(t, map[string][]string{})
+	e.service.CheckNow()
+
+	if c := e.client(t, "abuser"); c["enable"] != true {
+		t.Errorf("клиент, отключенный сервисом, не включен: %v", c)
+	}
+	if report := e.service.UserReport("abuser"); report.ServiceDisable != nil {
+		t.Errorf("запись об отключении не удалена после включения: %+v", report.ServiceDisable)
+	}
+
+	// Теперь администратор отключает конфиг в панели — сервис его не трогает
+	if err := client.PatchClient(e.service.ConfigManager, client.MatchEmail("abuser"), client.PatchEnable(false)); err != nil {
+		t.Fatalf("отключение администратором: %v", err)
+	}
+	e.service.CheckNow()
+	if c := e.client(t, "abuser"); c["enable"] != false {
+		t.Errorf("клиент, отключенный администратором, включен сервисом: %v", c)
+	}
+}
+
+func testManualChangesPrecedenceOff(t *testing.T) {
+	prev := ipban.MANUAL_CHANGES_PRECEDENCE
+	ipban.MANUAL_CHANGES_PRECEDENCE = false
+	t.Cleanup(func() { ipban.MANUAL_CHANGES_PRECEDENCE = prev })
+
+	expired := vlessClient("22222222-2222-2222-2222-222222222222", "expired", false)
+	expired["expiryTime"] = time.Now().Add(-time.Hour).UnixMilli()
+	e := newEnv(t, false, vlessClient("11111111-1111-1111-1111-111111111111", "idle", false), expired)
+	e.writeAccessLog(t, map[string][]string{})
+
+	e.service.CheckNow()
+
+	if c := e.client(t, "idle"); c["enable"] != true {
+		t.Errorf("без приоритета ручных изменений отключенный клиент должен включаться: %v", c)
+	}
+	if c := e.client(t, "expired"); c["enable"] != false {
+		t.Errorf("истёкший по сроку клиент включен сервисом: %v", c)
+	}
+}
+
+// Обновление с версии без журнала отключений: конфиг отключён прежней версией, бан есть в хранилище, журнал пуст
+func testLedgerSeededFromExistingBans(t *testing.T) {
+	e := newEnv(t, false, vlessClient("11111111-1111-1111-1111-111111111111", "upgraded", false))
+	if _, err := e.service.BanManager.BanUserFor("upgraded", "проверка", nil, time.Millisecond); err != nil {
+		t.Fatalf("BanUserFor: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	e.writeAccessLog(t, map[string][]string{})
+
+	e.service.CheckNow()
+
+	if c := e.client(t, "upgraded"); c["enable"] != true {
+		t.Errorf("конфиг, отключенный прежней версией, не включен после истечения бана: %v", c)
+	}
+}
@@ -0,0 +1,52 @@
+package ipban
+
+import "ipBanSystem/ipBan/logger/initLogs"
+
+// recordDisable заносит в журнал отключений конфиг, который сервис отключает (агрессивный сброс при бане).
+// Запись делается до обращения к панели: если сброс прервётся, конфиг всё равно считается отключенным сервисом.
+func (s *IPBanService) recordDisable(email, reason string) {
+	if s.Disables != nil {
+		s.Disables.Record(s.BanManager.KeyFor(email), email, reason)
+	}
+}
+
+// seedDisables переносит в новый журнал отключений баны конфигов из хранилища (один раз, см. DisableLedger.Seed).
+// Конфиги, которые отключила прежняя версия сервиса без журнала, иначе считались бы отключенными вручную
+// и не включались бы и после истечения бана. Вызывается после MigrateKeys: записи получают стабильные ключи.
+func (s *IPBanService) seedDisables() {
+	if s.Disables == nil {
+		return
+	}
+	s.BanManager.mutex.RLock()
+	bans := s.BanManager.listBans()
+	s.BanManager.mutex.RUnlock()
+
+	records := make(map[string]*DisableRecord, len(bans))
+	for key, ban := range bans {
+		// Бан по IP и ограничение скорости конфиг не отключают
+		if ban.Enforcement == EnforcementPerIP || ban.Enforcement == EnforcementThrottle {
+			continue
+		}
+		records[key] = &DisableRecord{Email: ban.Email, Reason: ban.Reason, DisabledAt: ban.BannedAt}
+	}
+	if n := s.Disables.Seed(records); n > 0 {
+		initLogs.LogIPBanInfo("Журнал отключений заполнен по банам прежней версии: %d", n)
+	}
+}
+
+// forgetDisable удаляет запись об отключении: конфиг снова включен (сервисом или администратором)
+func (s *IPBanService) forgetDisable(email string) {
+	if s.Disables != nil {
+		s.Disables.Forget(s.BanManager.KeyFor(email))
+	}
+}
+
+// enableBlocked возвращает причину, по которой сервис не должен включать отключенный конфиг без нарушений
+// (пусто — можно включать). Истёкший по сроку или исчерпавший трафик в панели конфиг не включается никогда;
+// при MANUAL_CHANGES_PRECEDENCE включаются, кроме того, только конфиги из журнала отключений.
+func (s *IPBanService) enableBlocked(email string) string {
+	if MANUAL_CHANGES_PRECEDENCE && (s.Disables == nil || s.Disables.Get(s.BanManager.KeyFor(email)) == nil) {
+		return "отключен не сервисом (ручное изменение в панели)"
+	}
+	return s.panelCapReached(email)
+}
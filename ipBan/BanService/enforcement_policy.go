@@ -0,0 +1,105 @@
+package ipban
+
+import (
+	"ipBanSystem/ipBan/logger/analyzerLogs"
+	"ipBanSystem/ipBan/logger/initLogs"
+)
+
+// Способы наказания за превышение лимита IP (значения ENFORCEMENT_MODE и BanInfo.Enforcement)
+const (
+	// EnforcementAccount — бан всего конфига (отключение и агрессивный сброс в панели)
+	EnforcementAccount = "account"
+	// EnforcementPerIP — блокировка на файрволе только лишних IP
+	EnforcementPerIP = "per_ip"
+	// EnforcementThrottle — ограничение скорости для IP пользователя вместо бана
+	EnforcementThrottle = "throttle"
+	// EnforcementEscalate — политика (только для ENFORCEMENT_MODE): троттлинг за первые нарушения,
+	// бан (ESCALATE_BAN_MODE) за повторные
+	EnforcementEscalate = "escalate"
+)
+
+// applyPolicy применяет наказание по ENFORCEMENT_MODE.
+// Возвращает false, если нужен бан конфига (EnforcementAccount) — его выполняет вызывающий код.
+func (s *IPBanService) applyPolicy(stats *analyzerLogs.EmailIPStats) bool {
+	switch ENFORCEMENT_MODE {
+	case EnforcementPerIP:
+		s.enforcePerIP(stats)
+		return true
+	case EnforcementThrottle:
+		s.enforceThrottle(stats)
+		return true
+	case EnforcementEscalate:
+		offenses := 1
+		if s.Offenses != nil {
+			offenses = s.Offenses.Record(s.BanManager.KeyFor(stats.Email))
+		}
+		if offenses <= ESCALATE_THROTTLE_OFFENSES {
+			initLogs.LogIPBanInfo("   🐢 Нарушение %d из %d допустимых — троттлинг для %s",
+				offenses, ESCALATE_THROTTLE_OFFENSES, stats.Email)
+			s.enforceThrottle(stats)
+			return true
+		}
+		initLogs.LogIPBanInfo("   ⛔ Повторное нарушение (%d) — бан %s для %s", offenses, ESCALATE_BAN_MODE, stats.Email)
+		// Бан заменяет троттлинг: снимаем ограничения, чтобы не держать два наказания
+		s.liftThrottle(stats.Email)
+		if ESCALATE_BAN_MODE == EnforcementPerIP {
+			s.enforcePerIP(stats)
+			return true
+		}
+		return false
+	default:
+		return false
+	}
+}
+
+// enforceThrottle ограничивает скорость для всех IP пользователя, не отключая конфиг.
+// Ограниченные IP записываются в наказание пользователя (BanInfo.ThrottledIPs) и снимаются по его истечении.
+func (s *IPBanService) enforceThrottle(stats *analyzerLogs.EmailIPStats) {
+	if s.Throttler == nil {
+		initLogs.LogIPBanError("❌ Троттлинг для %s невозможен: ограничитель скорости не инициализирован", stats.Email)
+		return
+	}
+
+	var already []string
+	if ban := s.BanManager.GetBanInfo(stats.Email); ban != nil && ban.Enforcement == EnforcementThrottle {
+		already = ban.ThrottledIPs
+	}
+	var seen, fresh []string
+	for ip := range stats.IPs {
+		seen = append(seen, ip)
+		if !containsString(already, ip) {
+			fresh = append(fresh, ip)
+		}
+	}
+	if len(fresh) == 0 {
+		return
+	}
+
+	duration := defaultThrottleTimeout()
+	if err := s.Throttler.ThrottleIPs(fresh, duration); err != nil {
+		initLogs.LogIPBanError("❌ Ошибка троттлинга %s: %v", stats.Email, err)
+		return
+	}
+	reason := s.violationReason(stats) + ", ограничена скорость"
+	if _, err := s.BanManager.BanIPs(stats.Email, EnforcementThrottle, reason, seen, fresh, duration); err != nil {
+		initLogs.LogIPBanError("Ошибка сохранения троттлинга %s: %v", stats.Email, err)
+		return
+	}
+	initLogs.LogIPBanInfo("   🐢 Для %s ограничена скорость на %d IP, конфиг остаётся включенным", stats.Email, len(fresh))
+}
+
+// liftThrottle снимает троттлинг пользователя (ограничения и запись наказания), если он есть
+func (s *IPBanService) liftThrottle(email string) {
+	ban := s.BanManager.GetBanInfo(email)
+	if ban == nil || ban.Enforcement != EnforcementThrottle {
+		return
+	}
+	if s.Throttler != nil {
+		if err := s.Throttler.UnthrottleIPs(ban.ThrottledIPs); err != nil {
+			initLogs.LogIPBanError("Ошибка снятия троттлинга %s: %v", email, err)
+		}
+	}
+	if err := s.BanManager.UnbanUser(email); err != nil {
+		initLogs.LogIPBanError("Ошибка удаления троттлинга %s: %v", email, err)
+	}
+}
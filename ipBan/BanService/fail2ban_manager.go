@@ -0,0 +1,228 @@
+package ipban
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"ipBanSystem/ipBan/logger/initLogs"
+)
+
+// fail2banClient — утилита управления fail2ban
+const fail2banClient = "fail2ban-client"
+
+// Fail2banFirewall передаёт блокировку IP в jail fail2ban (fail2ban-client set <jail> banip/unbanip).
+// Нужен на хостах, где fail2ban уже управляет файрволом: действие бана (banaction), порты и bantime
+// задаются в jail (см. other/fail2ban/jail.d/ipban.conf), поэтому область блокировки и timeout не используются.
+// Разблокировку по истечении бана выполняет сверка (Reconcile) или сам fail2ban по bantime.
+// Jail может банить IP и сам, по журналу нарушений (LOG_VIOLATIONS): поэтому IP, забаненные через banip,
+// сохраняются в отдельный файл (StatePath), и сверка управляет только ими.
+type Fail2banFirewall struct {
+	Jail       string
+	StatePath  string          // Файл с IP, которые забанил сам сервис
+	blockedIPs map[string]bool // IP, забаненные сервисом и ещё забаненные в jail
+	mutex      sync.RWMutex
+}
+
+// NewFail2banFirewall проверяет, что jail существует, и создаёт файрвол поверх него
+// с сохранённым списком IP, забаненных сервисом (statePath)
+func NewFail2banFirewall(jail, statePath string) (*Fail2banFirewall, error) {
+	f := &Fail2banFirewall{Jail: jail, StatePath: statePath, blockedIPs: make(map[string]bool)}
+	if _, err := f.listBanned(); err != nil {
+		return nil, err
+	}
+	if data, err := os.ReadFile(statePath); err == nil && len(data) > 0 {
+		var ips []string
+		if err := json.Unmarshal(data, &ips); err != nil {
+			initLogs.LogIPBanError("Ошибка загрузки списка IP fail2ban %s: %v", statePath, err)
+		}
+		for _, ip := range ips {
+			f.blockedIPs[ip] = true
+		}
+	}
+	initLogs.LogIPBanInfo("fail2ban: блокировка через jail %s (порты и bantime задаются в jail)", jail)
+	return f, nil
+}
+
+// BlockIP блокирует один IP в jail
+func (f *Fail2banFirewall) BlockIP(ipAddress string) error {
+	return f.BlockIPs([]string{ipAddress}, defaultBlockTimeout())
+}
+
+// UnblockIP снимает блокировку одного IP
+func (f *Fail2banFirewall) UnblockIP(ipAddress string) error {
+	return f.UnblockIPs([]string{ipAddress})
+}
+
+// BlockIPs блокирует несколько IP одной командой banip; timeout не используется (действует bantime jail)
+func (f *Fail2banFirewall) BlockIPs(ipAddresses []string, timeout time.Duration) error {
+	v4, v6, err := splitIPFamilies(ipAddresses)
+	if err != nil {
+		return err
+	}
+	all := append(v4, v6...)
+	if len(all) == 0 {
+		return nil
+	}
+
+	args := append([]string{"set", f.Jail, "banip"}, all...)
+	if out, err := runCommand(fail2banClient, args...); err != nil {
+		initLogs.LogIPBanError("Ошибка блокировки %d IP через fail2ban: %v", len(all), err)
+		return fmt.Errorf("ошибка блокировки IP через fail2ban (jail %s): %v: %s", f.Jail, err, strings.TrimSpace(string(out)))
+	}
+
+	f.mutex.Lock()
+	for _, ip := range all {
+		f.blockedIPs[ip] = true
+	}
+	f.saveLocked()
+	f.mutex.Unlock()
+	for _, ip := range all {
+		initLogs.LogIPBanAction("IP_ЗАБЛОКИРОВАН", ip, 0, []string{})
+	}
+	return nil
+}
+
+// UnblockIPs снимает блокировку нескольких IP одной командой unbanip
+func (f *Fail2banFirewall) UnblockIPs(ipAddresses []string) error {
+	v4, v6, err := splitIPFamilies(ipAddresses)
+	if err != nil {
+		return err
+	}
+	all := append(v4, v6...)
+	if len(all) == 0 {
+		return nil
+	}
+
+	args := append([]string{"set", f.Jail, "unbanip"}, all...)
+	if out, err := runCommand(fail2banClient, args...); err != nil {
+		// fail2ban отвечает ошибкой, если IP уже не забанен (истёк bantime) — это не сбой
+		if !strings.Contains(string(out), "is not banned") {
+			initLogs.LogIPBanError("Ошибка разблокировки %d IP через fail2ban: %v", len(all), err)
+			return fmt.Errorf("ошибка разблокировки IP через fail2ban (jail %s): %v: %s", f.Jail, err, strings.TrimSpace(string(out)))
+		}
+	}
+
+	f.mutex.Lock()
+	for _, ip := range all {
+		delete(f.blockedIPs, ip)
+	}
+	f.saveLocked()
+	f.mutex.Unlock()
+	for _, ip := range all {
+		initLogs.LogIPBanAction("IP_РАЗБЛОКИРОВАН", ip, 0, []string{})
+	}
+	return nil
+}
+
+// IsIPBlocked проверяет, заблокирован ли IP сервисом (по состоянию на момент последней сверки)
+func (f *Fail2banFirewall) IsIPBlocked(ipAddress string) bool {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	return f.blockedIPs[ipAddress]
+}
+
+// GetBlockedIPs возвращает список заблокированных IP
+func (f *Fail2banFirewall) GetBlockedIPs() []string {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	ips := make([]string, 0, len(f.blockedIPs))
+	for ip := range f.blockedIPs {
+		ips = append(ips, ip)
+	}
+	sort.Strings(ips)
+	return ips
+}
+
+// Reconcile читает забаненные IP jail и сверяет с активными банами те из них, которые забанил сервис.
+// Баны, которые jail поставил сам (по журналу нарушений), в сверку не попадают и не снимаются;
+// IP сервиса, уже разбаненные jail по bantime, забываются.
+func (f *Fail2banFirewall) Reconcile(expected map[string]time.Time, apply bool) (*FirewallDrift, error) {
+	banned, err := f.listBanned()
+	if err != nil {
+		return nil, err
+	}
+	found := make(map[string]int, len(banned))
+	f.mutex.Lock()
+	own := make(map[string]bool, len(f.blockedIPs))
+	for _, ip := range banned {
+		if f.blockedIPs[ip] {
+			found[ip] = 1
+			own[ip] = true
+		}
+	}
+	f.blockedIPs = own
+	f.saveLocked()
+	f.mutex.Unlock()
+
+	drift := classifyBlocked(found, expected)
+	drift.Backend = FirewallFail2ban + "/" + f.Jail
+	if !apply {
+		return drift, nil
+	}
+
+	var failed []string
+	if err := f.UnblockIPs(drift.Stale); err != nil {
+		failed = append(failed, drift.Stale...)
+	}
+	failed = append(failed, restoreMissing(f, drift.Missing, expected)...)
+	drift.Applied = true
+	if len(failed) > 0 {
+		return drift, fmt.Errorf("не удалось исправить %d блокировок: %s", len(failed), strings.Join(failed, ", "))
+	}
+	return drift, nil
+}
+
+// saveLocked атомарно записывает список IP, забаненных сервисом (через временный файл и rename).
+// Ошибка только логируется: блокировка в jail уже выполнена.
+func (f *Fail2banFirewall) saveLocked() {
+	if f.StatePath == "" {
+		return
+	}
+	ips := make([]string, 0, len(f.blockedIPs))
+	for ip := range f.blockedIPs {
+		ips = append(ips, ip)
+	}
+	sort.Strings(ips)
+	data, err := json.MarshalIndent(ips, "", "  ")
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(f.StatePath), 0o755)
+	}
+	if err == nil {
+		tmp := f.StatePath + ".tmp"
+		if err = os.WriteFile(tmp, data, 0o644); err == nil {
+			err = os.Rename(tmp, f.StatePath)
+		}
+	}
+	if err != nil {
+		initLogs.LogIPBanError("Ошибка сохранения списка IP fail2ban %s: %v", f.StatePath, err)
+	}
+}
+
+// listBanned читает список забаненных IP jail из "fail2ban-client status <jail>"
+func (f *Fail2banFirewall) listBanned() ([]string, error) {
+	out, err := runCommand(fail2banClient, "status", f.Jail)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения jail %s: %v: %s", f.Jail, err, strings.TrimSpace(string(out)))
+	}
+	// Строка вида: "   `- Banned IP list:	203.0.113.7 2001:db8::7"
+	var ips []string
+	for _, line := range strings.Split(string(out), "\n") {
+		_, list, ok := strings.Cut(line, "Banned IP list:")
+		if !ok {
+			continue
+		}
+		for _, field := range strings.Fields(list) {
+			if parsed := net.ParseIP(field); parsed != nil {
+				ips = append(ips, parsed.String())
+			}
+		}
+	}
+	return ips, nil
+}
@@ -0,0 +1,58 @@
+package ipban_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	ipban "ipBanSystem/ipBan/BanService"
+	"ipBanSystem/ipBan/runner"
+)
+
+// jailStatus — вывод "fail2ban-client status ipban" со списком забаненных IP
+func jailStatus(ips string) runner.Response {
+	return runner.Response{Stdout: "Status for the jail: ipban\n" +
+		"`- Actions\n" +
+		"   |- Currently banned:\t2\n" +
+		"   `- Banned IP list:\t" + ips + "\n"}
+}
+
+func TestFail2banReconcileKeepsJailBans(t *testing.T) {
+	fake := runner.NewScriptedRunner()
+	withRunner(t, fake)
+	state := filepath.Join(t.TempDir(), "fail2ban_banned.json")
+	fake.On("fail2ban-client status ipban", jailStatus(""))
+
+	f, err := ipban.NewFail2banFirewall("ipban", state)
+	if err != nil {
+		t.Fatalf("NewFail2banFirewall: %v", err)
+	}
+	if err := f.BlockIPs([]string{"203.0.113.2"}, time.Hour); err != nil {
+		t.Fatalf("BlockIPs: %v", err)
+	}
+
+	// После перезапуска: 203.0.113.1 jail забанил сам по журналу нарушений, 203.0.113.2 — сервис, бан истёк
+	fake = runner.NewScriptedRunner()
+	withRunner(t, fake)
+	fake.On("fail2ban-client status ipban", jailStatus("203.0.113.1 203.0.113.2"))
+	f, err = ipban.NewFail2banFirewall("ipban", state)
+	if err != nil {
+		t.Fatalf("NewFail2banFirewall: %v", err)
+	}
+	drift, err := f.Reconcile(map[string]time.Time{}, true)
+	if err != nil {
+		t.Fatalf("Reconcile: %v", err)
+	}
+	if len(drift.Stale) != 1 || drift.Stale[0] != "203.0.113.2" {
+		t.Errorf("Stale = %v, ожидался только IP сервиса", drift.Stale)
+	}
+	if fake.Count("fail2ban-client set ipban unbanip 203.0.113.2") != 1 {
+		t.Errorf("IP сервиса без бана не разбанен: %v", fake.Commands())
+	}
+	if fake.Count("fail2ban-client set ipban unbanip 203.0.113.1") != 0 {
+		t.Errorf("снят бан, который поставил jail: %v", fake.Commands())
+	}
+	if f.IsIPBlocked("203.0.113.1") {
+		t.Errorf("бан jail считается блокировкой сервиса")
+	}
+}
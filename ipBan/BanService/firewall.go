@@ -0,0 +1,103 @@
+package ipban
+
+import (
+	"fmt"
+	"time"
+)
+
+// Firewall — общий интерфейс блокировки IP на уровне хоста.
+// Реализации: IPTablesManager (iptables — правило на IP или наборы ipset), NFTablesManager (nftables с наборами)
+// и Fail2banFirewall (блокировка через jail fail2ban).
+type Firewall interface {
+	// BlockIP блокирует один IP на длительность бана по умолчанию (IP_BAN_DURATION)
+	BlockIP(ipAddress string) error
+	// UnblockIP снимает блокировку одного IP
+	UnblockIP(ipAddress string) error
+	// BlockIPs блокирует несколько IP одной операцией; timeout <= 0 — без автоматического истечения
+	BlockIPs(ipAddresses []string, timeout time.Duration) error
+	// UnblockIPs снимает блокировку нескольких IP одной операцией
+	UnblockIPs(ipAddresses []string) error
+	// IsIPBlocked проверяет, заблокирован ли IP
+	IsIPBlocked(ipAddress string) bool
+	// GetBlockedIPs возвращает список заблокированных IP
+	GetBlockedIPs() []string
+	// Reconcile читает фактические блокировки с хоста, восстанавливает по ним внутреннее состояние
+	// и сверяет с ожидаемым (IP -> момент истечения бана). apply=true удаляет блокировки без бана.
+	Reconcile(expected map[string]time.Time, apply bool) (*FirewallDrift, error)
+}
+
+// Поддерживаемые бэкенды файрвола (значения FIREWALL_BACKEND)
+const (
+	FirewallIPTables = "iptables"
+	FirewallNFTables = "nftables"
+	FirewallFail2ban = "fail2ban"
+)
+
+// NewFirewall создаёт файрвол, выбранный в конфигурации (FIREWALL_BACKEND).
+// scope — какой трафик заблокированных IP отбрасывается (см. ResolveFirewallScope); nil — оставить как настроено.
+func NewFirewall(scope *FirewallScope) (Firewall, error) {
+	switch FIREWALL_BACKEND {
+	case FirewallIPTables, "":
+		switch IPTABLES_MODE {
+		case IPTablesModeRules, "":
+			return NewIPTablesManager(IPTABLES_CHAIN, scope)
+		case IPTablesModeIPSet:
+			return NewIPSetManager(IPTABLES_CHAIN, IPSET_NAME, scope)
+		default:
+			return nil, fmt.Errorf("неизвестный режим iptables: %q", IPTABLES_MODE)
+		}
+	case FirewallNFTables:
+		return NewNFTablesManager(NFT_TABLE_NAME, scope)
+	case FirewallFail2ban:
+		return NewFail2banFirewall(FAIL2BAN_JAIL, FAIL2BAN_STATE_PATH)
+	default:
+		return nil, fmt.Errorf("неизвестный бэкенд файрвола: %q", FIREWALL_BACKEND)
+	}
+}
+
+// defaultBlockTimeout — длительность блокировки IP по умолчанию (совпадает с длительностью бана)
+func defaultBlockTimeout() time.Duration {
+	if IP_BAN_DURATION <= 0 {
+		return 0
+	}
+	return time.Duration(IP_BAN_DURATION) * time.Minute
+}
+
+// FirewallRequired сообщает, нужен ли режиму наказания файрвол: per_ip (в том числе как бан политики escalate)
+// блокирует IP на файрволе, а бан конфига (account) и троттлинг работают без него
+func FirewallRequired() bool {
+	return ENFORCEMENT_MODE == EnforcementPerIP ||
+		(ENFORCEMENT_MODE == EnforcementEscalate && ESCALATE_BAN_MODE == EnforcementPerIP)
+}
+
+// unavailableFirewall — файрвол, бэкенд которого не удалось инициализировать:
+// блокировки завершаются исходной ошибкой, снимать нечего
+type unavailableFirewall struct {
+	err error
+}
+
+// NewUnavailableFirewall возвращает заглушку файрвола для работы сервиса без блокировки IP
+// (initErr — ошибка инициализации бэкенда, её возвращают блокировки и сверка)
+func NewUnavailableFirewall(initErr error) Firewall {
+	return &unavailableFirewall{err: initErr}
+}
+
+func (u *unavailableFirewall) BlockIP(ipAddress string) error {
+	return fmt.Errorf("файрвол недоступен, IP %s не заблокирован: %v", ipAddress, u.err)
+}
+
+func (u *unavailableFirewall) UnblockIP(ipAddress string) error { return nil }
+
+func (u *unavailableFirewall) BlockIPs(ipAddresses []string, timeout time.Duration) error {
+	return fmt.Errorf("файрвол недоступен, %d IP не заблокировано: %v", len(ipAddresses), u.err)
+}
+
+func (u *unavailableFirewall) UnblockIPs(ipAddresses []string) error { return nil }
+
+func (u *unavailableFirewall) IsIPBlocked(ipAddress string) bool { return false }
+
+func (u *unavailableFirewall) GetBlockedIPs() []string { return nil }
+
+func (u *unavailableFirewall) Reconcile(expected map[string]time.Time, apply bool) (*FirewallDrift, error) {
+	return nil, fmt.Errorf("файрвол недоступен: %v", u.err)
+}
@@ -0,0 +1,149 @@
+package ipban
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"time"
+
+	"ipBanSystem/ipBan/logger/initLogs"
+)
+
+// FirewallDrift — расхождение между фактическим состоянием файрвола и активными банами
+type FirewallDrift struct {
+	// Backend — бэкенд файрвола, на котором выполнялась сверка
+	Backend string `json:"backend"`
+	// Adopted — блокировки, найденные на файрволе и соответствующие активному бану (взяты под управление)
+	Adopted []string `json:"adopted"`
+	// Stale — блокировки без активного бана (удаляются при применении)
+	Stale []string `json:"stale"`
+	// Missing — IP активных банов, которых нет на файрволе (блокируются заново при применении)
+	Missing []string `json:"missing,omitempty"`
+	// Duplicates — лишние копии одной и той же блокировки (удаляются при применении)
+	Duplicates []string `json:"duplicates,omitempty"`
+	// Legacy — правила старых версий сервиса прямо в INPUT (переносятся в цепочку сервиса при применении)
+	Legacy []string `json:"legacy,omitempty"`
+	// Foreign — правила DROP в INPUT для IP без записи бана: поставлены не сервисом, не удаляются
+	Foreign []string `json:"foreign,omitempty"`
+	// Applied — были ли исправления применены (false — только отчёт)
+	Applied bool `json:"applied"`
+}
+
+// HasDrift сообщает, нашлось ли что исправлять. Чужие правила (Foreign) сервис не трогает,
+// поэтому расхождением они не считаются.
+func (d *FirewallDrift) HasDrift() bool {
+	return len(d.Stale)+len(d.Missing)+len(d.Duplicates)+len(d.Legacy) > 0
+}
+
+// ExpectedFirewallState возвращает IP, которые должны быть заблокированы:
+// заблокированные по активным банам (BanInfo.BlockedIPs) и момент истечения самого позднего из них
+func ExpectedFirewallState(bm *BanManager) map[string]time.Time {
+	return expectedFromBans(bm, func(ban *BanInfo) []string { return ban.BlockedIPs })
+}
+
+// RecordedFirewallIPs возвращает IP всех записей хранилища банов (BlockedIPs и IPAddresses, включая истёкшие баны):
+// правило старой версии сервиса в INPUT считается своим, только если его IP есть в такой записи
+func RecordedFirewallIPs(bm *BanManager) map[string]bool {
+	recorded := make(map[string]bool)
+	for _, ban := range bm.listBans() {
+		for _, ip := range append(append([]string{}, ban.BlockedIPs...), ban.IPAddresses...) {
+			if parsed := net.ParseIP(ip); parsed != nil {
+				recorded[parsed.String()] = true
+			}
+		}
+	}
+	return recorded
+}
+
+// legacyRulesOwner — бэкенд, который находит правила старых версий сервиса вне своей цепочки (iptables)
+type legacyRulesOwner interface {
+	SetLegacyOwned(owned map[string]bool)
+}
+
+// expectedFromBans собирает IP активных банов, выбранные ips, с моментом истечения самого позднего бана
+func expectedFromBans(bm *BanManager, ips func(ban *BanInfo) []string) map[string]time.Time {
+	expected := make(map[string]time.Time)
+	for _, ban := range bm.GetActiveBans() {
+		for _, ip := range ips(ban) {
+			parsed := net.ParseIP(ip)
+			if parsed == nil {
+				continue
+			}
+			ip = parsed.String()
+			if ban.ExpiresAt.After(expected[ip]) {
+				expected[ip] = ban.ExpiresAt
+			}
+		}
+	}
+	return expected
+}
+
+// ReconcileFirewall сверяет файрвол с активными банами; apply=false — только отчёт о расхождениях
+func ReconcileFirewall(fw Firewall, bm *BanManager, apply bool) (*FirewallDrift, error) {
+	if l, ok := fw.(legacyRulesOwner); ok {
+		l.SetLegacyOwned(RecordedFirewallIPs(bm))
+	}
+	drift, err := fw.Reconcile(ExpectedFirewallState(bm), apply)
+	if err != nil {
+		return drift, fmt.Errorf("ошибка сверки файрвола: %v", err)
+	}
+	logDrift("Файрвол", drift)
+	return drift, nil
+}
+
+// logDrift логирует итог сверки
+func logDrift(what string, drift *FirewallDrift) {
+	if drift.HasDrift() {
+		initLogs.LogIPBanWarning("%s (%s): без бана %d, потеряно %d, дублей %d, старых правил %d, применено: %v",
+			what, drift.Backend, len(drift.Stale), len(drift.Missing), len(drift.Duplicates), len(drift.Legacy), drift.Applied)
+	} else {
+		initLogs.LogIPBanInfo("%s (%s) соответствует активным банам: под управлением %d IP",
+			what, drift.Backend, len(drift.Adopted))
+	}
+	if len(drift.Foreign) > 0 {
+		initLogs.LogIPBanInfo("%s (%s): чужих правил DROP в INPUT %d (поставлены не сервисом, не трогаются)",
+			what, drift.Backend, len(drift.Foreign))
+	}
+}
+
+// classifyBlocked раскладывает найденные на файрволе блокировки (IP -> число копий)
+// на соответствующие активным банам и лишние
+func classifyBlocked(found map[string]int, expected map[string]time.Time) (drift *FirewallDrift) {
+	drift = &FirewallDrift{Adopted: []string{}, Stale: []string{}}
+	for ip, count := range found {
+		if _, ok := expected[ip]; ok {
+			drift.Adopted = append(drift.Adopted, ip)
+		} else {
+			drift.Stale = append(drift.Stale, ip)
+		}
+		for n := 1; n < count; n++ {
+			drift.Duplicates = append(drift.Duplicates, ip)
+		}
+	}
+	for ip := range expected {
+		if _, ok := found[ip]; !ok {
+			drift.Missing = append(drift.Missing, ip)
+		}
+	}
+	sort.Strings(drift.Adopted)
+	sort.Strings(drift.Missing)
+	sort.Strings(drift.Stale)
+	sort.Strings(drift.Duplicates)
+	return drift
+}
+
+// restoreMissing заново блокирует IP активных банов, которых не оказалось на файрволе
+// (например, после перезагрузки хоста правила iptables не сохраняются). Возвращает IP, которые не удалось заблокировать.
+func restoreMissing(fw Firewall, missing []string, expected map[string]time.Time) []string {
+	var failed []string
+	for _, ip := range missing {
+		expiresAt := expected[ip]
+		if !time.Now().Before(expiresAt) || fw.IsIPBlocked(ip) {
+			continue
+		}
+		if err := fw.BlockIPs([]string{ip}, time.Until(expiresAt)); err != nil {
+			failed = append(failed, ip)
+		}
+	}
+	return failed
+}
@@ -0,0 +1,142 @@
+package ipban
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"ipBanSystem/ipBan/panel"
+	"ipBanSystem/ipBan/panel/inbound"
+)
+
+// Области действия блокировки (значения FIREWALL_SCOPE)
+const (
+	// FirewallScopePort — только порт и протокол inbound, с которым работает сервис (INBOUND_ID)
+	FirewallScopePort = "port"
+	// FirewallScopeInbounds — порты всех включенных inbound панели
+	FirewallScopeInbounds = "inbounds"
+	// FirewallScopeHost — весь трафик с IP (включая SSH и саму панель)
+	FirewallScopeHost = "host"
+)
+
+// PortRule — порт и протокол L4, на которые распространяется блокировка
+type PortRule struct {
+	Proto string `json:"proto"`
+	Port  int    `json:"port"`
+}
+
+// FirewallScope описывает, какой трафик заблокированного IP отбрасывается.
+// Пустой список Ports — весь трафик хоста. Область задаётся на входе в цепочку/таблицу сервиса,
+// поэтому сами блокировки остаются по одной записи на IP.
+// nil вместо области означает «не менять»: существующие правила входа сохраняются (офлайн-команды CLI).
+type FirewallScope struct {
+	Mode  string     `json:"mode"`
+	Ports []PortRule `json:"ports,omitempty"`
+}
+
+// HostScope — блокировка всего трафика с IP
+func HostScope() *FirewallScope {
+	return &FirewallScope{Mode: FirewallScopeHost}
+}
+
+// ResolveFirewallScope определяет область блокировки по FIREWALL_SCOPE и inbound панели
+func ResolveFirewallScope(cm *panel.ConfigManager) (*FirewallScope, error) {
+	scope := &FirewallScope{Mode: FIREWALL_SCOPE}
+	switch FIREWALL_SCOPE {
+	case FirewallScopeHost, "":
+		scope.Mode = FirewallScopeHost
+		return scope, nil
+	case FirewallScopePort:
+		scope.Mode = FirewallScopePort
+		inb, err := inbound.GetInbound(cm)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка получения inbound %d: %v", cm.InboundID, err)
+		}
+		scope.addInbound(inb)
+	case FirewallScopeInbounds:
+		inbounds, err := inbound.ListInbounds(cm)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка получения списка inbound: %v", err)
+		}
+		for n := range inbounds {
+			if inbounds[n].Enable {
+				scope.addInbound(&inbounds[n])
+			}
+		}
+	default:
+		return nil, fmt.Errorf("неизвестная область блокировки: %q", FIREWALL_SCOPE)
+	}
+
+	if len(scope.Ports) == 0 {
+		return nil, fmt.Errorf("не найдено ни одного порта inbound для области %s", scope.Mode)
+	}
+	return scope, nil
+}
+
+// addInbound добавляет порт inbound по всем его транспортным протоколам (без дублей)
+func (sc *FirewallScope) addInbound(inb *inbound.Inbound) {
+	if inb.Port <= 0 || inb.Port > 65535 {
+		return
+	}
+	for _, proto := range inb.TransportProtocols() {
+		rule := PortRule{Proto: proto, Port: inb.Port}
+		exists := false
+		for _, r := range sc.Ports {
+			if r == rule {
+				exists = true
+				break
+			}
+		}
+		if !exists {
+			sc.Ports = append(sc.Ports, rule)
+		}
+	}
+}
+
+// HostWide сообщает, что блокируется весь трафик с IP
+func (sc *FirewallScope) HostWide() bool {
+	return len(sc.Ports) == 0
+}
+
+// PortsByProto группирует порты по протоколу: "tcp" -> [443, 8443]
+func (sc *FirewallScope) PortsByProto() map[string][]int {
+	groups := make(map[string][]int)
+	for _, r := range sc.Ports {
+		groups[r.Proto] = append(groups[r.Proto], r.Port)
+	}
+	for proto := range groups {
+		sort.Ints(groups[proto])
+	}
+	return groups
+}
+
+// String возвращает читаемое описание области для логов
+func (sc *FirewallScope) String() string {
+	if sc == nil {
+		return "без изменений"
+	}
+	if sc.HostWide() {
+		return "весь трафик хоста"
+	}
+	var parts []string
+	groups := sc.PortsByProto()
+	protos := make([]string, 0, len(groups))
+	for proto := range groups {
+		protos = append(protos, proto)
+	}
+	sort.Strings(protos)
+	for _, proto := range protos {
+		parts = append(parts, proto+"/"+joinPorts(groups[proto], ","))
+	}
+	return sc.Mode + ": " + strings.Join(parts, " ")
+}
+
+// joinPorts склеивает номера портов через разделитель
+func joinPorts(ports []int, sep string) string {
+	s := make([]string, len(ports))
+	for n, p := range ports {
+		s[n] = strconv.Itoa(p)
+	}
+	return strings.Join(s, sep)
+}
@@ -0,0 +1,281 @@
+package ipban
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"ipBanSystem/ipBan/logger/initLogs"
+	"ipBanSystem/ipBan/panel/client"
+)
+
+// Префиксы стабильных ключей идентичности
+const (
+	identitySubPrefix   = "sub:"   // ключ по SubID клиента (предпочтительный)
+	identityIDPrefix    = "id:"    // ключ по отпечатку первых известных учётных данных клиента (если SubID пуст)
+	identityEmailPrefix = "email:" // запасной ключ для email, которого нет в таблице, или клиента без SubID и учётных данных
+)
+
+// resetEmailSuffix — суффикс, который AggressiveBanReset временно добавляет к email
+const resetEmailSuffix = "-reset"
+
+// Identity — стабильная идентичность клиента панели.
+// Email и UUID клиента меняются (переименование админом, AggressiveBanReset), а ключ — нет.
+type Identity struct {
+	// Key — стабильный ключ: "sub:<subId>", "id:<отпечаток первых учётных данных>" или "email:<email>"
+	Key string `json:"key"`
+	// SubID — идентификатор подписки (если задан в панели)
+	SubID string `json:"sub_id,omitempty"`
+	// Email — текущий email клиента
+	Email string `json:"email"`
+	// Emails — все email, под которыми клиент встречался (включая временные "-reset")
+	Emails []string `json:"emails"`
+	// ClientIDs — история отпечатков учётных данных клиента (UUID vless/vmess, пароль trojan/shadowsocks; см. credentialFingerprint)
+	ClientIDs []string `json:"client_ids"`
+}
+
+// IdentityMap — таблица соответствия email/UUID -> стабильный ключ идентичности.
+// Сохраняется в JSON-файл, чтобы переименования переживали перезапуск сервиса.
+type IdentityMap struct {
+	Path       string
+	identities map[string]*Identity
+	emailIndex map[string]string // email в нижнем регистре -> ключ
+	idIndex    map[string]string // отпечаток учётных данных (UUID или пароля) -> ключ
+	mutex      sync.RWMutex
+}
+
+// NewIdentityMap загружает таблицу идентичностей из файла (или создаёт пустую)
+func NewIdentityMap(path string) *IdentityMap {
+	im := &IdentityMap{
+		Path:       path,
+		identities: make(map[string]*Identity),
+	}
+
+	if data, err := os.ReadFile(path); err == nil && len(data) > 0 {
+		if err := json.Unmarshal(data, &im.identities); err != nil {
+			initLogs.LogIPBanError("Ошибка загрузки таблицы идентичностей %s: %v", path, err)
+			im.identities = make(map[string]*Identity)
+		}
+	}
+	im.rebuildIndex()
+	return im
+}
+
+// Sync сверяет таблицу с текущим списком клиентов панели.
+// Порядок сопоставления: SubID -> известные учётные данные -> известный email (в т.ч. без суффикса "-reset"),
+// email — только для клиента без учётных данных или с временным email "-reset" (см. matchLocked).
+// Новые email и учётные данные добавляются в историю найденной идентичности.
+func (im *IdentityMap) Sync(clients []client.Client) {
+	im.mutex.Lock()
+	defer im.mutex.Unlock()
+
+	changed := false
+	for _, c := range clients {
+		key := im.matchLocked(c)
+		ident := im.identities[key]
+		if ident == nil {
+			ident = &Identity{Key: key, SubID: c.SubID}
+			im.identities[key] = ident
+			changed = true
+		}
+
+		if ident.SubID == "" && c.SubID != "" {
+			ident.SubID = c.SubID
+			changed = true
+		}
+		// Временный "-reset" email запоминаем в истории, но текущим не делаем
+		if !strings.HasSuffix(c.Email, resetEmailSuffix) && ident.Email != c.Email {
+			if ident.Email != "" {
+				initLogs.LogIPBanInfo("Идентичность %s: email изменён %s -> %s", key, ident.Email, c.Email)
+			}
+			ident.Email = c.Email
+			changed = true
+		}
+		if addUnique(&ident.Emails, c.Email) {
+			changed = true
+		}
+		fingerprint := credentialFingerprint(c.Credential())
+		if fingerprint != "" && addUnique(&ident.ClientIDs, fingerprint) {
+			changed = true
+		}
+		im.emailIndex[strings.ToLower(c.Email)] = key
+		if fingerprint != "" {
+			im.idIndex[fingerprint] = key
+		}
+	}
+
+	if changed {
+		if err := im.saveLocked(); err != nil {
+			initLogs.LogIPBanError("Ошибка сохранения таблицы идентичностей: %v", err)
+		}
+	}
+}
+
+// Resolve возвращает стабильный ключ для email.
+// Email с суффиксом "-reset" сводится к исходному; неизвестный email получает ключ "email:<email>".
+func (im *IdentityMap) Resolve(email string) string {
+	im.mutex.RLock()
+	defer im.mutex.RUnlock()
+
+	lower := strings.ToLower(email)
+	if key, ok := im.emailIndex[lower]; ok {
+		return key
+	}
+	if key, ok := im.emailIndex[strings.TrimSuffix(lower, resetEmailSuffix)]; ok {
+		return key
+	}
+	return identityEmailPrefix + strings.TrimSuffix(lower, resetEmailSuffix)
+}
+
+// AddCredential запоминает новые учётные данные клиента email, выданные сервисом (AggressiveBanReset):
+// по истории email клиент с незнакомыми учётными данными не сопоставляется, поэтому их заносят сразу
+func (im *IdentityMap) AddCredential(email, credential string) {
+	fingerprint := credentialFingerprint(credential)
+	if fingerprint == "" {
+		return
+	}
+	im.mutex.Lock()
+	defer im.mutex.Unlock()
+
+	lower := strings.ToLower(email)
+	key, ok := im.emailIndex[lower]
+	if !ok {
+		key, ok = im.emailIndex[strings.TrimSuffix(lower, resetEmailSuffix)]
+	}
+	ident := im.identities[key]
+	if !ok || ident == nil || !addUnique(&ident.ClientIDs, fingerprint) {
+		return
+	}
+	im.idIndex[fingerprint] = key
+	if err := im.saveLocked(); err != nil {
+		initLogs.LogIPBanError("Ошибка сохранения таблицы идентичностей: %v", err)
+	}
+}
+
+// KeyFor возвращает ключ для клиента панели (без изменения таблицы)
+func (im *IdentityMap) KeyFor(c client.Client) string {
+	im.mutex.RLock()
+	defer im.mutex.RUnlock()
+	return im.matchLocked(c)
+}
+
+// Get возвращает копию идентичности по ключу
+func (im *IdentityMap) Get(key string) *Identity {
+	im.mutex.RLock()
+	defer im.mutex.RUnlock()
+	ident, ok := im.identities[key]
+	if !ok {
+		return nil
+	}
+	c := *ident
+	c.Emails = append([]string(nil), ident.Emails...)
+	c.ClientIDs = append([]string(nil), ident.ClientIDs...)
+	return &c
+}
+
+// CurrentEmail возвращает текущий email идентичности (пустая строка, если ключ неизвестен)
+func (im *IdentityMap) CurrentEmail(key string) string {
+	im.mutex.RLock()
+	defer im.mutex.RUnlock()
+	if ident, ok := im.identities[key]; ok {
+		return ident.Email
+	}
+	return ""
+}
+
+// IsStableKey сообщает, является ли ключ стабильным (а не запасным по email или устаревшим)
+func IsStableKey(key string) bool {
+	return strings.HasPrefix(key, identitySubPrefix) || strings.HasPrefix(key, identityIDPrefix)
+}
+
+// matchLocked находит ключ для клиента; вызывается под im.mutex.
+// Учётные данные — UUID (vless/vmess) или пароль (trojan/shadowsocks: ID у них пуст), в ключ идёт их отпечаток.
+// Клиент без SubID и учётных данных получает ключ по email: пустой ключ "id:" свёл бы таких клиентов в одного.
+// По истории email сопоставляются только клиенты без учётных данных и клиенты посреди сброса ("-reset"):
+// новый клиент, занявший email удалённого или переименованного, не наследует его баны и журнал отключений.
+func (im *IdentityMap) matchLocked(c client.Client) string {
+	if c.SubID != "" {
+		return identitySubPrefix + c.SubID
+	}
+	fingerprint := credentialFingerprint(c.Credential())
+	if fingerprint != "" {
+		if key, ok := im.idIndex[fingerprint]; ok {
+			return key
+		}
+	}
+	lower := strings.ToLower(c.Email)
+	if fingerprint == "" || strings.HasSuffix(lower, resetEmailSuffix) {
+		if key, ok := im.emailIndex[lower]; ok {
+			return key
+		}
+		if key, ok := im.emailIndex[strings.TrimSuffix(lower, resetEmailSuffix)]; ok {
+			return key
+		}
+	}
+	if fingerprint == "" {
+		return identityEmailPrefix + strings.TrimSuffix(lower, resetEmailSuffix)
+	}
+	return identityIDPrefix + fingerprint
+}
+
+// credentialFingerprint возвращает отпечаток учётных данных клиента (первые 16 hex-символов SHA-256; пусто — данных нет).
+// UUID и пароль — рабочие учётные данные прокси: в ключи, файлы, Redis и журналы попадает только отпечаток.
+func credentialFingerprint(credential string) string {
+	if credential == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(credential))
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+// rebuildIndex перестраивает индексы email и отпечатков учётных данных по таблице
+func (im *IdentityMap) rebuildIndex() {
+	im.emailIndex = make(map[string]string)
+	im.idIndex = make(map[string]string)
+	for key, ident := range im.identities {
+		for _, email := range ident.Emails {
+			im.emailIndex[strings.ToLower(email)] = key
+		}
+		for _, id := range ident.ClientIDs {
+			im.idIndex[id] = key
+		}
+	}
+	// Текущий email имеет приоритет над историей других идентичностей
+	for key, ident := range im.identities {
+		if ident.Email != "" {
+			im.emailIndex[strings.ToLower(ident.Email)] = key
+		}
+	}
+}
+
+// saveLocked записывает таблицу в файл; вызывается под im.mutex
+func (im *IdentityMap) saveLocked() error {
+	data, err := json.MarshalIndent(im.identities, "", "  ")
+	if err != nil {
+		return fmt.Errorf("ошибка сериализации таблицы идентичностей: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(im.Path), 0o755); err != nil {
+		return fmt.Errorf("ошибка создания директории: %v", err)
+	}
+	tmp := im.Path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, im.Path)
+}
+
+// addUnique добавляет значение в срез, если его там ещё нет; возвращает true при добавлении
+func addUnique(list *[]string, value string) bool {
+	for _, v := range *list {
+		if v == value {
+			return false
+		}
+	}
+	*list = append(*list, value)
+	return true
+}
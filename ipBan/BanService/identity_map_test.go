@@ -0,0 +1,105 @@
+package ipban_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	ipban "ipBanSystem/ipBan/BanService"
+	"ipBanSystem/ipBan/panel/client"
+)
+
+func TestIdentityKeysTrojanClientsWithoutSubID(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "identities.json")
+	im := ipban.NewIdentityMap(path)
+	alice := client.Client{Email: "alice", Enable: true, Password: "pass-alice"}
+	bob := client.Client{Email: "bob", Enable: true, Password: "pass-bob"}
+	im.Sync([]client.Client{alice, bob})
+
+	keyAlice, keyBob := im.KeyFor(alice), im.KeyFor(bob)
+	if keyAlice == keyBob {
+		t.Fatalf("клиенты trojan без subId получили общий ключ %q", keyAlice)
+	}
+	for _, key := range []string{keyAlice, keyBob} {
+		if key == "id:" || !strings.HasPrefix(key, "id:") {
+			t.Errorf("ключ %q: ожидался ключ по паролю", key)
+		}
+		if strings.Contains(key, "pass-") {
+			t.Errorf("ключ %q содержит пароль клиента", key)
+		}
+	}
+
+	// В файле таблицы только отпечатки паролей, доступ — только владельцу
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("таблица идентичностей не сохранена: %v", err)
+	}
+	if strings.Contains(string(data), "pass-") {
+		t.Errorf("пароль клиента записан в таблицу идентичностей: %s", data)
+	}
+	if info, err := os.Stat(path); err != nil {
+		t.Errorf("Stat: %v", err)
+	} else if info.Mode().Perm() != 0o600 {
+		t.Errorf("права таблицы идентичностей: %v, ожидались 0600", info.Mode().Perm())
+	}
+
+	// Сброс меняет email, но пароль тот же: ключ сохраняется и после перезагрузки
+	reloaded := ipban.NewIdentityMap(path)
+	renamed := client.Client{Email: "alice-reset", Enable: true, Password: "pass-alice"}
+	if got := reloaded.KeyFor(renamed); got != keyAlice {
+		t.Errorf("KeyFor(%s) = %q, ожидался %q", renamed.Email, got, keyAlice)
+	}
+}
+
+func TestIdentityKeyWithoutCredentialFallsBackToEmail(t *testing.T) {
+	im := ipban.NewIdentityMap(filepath.Join(t.TempDir(), "identities.json"))
+	first := client.Client{Email: "First", Enable: true}
+	second := client.Client{Email: "second", Enable: true}
+	im.Sync([]client.Client{first, second})
+
+	if got := im.KeyFor(first); got != "email:first" {
+		t.Errorf("KeyFor(%s) = %q, ожидался email:first", first.Email, got)
+	}
+	if got := im.KeyFor(second); got != "email:second" {
+		t.Errorf("KeyFor(%s) = %q, ожидался email:second", second.Email, got)
+	}
+}
+
+func TestIdentityReusedEmailGetsNewKey(t *testing.T) {
+	im := ipban.NewIdentityMap(filepath.Join(t.TempDir(), "identities.json"))
+	deleted := client.Client{Email: "alice", Enable: true, Password: "pass-old"}
+	im.Sync([]client.Client{deleted})
+	oldKey := im.KeyFor(deleted)
+
+	// Клиент удалён, новый клиент с другим паролем занял его email: бан и журнал прежнего не наследуются
+	reused := client.Client{Email: "alice", Enable: true, Password: "pass-new"}
+	im.Sync([]client.Client{reused})
+	if got := im.KeyFor(reused); got == oldKey {
+		t.Errorf("новый клиент с email удалённого унаследовал ключ %q", got)
+	}
+	if got := im.Resolve("alice"); got != im.KeyFor(reused) {
+		t.Errorf("Resolve(alice) = %q, ожидался ключ нового клиента %q", got, im.KeyFor(reused))
+	}
+}
+
+func TestIdentityKeepsKeyAfterServiceReset(t *testing.T) {
+	im := ipban.NewIdentityMap(filepath.Join(t.TempDir(), "identities.json"))
+	before := client.Client{Email: "alice", Enable: true, Password: "pass-old"}
+	im.Sync([]client.Client{before})
+	key := im.KeyFor(before)
+
+	// Прерванный сброс: временный email "-reset" с новым паролем сопоставляется по email
+	interrupted := client.Client{Email: "alice-reset", Password: "pass-interrupted"}
+	if got := im.KeyFor(interrupted); got != key {
+		t.Errorf("KeyFor(%s) = %q, ожидался %q", interrupted.Email, got, key)
+	}
+
+	// Завершённый сброс: сервис заносит выданный пароль, клиент узнаётся по нему
+	im.AddCredential("alice", "pass-rotated")
+	rotated := client.Client{Email: "alice", Password: "pass-rotated"}
+	im.Sync([]client.Client{rotated})
+	if got := im.KeyFor(rotated); got != key {
+		t.Errorf("после сброса KeyFor = %q, ожидался %q", got, key)
+	}
+}
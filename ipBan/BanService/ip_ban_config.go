@@ -34,6 +34,73 @@ var (
 	// Установите значение 0 для бессрочного бана.
 	IP_BAN_DURATION int
 
+	// Способ наказания за превышение лимита IP:
+	// "account" — бан всего конфига (отключение и агрессивный сброс в панели);
+	// "per_ip" — конфиг остаётся включенным, на файрволе блокируются только самые новые лишние IP,
+	// а MAX_IPS_PER_CONFIG самых давних IP продолжают работать;
+	// "throttle" — конфиг и IP работают, но со сниженной скоростью (THROTTLE_*);
+	// "escalate" — троттлинг за первые ESCALATE_THROTTLE_OFFENSES нарушений, затем бан (ESCALATE_BAN_MODE).
+	ENFORCEMENT_MODE string
+
+	// Для режима "escalate": сколько нарушений наказываются троттлингом, прежде чем последует бан.
+	// Продолжение нарушения во время троттлинга считается повторным нарушением.
+	ESCALATE_THROTTLE_OFFENSES int
+
+	// Для режима "escalate": способ бана повторных нарушителей ("account" или "per_ip").
+	ESCALATE_BAN_MODE string
+
+	// Окно учёта нарушений в минутах: нарушения старше окна забываются.
+	OFFENSE_WINDOW int
+
+	// Путь к файлу счётчика нарушений (для режима "escalate").
+	OFFENSE_TRACKER_PATH string
+
+	// Троттлинг (iptables hashlimit): максимум новых соединений в минуту с одного IP.
+	// 0 — не ограничивать.
+	THROTTLE_CONN_PER_MIN int
+
+	// Троттлинг: максимальная скорость отдачи на один IP в килобайтах в секунду.
+	// 0 — не ограничивать.
+	THROTTLE_RATE_KBPS int
+
+	// Длительность троттлинга в минутах.
+	THROTTLE_DURATION int
+
+	// Цепочка iptables для троттлинга (входящая; для исходящего трафика — с суффиксом "_OUT").
+	THROTTLE_CHAIN string
+
+	// Разрывать ли установленные соединения IP при бане (иначе они живут до таймаута).
+	// Включается явно: по умолчанию выключено, как в прежних версиях.
+	KILL_CONNECTIONS_ON_BAN bool
+
+	// Способы разрыва соединений через запятую:
+	// "conntrack" — удаление записей conntrack (conntrack -D), "ss" — закрытие сокетов Xray (ss -K).
+	// Разрыв ограничен портами области блокировки (FIREWALL_SCOPE).
+	CONNECTION_KILL_METHODS string
+
+	// Сколько изменений клиентов записывать в панель одним обновлением inbound в конце цикла проверки.
+	// 0 — все изменения цикла одной записью.
+	PANEL_BATCH_SIZE int
+
+	// Окно учёта трафика клиентов в минутах: расход считается по показаниям панели (clientStats) за это время.
+	TRAFFIC_WINDOW int
+
+	// Порог расхода трафика в гигабайтах за окно TRAFFIC_WINDOW, выше которого расход считается
+	// нарушением наравне с превышением лимита IP. 0 — не учитывать трафик.
+	// В режиме "per_ip" одно лишь превышение порога трафика не наказывается: лишних IP нет.
+	TRAFFIC_ABUSE_GB float64
+
+	// Не включать после разбана конфиг, который исчерпал лимит трафика или срок действия в панели.
+	KEEP_DEPLETED_DISABLED bool
+
+	// Ручные изменения в панели важнее решений сервиса: сервис включает обратно только конфиги,
+	// которые отключил сам (журнал DISABLE_LEDGER_PATH). Конфиг, отключенный администратором, сервис не включает.
+	// false — включается любой отключенный конфиг без нарушений, кроме истёкших по сроку или исчерпавших трафик.
+	MANUAL_CHANGES_PRECEDENCE bool
+
+	// Путь к журналу отключений, выполненных сервисом.
+	DISABLE_LEDGER_PATH string
+
 	// Время в минутах, в течение которого система будет помнить IP-адреса пользователя.
 	// Если IP-адрес не появлялся в логах дольше этого времени, он будет удален из счетчика.
 	// Это помогает предотвратить накопление старых, неиспользуемых IP.
@@ -49,6 +116,86 @@ var (
 
 	// Путь к файлу, в который будут записываться только логи о забаненных пользователях.
 	BANNED_USERS_LOG_PATH string
+
+	// Включает журнал нарушений для fail2ban: по строке на каждый IP сверх лимита (самые новые IP пользователя,
+	// превысившего лимит; давние IP владельца не пишутся) в формате "IPBAN violation user=<email> ip=<ip> count=<n>" (фильтр — other/fail2ban/filter.d/ipban.conf).
+	LOG_VIOLATIONS bool
+
+	// Путь к журналу нарушений (logpath в jail fail2ban).
+	VIOLATIONS_LOG_PATH string
+
+	// Бэкенд хранилища банов: "file" (JSON-файл), "bolt" (встроенная база bbolt)
+	// или "redis" (Redis-совместимый сервер, общий для нескольких нод).
+	BAN_STORE_BACKEND string
+
+	// Путь к JSON-файлу банов для бэкенда "file".
+	BAN_STORE_FILE_PATH string
+
+	// Путь к файлу базы bbolt для бэкенда "bolt".
+	BAN_STORE_BOLT_PATH string
+
+	// Адрес Redis-совместимого сервера (host:port) для бэкенда "redis".
+	BAN_STORE_REDIS_ADDR string
+
+	// Пароль Redis (пустая строка — без AUTH).
+	BAN_STORE_REDIS_PASSWORD string
+
+	// Номер базы Redis (SELECT).
+	BAN_STORE_REDIS_DB int
+
+	// Ключ хэша Redis, в котором хранятся баны. Ноды с одинаковым ключом разделяют баны.
+	BAN_STORE_REDIS_KEY string
+
+	// Путь к таблице идентичностей (email/UUID -> стабильный ключ SubID/UUID).
+	// Баны и статистика привязаны к стабильному ключу, чтобы переименование клиента не снимало бан.
+	IDENTITY_MAP_PATH string
+
+	// Бэкенд файрвола для блокировки IP: "iptables" (правило на каждый IP, для старых хостов),
+	// "nftables" (собственная таблица с наборами и таймаутами элементов)
+	// или "fail2ban" (блокировка через jail fail2ban — на хостах, где fail2ban уже управляет файрволом).
+	FIREWALL_BACKEND string
+
+	// Имя jail fail2ban для FIREWALL_BACKEND="fail2ban" (см. other/fail2ban).
+	FAIL2BAN_JAIL string
+
+	// Файл со списком IP, которые сервис сам забанил в jail (FIREWALL_BACKEND="fail2ban").
+	// Сверка снимает только их: баны, которые jail поставил по журналу нарушений, не трогаются.
+	FAIL2BAN_STATE_PATH string
+
+	// Имя таблицы nftables (семейство inet), которой владеет сервис.
+	NFT_TABLE_NAME string
+
+	// Режим бэкенда iptables: "rules" (отдельное правило на каждый IP)
+	// или "ipset" (наборы ipset с таймаутами и одно правило DROP на набор).
+	IPTABLES_MODE string
+
+	// Базовое имя наборов ipset; к нему добавляется суффикс семейства ("4"/"6").
+	IPSET_NAME string
+
+	// Область блокировки IP на файрволе:
+	// "host" — весь трафик с IP (по умолчанию, как в прежних версиях; пусто — то же);
+	// "port" — только порт и протокол inbound сервиса (SSH, панель и другие сервисы хоста остаются доступны);
+	// "inbounds" — порты всех включенных inbound панели. "port" и "inbounds" включаются явно.
+	FIREWALL_SCOPE string
+
+	// Цепочка iptables, которой владеет сервис (переход в неё добавляется в INPUT).
+	// При запуске правила цепочки сверяются с активными банами.
+	IPTABLES_CHAIN string
+
+	// Адрес сервера (домен или IP) в ссылках подключения клиентов ("users link").
+	// Пусто — адрес прослушивания inbound, а если он не задан — хост панели из PANEL_URL.
+	SHARE_LINK_HOST string
+
+	// Каталог снимков inbound: перед каждым изменением панели сохраняются поля inbound и затронутые клиенты
+	// (команды "snapshots list/diff/restore"; файлы доступны только владельцу). Пусто — снимки не сохраняются.
+	SNAPSHOT_DIR string
+
+	// Сколько последних снимков inbound хранить (0 — без ограничения).
+	SNAPSHOT_KEEP int
+
+	// Путь к unix-сокету управляющего API демона.
+	// Через него CLI-команды (ban/unban/bans/users/firewall) обращаются к запущенному сервису.
+	CONTROL_SOCKET_PATH string
 )
 
 // Инициализация настроек IP-бана и логирования
@@ -69,6 +216,36 @@ func init() {
 	IP_BAN_GRACE_PERIOD = 10
 	// Длительность бана (минуты).
 	IP_BAN_DURATION = 120
+	// Способ наказания: бан конфига или блокировка лишних IP.
+	ENFORCEMENT_MODE = "account"
+	// Эскалация: троттлинг за первое нарушение, затем бан конфига.
+	ESCALATE_THROTTLE_OFFENSES = 1
+	ESCALATE_BAN_MODE = "account"
+	// Окно учёта нарушений (минуты) — неделя.
+	OFFENSE_WINDOW = 10080
+	// Файл счётчика нарушений.
+	OFFENSE_TRACKER_PATH = "/root/tools/ipBanSystem/data/offenses.json"
+	// Лимиты троттлинга.
+	THROTTLE_CONN_PER_MIN = 30
+	THROTTLE_RATE_KBPS = 256
+	// Длительность троттлинга (минуты).
+	THROTTLE_DURATION = 120
+	// Цепочка троттлинга.
+	THROTTLE_CHAIN = "IPBAN_THROTTLE"
+	// Разрыв соединений при бане (выключен: включается явно).
+	KILL_CONNECTIONS_ON_BAN = false
+	CONNECTION_KILL_METHODS = "conntrack,ss"
+	// Изменений клиентов на одну запись inbound (0 — все одной записью).
+	PANEL_BATCH_SIZE = 0
+	// Окно учёта трафика (минуты) и порог расхода за окно (ГБ, 0 — выключено).
+	TRAFFIC_WINDOW = 60
+	TRAFFIC_ABUSE_GB = 0
+	// Исчерпавшие трафик или срок конфиги остаются отключенными после разбана.
+	KEEP_DEPLETED_DISABLED = true
+	// Сервис включает только отключенные им самим конфиги.
+	MANUAL_CHANGES_PRECEDENCE = true
+	// Журнал отключений сервиса.
+	DISABLE_LEDGER_PATH = "/root/tools/ipBanSystem/data/disables.json"
 	// Время хранения счетчиков IP (минуты).
 	IP_COUNTER_RETENTION = 20
 	// Интервал очистки старых логов (часы).
@@ -78,4 +255,51 @@ func init() {
 	LOG_BANNED_USERS = true
 	// Путь к логам забаненных пользователей.
 	BANNED_USERS_LOG_PATH = "/root/tools/ipBanSystem/logs/ban.log"
+	// Журнал нарушений для fail2ban.
+	LOG_VIOLATIONS = false
+	VIOLATIONS_LOG_PATH = "/root/tools/ipBanSystem/logs/violations.log"
+
+	// Бэкенд хранилища банов.
+	BAN_STORE_BACKEND = "file"
+	// Путь к JSON-файлу банов.
+	BAN_STORE_FILE_PATH = "/var/log/ip_bans.json"
+	// Путь к базе bbolt.
+	BAN_STORE_BOLT_PATH = "/root/tools/ipBanSystem/data/ip_bans.db"
+	// Адрес Redis.
+	BAN_STORE_REDIS_ADDR = "127.0.0.1:6379"
+	// Пароль Redis.
+	BAN_STORE_REDIS_PASSWORD = ""
+	// Номер базы Redis.
+	BAN_STORE_REDIS_DB = 0
+	// Ключ хэша банов в Redis.
+	BAN_STORE_REDIS_KEY = "ipban:bans"
+
+	// Путь к таблице идентичностей.
+	IDENTITY_MAP_PATH = "/root/tools/ipBanSystem/data/identities.json"
+
+	// Бэкенд файрвола.
+	FIREWALL_BACKEND = "iptables"
+	// Jail fail2ban.
+	FAIL2BAN_JAIL = "ipban"
+	// IP, забаненные сервисом в jail.
+	FAIL2BAN_STATE_PATH = "/root/tools/ipBanSystem/data/fail2ban_banned.json"
+	// Таблица nftables сервиса.
+	NFT_TABLE_NAME = "ipban"
+	// Режим iptables и имя наборов ipset.
+	IPTABLES_MODE = "rules"
+	IPSET_NAME = "ipban"
+	// Область блокировки IP (весь хост, как в прежних версиях).
+	FIREWALL_SCOPE = "host"
+	// Цепочка iptables сервиса.
+	IPTABLES_CHAIN = "IPBAN"
+
+	// Адрес сервера в ссылках подключения (пусто — определяется автоматически).
+	SHARE_LINK_HOST = ""
+
+	// Каталог и число хранимых снимков inbound.
+	SNAPSHOT_DIR = "/root/tools/ipBanSystem/data/snapshots"
+	SNAPSHOT_KEEP = 20
+
+	// Путь к сокету управляющего API.
+	CONTROL_SOCKET_PATH = "/run/ipBanService.sock"
 }
@@ -1,14 +1,15 @@
 package ipban
 
 import (
-    "fmt"
-    "ipBanSystem/ipBan/logger/analyzerLogs"
-    "ipBanSystem/ipBan/logger/initLogs"
-    "ipBanSystem/ipBan/panel"
-    "ipBanSystem/ipBan/panel/client"
-    adjustingdays "ipBanSystem/ipBan/panel/client/adjusting_days"
-    "strings"
-    "time"
+	"fmt"
+	"ipBanSystem/ipBan/logger/analyzerLogs"
+	"ipBanSystem/ipBan/logger/initLogs"
+	"ipBanSystem/ipBan/panel"
+	"ipBanSystem/ipBan/panel/client"
+	adjustingdays "ipBanSystem/ipBan/panel/client/adjusting_days"
+	"strings"
+	"sync"
+	"time"
 )
 
 // IPBanService основной сервис для управления IP банами
@@ -16,21 +17,28 @@ type IPBanService struct {
 	Analyzer      *analyzerLogs.LogAnalyzer
 	ConfigManager *panel.ConfigManager
 	BanManager    *BanManager
-	IPTables      *IPTablesManager
+	Firewall      Firewall
+	Throttler     Throttler         // Ограничитель скорости (nil, если троттлинг не используется)
+	Offenses      *OffenseTracker   // Счётчик нарушений для политики "escalate" (nil — не ведётся)
+	Killer        *ConnectionKiller // Разрыв соединений при бане (nil — соединения не разрываются)
+	Traffic       *TrafficTracker   // Расход трафика клиентов по циклам (nil — трафик не учитывается)
+	Disables      *DisableLedger    // Журнал отключений, выполненных сервисом (nil — не ведётся)
+	cycle         *cycleChanges     // Изменения панели текущего цикла проверки (nil вне performCheck — применяются сразу)
 	MaxIPs        int
 	CheckInterval time.Duration
 	GracePeriod   time.Duration
 	Running       bool
 	StopChan      chan bool
+	cycleMutex    sync.Mutex // Сериализует цикл проверки и ручные команды (ban/unban из CLI)
 }
 
 // NewIPBanService создает новый сервис IP бана
-func NewIPBanService(analyzer *analyzerLogs.LogAnalyzer, configManager *panel.ConfigManager, banManager *BanManager, iptables *IPTablesManager, maxIPs int, checkInterval, gracePeriod time.Duration) *IPBanService {
+func NewIPBanService(analyzer *analyzerLogs.LogAnalyzer, configManager *panel.ConfigManager, banManager *BanManager, firewall Firewall, maxIPs int, checkInterval, gracePeriod time.Duration) *IPBanService {
 	return &IPBanService{
 		Analyzer:      analyzer,
 		ConfigManager: configManager,
 		BanManager:    banManager,
-		IPTables:      iptables,
+		Firewall:      firewall,
 		MaxIPs:        maxIPs,
 		CheckInterval: checkInterval,
 		GracePeriod:   gracePeriod,
@@ -52,6 +60,21 @@ func (s *IPBanService) Start() error {
 	fmt.Printf("⏳ Период ожидания: %v\n", s.GracePeriod)
 	fmt.Println(strings.Repeat("=", 50))
 
+	// После перезапуска внутреннее состояние файрвола пусто: восстанавливаем его по правилам хоста
+	// и удаляем блокировки, бан которых уже снят или истёк
+	if _, err := s.SyncFirewall(true); err != nil {
+		initLogs.LogIPBanError("%v", err)
+	}
+	if s.Throttler != nil {
+		if _, err := ReconcileThrottle(s.Throttler, s.BanManager, true); err != nil {
+			initLogs.LogIPBanError("%v", err)
+		}
+	}
+	// Процесс мог остановиться посреди агрессивного сброса: возвращаем клиентам исходные email
+	if n := s.RecoverInterruptedResets(); n > 0 {
+		initLogs.LogIPBanInfo("Исправлено прерванных сбросов: %d", n)
+	}
+
 	go s.monitorLoop()
 	return nil
 }
@@ -83,12 +106,20 @@ func (s *IPBanService) monitorLoop() {
 	}
 }
 
+// CheckNow выполняет один цикл проверки немедленно, вне расписания monitorLoop
+func (s *IPBanService) CheckNow() {
+	s.performCheck()
+}
+
 // performCheck выполняет проверку и управление конфигами
 func (s *IPBanService) performCheck() {
+	s.cycleMutex.Lock()
+	defer s.cycleMutex.Unlock()
+
 	initLogs.LogIPBanInfo("Начало проверки...")
 
-	// Получаем все конфиги из панели
-	allConfigs, err := client.All(s.ConfigManager)
+	// Получаем все конфиги и их трафик из панели
+	allConfigs, traffic, err := client.AllWithTraffic(s.ConfigManager)
 	if err != nil {
 		initLogs.LogIPBanError("Ошибка получения конфигов из панели: %v", err)
 		return
@@ -99,6 +130,28 @@ func (s *IPBanService) performCheck() {
 		return
 	}
 
+	// Следы прерванных агрессивных сбросов ("-reset" email, расходящиеся флаги) исправляем до анализа
+	// и перечитываем клиентов: решения цикла принимаются по исходным email
+	if s.recoverResets(allConfigs) > 0 {
+		if allConfigs, traffic, err = client.AllWithTraffic(s.ConfigManager); err != nil {
+			initLogs.LogIPBanError("Ошибка получения конфигов из панели: %v", err)
+			return
+		}
+	}
+
+	// Сверяем таблицу идентичностей (переименования, смена UUID) и переносим баны на стабильные ключи
+	if s.BanManager.Identities != nil {
+		s.BanManager.Identities.Sync(allConfigs)
+		s.BanManager.MigrateKeys()
+	}
+	// Баны версии без журнала отключений переносятся в журнал при первом цикле (один раз)
+	s.seedDisables()
+
+	// Показания трафика цикла: расход с прошлого цикла и за окно учёта
+	if s.Traffic != nil {
+		s.Traffic.Observe(traffic, s.BanManager.KeyFor)
+	}
+
 	// Анализируем лог файл для получения статистики IP
 	logStats, err := s.Analyzer.AnalyzeLog()
 	if err != nil {
@@ -106,11 +159,13 @@ func (s *IPBanService) performCheck() {
 		return
 	}
 
-	// Создаем карту статистики IP по email
-	ipStatsMap := make(map[string]*analyzerLogs.EmailIPStats)
-	for _, stats := range logStats {
-		ipStatsMap[stats.Email] = stats
-	}
+	// Изменения клиентов копятся до конца цикла и записываются в панель одним обновлением;
+	// агрессивный сброс при бане выполняется сразу (две последовательные записи)
+	s.beginCycleChanges(allConfigs, traffic)
+
+	// Создаем карту статистики IP по стабильному ключу идентичности
+	// (строки лога под старым email или под временным "-reset" email сводятся к одному клиенту)
+	ipStatsMap := s.statsByIdentity(logStats)
 
 	// Очищаем истекшие баны
 	s.BanManager.CleanupExpiredBans()
@@ -121,8 +176,8 @@ func (s *IPBanService) performCheck() {
 	// Обрабатываем каждый конфиг из панели
 	suspiciousCount := 0
 	normalCount := 0
-	enabledCount := 0
 	bannedCount := 0
+	keptDisabledCount := 0
 
 	for _, config := range allConfigs {
 		// Проверяем, не забанен ли пользователь
@@ -131,10 +186,27 @@ func (s *IPBanService) performCheck() {
 			initLogs.LogIPBanInfo("Забаненный конфиг: %s (бан до: %s)", config.Email, banInfo.ExpiresAt.Format("15:04:05 02.01.2006"))
 			bannedCount++
 
+			// Бан per_ip не отключает конфиг: блокируем только новые лишние IP, если они появились
+			if banInfo.Enforcement == EnforcementPerIP {
+				if ipStats, ok := ipStatsMap[s.BanManager.KeyFor(config.Email)]; ok {
+					s.enforcePerIP(ipStats)
+				}
+				continue
+			}
+			// Троттлинг: если нарушение продолжается, применяем политику заново
+			// (throttle — ограничиваем новые IP, escalate — повторное нарушение ведёт к бану)
+			if banInfo.Enforcement == EnforcementThrottle {
+				if ipStats, ok := ipStatsMap[s.BanManager.KeyFor(config.Email)]; ok && s.violates(ipStats) {
+					s.handleSuspiciousConfig(ipStats)
+				}
+				continue
+			}
+
 			// ВАЖНО: Если забаненный конфиг включен в панели — применяем АГРЕССИВНЫЙ сброс
 			if config.Enable {
 				initLogs.LogIPBanInfo("Забаненный конфиг %s включен — выполняем агрессивный сброс", config.Email)
-				if _, err := client.AggressiveBanReset(s.ConfigManager, config.Email); err != nil {
+				s.recordDisable(config.Email, banInfo.Reason)
+				if err := s.aggressiveReset(config.Email); err != nil {
 					initLogs.LogIPBanError("Ошибка AggressiveBanReset для %s: %v", config.Email, err)
 				} else {
 					initLogs.LogIPBanInfo("Забаненный конфиг %s агрессивно сброшен (enable=false, depleted/exhausted=true, UUID обновлён)", config.Email)
@@ -145,13 +217,18 @@ func (s *IPBanService) performCheck() {
 			continue
 		}
 
+		// Включенный и не забаненный конфиг больше не считается отключенным сервисом
+		if config.Enable {
+			s.forgetDisable(config.Email)
+		}
+
 		// Получаем статистику IP для этого конфига
-		ipStats, hasActivity := ipStatsMap[config.Email]
+		ipStats, hasActivity := ipStatsMap[s.BanManager.KeyFor(config.Email)]
 
 		if hasActivity {
 			// Конфиг имеет активность в логах
-			if ipStats.TotalIPs > s.MaxIPs {
-				// Подозрительный конфиг - баним
+			if s.violates(ipStats) {
+				// Подозрительный конфиг (лимит IP или порог трафика) - баним
 				suspiciousCount++
 				s.handleSuspiciousConfig(ipStats)
 			} else {
@@ -162,14 +239,21 @@ func (s *IPBanService) performCheck() {
 		} else {
 			// Конфиг не имеет активности в логах
 			if !config.Enable {
-				// Отключенный конфиг без активности - включаем
-				initLogs.LogIPBanInfo("Конфиг без активности: %s (отключен, включаем)", config.Email)
-				if err := client.EnableConfig(s.ConfigManager, config.Email); err != nil {
-					initLogs.LogIPBanError("Ошибка включения конфига %s: %v", config.Email, err)
-				} else {
-					initLogs.LogIPBanInfo("Конфиг %s успешно включен", config.Email)
-					enabledCount++
+				// Конфиг, отключенный администратором, истёкший или исчерпавший трафик, не включаем
+				if s.enableBlocked(config.Email) != "" {
+					keptDisabledCount++
+					continue
 				}
+				// Отключенный сервисом конфиг без активности - включаем
+				initLogs.LogIPBanInfo("Конфиг без активности: %s (отключен, включаем)", config.Email)
+				s.changeClient(config.Email, actionEnableIdle, client.PatchEnable(true), func(r client.ChangeResult) {
+					if r.Err != nil {
+						initLogs.LogIPBanError("Ошибка включения конфига %s: %v", r.Selector.Email, r.Err)
+					} else {
+						s.forgetDisable(r.Selector.Email)
+						initLogs.LogIPBanInfo("Конфиг %s успешно включен", r.Selector.Email)
+					}
+				})
 			} else {
 				// Включенный конфиг без активности - оставляем как есть, логировать не нужно
 			}
@@ -178,93 +262,187 @@ func (s *IPBanService) performCheck() {
 
 	// Фаза 2: проверяем возможность разбана и включения нормализовавшихся конфигов
 	unbannedCount := 0
-	reEnabledCount := 0
 
 	for _, config := range allConfigs {
 		// Обрабатываем только тех, кто находится в бане
 		if !s.BanManager.IsBanned(config.Email) {
 			continue
 		}
+		// Блокировка лишних IP (per_ip) и троттлинг держатся весь срок наказания: заблокированные IP
+		// пропадают из логов, и досрочный разбан по числу IP сразу вернул бы их
+		if ban := s.BanManager.GetBanInfo(config.Email); ban != nil &&
+			(ban.Enforcement == EnforcementPerIP || ban.Enforcement == EnforcementThrottle) {
+			continue
+		}
 
 		// Получаем статистику IP (если нет активности — считаем 0 IP)
-		ipStats, hasActivity := ipStatsMap[config.Email]
+		ipStats, hasActivity := ipStatsMap[s.BanManager.KeyFor(config.Email)]
 		ipCount := 0
 		if hasActivity {
 			ipCount = ipStats.TotalIPs
 		}
 
-		// Если количество IP не превышает лимит — разбаниваем и включаем конфиг
-		if ipCount <= s.MaxIPs {
+		// Если количество IP не превышает лимит и расход трафика в норме — разбаниваем и включаем конфиг
+		if ipCount <= s.MaxIPs && s.trafficAbuse(config.Email) == "" {
 			initLogs.LogIPBanInfo("Разбан и повторное включение: %s (IP: %d, лимит: %d)", config.Email, ipCount, s.MaxIPs)
 
 			// Разбан
-            if err := s.BanManager.UnbanUser(config.Email); err != nil {
-                initLogs.LogIPBanError("Ошибка разбана %s: %v", config.Email, err)
-            } else {
-                unbannedCount++
-
-                // Через 10 секунд после успешного разбана — добавить +1 день к подписке
-                time.AfterFunc(10*time.Second, func() {
-                    if err := adjustingdays.AddOneDay(s.ConfigManager, config.Email); err != nil {
-                        initLogs.LogIPBanError("Ошибка добавления +1 дня для %s: %v", config.Email, err)
-                    } else {
-                        initLogs.LogIPBanInfo("   🎁 +1 день добавлен для %s после разбана", config.Email)
-                    }
-                })
-            }
-
-			// После разбана: сбросить статус "исчерпано" (depleted/exhausted=false)
-			if err := client.ResetDepletedStatus(s.ConfigManager, config.Email); err != nil {
-				initLogs.LogIPBanError("Ошибка сброса статуса 'исчерпано' для %s: %v", config.Email, err)
+			if err := s.BanManager.UnbanUser(config.Email); err != nil {
+				initLogs.LogIPBanError("Ошибка разбана %s: %v", config.Email, err)
 			} else {
-				initLogs.LogIPBanInfo("   ✅ Снят статус 'исчерпано' для %s", config.Email)
+				unbannedCount++
+
+				// После успешного разбана — +1 день к подписке (в той же записи цикла, что и включение)
+				s.changeClient(config.Email, actionAddDayAfterUnban, adjustingdays.PatchAddOneDay(time.Now()), func(r client.ChangeResult) {
+					if r.Err != nil {
+						initLogs.LogIPBanError("Ошибка добавления +1 дня для %s: %v", config.Email, r.Err)
+					} else {
+						initLogs.LogIPBanInfo("   🎁 +1 день добавлен для %s после разбана", config.Email)
+					}
+				})
 			}
 
-			// Разблокируем IP в iptables (если были зафиксированы)
+			// После разбана: снять "исчерпано", разблокировать IP и включить конфиг
+			var seenIPs []string
 			if hasActivity {
-				unblocked := 0
 				for ip := range ipStats.IPs {
-					if s.IPTables.IsIPBlocked(ip) {
-						if err := s.IPTables.UnblockIP(ip); err != nil {
-							initLogs.LogIPBanError("Ошибка разблокировки IP %s: %v", ip, err)
-						} else {
-							unblocked++
-						}
-					}
-				}
-				if unblocked > 0 {
-					initLogs.LogIPBanInfo("   ✅ Разблокировано %d IP адресов через iptables", unblocked)
+					seenIPs = append(seenIPs, ip)
 				}
 			}
+			s.restoreAfterUnban(config.Email, seenIPs)
+		}
+	}
 
-			// Включаем конфиг в панели при необходимости
-			currentStatus, err := client.Status(s.ConfigManager, config.Email)
-			if err != nil {
-				initLogs.LogIPBanError("Ошибка получения статуса конфига %s: %v", config.Email, err)
-			} else if !currentStatus {
-				if err := client.EnableConfig(s.ConfigManager, config.Email); err != nil {
-					initLogs.LogIPBanError("Ошибка включения конфига %s после разбана: %v", config.Email, err)
-				} else {
-					initLogs.LogIPBanInfo("   ✅ Конфиг %s включен после разбана", config.Email)
-					reEnabledCount++
-				}
-			}
+	// Записываем накопленные изменения клиентов одним обновлением inbound
+	changed := s.flushCycleChanges()
+	enabledCount := changed[actionEnableIdle]
+	reEnabledCount := changed[actionEnableAfterUnban]
+
+	// Файрвол следует за банами: блокировки истекших банов снимаются, потерянные — восстанавливаются
+	if _, err := ReconcileFirewall(s.Firewall, s.BanManager, true); err != nil {
+		initLogs.LogIPBanError("%v", err)
+	}
+	if s.Throttler != nil {
+		if _, err := ReconcileThrottle(s.Throttler, s.BanManager, true); err != nil {
+			initLogs.LogIPBanError("%v", err)
 		}
 	}
 
 	initLogs.LogIPBanInfo("Подозрительных конфигов: %d", suspiciousCount)
 	initLogs.LogIPBanInfo("Нормальных конфигов: %d", normalCount)
 	initLogs.LogIPBanInfo("Включено отключенных: %d", enabledCount)
+	initLogs.LogIPBanInfo("Оставлено отключенными (ручное отключение, срок или трафик): %d", keptDisabledCount)
 	initLogs.LogIPBanInfo("Забаненных конфигов: %d", bannedCount)
 	initLogs.LogIPBanInfo("Разбанено конфигов: %d", unbannedCount)
 	initLogs.LogIPBanInfo("Повторно включено после разбана: %d", reEnabledCount)
 	initLogs.LogIPBanInfo("Проверка завершена")
 }
 
+// statsByIdentity группирует статистику анализатора по стабильному ключу идентичности.
+// Если клиент встречался в логах под несколькими email, его IP объединяются,
+// а Email результата — текущий email клиента в панели. Статистика анализатора не изменяется.
+func (s *IPBanService) statsByIdentity(logStats map[string]*analyzerLogs.EmailIPStats) map[string]*analyzerLogs.EmailIPStats {
+	result := make(map[string]*analyzerLogs.EmailIPStats)
+	for _, stats := range logStats {
+		key := s.BanManager.KeyFor(stats.Email)
+
+		merged, exists := result[key]
+		if !exists {
+			email := stats.Email
+			if s.BanManager.Identities != nil {
+				if current := s.BanManager.Identities.CurrentEmail(key); current != "" {
+					email = current
+				}
+			}
+			merged = &analyzerLogs.EmailIPStats{
+				Email: email,
+				IPs:   make(map[string]*analyzerLogs.IPActivity),
+			}
+			result[key] = merged
+		}
+
+		for ip, activity := range stats.IPs {
+			existing, ok := merged.IPs[ip]
+			if !ok {
+				a := *activity
+				merged.IPs[ip] = &a
+				continue
+			}
+			existing.Count += activity.Count
+			if activity.LastSeen.After(existing.LastSeen) {
+				existing.LastSeen = activity.LastSeen
+			}
+			if activity.FirstSeen.Before(existing.FirstSeen) {
+				existing.FirstSeen = activity.FirstSeen
+			}
+		}
+		if stats.LastUpdate.After(merged.LastUpdate) {
+			merged.LastUpdate = stats.LastUpdate
+		}
+		merged.TotalIPs = len(merged.IPs)
+	}
+	return result
+}
+
+// restoreAfterUnban возвращает конфиг в рабочее состояние после снятия бана:
+// сбрасывает depleted/exhausted, разблокирует перечисленные IP на файрволе и включает конфиг в панели.
+// Возвращает число разблокированных IP и признак того, что конфиг был включен.
+// В цикле проверки изменения панели только добавляются в набор цикла — тогда признак всегда false.
+// Журнал отключений здесь не проверяется: конфиг отключил сам сервис — об этом говорит снятый бан.
+func (s *IPBanService) restoreAfterUnban(email string, ips []string) (int, bool) {
+	// Конфиг, исчерпавший трафик или срок в панели, остаётся отключенным (KEEP_DEPLETED_DISABLED)
+	keepDisabled := s.trafficCapReached(email)
+
+	// Сбросить статус "исчерпано" (depleted/exhausted=false)
+	if keepDisabled == "" {
+		s.changeClient(email, actionResetDepleted, client.PatchResetDepleted, func(r client.ChangeResult) {
+			if r.Err != nil {
+				initLogs.LogIPBanError("Ошибка сброса статуса 'исчерпано' для %s: %v", email, r.Err)
+			} else {
+				initLogs.LogIPBanInfo("   ✅ Снят статус 'исчерпано' для %s", email)
+			}
+		})
+	}
+
+	// Разблокируем IP на файрволе (если были зафиксированы)
+	unblocked := 0
+	for _, ip := range ips {
+		if s.Firewall.IsIPBlocked(ip) {
+			if err := s.Firewall.UnblockIP(ip); err != nil {
+				initLogs.LogIPBanError("Ошибка разблокировки IP %s: %v", ip, err)
+			} else {
+				unblocked++
+			}
+		}
+	}
+	if unblocked > 0 {
+		initLogs.LogIPBanInfo("   ✅ Разблокировано %d IP адресов на файрволе", unblocked)
+	}
+
+	if keepDisabled != "" {
+		initLogs.LogIPBanInfo("   ⏸️  Конфиг %s остаётся отключенным после разбана: %s", email, keepDisabled)
+		return unblocked, false
+	}
+
+	// Включаем конфиг в панели; уже включенный конфиг не записывается
+	enabled := false
+	s.changeClient(email, actionEnableAfterUnban, client.PatchEnable(true), func(r client.ChangeResult) {
+		if r.Err != nil {
+			initLogs.LogIPBanError("Ошибка включения конфига %s после разбана: %v", email, r.Err)
+			return
+		}
+		s.forgetDisable(email)
+		if r.Changed {
+			enabled = true
+			initLogs.LogIPBanInfo("   ✅ Конфиг %s включен после разбана", email)
+		}
+	})
+	return unblocked, enabled
+}
+
 // handleSuspiciousConfig обрабатывает подозрительный конфиг
 func (s *IPBanService) handleSuspiciousConfig(stats *analyzerLogs.EmailIPStats) {
-	initLogs.LogIPBanInfo("Подозрительный конфиг: %s (IP адресов: %d, максимум: %d)",
-		stats.Email, stats.TotalIPs, s.MaxIPs)
+	initLogs.LogIPBanInfo("Подозрительный конфиг: %s (%s)", stats.Email, s.violationReason(stats))
 
 	// Собираем список IP адресов для уведомления
 	var ipAddresses []string
@@ -273,6 +451,17 @@ func (s *IPBanService) handleSuspiciousConfig(stats *analyzerLogs.EmailIPStats)
 			ip, activity.Count, activity.LastSeen.Format("15:04:05"))
 		ipAddresses = append(ipAddresses, ip)
 	}
+	// Строки для fail2ban (пишутся, только если LOG_VIOLATIONS): только IP сверх лимита,
+	// самые давние IP владельца конфига в журнал не попадают и jail их не банит
+	_, excess := selectExcessIPs(stats, s.MaxIPs, nil)
+	for _, ip := range excess {
+		initLogs.LogViolation(stats.Email, ip, stats.TotalIPs)
+	}
+
+	// Режимы per_ip, throttle и escalate не отключают конфиг (escalate — до повторного нарушения)
+	if s.applyPolicy(stats) {
+		return
+	}
 
 	// Проверяем, не забанен ли уже пользователь
 	if s.BanManager.IsBanned(stats.Email) {
@@ -283,7 +472,7 @@ func (s *IPBanService) handleSuspiciousConfig(stats *analyzerLogs.EmailIPStats)
 	}
 
 	// Баним пользователя
-	reason := fmt.Sprintf("Превышение лимита IP адресов: %d (максимум: %d)", stats.TotalIPs, s.MaxIPs)
+	reason := s.violationReason(stats)
 	initLogs.LogIPBanInfo("Начало банирования пользователя %s (IP адресов: %d, лимит: %d)", stats.Email, stats.TotalIPs, s.MaxIPs)
 
 	if err := s.BanManager.BanUser(stats.Email, reason, ipAddresses); err != nil {
@@ -295,11 +484,15 @@ func (s *IPBanService) handleSuspiciousConfig(stats *analyzerLogs.EmailIPStats)
 
 	// Агрессивный сброс: отключение, выставление depleted/exhausted, смена email(-reset) и UUID, двойной апдейт + ресет Remark
 	initLogs.LogIPBanInfo("   🔒 Агрессивный сброс для %s...", stats.Email)
-	if _, err := client.AggressiveBanReset(s.ConfigManager, stats.Email); err != nil {
+	s.recordDisable(stats.Email, reason)
+	if err := s.aggressiveReset(stats.Email); err != nil {
 		initLogs.LogIPBanError("❌ Ошибка AggressiveBanReset для %s: %v", stats.Email, err)
 	} else {
 		initLogs.LogIPBanInfo("   ✅ Агрессивный сброс применён для %s", stats.Email)
 	}
+
+	// Конфиг отключен, но открытые соединения продолжают работать — разрываем их
+	s.killConnections(stats.Email, ipAddresses)
 }
 
 // handleNormalConfig обрабатывает нормальный конфиг
@@ -314,7 +507,7 @@ func (s *IPBanService) handleNormalConfig(stats *analyzerLogs.EmailIPStats) {
 
 		unblockedCount := 0
 		for ip := range stats.IPs {
-			if err := s.IPTables.UnblockIP(ip); err != nil {
+			if err := s.Firewall.UnblockIP(ip); err != nil {
 				initLogs.LogIPBanError("Ошибка разблокировки IP %s: %v", ip, err)
 			} else {
 				unblockedCount++
@@ -322,21 +515,29 @@ func (s *IPBanService) handleNormalConfig(stats *analyzerLogs.EmailIPStats) {
 		}
 
 		if unblockedCount > 0 {
-			initLogs.LogIPBanInfo("   ✅ Разблокировано %d IP адресов через iptables", unblockedCount)
+			initLogs.LogIPBanInfo("   ✅ Разблокировано %d IP адресов на файрволе", unblockedCount)
 		}
 	} else {
 		// ВАЖНО: Проверяем статус конфига в панели - если он отключен, включаем его
-		currentStatus, err := client.Status(s.ConfigManager, stats.Email)
+		currentStatus, err := s.clientEnabled(stats.Email)
 		if err != nil {
 			initLogs.LogIPBanError("Ошибка получения статуса нормального конфига %s: %v", stats.Email, err)
 		} else if !currentStatus {
-			// Конфиг отключен в панели, но активность нормальная - включаем его
-			initLogs.LogIPBanInfo("   🔓 Нормальный конфиг %s отключен в панели - включаем!", stats.Email)
-			if err := client.EnableConfig(s.ConfigManager, stats.Email); err != nil {
-				initLogs.LogIPBanError("Ошибка включения нормального конфига %s: %v", stats.Email, err)
-			} else {
-				initLogs.LogIPBanInfo("   ✅ Нормальный конфиг %s успешно включен в панели", stats.Email)
+			// Конфиг, отключенный администратором, истёкший или исчерпавший трафик, не включаем
+			if reason := s.enableBlocked(stats.Email); reason != "" {
+				initLogs.LogIPBanInfo("   ⏸️  Нормальный конфиг %s отключен в панели и остаётся отключенным: %s", stats.Email, reason)
+				return
 			}
+			// Конфиг отключен сервисом, но активность нормальная - включаем его
+			initLogs.LogIPBanInfo("   🔓 Нормальный конфиг %s отключен в панели - включаем!", stats.Email)
+			s.changeClient(stats.Email, actionEnableNormal, client.PatchEnable(true), func(r client.ChangeResult) {
+				if r.Err != nil {
+					initLogs.LogIPBanError("Ошибка включения нормального конфига %s: %v", stats.Email, r.Err)
+				} else {
+					s.forgetDisable(stats.Email)
+					initLogs.LogIPBanInfo("   ✅ Нормальный конфиг %s успешно включен в панели", stats.Email)
+				}
+			})
 		}
 		// Если конфиг уже включен и работает нормально, дополнительное логирование не требуется,
 		// так как основная информация уже была залогирована в начале функции.
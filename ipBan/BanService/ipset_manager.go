@@ -0,0 +1,169 @@
+package ipban
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"ipBanSystem/ipBan/logger/initLogs"
+)
+
+// ipsetMaxTimeout — максимальный таймаут элемента ipset (секунды)
+const ipsetMaxTimeout = 2147483
+
+// setV4 возвращает имя набора для IPv4
+func (i *IPTablesManager) setV4() string { return i.SetName + "4" }
+
+// setV6 возвращает имя набора для IPv6
+func (i *IPTablesManager) setV6() string { return i.SetName + "6" }
+
+// ensureIPSets создаёт наборы hash:ip (IPv4 и IPv6) с поддержкой таймаутов
+// и добавляет в цепочку сервиса по одному правилу DROP на набор, если его ещё нет
+func (i *IPTablesManager) ensureIPSets() error {
+	var batch strings.Builder
+	fmt.Fprintf(&batch, "create %s hash:ip family inet timeout 0\n", i.setV4())
+	fmt.Fprintf(&batch, "create %s hash:ip family inet6 timeout 0\n", i.setV6())
+	if err := runIPSetRestore(batch.String()); err != nil {
+		return fmt.Errorf("ошибка создания наборов ipset %s: %v", i.SetName, err)
+	}
+
+	rules := []struct {
+		binary string
+		set    string
+	}{
+		{"iptables", i.setV4()},
+		{"ip6tables", i.setV6()},
+	}
+	for _, r := range rules {
+		rule := []string{i.Chain, "-m", "set", "--match-set", r.set, "src", "-j", "DROP"}
+		// Правило уже есть — ничего не делаем (iptables -C возвращает 0)
+		if commandSucceeds(r.binary, append([]string{"-C"}, rule...)...) {
+			continue
+		}
+		if out, err := runCommand(r.binary, append([]string{"-I"}, rule...)...); err != nil {
+			return fmt.Errorf("ошибка добавления правила %s для набора %s: %v: %s", r.binary, r.set, err, strings.TrimSpace(string(out)))
+		}
+	}
+	return nil
+}
+
+// ipsetBlock добавляет адреса в наборы одним пакетом "ipset restore".
+// Повторное добавление обновляет таймаут (флаг -exist).
+func (i *IPTablesManager) ipsetBlock(ipAddresses []string, timeout time.Duration) error {
+	v4, v6, err := splitIPFamilies(ipAddresses)
+	if err != nil {
+		return err
+	}
+	if len(v4)+len(v6) == 0 {
+		return nil
+	}
+
+	seconds := int64(0) // 0 — элемент без истечения
+	if timeout > 0 {
+		seconds = int64((timeout + time.Second - 1) / time.Second)
+		if seconds > ipsetMaxTimeout {
+			seconds = ipsetMaxTimeout
+		}
+	}
+
+	var batch strings.Builder
+	for _, ip := range v4 {
+		fmt.Fprintf(&batch, "add %s %s timeout %d\n", i.setV4(), ip, seconds)
+	}
+	for _, ip := range v6 {
+		fmt.Fprintf(&batch, "add %s %s timeout %d\n", i.setV6(), ip, seconds)
+	}
+	if err := runIPSetRestore(batch.String()); err != nil {
+		initLogs.LogIPBanError("Ошибка блокировки %d IP через ipset: %v", len(v4)+len(v6), err)
+		return fmt.Errorf("ошибка блокировки IP через ipset: %v", err)
+	}
+
+	var expiresAt time.Time
+	if seconds > 0 {
+		expiresAt = time.Now().Add(time.Duration(seconds) * time.Second)
+	}
+	all := append(v4, v6...)
+	i.mutex.Lock()
+	for _, ip := range all {
+		i.BlockedIPs[ip] = true
+		i.expiresAt[ip] = expiresAt
+	}
+	i.mutex.Unlock()
+
+	for _, ip := range all {
+		initLogs.LogIPBanAction("IP_ЗАБЛОКИРОВАН", ip, 0, []string{})
+	}
+	return nil
+}
+
+// ipsetUnblock удаляет адреса из наборов одним пакетом; отсутствующие адреса пропускаются (флаг -exist)
+func (i *IPTablesManager) ipsetUnblock(ipAddresses []string) error {
+	v4, v6, err := splitIPFamilies(ipAddresses)
+	if err != nil {
+		return err
+	}
+	if len(v4)+len(v6) == 0 {
+		return nil
+	}
+
+	var batch strings.Builder
+	for _, ip := range v4 {
+		fmt.Fprintf(&batch, "del %s %s\n", i.setV4(), ip)
+	}
+	for _, ip := range v6 {
+		fmt.Fprintf(&batch, "del %s %s\n", i.setV6(), ip)
+	}
+	if err := runIPSetRestore(batch.String()); err != nil {
+		initLogs.LogIPBanError("Ошибка разблокировки %d IP через ipset: %v", len(v4)+len(v6), err)
+		return fmt.Errorf("ошибка разблокировки IP через ipset: %v", err)
+	}
+
+	all := append(v4, v6...)
+	i.mutex.Lock()
+	for _, ip := range all {
+		delete(i.BlockedIPs, ip)
+		delete(i.expiresAt, ip)
+	}
+	i.mutex.Unlock()
+
+	for _, ip := range all {
+		initLogs.LogIPBanAction("IP_РАЗБЛОКИРОВАН", ip, 0, []string{})
+	}
+	return nil
+}
+
+// runIPSetRestore применяет пакет команд ipset одним процессом
+func runIPSetRestore(batch string) error {
+	if out, err := runCommandInput(batch, "ipset", "-exist", "restore"); err != nil {
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// listIPSetMembers читает содержимое наборов сервиса ("ipset save"): IP -> оставшийся таймаут (0 — бессрочно)
+func (i *IPTablesManager) listIPSetMembers() (map[string]time.Duration, error) {
+	members := make(map[string]time.Duration)
+	for _, set := range []string{i.setV4(), i.setV6()} {
+		out, err := runCommand("ipset", "save", set)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка чтения набора ipset %s: %v: %s", set, err, strings.TrimSpace(string(out)))
+		}
+		// Строки вида: "add ipban4 203.0.113.7 timeout 3581"
+		for _, line := range strings.Split(string(out), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) < 3 || fields[0] != "add" || fields[1] != set {
+				continue
+			}
+			var remaining time.Duration
+			for n := 3; n+1 < len(fields); n++ {
+				if fields[n] == "timeout" {
+					var sec int64
+					fmt.Sscanf(fields[n+1], "%d", &sec)
+					remaining = time.Duration(sec) * time.Second
+				}
+			}
+			members[fields[2]] = remaining
+		}
+	}
+	return members, nil
+}
@@ -0,0 +1,269 @@
+package ipban
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"ipBanSystem/ipBan/logger/initLogs"
+)
+
+// iptablesFamilies — утилиты обоих семейств: цепочка сервиса создаётся и в iptables, и в ip6tables
+var iptablesFamilies = []string{"iptables", "ip6tables"}
+
+// iptablesBinary возвращает утилиту для семейства адреса
+func iptablesBinary(ipAddress string) string {
+	if parsed := net.ParseIP(ipAddress); parsed != nil && parsed.To4() == nil {
+		return "ip6tables"
+	}
+	return "iptables"
+}
+
+// ensureChain создаёт цепочку сервиса и переходы в неё из INPUT по области блокировки (Scope)
+func (i *IPTablesManager) ensureChain() error {
+	return ensureJumpChain("INPUT", i.Chain, i.Scope, "--dports")
+}
+
+// ensureJumpChain создаёт цепочку и переходы в неё из parent по области блокировки
+// (portFlag: "--dports" для входящего трафика, "--sports" для исходящего).
+// Переходы, не совпадающие с областью (например, после смены FIREWALL_SCOPE), заменяются.
+// При scope == nil существующие переходы не трогаются; если их нет — добавляется переход для всего трафика.
+func ensureJumpChain(parent, chain string, scope *FirewallScope, portFlag string) error {
+	for _, binary := range iptablesFamilies {
+		// -S завершается ошибкой, если цепочки нет
+		if !commandSucceeds(binary, "-S", chain) {
+			if out, err := runCommand(binary, "-N", chain); err != nil {
+				return fmt.Errorf("ошибка создания цепочки %s (%s): %v: %s", chain, binary, err, strings.TrimSpace(string(out)))
+			}
+		}
+
+		existing, err := listJumpRules(binary, parent, chain)
+		if err != nil {
+			return err
+		}
+		effective := scope
+		if effective == nil {
+			if len(existing) > 0 {
+				continue
+			}
+			effective = HostScope()
+		}
+
+		desired := jumpSpecs(effective, chain, portFlag)
+		upToDate := len(existing) == len(desired)
+		for _, spec := range desired {
+			if !upToDate {
+				break
+			}
+			upToDate = commandSucceeds(binary, append([]string{"-C", parent}, spec...)...)
+		}
+		if upToDate {
+			continue
+		}
+
+		for _, spec := range existing {
+			if out, err := runCommand(binary, append([]string{"-D", parent}, spec...)...); err != nil {
+				return fmt.Errorf("ошибка удаления перехода %s -> %s (%s): %v: %s", parent, chain, binary, err, strings.TrimSpace(string(out)))
+			}
+		}
+		for _, spec := range desired {
+			if out, err := runCommand(binary, append([]string{"-I", parent}, spec...)...); err != nil {
+				return fmt.Errorf("ошибка добавления перехода %s -> %s (%s): %v: %s", parent, chain, binary, err, strings.TrimSpace(string(out)))
+			}
+		}
+	}
+	return nil
+}
+
+// iptablesMultiportMax — максимум портов в одном правиле multiport
+const iptablesMultiportMax = 15
+
+// jumpSpecs возвращает спецификации правил перехода в цепочку сервиса для области блокировки
+func jumpSpecs(scope *FirewallScope, chain, portFlag string) [][]string {
+	if scope.HostWide() {
+		return [][]string{{"-j", chain}}
+	}
+	var specs [][]string
+	groups := scope.PortsByProto()
+	for _, proto := range []string{"tcp", "udp"} {
+		ports := groups[proto]
+		for start := 0; start < len(ports); start += iptablesMultiportMax {
+			end := start + iptablesMultiportMax
+			if end > len(ports) {
+				end = len(ports)
+			}
+			specs = append(specs, []string{"-p", proto, "-m", "multiport", portFlag, joinPorts(ports[start:end], ","), "-j", chain})
+		}
+	}
+	return specs
+}
+
+// listJumpRules читает правила parent, ведущие в цепочку сервиса (спецификации без "-A <parent>")
+func listJumpRules(binary, parent, chain string) ([][]string, error) {
+	out, err := runCommand(binary, "-S", parent)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения цепочки %s (%s): %v: %s", parent, binary, err, strings.TrimSpace(string(out)))
+	}
+	var specs [][]string
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 || fields[0] != "-A" || fields[1] != parent {
+			continue
+		}
+		if fields[len(fields)-2] == "-j" && fields[len(fields)-1] == chain {
+			specs = append(specs, fields[2:])
+		}
+	}
+	return specs, nil
+}
+
+// listDropRules читает правила "-s <ip> -j DROP" цепочки в обоих семействах: IP -> число одинаковых правил
+func listDropRules(chain string) (map[string]int, error) {
+	rules := make(map[string]int)
+	for _, binary := range iptablesFamilies {
+		out, err := runCommand(binary, "-S", chain)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка чтения цепочки %s (%s): %v: %s", chain, binary, err, strings.TrimSpace(string(out)))
+		}
+		// Строки вида: "-A IPBAN -s 203.0.113.7/32 -j DROP"; остальные правила (переходы, ipset) пропускаются
+		for _, line := range strings.Split(string(out), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) != 6 || fields[0] != "-A" || fields[1] != chain || fields[2] != "-s" || fields[4] != "-j" || fields[5] != "DROP" {
+				continue
+			}
+			ip, ipNet, err := net.ParseCIDR(fields[3])
+			if err != nil {
+				continue
+			}
+			// Сервис блокирует только отдельные адреса (/32, /128); подсети — чужие правила
+			if ones, bits := ipNet.Mask.Size(); ones != bits {
+				continue
+			}
+			rules[ip.String()]++
+		}
+	}
+	return rules, nil
+}
+
+// deleteDropRule удаляет одно правило "-s <ip> -j DROP" из цепочки
+func deleteDropRule(chain, ipAddress string) error {
+	if out, err := runCommand(iptablesBinary(ipAddress), "-D", chain, "-s", ipAddress, "-j", "DROP"); err != nil {
+		return fmt.Errorf("ошибка удаления правила %s из %s: %v: %s", ipAddress, chain, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// SetLegacyOwned задаёт IP из записей хранилища банов (см. RecordedFirewallIPs) для следующей сверки
+func (i *IPTablesManager) SetLegacyOwned(owned map[string]bool) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	i.legacy = owned
+}
+
+// Reconcile восстанавливает состояние менеджера по правилам цепочки (или наборам ipset) и сверяет его с активными банами.
+// Правила "-s <ip> -j DROP" прямо в INPUT могли остаться от версий сервиса без собственной цепочки.
+// Своими считаются только правила для IP из записей хранилища банов (SetLegacyOwned): при применении
+// они переносятся в цепочку (если бан ещё активен) или удаляются. Остальные правила INPUT поставлены
+// не сервисом — они попадают в отчёт (Foreign) и никогда не удаляются.
+func (i *IPTablesManager) Reconcile(expected map[string]time.Time, apply bool) (*FirewallDrift, error) {
+	var drift *FirewallDrift
+	if i.Mode == IPTablesModeIPSet {
+		members, err := i.listIPSetMembers()
+		if err != nil {
+			return nil, err
+		}
+		found := make(map[string]int, len(members))
+		i.mutex.Lock()
+		i.BlockedIPs = make(map[string]bool, len(members))
+		i.expiresAt = make(map[string]time.Time, len(members))
+		for ip, remaining := range members {
+			found[ip] = 1
+			i.BlockedIPs[ip] = true
+			if remaining > 0 {
+				i.expiresAt[ip] = time.Now().Add(remaining)
+			} else {
+				i.expiresAt[ip] = time.Time{}
+			}
+		}
+		i.mutex.Unlock()
+		drift = classifyBlocked(found, expected)
+		drift.Backend = FirewallIPTables + "/" + IPTablesModeIPSet
+	} else {
+		found, err := listDropRules(i.Chain)
+		if err != nil {
+			return nil, err
+		}
+		i.mutex.Lock()
+		i.BlockedIPs = make(map[string]bool, len(found))
+		for ip := range found {
+			i.BlockedIPs[ip] = true
+		}
+		i.mutex.Unlock()
+		drift = classifyBlocked(found, expected)
+		drift.Backend = FirewallIPTables
+	}
+
+	legacy, err := listDropRules("INPUT")
+	if err != nil {
+		return nil, err
+	}
+	i.mutex.RLock()
+	owned := i.legacy
+	i.mutex.RUnlock()
+	for ip := range legacy {
+		if owned[ip] {
+			drift.Legacy = append(drift.Legacy, ip)
+		} else {
+			drift.Foreign = append(drift.Foreign, ip)
+			delete(legacy, ip)
+		}
+	}
+	sort.Strings(drift.Legacy)
+	sort.Strings(drift.Foreign)
+
+	if !apply {
+		return drift, nil
+	}
+
+	var failed []string
+	// Лишние блокировки: без активного бана и дубли
+	if i.Mode == IPTablesModeIPSet {
+		if err := i.ipsetUnblock(drift.Stale); err != nil {
+			failed = append(failed, drift.Stale...)
+		}
+	} else {
+		for _, ip := range append(append([]string{}, drift.Stale...), drift.Duplicates...) {
+			if err := deleteDropRule(i.Chain, ip); err != nil {
+				initLogs.LogIPBanError("%v", err)
+				failed = append(failed, ip)
+			}
+		}
+		i.mutex.Lock()
+		for _, ip := range drift.Stale {
+			delete(i.BlockedIPs, ip)
+		}
+		i.mutex.Unlock()
+		for _, ip := range drift.Stale {
+			initLogs.LogIPBanAction("IP_РАЗБЛОКИРОВАН", ip, 0, []string{})
+		}
+	}
+
+	// Правила старых версий сервиса удаляем из INPUT; если бан активен, IP попадёт в Missing и будет заблокирован в цепочке
+	for ip, count := range legacy {
+		for n := 0; n < count; n++ {
+			if err := deleteDropRule("INPUT", ip); err != nil {
+				initLogs.LogIPBanError("%v", err)
+				failed = append(failed, ip)
+			}
+		}
+	}
+	failed = append(failed, restoreMissing(i, drift.Missing, expected)...)
+
+	drift.Applied = true
+	if len(failed) > 0 {
+		return drift, fmt.Errorf("не удалось исправить %d правил: %s", len(failed), strings.Join(failed, ", "))
+	}
+	return drift, nil
+}
@@ -4,22 +4,68 @@ import (
 	"fmt"
 	"ipBanSystem/ipBan/logger/initLogs"
 	"net"
-	"os/exec"
 	"sync"
+	"time"
 )
 
 // IPTablesManager управляет блокировкой IP через iptables
 // mutex добавлен для предотвращения гонок при одновременном доступе к карте BlockedIPs
+// Все правила сервиса живут в собственной цепочке (Chain, по умолчанию IPBAN), на которую ведёт переход из INPUT:
+// так их можно перечислить после перезапуска и сверить с активными банами (см. Reconcile).
+// Режимы работы (Mode):
+//   - "rules" — отдельное правило DROP в цепочке на каждый IP (исходное поведение)
+//   - "ipset" — IP хранятся в наборах ipset hash:ip с таймаутами, в цепочке одно правило на семейство
 type IPTablesManager struct {
-	BlockedIPs map[string]bool // Карта заблокированных IP
-	mutex      sync.RWMutex    // Мьютекс для синхронизации доступа к карте BlockedIPs
+	Mode       string               // Режим: IPTablesModeRules или IPTablesModeIPSet
+	Chain      string               // Цепочка сервиса (таблица filter), переход в неё — из INPUT
+	Scope      *FirewallScope       // Какой трафик заблокированных IP отбрасывается (nil — не менять переходы)
+	SetName    string               // Базовое имя наборов ipset (к нему добавляются суффиксы 4/6)
+	BlockedIPs map[string]bool      // Карта заблокированных IP
+	expiresAt  map[string]time.Time // Момент истечения блокировки в режиме ipset (нулевое время — бессрочно)
+	legacy     map[string]bool      // IP из записей хранилища банов: только их правила старых версий в INPUT считаются своими
+	mutex      sync.RWMutex         // Мьютекс для синхронизации доступа к карте BlockedIPs
 }
 
-// NewIPTablesManager создает новый менеджер iptables
-func NewIPTablesManager() *IPTablesManager {
+// Режимы IPTablesManager (значения IPTABLES_MODE)
+const (
+	IPTablesModeRules = "rules"
+	IPTablesModeIPSet = "ipset"
+)
+
+// newIPTablesManager создает менеджер без обращения к iptables
+func newIPTablesManager(chain string, scope *FirewallScope) *IPTablesManager {
 	return &IPTablesManager{
+		Mode:       IPTablesModeRules,
+		Chain:      chain,
+		Scope:      scope,
 		BlockedIPs: make(map[string]bool),
+		expiresAt:  make(map[string]time.Time),
+	}
+}
+
+// NewIPTablesManager создает менеджер iptables в режиме отдельных правил и подготавливает цепочку сервиса
+func NewIPTablesManager(chain string, scope *FirewallScope) (*IPTablesManager, error) {
+	i := newIPTablesManager(chain, scope)
+	if err := i.ensureChain(); err != nil {
+		return nil, err
 	}
+	initLogs.LogIPBanInfo("iptables: цепочка %s готова (область: %s)", chain, scope)
+	return i, nil
+}
+
+// NewIPSetManager создает менеджер iptables в режиме ipset и подготавливает цепочку, наборы и правила
+func NewIPSetManager(chain, setName string, scope *FirewallScope) (*IPTablesManager, error) {
+	i := newIPTablesManager(chain, scope)
+	i.Mode = IPTablesModeIPSet
+	i.SetName = setName
+	if err := i.ensureChain(); err != nil {
+		return nil, err
+	}
+	if err := i.ensureIPSets(); err != nil {
+		return nil, err
+	}
+	initLogs.LogIPBanInfo("iptables: режим ipset, цепочка %s, наборы %s / %s готовы (область: %s)", chain, i.setV4(), i.setV6(), scope)
+	return i, nil
 }
 
 // validateIP проверяет, является ли строка действительным IP-адресом
@@ -38,6 +84,11 @@ func (i *IPTablesManager) BlockIP(ipAddress string) error {
 		return fmt.Errorf("недействительный IP-адрес: %s", ipAddress)
 	}
 
+	// В режиме ipset блокировка выполняется пакетом с таймаутом, равным длительности бана
+	if i.Mode == IPTablesModeIPSet {
+		return i.ipsetBlock([]string{ipAddress}, defaultBlockTimeout())
+	}
+
 	// Проверяем, не заблокирован ли уже IP
 	// Используем RLock для безопасного чтения из общей карты
 	i.mutex.RLock()
@@ -55,10 +106,9 @@ func (i *IPTablesManager) BlockIP(ipAddress string) error {
 	initLogs.LogIPBanInfo("Блокировка IP %s через iptables", ipAddress)
 
 	// Блокируем IP через iptables (используем безопасное выполнение команды)
-	// Вместо fmt.Sprintf команды используем exec.Command с отдельными аргументами
+	// Вместо fmt.Sprintf команды передаём отдельные аргументы
 	// для предотвращения командной инъекции
-	cmd := exec.Command("iptables", "-I", "INPUT", "-s", ipAddress, "-j", "DROP")
-	if err := cmd.Run(); err != nil {
+	if _, err := runCommand(iptablesBinary(ipAddress), "-A", i.Chain, "-s", ipAddress, "-j", "DROP"); err != nil {
 		// Логируем в bot.log: ошибка блокировки IP
 		initLogs.LogIPBanError("Ошибка блокировки IP %s через iptables: %v", ipAddress, err)
 		return fmt.Errorf("ошибка блокировки IP %s: %v", ipAddress, err)
@@ -87,6 +137,10 @@ func (i *IPTablesManager) UnblockIP(ipAddress string) error {
 		return fmt.Errorf("недействительный IP-адрес: %s", ipAddress)
 	}
 
+	if i.Mode == IPTablesModeIPSet {
+		return i.ipsetUnblock([]string{ipAddress})
+	}
+
 	// Проверяем, заблокирован ли IP
 	// Используем RLock для безопасного чтения из общей карты
 	i.mutex.RLock()
@@ -104,10 +158,9 @@ func (i *IPTablesManager) UnblockIP(ipAddress string) error {
 	initLogs.LogIPBanInfo("Разблокировка IP %s через iptables", ipAddress)
 
 	// Разблокируем IP через iptables (используем безопасное выполнение команды)
-	// Вместо fmt.Sprintf команды используем exec.Command с отдельными аргументами
+	// Вместо fmt.Sprintf команды передаём отдельные аргументы
 	// для предотвращения командной инъекции
-	cmd := exec.Command("iptables", "-D", "INPUT", "-s", ipAddress, "-j", "DROP")
-	if err := cmd.Run(); err != nil {
+	if _, err := runCommand(iptablesBinary(ipAddress), "-D", i.Chain, "-s", ipAddress, "-j", "DROP"); err != nil {
 		// Логируем в bot.log: ошибка разблокировки IP
 		initLogs.LogIPBanError("Ошибка разблокировки IP %s через iptables: %v", ipAddress, err)
 		return fmt.Errorf("ошибка разблокировки IP %s: %v", ipAddress, err)
@@ -127,6 +180,44 @@ func (i *IPTablesManager) UnblockIP(ipAddress string) error {
 	return nil
 }
 
+// BlockIPs блокирует несколько IP.
+// В режиме ipset — одним пакетом "ipset restore" с таймаутом элементов.
+// В режиме rules — по одному правилу на адрес; правила не истекают, поэтому timeout игнорируется.
+func (i *IPTablesManager) BlockIPs(ipAddresses []string, timeout time.Duration) error {
+	if i.Mode == IPTablesModeIPSet {
+		return i.ipsetBlock(ipAddresses, timeout)
+	}
+
+	var failed []string
+	for _, ip := range ipAddresses {
+		if err := i.BlockIP(ip); err != nil {
+			failed = append(failed, ip)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("не удалось заблокировать %d IP: %v", len(failed), failed)
+	}
+	return nil
+}
+
+// UnblockIPs разблокирует несколько IP
+func (i *IPTablesManager) UnblockIPs(ipAddresses []string) error {
+	if i.Mode == IPTablesModeIPSet {
+		return i.ipsetUnblock(ipAddresses)
+	}
+
+	var failed []string
+	for _, ip := range ipAddresses {
+		if err := i.UnblockIP(ip); err != nil {
+			failed = append(failed, ip)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("не удалось разблокировать %d IP: %v", len(failed), failed)
+	}
+	return nil
+}
+
 // GetBlockedIPs возвращает список заблокированных IP
 // Использует RWMutex для безопасного чтения из общей карты BlockedIPs
 func (i *IPTablesManager) GetBlockedIPs() []string {
@@ -135,7 +226,9 @@ func (i *IPTablesManager) GetBlockedIPs() []string {
 
 	var ips []string
 	for ip := range i.BlockedIPs {
-		ips = append(ips, ip)
+		if i.isActiveLocked(ip) {
+			ips = append(ips, ip)
+		}
 	}
 	return ips
 }
@@ -146,5 +239,12 @@ func (i *IPTablesManager) IsIPBlocked(ipAddress string) bool {
 	i.mutex.RLock()
 	defer i.mutex.RUnlock()
 
-	return i.BlockedIPs[ipAddress]
+	return i.BlockedIPs[ipAddress] && i.isActiveLocked(ipAddress)
+}
+
+// isActiveLocked проверяет, не истёк ли таймаут блокировки (актуально для режима ipset)
+// Вызывается под i.mutex
+func (i *IPTablesManager) isActiveLocked(ipAddress string) bool {
+	expiresAt, ok := i.expiresAt[ipAddress]
+	return !ok || expiresAt.IsZero() || time.Now().Before(expiresAt)
 }
@@ -0,0 +1,317 @@
+// Проверки IPTablesManager на фейковом исполнителе команд (runner.ScriptedRunner).
+// Не требует root и iptables: вместо процессов проверяется последовательность команд и реакция на ошибки.
+package ipban_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	ipban "ipBanSystem/ipBan/BanService"
+	"ipBanSystem/ipBan/runner"
+)
+
+const chain = "IPBAN"
+
+// TestIPTables прогоняет набор проверок блокировки и разблокировки через iptables
+func TestIPTables(t *testing.T) {
+	t.Run("ChainCreated", testChainCreated)
+	t.Run("ChainCreateError", testChainCreateError)
+	t.Run("BlockIdempotent", testBlockIdempotent)
+	t.Run("UnblockIdempotent", testUnblockIdempotent)
+	t.Run("IPv6UsesIp6tables", testIPv6UsesIp6tables)
+	t.Run("InvalidIPRunsNothing", testInvalidIPRunsNothing)
+	t.Run("BlockError", testBlockError)
+	t.Run("UnblockError", testUnblockError)
+	t.Run("ReconcileRemovesStale", testReconcileRemovesStale)
+	t.Run("LegacyInputRulesOnlyFromBans", testLegacyInputRulesOnlyFromBans)
+	t.Run("IPSetBatch", testIPSetBatch)
+	t.Run("IPSetRestoreError", testIPSetRestoreError)
+}
+
+// withRunner подменяет исполнитель команд на время проверки
+func withRunner(t *testing.T, r runner.CommandRunner) {
+	t.Helper()
+	restore := ipban.SetCommandRunner(r)
+	t.Cleanup(restore)
+}
+
+// newManager создаёт менеджер в режиме rules на фейке, где цепочка уже существует, и очищает журнал команд
+func newManager(t *testing.T, fake *runner.ScriptedRunner) *ipban.IPTablesManager {
+	t.Helper()
+	withRunner(t, fake)
+	m, err := ipban.NewIPTablesManager(chain, ipban.HostScope())
+	if err != nil {
+		t.Fatalf("NewIPTablesManager: %v", err)
+	}
+	fake.Reset()
+	return m
+}
+
+func testChainCreated(t *testing.T) {
+	fake := runner.NewScriptedRunner()
+	fake.Fail("iptables -S IPBAN", 1, "iptables: No chain/target/match by that name.")
+	withRunner(t, fake)
+
+	if _, err := ipban.NewIPTablesManager(chain, ipban.HostScope()); err != nil {
+		t.Fatalf("NewIPTablesManager: %v", err)
+	}
+	if fake.Count("iptables -N IPBAN") != 1 {
+		t.Errorf("цепочка не создана: %v", fake.Commands())
+	}
+	if fake.Count("ip6tables -N IPBAN") != 0 {
+		t.Errorf("цепочка ip6tables уже есть, но создана повторно: %v", fake.Commands())
+	}
+	if fake.Count("iptables -I INPUT -j IPBAN") != 1 || fake.Count("ip6tables -I INPUT -j IPBAN") != 1 {
+		t.Errorf("нет перехода из INPUT: %v", fake.Commands())
+	}
+}
+
+func testChainCreateError(t *testing.T) {
+	fake := runner.NewScriptedRunner()
+	fake.Fail("iptables -S IPBAN", 1, "No chain")
+	fake.Fail("iptables -N IPBAN", 4, "Permission denied (you must be root)")
+	withRunner(t, fake)
+
+	_, err := ipban.NewIPTablesManager(chain, ipban.HostScope())
+	if err == nil {
+		t.Fatal("ожидалась ошибка создания цепочки")
+	}
+	if !strings.Contains(err.Error(), "Permission denied") {
+		t.Errorf("в ошибке нет вывода iptables: %v", err)
+	}
+}
+
+func testBlockIdempotent(t *testing.T) {
+	fake := runner.NewScriptedRunner()
+	m := newManager(t, fake)
+
+	for n := 0; n < 3; n++ {
+		if err := m.BlockIP("203.0.113.7"); err != nil {
+			t.Fatalf("BlockIP #%d: %v", n+1, err)
+		}
+	}
+	if got := fake.Count("iptables -A IPBAN -s 203.0.113.7 -j DROP"); got != 1 {
+		t.Errorf("правило добавлено %d раз, ожидался 1: %v", got, fake.Commands())
+	}
+	if !m.IsIPBlocked("203.0.113.7") {
+		t.Error("IP не отмечен заблокированным")
+	}
+}
+
+func testUnblockIdempotent(t *testing.T) {
+	fake := runner.NewScriptedRunner()
+	m := newManager(t, fake)
+
+	if err := m.UnblockIP("203.0.113.7"); err != nil {
+		t.Fatalf("UnblockIP незаблокированного IP: %v", err)
+	}
+	if len(fake.Calls()) != 0 {
+		t.Errorf("для незаблокированного IP выполнены команды: %v", fake.Commands())
+	}
+
+	if err := m.BlockIP("203.0.113.7"); err != nil {
+		t.Fatalf("BlockIP: %v", err)
+	}
+	for n := 0; n < 2; n++ {
+		if err := m.UnblockIP("203.0.113.7"); err != nil {
+			t.Fatalf("UnblockIP #%d: %v", n+1, err)
+		}
+	}
+	if got := fake.Count("iptables -D IPBAN -s 203.0.113.7 -j DROP"); got != 1 {
+		t.Errorf("правило удалено %d раз, ожидался 1: %v", got, fake.Commands())
+	}
+	if m.IsIPBlocked("203.0.113.7") {
+		t.Error("IP остался заблокированным")
+	}
+}
+
+func testIPv6UsesIp6tables(t *testing.T) {
+	fake := runner.NewScriptedRunner()
+	m := newManager(t, fake)
+
+	if err := m.BlockIP("2001:db8::7"); err != nil {
+		t.Fatalf("BlockIP: %v", err)
+	}
+	if fake.Count("ip6tables -A IPBAN -s 2001:db8::7 -j DROP") != 1 || fake.Count("iptables -A") != 0 {
+		t.Errorf("IPv6 заблокирован не через ip6tables: %v", fake.Commands())
+	}
+}
+
+func testInvalidIPRunsNothing(t *testing.T) {
+	fake := runner.NewScriptedRunner()
+	m := newManager(t, fake)
+
+	for _, ip := range []string{"", "203.0.113.7; reboot", "-j ACCEPT", "999.1.1.1"} {
+		if err := m.BlockIP(ip); err == nil {
+			t.Errorf("BlockIP(%q): ожидалась ошибка", ip)
+		}
+		if err := m.UnblockIP(ip); err == nil {
+			t.Errorf("UnblockIP(%q): ожидалась ошибка", ip)
+		}
+	}
+	if len(fake.Calls()) != 0 {
+		t.Errorf("для недействительных IP выполнены команды: %v", fake.Commands())
+	}
+}
+
+func testBlockError(t *testing.T) {
+	fake := runner.NewScriptedRunner()
+	m := newManager(t, fake)
+	fake.Fail("iptables -A IPBAN -s 203.0.113.8", 1, "iptables: Resource temporarily unavailable.")
+
+	if err := m.BlockIP("203.0.113.8"); err == nil {
+		t.Fatal("ожидалась ошибка блокировки")
+	}
+	if m.IsIPBlocked("203.0.113.8") {
+		t.Error("IP отмечен заблокированным после ошибки")
+	}
+
+	err := m.BlockIPs([]string{"203.0.113.7", "203.0.113.8", "203.0.113.9"}, time.Hour)
+	if err == nil || !strings.Contains(err.Error(), "203.0.113.8") {
+		t.Errorf("BlockIPs: ожидалась ошибка с неудавшимся IP, получено %v", err)
+	}
+	if !m.IsIPBlocked("203.0.113.7") || !m.IsIPBlocked("203.0.113.9") {
+		t.Error("ошибка одного IP прервала блокировку остальных")
+	}
+
+	// Повторная попытка после ошибки снова вызывает iptables
+	if got := fake.Count("iptables -A IPBAN -s 203.0.113.8"); got != 2 {
+		t.Errorf("попыток блокировки 203.0.113.8: %d, ожидалось 2", got)
+	}
+}
+
+func testUnblockError(t *testing.T) {
+	fake := runner.NewScriptedRunner()
+	m := newManager(t, fake)
+	if err := m.BlockIP("203.0.113.7"); err != nil {
+		t.Fatalf("BlockIP: %v", err)
+	}
+	fake.Once("iptables -D IPBAN", runner.Response{ExitCode: 1, Stderr: "iptables: Bad rule."})
+
+	if err := m.UnblockIP("203.0.113.7"); err == nil {
+		t.Fatal("ожидалась ошибка разблокировки")
+	}
+	if !m.IsIPBlocked("203.0.113.7") {
+		t.Fatal("IP снят с учёта, хотя правило не удалено")
+	}
+	if err := m.UnblockIP("203.0.113.7"); err != nil {
+		t.Fatalf("повторная разблокировка: %v", err)
+	}
+	if m.IsIPBlocked("203.0.113.7") {
+		t.Error("IP остался заблокированным")
+	}
+}
+
+func testReconcileRemovesStale(t *testing.T) {
+	fake := runner.NewScriptedRunner()
+	m := newManager(t, fake)
+	fake.On("iptables -S IPBAN", runner.Response{Stdout: "-N IPBAN\n" +
+		"-A IPBAN -s 203.0.113.7/32 -j DROP\n" +
+		"-A IPBAN -s 203.0.113.9/32 -j DROP\n" +
+		"-A IPBAN -s 203.0.113.9/32 -j DROP\n" +
+		"-A IPBAN -s 198.51.100.0/24 -j DROP\n"})
+
+	expected := map[string]time.Time{
+		"203.0.113.9":  time.Now().Add(time.Hour),
+		"203.0.113.10": time.Now().Add(time.Hour),
+	}
+	drift, err := m.Reconcile(expected, true)
+	if err != nil {
+		t.Fatalf("Reconcile: %v", err)
+	}
+	if len(drift.Stale) != 1 || drift.Stale[0] != "203.0.113.7" {
+		t.Errorf("Stale = %v, ожидался [203.0.113.7]", drift.Stale)
+	}
+	if fake.Count("iptables -D IPBAN -s 203.0.113.7 -j DROP") != 1 {
+		t.Errorf("правило без бана не удалено: %v", fake.Commands())
+	}
+	if fake.Count("iptables -D IPBAN -s 203.0.113.9 -j DROP") != 1 {
+		t.Errorf("дубль правила не удалён: %v", fake.Commands())
+	}
+	if fake.Count("iptables -A IPBAN -s 203.0.113.10 -j DROP") != 1 {
+		t.Errorf("потерянная блокировка не восстановлена: %v", fake.Commands())
+	}
+	if fake.Count("iptables -D IPBAN -s 198.51.100.0") != 0 {
+		t.Error("чужое правило для подсети удалено")
+	}
+}
+
+func testLegacyInputRulesOnlyFromBans(t *testing.T) {
+	fake := runner.NewScriptedRunner()
+	m := newManager(t, fake)
+	fake.On("iptables -S INPUT", runner.Response{Stdout: "-P INPUT ACCEPT\n" +
+		"-A INPUT -j IPBAN\n" +
+		"-A INPUT -s 203.0.113.5/32 -j DROP\n" +
+		"-A INPUT -s 198.51.100.9/32 -j DROP\n"})
+
+	// Без сведений о записях банов правила INPUT только попадают в отчёт
+	drift, err := m.Reconcile(map[string]time.Time{}, true)
+	if err != nil {
+		t.Fatalf("Reconcile: %v", err)
+	}
+	if len(drift.Legacy) != 0 || len(drift.Foreign) != 2 || fake.Count("iptables -D INPUT") != 0 {
+		t.Fatalf("правила INPUT тронуты без записи бана: %+v, %v", drift, fake.Commands())
+	}
+	if drift.HasDrift() {
+		t.Errorf("чужие правила не должны считаться расхождением: %+v", drift)
+	}
+
+	// 203.0.113.5 есть в записи бана (бан истёк) — правило старой версии удаляется; 198.51.100.9 — правило администратора
+	m.SetLegacyOwned(map[string]bool{"203.0.113.5": true})
+	drift, err = m.Reconcile(map[string]time.Time{}, true)
+	if err != nil {
+		t.Fatalf("Reconcile: %v", err)
+	}
+	if len(drift.Legacy) != 1 || drift.Legacy[0] != "203.0.113.5" || len(drift.Foreign) != 1 || drift.Foreign[0] != "198.51.100.9" {
+		t.Errorf("Legacy = %v, Foreign = %v", drift.Legacy, drift.Foreign)
+	}
+	if fake.Count("iptables -D INPUT -s 203.0.113.5 -j DROP") != 1 {
+		t.Errorf("правило старой версии сервиса не удалено: %v", fake.Commands())
+	}
+	if fake.Count("iptables -D INPUT -s 198.51.100.9") != 0 {
+		t.Errorf("удалено правило, которое сервис не ставил: %v", fake.Commands())
+	}
+}
+
+func testIPSetBatch(t *testing.T) {
+	fake := runner.NewScriptedRunner()
+	withRunner(t, fake)
+	m, err := ipban.NewIPSetManager(chain, "ipban", ipban.HostScope())
+	if err != nil {
+		t.Fatalf("NewIPSetManager: %v", err)
+	}
+	fake.Reset()
+
+	if err := m.BlockIPs([]string{"203.0.113.7", "2001:db8::7", "203.0.113.8"}, 90*time.Second); err != nil {
+		t.Fatalf("BlockIPs: %v", err)
+	}
+	calls := fake.Calls()
+	if len(calls) != 1 || calls[0].String() != "ipset -exist restore" {
+		t.Fatalf("ожидался один вызов ipset restore, выполнено: %v", fake.Commands())
+	}
+	want := "add ipban4 203.0.113.7 timeout 90\nadd ipban4 203.0.113.8 timeout 90\nadd ipban6 2001:db8::7 timeout 90\n"
+	if calls[0].Stdin != want {
+		t.Errorf("пакет ipset:\n%s\nожидался:\n%s", calls[0].Stdin, want)
+	}
+	if !m.IsIPBlocked("2001:db8::7") {
+		t.Error("IPv6 не отмечен заблокированным")
+	}
+}
+
+func testIPSetRestoreError(t *testing.T) {
+	fake := runner.NewScriptedRunner()
+	withRunner(t, fake)
+	m, err := ipban.NewIPSetManager(chain, "ipban", ipban.HostScope())
+	if err != nil {
+		t.Fatalf("NewIPSetManager: %v", err)
+	}
+	fake.Fail("ipset -exist restore", 1, "ipset v7.15: Error in line 1: The set with the given name does not exist")
+
+	if err := m.BlockIPs([]string{"203.0.113.7"}, time.Minute); err == nil {
+		t.Fatal("ожидалась ошибка ipset restore")
+	}
+	if m.IsIPBlocked("203.0.113.7") {
+		t.Error("IP отмечен заблокированным после ошибки")
+	}
+}
@@ -0,0 +1,186 @@
+package ipban
+
+import (
+	"fmt"
+	"time"
+
+	"ipBanSystem/ipBan/logger/initLogs"
+	"ipBanSystem/ipBan/panel/client"
+	"ipBanSystem/ipBan/panel/snapshot"
+)
+
+// UnbanResult описывает итог ручного разбана
+type UnbanResult struct {
+	// WasBanned — был ли у пользователя активный бан
+	WasBanned bool `json:"was_banned"`
+	// UnblockedIPs — сколько IP было разблокировано на файрволе
+	UnblockedIPs int `json:"unblocked_ips"`
+	// Enabled — был ли конфиг включен в панели в результате разбана
+	Enabled bool `json:"enabled"`
+}
+
+// UserReport собирает всё, что сервис знает о пользователе: клиента панели, бан и текущие IP
+type UserReport struct {
+	Email string `json:"email"`
+	// Key — стабильный ключ идентичности, под которым хранятся бан и статистика
+	Key string `json:"key,omitempty"`
+	// Client — клиент из панели (nil, если панель недоступна или клиент не найден)
+	Client *client.Client `json:"client,omitempty"`
+	// PanelError — ошибка получения клиента из панели
+	PanelError string `json:"panel_error,omitempty"`
+	// Ban — активный бан (nil, если пользователь не забанен)
+	Ban *BanInfo `json:"ban,omitempty"`
+	// IPs — IP адреса, замеченные анализатором за время хранения счётчиков
+	IPs []string `json:"ips"`
+	// MaxIPs — текущий лимит IP на конфиг
+	MaxIPs int `json:"max_ips"`
+	// Traffic — счётчики трафика клиента в панели (nil — статистика недоступна)
+	Traffic *client.Traffic `json:"traffic,omitempty"`
+	// Usage — расход трафика по циклам проверки (nil — показаний ещё нет)
+	Usage *TrafficUsage `json:"usage,omitempty"`
+	// ServiceDisable — запись об отключении конфига сервисом (nil — сервис конфиг не отключал)
+	ServiceDisable *DisableRecord `json:"service_disable,omitempty"`
+}
+
+// ManualBan банит пользователя по команде администратора и сразу применяет агрессивный сброс в панели.
+// duration <= 0 означает длительность по умолчанию (IP_BAN_DURATION).
+func (s *IPBanService) ManualBan(email string, duration time.Duration, reason string) (*BanInfo, error) {
+	s.cycleMutex.Lock()
+	defer s.cycleMutex.Unlock()
+
+	// Проверяем, что клиент существует, и берём email в написании панели
+	c, err := client.ByEmail(s.ConfigManager, email)
+	if err != nil {
+		return nil, fmt.Errorf("клиент %s не найден в панели: %w", email, err)
+	}
+	email = c.Email
+	s.syncIdentity(c)
+
+	if duration <= 0 {
+		duration = time.Duration(IP_BAN_DURATION) * time.Minute
+	}
+	if reason == "" {
+		reason = "Ручной бан администратором"
+	}
+
+	initLogs.LogIPBanInfo("Ручной бан пользователя %s на %v (причина: %s)", email, duration, reason)
+	ban, err := s.BanManager.BanUserFor(email, reason, s.Analyzer.GetEmailIPs(email), duration)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка сохранения бана: %v", err)
+	}
+
+	s.recordDisable(email, reason)
+	if err := s.aggressiveReset(email); err != nil {
+		initLogs.LogIPBanError("❌ Ошибка AggressiveBanReset для %s: %v", email, err)
+		return ban, fmt.Errorf("бан сохранён, но сброс в панели не удался: %w", err)
+	}
+	initLogs.LogIPBanInfo("   ✅ Агрессивный сброс применён для %s", email)
+
+	if n := s.killConnections(email, ban.IPAddresses); n > 0 {
+		ban.KilledConnections += n
+	}
+	return ban, nil
+}
+
+// ManualUnban снимает бан по команде администратора и сразу возвращает конфиг в рабочее состояние
+func (s *IPBanService) ManualUnban(email string) (*UnbanResult, error) {
+	s.cycleMutex.Lock()
+	defer s.cycleMutex.Unlock()
+
+	c, err := client.ByEmail(s.ConfigManager, email)
+	if err != nil {
+		return nil, fmt.Errorf("клиент %s не найден в панели: %w", email, err)
+	}
+	email = c.Email
+	s.syncIdentity(c)
+
+	result := &UnbanResult{}
+	ips := s.Analyzer.GetEmailIPs(email)
+	if ban := s.BanManager.GetBanInfo(email); ban != nil {
+		result.WasBanned = true
+		ips = append(ips, ban.IPAddresses...)
+		ips = append(ips, ban.BlockedIPs...)
+		if s.Throttler != nil && len(ban.ThrottledIPs) > 0 {
+			if err := s.Throttler.UnthrottleIPs(ban.ThrottledIPs); err != nil {
+				initLogs.LogIPBanError("Ошибка снятия троттлинга %s: %v", email, err)
+			}
+		}
+		if err := s.BanManager.UnbanUser(email); err != nil {
+			return nil, fmt.Errorf("ошибка удаления бана: %v", err)
+		}
+	}
+
+	initLogs.LogIPBanInfo("Ручной разбан пользователя %s", email)
+	result.UnblockedIPs, result.Enabled = s.restoreAfterUnban(email, ips)
+	return result, nil
+}
+
+// ListBans возвращает активные баны
+func (s *IPBanService) ListBans() map[string]*BanInfo {
+	return s.BanManager.GetActiveBans()
+}
+
+// GetBan возвращает активный бан пользователя или nil
+func (s *IPBanService) GetBan(email string) *BanInfo {
+	return s.BanManager.GetBanInfo(email)
+}
+
+// UserReport собирает сведения о пользователе для команды "users show"
+func (s *IPBanService) UserReport(email string) *UserReport {
+	report := &UserReport{
+		Email:  email,
+		Ban:    s.BanManager.GetBanInfo(email),
+		IPs:    s.Analyzer.GetEmailIPs(email),
+		MaxIPs: s.MaxIPs,
+	}
+	if c, err := client.ByEmail(s.ConfigManager, email); err != nil {
+		report.PanelError = err.Error()
+	} else {
+		report.Client = c
+		s.syncIdentity(c)
+		report.Key = s.BanManager.KeyFor(c.Email)
+		report.Ban = s.BanManager.GetBanInfo(c.Email)
+		if t, err := client.GetTraffic(s.ConfigManager, c.Email); err == nil {
+			report.Traffic = t
+		}
+		if s.Traffic != nil {
+			if usage, ok := s.Traffic.Usage(report.Key); ok {
+				report.Usage = &usage
+			}
+		}
+		if s.Disables != nil {
+			report.ServiceDisable = s.Disables.Get(report.Key)
+		}
+	}
+	return report
+}
+
+// syncIdentity добавляет клиента в таблицу идентичностей до первого цикла проверки
+func (s *IPBanService) syncIdentity(c *client.Client) {
+	if s.BanManager.Identities != nil {
+		s.BanManager.Identities.Sync([]client.Client{*c})
+	}
+}
+
+// RestoreSnapshot восстанавливает inbound из снимка по команде администратора
+// и сразу сверяет таблицу идентичностей с восстановленными клиентами
+func (s *IPBanService) RestoreSnapshot(id string) (*snapshot.Snapshot, error) {
+	s.cycleMutex.Lock()
+	defer s.cycleMutex.Unlock()
+
+	snap, err := client.RestoreSnapshot(s.ConfigManager, id)
+	if err != nil {
+		return snap, err
+	}
+	if clients, err := client.All(s.ConfigManager); err == nil && s.BanManager.Identities != nil {
+		s.BanManager.Identities.Sync(clients)
+	}
+	return snap, nil
+}
+
+// SyncFirewall сверяет файрвол с активными банами; apply=false — только отчёт о расхождениях
+func (s *IPBanService) SyncFirewall(apply bool) (*FirewallDrift, error) {
+	s.cycleMutex.Lock()
+	defer s.cycleMutex.Unlock()
+	return ReconcileFirewall(s.Firewall, s.BanManager, apply)
+}
@@ -0,0 +1,233 @@
+package ipban
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"ipBanSystem/ipBan/logger/initLogs"
+)
+
+// NFTablesManager управляет блокировкой IP через nftables.
+// Сервис владеет собственной таблицей (inet <Table>) с цепочкой на хуке input
+// и двумя наборами (IPv4/IPv6) с флагом timeout: блокировка истекает сама, вместе с баном.
+// Все изменения применяются пакетами через "nft -f -" — одна транзакция на операцию.
+type NFTablesManager struct {
+	Table      string
+	Scope      *FirewallScope       // Какой трафик заблокированных IP отбрасывается (nil — не менять правила цепочки)
+	BlockedIPs map[string]time.Time // IP -> момент истечения блокировки (нулевое время — бессрочно)
+	mutex      sync.RWMutex         // Мьютекс для синхронизации доступа к карте BlockedIPs
+}
+
+// Имена объектов внутри таблицы сервиса
+const (
+	nftChain  = "input"
+	nftSetV4  = "blocked4"
+	nftSetV6  = "blocked6"
+	nftBinary = "nft"
+)
+
+// NewNFTablesManager создаёт менеджер и гарантирует наличие таблицы, наборов и правил
+func NewNFTablesManager(table string, scope *FirewallScope) (*NFTablesManager, error) {
+	n := &NFTablesManager{
+		Table:      table,
+		Scope:      scope,
+		BlockedIPs: make(map[string]time.Time),
+	}
+	if err := n.ensureTable(); err != nil {
+		return nil, err
+	}
+	initLogs.LogIPBanInfo("nftables: таблица inet %s готова (область: %s)", table, scope)
+	return n, nil
+}
+
+// ensureTable создаёт таблицу, наборы и цепочку (операции add идемпотентны),
+// затем пересоздаёт правила цепочки по области блокировки (Scope).
+// При Scope == nil правила уже существующей цепочки не трогаются.
+func (n *NFTablesManager) ensureTable() error {
+	scope := n.Scope
+	if scope == nil {
+		out, err := runCommandOutput(nftBinary, "list", "chain", "inet", n.Table, nftChain)
+		if err == nil && strings.Contains(string(out), "@"+nftSetV4) {
+			return nil
+		}
+		scope = HostScope()
+	}
+
+	// Фильтр по портам ставится перед проверкой набора: "tcp dport { 443 } ip saddr @blocked4 drop"
+	var matches []string
+	if scope.HostWide() {
+		matches = []string{""}
+	} else {
+		groups := scope.PortsByProto()
+		for _, proto := range []string{"tcp", "udp"} {
+			if ports := groups[proto]; len(ports) > 0 {
+				matches = append(matches, fmt.Sprintf("%s dport { %s } ", proto, joinPorts(ports, ", ")))
+			}
+		}
+	}
+
+	var script strings.Builder
+	fmt.Fprintf(&script, "add table inet %s\n", n.Table)
+	fmt.Fprintf(&script, "add set inet %s %s { type ipv4_addr; flags timeout; }\n", n.Table, nftSetV4)
+	fmt.Fprintf(&script, "add set inet %s %s { type ipv6_addr; flags timeout; }\n", n.Table, nftSetV6)
+	fmt.Fprintf(&script, "add chain inet %s %s { type filter hook input priority -10; policy accept; }\n", n.Table, nftChain)
+	fmt.Fprintf(&script, "flush chain inet %s %s\n", n.Table, nftChain)
+	for _, match := range matches {
+		fmt.Fprintf(&script, "add rule inet %s %s %sip saddr @%s drop\n", n.Table, nftChain, match, nftSetV4)
+		fmt.Fprintf(&script, "add rule inet %s %s %sip6 saddr @%s drop\n", n.Table, nftChain, match, nftSetV6)
+	}
+
+	if err := runNFTScript(script.String()); err != nil {
+		return fmt.Errorf("ошибка подготовки таблицы nftables %s: %v", n.Table, err)
+	}
+	return nil
+}
+
+// BlockIP блокирует IP на длительность бана по умолчанию
+func (n *NFTablesManager) BlockIP(ipAddress string) error {
+	return n.BlockIPs([]string{ipAddress}, defaultBlockTimeout())
+}
+
+// UnblockIP снимает блокировку IP
+func (n *NFTablesManager) UnblockIP(ipAddress string) error {
+	return n.UnblockIPs([]string{ipAddress})
+}
+
+// BlockIPs добавляет адреса в наборы одной транзакцией с таймаутом элемента
+func (n *NFTablesManager) BlockIPs(ipAddresses []string, timeout time.Duration) error {
+	v4, v6, err := splitIPFamilies(ipAddresses)
+	if err != nil {
+		return err
+	}
+	if len(v4)+len(v6) == 0 {
+		return nil
+	}
+
+	elemSuffix := ""
+	if timeout > 0 {
+		// nftables принимает таймаут с точностью до секунды
+		elemSuffix = fmt.Sprintf(" timeout %ds", int64((timeout+time.Second-1)/time.Second))
+	}
+
+	var script strings.Builder
+	n.writeElements(&script, "add", nftSetV4, v4, elemSuffix)
+	n.writeElements(&script, "add", nftSetV6, v6, elemSuffix)
+	if err := runNFTScript(script.String()); err != nil {
+		initLogs.LogIPBanError("Ошибка блокировки %d IP через nftables: %v", len(v4)+len(v6), err)
+		return fmt.Errorf("ошибка блокировки IP через nftables: %v", err)
+	}
+
+	var expiresAt time.Time
+	if timeout > 0 {
+		expiresAt = time.Now().Add(timeout)
+	}
+	all := append(v4, v6...)
+	n.mutex.Lock()
+	for _, ip := range all {
+		n.BlockedIPs[ip] = expiresAt
+	}
+	n.mutex.Unlock()
+
+	for _, ip := range all {
+		initLogs.LogIPBanAction("IP_ЗАБЛОКИРОВАН", ip, 0, []string{})
+	}
+	return nil
+}
+
+// UnblockIPs удаляет адреса из наборов одной транзакцией.
+// Перед удалением каждый адрес добавляется: "add element" идемпотентен, а "delete element"
+// для отсутствующего адреса сорвал бы всю транзакцию (например, если таймаут уже истёк).
+func (n *NFTablesManager) UnblockIPs(ipAddresses []string) error {
+	v4, v6, err := splitIPFamilies(ipAddresses)
+	if err != nil {
+		return err
+	}
+	if len(v4)+len(v6) == 0 {
+		return nil
+	}
+
+	var script strings.Builder
+	n.writeElements(&script, "add", nftSetV4, v4, "")
+	n.writeElements(&script, "add", nftSetV6, v6, "")
+	n.writeElements(&script, "delete", nftSetV4, v4, "")
+	n.writeElements(&script, "delete", nftSetV6, v6, "")
+	if err := runNFTScript(script.String()); err != nil {
+		initLogs.LogIPBanError("Ошибка разблокировки %d IP через nftables: %v", len(v4)+len(v6), err)
+		return fmt.Errorf("ошибка разблокировки IP через nftables: %v", err)
+	}
+
+	all := append(v4, v6...)
+	n.mutex.Lock()
+	for _, ip := range all {
+		delete(n.BlockedIPs, ip)
+	}
+	n.mutex.Unlock()
+
+	for _, ip := range all {
+		initLogs.LogIPBanAction("IP_РАЗБЛОКИРОВАН", ip, 0, []string{})
+	}
+	return nil
+}
+
+// IsIPBlocked проверяет, заблокирован ли IP (с учётом истечения таймаута)
+func (n *NFTablesManager) IsIPBlocked(ipAddress string) bool {
+	n.mutex.RLock()
+	defer n.mutex.RUnlock()
+	expiresAt, ok := n.BlockedIPs[ipAddress]
+	return ok && (expiresAt.IsZero() || time.Now().Before(expiresAt))
+}
+
+// GetBlockedIPs возвращает список IP, блокировка которых ещё не истекла
+func (n *NFTablesManager) GetBlockedIPs() []string {
+	n.mutex.RLock()
+	defer n.mutex.RUnlock()
+
+	now := time.Now()
+	var ips []string
+	for ip, expiresAt := range n.BlockedIPs {
+		if expiresAt.IsZero() || now.Before(expiresAt) {
+			ips = append(ips, ip)
+		}
+	}
+	return ips
+}
+
+// writeElements дописывает в скрипт команду над элементами набора
+func (n *NFTablesManager) writeElements(script *strings.Builder, verb, set string, ips []string, suffix string) {
+	if len(ips) == 0 {
+		return
+	}
+	elems := make([]string, len(ips))
+	for i, ip := range ips {
+		elems[i] = ip + suffix
+	}
+	fmt.Fprintf(script, "%s element inet %s %s { %s }\n", verb, n.Table, set, strings.Join(elems, ", "))
+}
+
+// splitIPFamilies проверяет адреса и раскладывает их по семействам IPv4/IPv6.
+// Проверка обязательна: адреса подставляются в скрипт nft.
+func splitIPFamilies(ipAddresses []string) (v4, v6 []string, err error) {
+	for _, ip := range ipAddresses {
+		parsed := net.ParseIP(ip)
+		if parsed == nil {
+			return nil, nil, fmt.Errorf("недействительный IP-адрес: %s", ip)
+		}
+		if parsed.To4() != nil {
+			v4 = append(v4, parsed.String())
+		} else {
+			v6 = append(v6, parsed.String())
+		}
+	}
+	return v4, v6, nil
+}
+
+// runNFTScript применяет скрипт nft одной транзакцией
+func runNFTScript(script string) error {
+	if out, err := runCommandInput(script, nftBinary, "-f", "-"); err != nil {
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package ipban
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// nftSetListing — фрагмент вывода "nft -j list set", нужный для чтения элементов набора
+type nftSetListing struct {
+	Nftables []struct {
+		Set *struct {
+			Name string            `json:"name"`
+			Elem []json.RawMessage `json:"elem"`
+		} `json:"set"`
+	} `json:"nftables"`
+}
+
+// nftTimedElem — элемент набора с таймаутом: {"elem": {"val": "1.2.3.4", "timeout": 3600, "expires": 3550}}
+type nftTimedElem struct {
+	Elem struct {
+		Val     string `json:"val"`
+		Expires int64  `json:"expires"`
+	} `json:"elem"`
+}
+
+// listSetElements читает элементы наборов сервиса: IP -> оставшееся время (0 — бессрочно)
+func (n *NFTablesManager) listSetElements() (map[string]time.Duration, error) {
+	elements := make(map[string]time.Duration)
+	for _, set := range []string{nftSetV4, nftSetV6} {
+		out, err := runCommandOutput(nftBinary, "-j", "list", "set", "inet", n.Table, set)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка чтения набора %s таблицы %s: %v", set, n.Table, err)
+		}
+		var listing nftSetListing
+		if err := json.Unmarshal(out, &listing); err != nil {
+			return nil, fmt.Errorf("ошибка парсинга набора %s: %v", set, err)
+		}
+		for _, item := range listing.Nftables {
+			if item.Set == nil {
+				continue
+			}
+			for _, raw := range item.Set.Elem {
+				// Элемент без таймаута выводится строкой, с таймаутом — объектом
+				var plain string
+				if json.Unmarshal(raw, &plain) == nil {
+					elements[plain] = 0
+					continue
+				}
+				var timed nftTimedElem
+				if err := json.Unmarshal(raw, &timed); err != nil || timed.Elem.Val == "" {
+					continue
+				}
+				elements[timed.Elem.Val] = time.Duration(timed.Elem.Expires) * time.Second
+			}
+		}
+	}
+	return elements, nil
+}
+
+// Reconcile восстанавливает карту блокировок по наборам таблицы, удаляет адреса без активного бана
+// и заново добавляет потерянные адреса активных банов
+func (n *NFTablesManager) Reconcile(expected map[string]time.Time, apply bool) (*FirewallDrift, error) {
+	elements, err := n.listSetElements()
+	if err != nil {
+		return nil, err
+	}
+
+	found := make(map[string]int, len(elements))
+	n.mutex.Lock()
+	n.BlockedIPs = make(map[string]time.Time, len(elements))
+	for ip, remaining := range elements {
+		found[ip] = 1
+		if remaining > 0 {
+			n.BlockedIPs[ip] = time.Now().Add(remaining)
+		} else {
+			n.BlockedIPs[ip] = time.Time{}
+		}
+	}
+	n.mutex.Unlock()
+
+	drift := classifyBlocked(found, expected)
+	drift.Backend = FirewallNFTables
+	if !apply {
+		return drift, nil
+	}
+	if err := n.UnblockIPs(drift.Stale); err != nil {
+		return drift, fmt.Errorf("не удалось удалить %d лишних блокировок: %s: %v", len(drift.Stale), strings.Join(drift.Stale, ", "), err)
+	}
+	if failed := restoreMissing(n, drift.Missing, expected); len(failed) > 0 {
+		return drift, fmt.Errorf("не удалось восстановить %d блокировок: %s", len(failed), strings.Join(failed, ", "))
+	}
+	drift.Applied = true
+	return drift, nil
+}
@@ -0,0 +1,112 @@
+package ipban
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"ipBanSystem/ipBan/logger/initLogs"
+)
+
+// OffenseRecord — история нарушений одного клиента
+type OffenseRecord struct {
+	// Times — моменты нарушений в пределах окна учёта
+	Times []time.Time `json:"times"`
+}
+
+// OffenseTracker считает нарушения лимита IP по стабильному ключу идентичности.
+// Нужен политике "escalate": баны удаляются после истечения, а история нарушений должна их пережить.
+// Сохраняется в JSON-файл; нарушения старше Window забываются.
+type OffenseTracker struct {
+	Path     string
+	Window   time.Duration
+	offenses map[string]*OffenseRecord
+	mutex    sync.Mutex
+}
+
+// NewOffenseTracker загружает счётчик нарушений из файла (или создаёт пустой)
+func NewOffenseTracker(path string, window time.Duration) *OffenseTracker {
+	ot := &OffenseTracker{
+		Path:     path,
+		Window:   window,
+		offenses: make(map[string]*OffenseRecord),
+	}
+	if data, err := os.ReadFile(path); err == nil && len(data) > 0 {
+		if err := json.Unmarshal(data, &ot.offenses); err != nil {
+			initLogs.LogIPBanError("Ошибка загрузки счётчика нарушений %s: %v", path, err)
+			ot.offenses = make(map[string]*OffenseRecord)
+		}
+	}
+	return ot
+}
+
+// Record фиксирует нарушение и возвращает число нарушений клиента в окне учёта (включая это)
+func (ot *OffenseTracker) Record(key string) int {
+	ot.mutex.Lock()
+	defer ot.mutex.Unlock()
+
+	now := time.Now()
+	ot.pruneLocked(now)
+	rec := ot.offenses[key]
+	if rec == nil {
+		rec = &OffenseRecord{}
+		ot.offenses[key] = rec
+	}
+	rec.Times = append(rec.Times, now)
+
+	if err := ot.saveLocked(); err != nil {
+		initLogs.LogIPBanError("Ошибка сохранения счётчика нарушений: %v", err)
+	}
+	return len(rec.Times)
+}
+
+// Count возвращает число нарушений клиента в окне учёта
+func (ot *OffenseTracker) Count(key string) int {
+	ot.mutex.Lock()
+	defer ot.mutex.Unlock()
+
+	ot.pruneLocked(time.Now())
+	if rec := ot.offenses[key]; rec != nil {
+		return len(rec.Times)
+	}
+	return 0
+}
+
+// pruneLocked забывает нарушения старше окна учёта
+func (ot *OffenseTracker) pruneLocked(now time.Time) {
+	if ot.Window <= 0 {
+		return
+	}
+	cutoff := now.Add(-ot.Window)
+	for key, rec := range ot.offenses {
+		kept := rec.Times[:0]
+		for _, t := range rec.Times {
+			if t.After(cutoff) {
+				kept = append(kept, t)
+			}
+		}
+		rec.Times = kept
+		if len(rec.Times) == 0 {
+			delete(ot.offenses, key)
+		}
+	}
+}
+
+// saveLocked атомарно записывает счётчик в файл (через временный файл и rename)
+func (ot *OffenseTracker) saveLocked() error {
+	data, err := json.MarshalIndent(ot.offenses, "", "  ")
+	if err != nil {
+		return fmt.Errorf("ошибка сериализации счётчика нарушений: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(ot.Path), 0o755); err != nil {
+		return fmt.Errorf("ошибка создания директории: %v", err)
+	}
+	tmp := ot.Path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, ot.Path)
+}
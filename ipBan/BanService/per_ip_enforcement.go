@@ -0,0 +1,79 @@
+package ipban
+
+import (
+	"fmt"
+	"sort"
+
+	"ipBanSystem/ipBan/logger/analyzerLogs"
+	"ipBanSystem/ipBan/logger/initLogs"
+)
+
+// selectExcessIPs делит IP пользователя на оставляемые (maxIPs самых давних по FirstSeen) и лишние.
+// IP из skip (уже заблокированные) не участвуют: они не занимают место в лимите.
+func selectExcessIPs(stats *analyzerLogs.EmailIPStats, maxIPs int, skip []string) (keep, excess []string) {
+	candidates := make([]*analyzerLogs.IPActivity, 0, len(stats.IPs))
+	for ip, activity := range stats.IPs {
+		if containsString(skip, ip) {
+			continue
+		}
+		a := *activity
+		a.IPAddress = ip
+		candidates = append(candidates, &a)
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if !a.FirstSeen.Equal(b.FirstSeen) {
+			return a.FirstSeen.Before(b.FirstSeen)
+		}
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.IPAddress < b.IPAddress
+	})
+
+	for n, activity := range candidates {
+		if n < maxIPs {
+			keep = append(keep, activity.IPAddress)
+		} else {
+			excess = append(excess, activity.IPAddress)
+		}
+	}
+	return keep, excess
+}
+
+// enforcePerIP блокирует на файрволе самые новые IP сверх лимита, не отключая конфиг.
+// Заблокированные IP записываются в бан пользователя (BanInfo.BlockedIPs), чтобы снять их вместе с баном.
+func (s *IPBanService) enforcePerIP(stats *analyzerLogs.EmailIPStats) {
+	var alreadyBlocked []string
+	if ban := s.BanManager.GetBanInfo(stats.Email); ban != nil {
+		alreadyBlocked = ban.BlockedIPs
+	}
+
+	keep, excess := selectExcessIPs(stats, s.MaxIPs, alreadyBlocked)
+	if len(excess) == 0 {
+		return
+	}
+	initLogs.LogIPBanInfo("   ✅ Оставлены самые давние IP (%d): %v", len(keep), keep)
+	initLogs.LogIPBanInfo("   🧱 Блокировка лишних IP (%d): %v", len(excess), excess)
+
+	banDuration := defaultBlockTimeout()
+	if err := s.Firewall.BlockIPs(excess, banDuration); err != nil {
+		initLogs.LogIPBanError("❌ Ошибка блокировки лишних IP %s: %v", stats.Email, err)
+		return
+	}
+
+	var seen []string
+	for ip := range stats.IPs {
+		seen = append(seen, ip)
+	}
+	reason := fmt.Sprintf("Превышение лимита IP адресов: %d (максимум: %d), заблокировано лишних IP: %d",
+		len(keep)+len(excess), s.MaxIPs, len(excess))
+	if _, err := s.BanManager.BanIPs(stats.Email, EnforcementPerIP, reason, seen, excess, banDuration); err != nil {
+		initLogs.LogIPBanError("Ошибка сохранения блокировки IP %s: %v", stats.Email, err)
+		return
+	}
+	initLogs.LogIPBanInfo("   🚫 Для %s заблокировано %d IP, конфиг остаётся включенным", stats.Email, len(excess))
+
+	// Правило DROP не обрывает уже открытые соединения лишних IP
+	s.killConnections(stats.Email, excess)
+}
@@ -0,0 +1,208 @@
+package ipban_test
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// redisStandIn — минимальный Redis-совместимый сервер в памяти для проверки RedisBanStore
+// без настоящего Redis. Поддерживает PING, AUTH, SELECT, HGET, HSET, HDEL, HGETALL.
+type redisStandIn struct {
+	// Addr — адрес, на котором слушает сервер (127.0.0.1:порт)
+	Addr string
+	// Password — если не пустой, сервер требует AUTH перед остальными командами
+	Password string
+	// BrokenReplies — сколько следующих HGET получат испорченный ответ: начало с неизвестным типом
+	// и хвост, который остаётся в соединении (задаётся до запуска команд)
+	BrokenReplies int
+
+	listener net.Listener
+	hashes   map[string]map[string]string
+	mutex    sync.Mutex
+	wg       sync.WaitGroup
+}
+
+// newRedisStandIn запускает сервер на случайном локальном порту
+func newRedisStandIn(password string) (*redisStandIn, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("ошибка запуска Redis stand-in: %v", err)
+	}
+	srv := &redisStandIn{
+		Addr:     ln.Addr().String(),
+		Password: password,
+		listener: ln,
+		hashes:   make(map[string]map[string]string),
+	}
+	srv.wg.Add(1)
+	go srv.acceptLoop()
+	return srv, nil
+}
+
+// Close останавливает сервер и дожидается завершения обработчиков
+func (srv *redisStandIn) Close() error {
+	err := srv.listener.Close()
+	srv.wg.Wait()
+	return err
+}
+
+// acceptLoop принимает соединения до закрытия listener
+func (srv *redisStandIn) acceptLoop() {
+	defer srv.wg.Done()
+	for {
+		conn, err := srv.listener.Accept()
+		if err != nil {
+			return
+		}
+		srv.wg.Add(1)
+		go srv.serve(conn)
+	}
+}
+
+// serve обрабатывает команды одного соединения
+func (srv *redisStandIn) serve(conn net.Conn) {
+	defer srv.wg.Done()
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	authed := srv.Password == ""
+	for {
+		args, err := readCommand(reader)
+		if err != nil {
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+
+		cmd := strings.ToUpper(args[0])
+		if cmd == "AUTH" {
+			if len(args) == 2 && args[1] == srv.Password {
+				authed = true
+				io.WriteString(conn, "+OK\r\n")
+			} else {
+				io.WriteString(conn, "-WRONGPASS invalid password\r\n")
+			}
+			continue
+		}
+		if !authed {
+			io.WriteString(conn, "-NOAUTH Authentication required.\r\n")
+			continue
+		}
+		io.WriteString(conn, srv.execute(cmd, args[1:]))
+	}
+}
+
+// execute выполняет команду над данными в памяти и возвращает RESP-ответ
+func (srv *redisStandIn) execute(cmd string, args []string) string {
+	srv.mutex.Lock()
+	defer srv.mutex.Unlock()
+
+	switch cmd {
+	case "PING":
+		return "+PONG\r\n"
+	case "SELECT":
+		return "+OK\r\n"
+	case "HGET":
+		if len(args) != 2 {
+			return "-ERR wrong number of arguments for 'hget' command\r\n"
+		}
+		if srv.BrokenReplies > 0 {
+			srv.BrokenReplies--
+			return "*2\r\n?broken\r\n" + bulk("stale")
+		}
+		v, ok := srv.hashes[args[0]][args[1]]
+		if !ok {
+			return "$-1\r\n"
+		}
+		return bulk(v)
+	case "HSET":
+		if len(args) < 3 || len(args)%2 != 1 {
+			return "-ERR wrong number of arguments for 'hset' command\r\n"
+		}
+		h := srv.hashes[args[0]]
+		if h == nil {
+			h = make(map[string]string)
+			srv.hashes[args[0]] = h
+		}
+		added := 0
+		for i := 1; i < len(args); i += 2 {
+			if _, exists := h[args[i]]; !exists {
+				added++
+			}
+			h[args[i]] = args[i+1]
+		}
+		return ":" + strconv.Itoa(added) + "\r\n"
+	case "HDEL":
+		if len(args) < 2 {
+			return "-ERR wrong number of arguments for 'hdel' command\r\n"
+		}
+		removed := 0
+		for _, field := range args[1:] {
+			if _, exists := srv.hashes[args[0]][field]; exists {
+				delete(srv.hashes[args[0]], field)
+				removed++
+			}
+		}
+		return ":" + strconv.Itoa(removed) + "\r\n"
+	case "HGETALL":
+		if len(args) != 1 {
+			return "-ERR wrong number of arguments for 'hgetall' command\r\n"
+		}
+		h := srv.hashes[args[0]]
+		var sb strings.Builder
+		sb.WriteString("*" + strconv.Itoa(len(h)*2) + "\r\n")
+		for k, v := range h {
+			sb.WriteString(bulk(k))
+			sb.WriteString(bulk(v))
+		}
+		return sb.String()
+	default:
+		return "-ERR unknown command '" + cmd + "'\r\n"
+	}
+}
+
+// bulk кодирует строку как RESP bulk-строку
+func bulk(s string) string {
+	return "$" + strconv.Itoa(len(s)) + "\r\n" + s + "\r\n"
+}
+
+// readCommand читает команду клиента в формате RESP-массива bulk-строк
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if !strings.HasPrefix(line, "*") {
+		// inline-команда (например, из telnet)
+		return strings.Fields(line), nil
+	}
+
+	count, err := strconv.Atoi(line[1:])
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		header, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimRight(header, "\r\n")[1:])
+		if err != nil {
+			return nil, err
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		args = append(args, string(data[:size]))
+	}
+	return args, nil
+}
@@ -0,0 +1,119 @@
+package ipban
+
+import (
+	"strings"
+
+	"ipBanSystem/ipBan/logger/initLogs"
+	"ipBanSystem/ipBan/panel/client"
+)
+
+// RecoverInterruptedResets сверяет клиентов панели с банами и завершает или отменяет агрессивные сбросы,
+// прерванные между фазами (см. recoverResets). Вызывается при старте сервиса; в цикле проверки
+// та же сверка выполняется по снимку клиентов цикла. Возвращает число исправленных клиентов.
+func (s *IPBanService) RecoverInterruptedResets() int {
+	s.cycleMutex.Lock()
+	defer s.cycleMutex.Unlock()
+
+	clients, err := client.All(s.ConfigManager)
+	if err != nil {
+		initLogs.LogIPBanError("Проверка прерванных сбросов: %v", err)
+		return 0
+	}
+	return s.recoverResets(clients)
+}
+
+// recoverResets находит следы прерванного AggressiveBanReset и исправляет их по записям банов:
+//   - клиент с email "<email>-reset", отключенный и "исчерпанный" — процесс остановился между фазами A и B;
+//   - клиент, у которого depleted и exhausted расходятся, — только если сервис его трогал (запись в журнале
+//     отключений или бан): сервис всегда ставит флаги вместе, а расхождение у остальных оставили панель
+//     или ProxyMaster, и снимать такие флаги нельзя.
+//
+// Если у клиента активный бан конфига, операция завершается (исходный email, enable=false, "исчерпано");
+// иначе отменяется (исходный email, "исчерпано" снято, enable не трогается — его решает цикл проверки).
+// Возвращает число исправленных клиентов.
+func (s *IPBanService) recoverResets(clients []client.Client) int {
+	taken := make(map[string]bool, len(clients))
+	for _, c := range clients {
+		taken[strings.ToLower(c.Email)] = true
+	}
+
+	fixed := 0
+	for _, c := range clients {
+		depleted := c.Depleted != nil && *c.Depleted
+		exhausted := c.Exhausted != nil && *c.Exhausted
+		leftover := strings.HasSuffix(strings.ToLower(c.Email), resetEmailSuffix) && !c.Enable && (depleted || exhausted)
+		if !leftover && (depleted == exhausted || !s.touchedByService(c.Email)) {
+			continue
+		}
+
+		original := c.Email
+		if leftover {
+			original = c.Email[:len(c.Email)-len(resetEmailSuffix)]
+			if taken[strings.ToLower(original)] {
+				initLogs.LogIPBanError("Прерванный сброс %s: email %s занят другим клиентом — нужна ручная проверка", c.Email, original)
+				continue
+			}
+		}
+
+		// Новые учётные данные "-reset" клиента заносим в его идентичность, пока email ещё указывает на неё
+		if leftover && s.BanManager.Identities != nil {
+			s.BanManager.Identities.Sync([]client.Client{c})
+		}
+
+		ban := s.BanManager.GetBanInfo(original)
+		finish := ban != nil && ban.Enforcement != EnforcementPerIP && ban.Enforcement != EnforcementThrottle
+		err := client.PatchClient(s.ConfigManager, client.MatchEmail(c.Email), func(m map[string]interface{}) error {
+			m["email"] = original
+			if finish {
+				m["enable"] = false
+				m["depleted"] = true
+				m["exhausted"] = true
+				return nil
+			}
+			return client.PatchResetDepleted(m)
+		})
+
+		action := "отменён (бана нет)"
+		if finish {
+			action = "завершён (бан активен)"
+		}
+		if err != nil {
+			initLogs.LogIPBanError("Прерванный сброс %s не исправлен: %v", c.Email, err)
+			continue
+		}
+		initLogs.LogIPBanInfo("🩹 Прерванный сброс %s %s, email: %s", c.Email, action, original)
+		// Конфиг отключил сервис: после отмены сброса цикл проверки вправе включить его обратно
+		if finish {
+			s.recordDisable(original, ban.Reason)
+		} else if leftover {
+			s.recordDisable(original, "прерванный агрессивный сброс")
+		}
+		if leftover {
+			delete(taken, strings.ToLower(c.Email))
+			taken[strings.ToLower(original)] = true
+		}
+		fixed++
+	}
+	return fixed
+}
+
+// touchedByService сообщает, что сервис отключал или банил клиента: по журналу отключений или записи бана
+func (s *IPBanService) touchedByService(email string) bool {
+	if s.Disables != nil && s.Disables.Get(s.BanManager.KeyFor(email)) != nil {
+		return true
+	}
+	return s.BanManager.GetBanInfo(email) != nil
+}
+
+// aggressiveReset выполняет AggressiveBanReset и заносит выданные клиенту учётные данные в таблицу идентичностей:
+// иначе клиент без SubID со сменёнными учётными данными получил бы при сверке новую идентичность и потерял бан
+func (s *IPBanService) aggressiveReset(email string) error {
+	credential, err := client.AggressiveBanReset(s.ConfigManager, email)
+	if err != nil {
+		return err
+	}
+	if s.BanManager.Identities != nil {
+		s.BanManager.Identities.AddCredential(email, credential)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package ipban
+
+import (
+	"fmt"
+	"time"
+)
+
+// Throttler — ограничение скорости для IP вместо полной блокировки (действие "throttle").
+// Реализация: HashlimitThrottler (iptables hashlimit — лимит новых соединений и полосы на IP).
+type Throttler interface {
+	// ThrottleIPs ограничивает скорость для IP; timeout — срок наказания (для состояния, правила снимаются сверкой)
+	ThrottleIPs(ipAddresses []string, timeout time.Duration) error
+	// UnthrottleIPs снимает ограничения с IP
+	UnthrottleIPs(ipAddresses []string) error
+	// IsThrottled проверяет, ограничен ли IP
+	IsThrottled(ipAddress string) bool
+	// Reconcile сверяет ограничения на хосте с ожидаемыми (IP -> момент истечения);
+	// apply=true снимает ограничения без активного наказания и восстанавливает потерянные
+	Reconcile(expected map[string]time.Time, apply bool) (*FirewallDrift, error)
+}
+
+// NewThrottler создаёт ограничитель скорости с параметрами из конфигурации (THROTTLE_*)
+func NewThrottler(scope *FirewallScope) (Throttler, error) {
+	if THROTTLE_CONN_PER_MIN <= 0 && THROTTLE_RATE_KBPS <= 0 {
+		return nil, fmt.Errorf("не заданы лимиты троттлинга (THROTTLE_CONN_PER_MIN, THROTTLE_RATE_KBPS)")
+	}
+	return NewHashlimitThrottler(THROTTLE_CHAIN, scope, THROTTLE_CONN_PER_MIN, THROTTLE_RATE_KBPS)
+}
+
+// defaultThrottleTimeout — срок троттлинга по умолчанию
+func defaultThrottleTimeout() time.Duration {
+	return time.Duration(THROTTLE_DURATION) * time.Minute
+}
+
+// ExpectedThrottleState возвращает IP, которые должны быть ограничены по активным наказаниям (BanInfo.ThrottledIPs)
+func ExpectedThrottleState(bm *BanManager) map[string]time.Time {
+	return expectedFromBans(bm, func(ban *BanInfo) []string { return ban.ThrottledIPs })
+}
+
+// ReconcileThrottle сверяет ограничения скорости с активными наказаниями; apply=false — только отчёт
+func ReconcileThrottle(t Throttler, bm *BanManager, apply bool) (*FirewallDrift, error) {
+	drift, err := t.Reconcile(ExpectedThrottleState(bm), apply)
+	if err != nil {
+		return drift, fmt.Errorf("ошибка сверки троттлинга: %v", err)
+	}
+	logDrift("Троттлинг", drift)
+	return drift, nil
+}
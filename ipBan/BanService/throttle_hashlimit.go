@@ -0,0 +1,266 @@
+package ipban
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"ipBanSystem/ipBan/logger/initLogs"
+)
+
+// HashlimitThrottler ограничивает скорость для IP правилами iptables hashlimit.
+// Сервис владеет двумя цепочками: Chain (переход из INPUT — лимит новых соединений с IP)
+// и Chain+"_OUT" (переход из OUTPUT — лимит полосы отдачи на IP). Переходы ограничены областью блокировки,
+// поэтому SSH и панель не замедляются. Пакеты сверх лимита отбрасываются, TCP сам снижает скорость.
+type HashlimitThrottler struct {
+	Chain      string
+	Scope      *FirewallScope
+	ConnPerMin int // Новых соединений в минуту с IP (0 — без лимита)
+	RateKBps   int // Килобайт в секунду на IP (0 — без лимита)
+
+	throttled map[string]time.Time // IP -> момент окончания троттлинга
+	mutex     sync.RWMutex
+}
+
+// NewHashlimitThrottler создаёт ограничитель и подготавливает цепочки и переходы в них
+func NewHashlimitThrottler(chain string, scope *FirewallScope, connPerMin, rateKBps int) (*HashlimitThrottler, error) {
+	h := &HashlimitThrottler{
+		Chain:      chain,
+		Scope:      scope,
+		ConnPerMin: connPerMin,
+		RateKBps:   rateKBps,
+		throttled:  make(map[string]time.Time),
+	}
+	if err := ensureJumpChain("INPUT", h.Chain, scope, "--dports"); err != nil {
+		return nil, err
+	}
+	if err := ensureJumpChain("OUTPUT", h.outChain(), scope, "--sports"); err != nil {
+		return nil, err
+	}
+	initLogs.LogIPBanInfo("Троттлинг: цепочки %s / %s готовы (соединений/мин: %d, КБ/с: %d, область: %s)",
+		h.Chain, h.outChain(), connPerMin, rateKBps, scope)
+	return h, nil
+}
+
+// outChain — цепочка для исходящего трафика
+func (h *HashlimitThrottler) outChain() string {
+	return h.Chain + "_OUT"
+}
+
+// rules возвращает правила троттлинга для IP по текущим лимитам
+func (h *HashlimitThrottler) rules(ip string) []throttleRule {
+	binary := iptablesBinary(ip)
+	var rules []throttleRule
+	if h.ConnPerMin > 0 {
+		rate := strconv.Itoa(h.ConnPerMin) + "/minute"
+		rules = append(rules, throttleRule{binary: binary, chain: h.Chain, spec: []string{
+			"-s", ip, "-m", "conntrack", "--ctstate", "NEW",
+			"-m", "hashlimit", "--hashlimit-above", rate, "--hashlimit-burst", strconv.Itoa(h.ConnPerMin),
+			"--hashlimit-mode", "srcip", "--hashlimit-name", "ipban_conn", "-j", "DROP",
+		}})
+	}
+	if h.RateKBps > 0 {
+		rate := strconv.Itoa(h.RateKBps) + "kb/s"
+		burst := strconv.Itoa(h.RateKBps*2) + "kb"
+		rules = append(rules, throttleRule{binary: binary, chain: h.outChain(), spec: []string{
+			"-d", ip, "-m", "hashlimit", "--hashlimit-above", rate, "--hashlimit-burst", burst,
+			"--hashlimit-mode", "dstip", "--hashlimit-name", "ipban_bw", "-j", "DROP",
+		}})
+	}
+	return rules
+}
+
+// ThrottleIPs добавляет правила троттлинга для IP, которые ещё не ограничены
+func (h *HashlimitThrottler) ThrottleIPs(ipAddresses []string, timeout time.Duration) error {
+	v4, v6, err := splitIPFamilies(ipAddresses)
+	if err != nil {
+		return err
+	}
+	expiresAt := time.Now().Add(timeout)
+
+	var failed []string
+	for _, ip := range append(v4, v6...) {
+		if h.IsThrottled(ip) {
+			h.mutex.Lock()
+			if expiresAt.After(h.throttled[ip]) {
+				h.throttled[ip] = expiresAt
+			}
+			h.mutex.Unlock()
+			continue
+		}
+		ok := true
+		for _, rule := range h.rules(ip) {
+			args := append([]string{"-A", rule.chain}, rule.spec...)
+			if out, err := runCommand(rule.binary, args...); err != nil {
+				initLogs.LogIPBanError("Ошибка троттлинга IP %s: %v: %s", ip, err, strings.TrimSpace(string(out)))
+				ok = false
+			}
+		}
+		if !ok {
+			failed = append(failed, ip)
+			continue
+		}
+		h.mutex.Lock()
+		h.throttled[ip] = expiresAt
+		h.mutex.Unlock()
+		initLogs.LogIPBanAction("IP_ОГРАНИЧЕН", ip, 0, []string{})
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("не удалось ограничить %d IP: %s", len(failed), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// UnthrottleIPs удаляет все правила троттлинга для IP (по фактическим правилам цепочек,
+// поэтому снимаются и правила, созданные с другими лимитами)
+func (h *HashlimitThrottler) UnthrottleIPs(ipAddresses []string) error {
+	if len(ipAddresses) == 0 {
+		return nil
+	}
+	current, err := h.listRules()
+	if err != nil {
+		return err
+	}
+
+	var failed []string
+	for _, ip := range ipAddresses {
+		if parsed := net.ParseIP(ip); parsed != nil {
+			ip = parsed.String()
+		}
+		if err := h.deleteRules(ip, current[ip]); err != nil {
+			initLogs.LogIPBanError("%v", err)
+			failed = append(failed, ip)
+			continue
+		}
+		h.mutex.Lock()
+		_, was := h.throttled[ip]
+		delete(h.throttled, ip)
+		h.mutex.Unlock()
+		if was || len(current[ip]) > 0 {
+			initLogs.LogIPBanAction("IP_ОГРАНИЧЕНИЕ_СНЯТО", ip, 0, []string{})
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("не удалось снять ограничения с %d IP: %s", len(failed), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// IsThrottled проверяет, ограничен ли IP
+func (h *HashlimitThrottler) IsThrottled(ipAddress string) bool {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	expiresAt, ok := h.throttled[ipAddress]
+	return ok && time.Now().Before(expiresAt)
+}
+
+// Reconcile восстанавливает состояние по правилам цепочек, снимает ограничения без активного наказания
+// и заново добавляет потерянные
+func (h *HashlimitThrottler) Reconcile(expected map[string]time.Time, apply bool) (*FirewallDrift, error) {
+	current, err := h.listRules()
+	if err != nil {
+		return nil, err
+	}
+
+	found := make(map[string]int, len(current))
+	h.mutex.Lock()
+	h.throttled = make(map[string]time.Time, len(current))
+	for ip := range current {
+		found[ip] = 1
+		expiresAt, ok := expected[ip]
+		if !ok {
+			// Наказания нет: IP считается ограниченным до применения исправлений
+			expiresAt = time.Now().Add(time.Minute)
+		}
+		h.throttled[ip] = expiresAt
+	}
+	h.mutex.Unlock()
+
+	drift := classifyBlocked(found, expected)
+	drift.Backend = "iptables/hashlimit"
+	if !apply {
+		return drift, nil
+	}
+
+	var failed []string
+	if err := h.UnthrottleIPs(drift.Stale); err != nil {
+		failed = append(failed, drift.Stale...)
+	}
+	for _, ip := range drift.Missing {
+		expiresAt := expected[ip]
+		if !time.Now().Before(expiresAt) {
+			continue
+		}
+		if err := h.ThrottleIPs([]string{ip}, time.Until(expiresAt)); err != nil {
+			failed = append(failed, ip)
+		}
+	}
+	drift.Applied = true
+	if len(failed) > 0 {
+		return drift, fmt.Errorf("не удалось исправить троттлинг для %d IP: %s", len(failed), strings.Join(failed, ", "))
+	}
+	return drift, nil
+}
+
+// throttleRule — правило троттлинга: утилита (iptables/ip6tables), цепочка и спецификация без "-A <цепочка>"
+type throttleRule struct {
+	binary string
+	chain  string
+	spec   []string
+}
+
+// listRules читает правила обеих цепочек в обоих семействах: IP -> правила
+func (h *HashlimitThrottler) listRules() (map[string][]throttleRule, error) {
+	rules := make(map[string][]throttleRule)
+	for _, binary := range iptablesFamilies {
+		for _, chain := range []string{h.Chain, h.outChain()} {
+			out, err := runCommand(binary, "-S", chain)
+			if err != nil {
+				return nil, fmt.Errorf("ошибка чтения цепочки %s (%s): %v: %s", chain, binary, err, strings.TrimSpace(string(out)))
+			}
+			// Строки вида: "-A IPBAN_THROTTLE -s 203.0.113.7/32 -m conntrack ... -j DROP"
+			for _, line := range strings.Split(string(out), "\n") {
+				fields := strings.Fields(line)
+				if len(fields) < 4 || fields[0] != "-A" || fields[1] != chain || (fields[2] != "-s" && fields[2] != "-d") {
+					continue
+				}
+				ip, _, err := net.ParseCIDR(fields[3])
+				if err != nil {
+					continue
+				}
+				rules[ip.String()] = append(rules[ip.String()], throttleRule{binary: binary, chain: chain, spec: fields[2:]})
+			}
+		}
+	}
+	return rules, nil
+}
+
+// deleteRules удаляет найденные правила IP
+func (h *HashlimitThrottler) deleteRules(ip string, rules []throttleRule) error {
+	for _, rule := range rules {
+		args := append([]string{"-D", rule.chain}, rule.spec...)
+		if out, err := runCommand(rule.binary, args...); err != nil {
+			return fmt.Errorf("ошибка удаления правила троттлинга %s из %s: %v: %s", ip, rule.chain, err, strings.TrimSpace(string(out)))
+		}
+	}
+	return nil
+}
+
+// GetThrottledIPs возвращает IP с действующим ограничением
+func (h *HashlimitThrottler) GetThrottledIPs() []string {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	now := time.Now()
+	var ips []string
+	for ip, expiresAt := range h.throttled {
+		if now.Before(expiresAt) {
+			ips = append(ips, ip)
+		}
+	}
+	sort.Strings(ips)
+	return ips
+}
@@ -0,0 +1,74 @@
+package ipban
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"ipBanSystem/ipBan/logger/analyzerLogs"
+	"ipBanSystem/ipBan/panel/client"
+)
+
+// bytesPerGB — гигабайт в байтах (как считает лимиты панель)
+const bytesPerGB = 1 << 30
+
+// trafficAbuse возвращает описание подозрительного расхода трафика клиента за окно TRAFFIC_WINDOW
+// (пусто — порог TRAFFIC_ABUSE_GB не превышен или учёт трафика выключен)
+func (s *IPBanService) trafficAbuse(email string) string {
+	if s.Traffic == nil || TRAFFIC_ABUSE_GB <= 0 {
+		return ""
+	}
+	usage, ok := s.Traffic.Usage(s.BanManager.KeyFor(email))
+	if !ok || float64(usage.Window) <= TRAFFIC_ABUSE_GB*bytesPerGB {
+		return ""
+	}
+	return fmt.Sprintf("расход трафика %.1f ГБ за %v (порог: %.1f ГБ за %d мин)",
+		float64(usage.Window)/bytesPerGB, usage.Span.Round(time.Minute), TRAFFIC_ABUSE_GB, TRAFFIC_WINDOW)
+}
+
+// violates сообщает, нарушает ли клиент ограничения: лимит IP или порог расхода трафика
+func (s *IPBanService) violates(stats *analyzerLogs.EmailIPStats) bool {
+	return stats.TotalIPs > s.MaxIPs || s.trafficAbuse(stats.Email) != ""
+}
+
+// violationReason описывает нарушения клиента для причины бана и журнала
+func (s *IPBanService) violationReason(stats *analyzerLogs.EmailIPStats) string {
+	var reasons []string
+	if stats.TotalIPs > s.MaxIPs {
+		reasons = append(reasons, fmt.Sprintf("Превышение лимита IP адресов: %d (максимум: %d)", stats.TotalIPs, s.MaxIPs))
+	}
+	if abuse := s.trafficAbuse(stats.Email); abuse != "" {
+		reasons = append(reasons, "Подозрительный "+abuse)
+	}
+	return strings.Join(reasons, "; ")
+}
+
+// trafficCapReached возвращает причину, по которой конфиг должен остаться отключенным после разбана:
+// исчерпан лимит трафика или истёк срок в панели (пусто — можно включать; KEEP_DEPLETED_DISABLED выключен)
+func (s *IPBanService) trafficCapReached(email string) string {
+	if !KEEP_DEPLETED_DISABLED {
+		return ""
+	}
+	return s.panelCapReached(email)
+}
+
+// panelCapReached описывает исчерпанный лимит трафика или истёкший срок клиента в панели
+// (пусто — ни то ни другое или статистика недоступна)
+func (s *IPBanService) panelCapReached(email string) string {
+	traffic, ok := s.cycleTraffic(email)
+	if !ok {
+		t, err := client.GetTraffic(s.ConfigManager, email)
+		if err != nil {
+			return ""
+		}
+		traffic = *t
+	}
+	switch {
+	case traffic.Depleted():
+		return fmt.Sprintf("исчерпан лимит трафика (%.1f из %.1f ГБ)",
+			float64(traffic.Used())/bytesPerGB, float64(traffic.Total)/bytesPerGB)
+	case traffic.Expired(time.Now()):
+		return "истёк срок действия " + time.UnixMilli(traffic.ExpiryTime).Format("15:04:05 02.01.2006")
+	}
+	return ""
+}
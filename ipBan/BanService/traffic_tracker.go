@@ -0,0 +1,111 @@
+package ipban
+
+import (
+	"sync"
+	"time"
+
+	"ipBanSystem/ipBan/panel/client"
+)
+
+// trafficSample — показание счётчика трафика клиента (up+down) в момент цикла проверки
+type trafficSample struct {
+	At   time.Time
+	Used int64
+}
+
+// TrafficUsage — расход трафика клиента по показаниям циклов проверки
+type TrafficUsage struct {
+	// Delta — трафик с предыдущего цикла (байты)
+	Delta int64 `json:"delta"`
+	// Interval — время с предыдущего цикла (0 — предыдущего показания нет)
+	Interval time.Duration `json:"interval"`
+	// Window — трафик за окно учёта TrafficTracker.Window (байты)
+	Window int64 `json:"window"`
+	// Span — сколько времени покрывают показания окна (меньше окна, пока показания копятся)
+	Span time.Duration `json:"span"`
+}
+
+// TrafficTracker хранит показания счётчиков трафика клиентов по стабильному ключу идентичности
+// и считает расход между циклами и за скользящее окно. Показания хранятся в памяти:
+// после перезапуска расход считается с первого цикла.
+// Сброс счётчика в панели (показание меньше предыдущего) считается расходом с нуля.
+type TrafficTracker struct {
+	Window  time.Duration
+	samples map[string][]trafficSample
+	usage   map[string]TrafficUsage
+	now     func() time.Time
+	mutex   sync.Mutex
+}
+
+// NewTrafficTracker создаёт счётчик расхода с окном учёта window
+func NewTrafficTracker(window time.Duration) *TrafficTracker {
+	return &TrafficTracker{
+		Window:  window,
+		samples: make(map[string][]trafficSample),
+		usage:   make(map[string]TrafficUsage),
+		now:     time.Now,
+	}
+}
+
+// Observe добавляет показания цикла (email в нижнем регистре -> статистика панели) и пересчитывает расход.
+// keyFor переводит email в стабильный ключ, чтобы переименование клиента не обнуляло историю
+func (tt *TrafficTracker) Observe(stats map[string]client.Traffic, keyFor func(email string) string) {
+	tt.mutex.Lock()
+	defer tt.mutex.Unlock()
+
+	now := tt.now()
+	seen := make(map[string]bool, len(stats))
+	for _, st := range stats {
+		key := keyFor(st.Email)
+		seen[key] = true
+
+		samples := append(tt.samples[key], trafficSample{At: now, Used: st.Used()})
+		// Оставляем одно показание старше окна — от него отсчитывается расход за окно
+		cut := 0
+		for cut+1 < len(samples) && now.Sub(samples[cut+1].At) >= tt.Window {
+			cut++
+		}
+		samples = samples[cut:]
+		tt.samples[key] = samples
+		tt.usage[key] = usageOf(samples)
+	}
+	// Клиенты, удалённые из панели, забываются
+	for key := range tt.samples {
+		if !seen[key] {
+			delete(tt.samples, key)
+			delete(tt.usage, key)
+		}
+	}
+}
+
+// Usage возвращает расход клиента по ключу идентичности (false — показаний ещё нет)
+func (tt *TrafficTracker) Usage(key string) (TrafficUsage, bool) {
+	tt.mutex.Lock()
+	defer tt.mutex.Unlock()
+	u, ok := tt.usage[key]
+	return u, ok
+}
+
+// usageOf считает расход по показаниям одного клиента (последнее — текущее)
+func usageOf(samples []trafficSample) TrafficUsage {
+	var u TrafficUsage
+	if len(samples) < 2 {
+		return u
+	}
+	for i := 1; i < len(samples); i++ {
+		u.Window += increment(samples[i-1].Used, samples[i].Used)
+	}
+	last, prev := samples[len(samples)-1], samples[len(samples)-2]
+	u.Delta = increment(prev.Used, last.Used)
+	u.Interval = last.At.Sub(prev.At)
+	u.Span = last.At.Sub(samples[0].At)
+	return u
+}
+
+// increment возвращает прирост счётчика; уменьшение означает сброс в панели — прирост с нуля
+func increment(prev, cur int64) int64 {
+	if cur < prev {
+		return cur
+	}
+	return cur - prev
+}
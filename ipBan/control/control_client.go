@@ -0,0 +1,226 @@
+package control
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	ipban "ipBanSystem/ipBan/BanService"
+	"ipBanSystem/ipBan/panel/client/lifecycle"
+	"ipBanSystem/ipBan/panel/snapshot"
+)
+
+// ErrDaemonUnavailable возвращается, когда демон не запущен (сокет отсутствует или не принимает соединения)
+var ErrDaemonUnavailable = errors.New("демон ipBanService не запущен")
+
+// Client обращается к управляющему API запущенного демона
+type Client struct {
+	SocketPath string
+	http       *http.Client
+}
+
+// NewClient создаёт клиента управляющего API; подключение выполняется при каждом запросе
+func NewClient(socketPath string) *Client {
+	return &Client{
+		SocketPath: socketPath,
+		http: &http.Client{
+			// Ручной бан включает агрессивный сброс в панели, поэтому таймаут с запасом
+			Timeout: 2 * time.Minute,
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, "unix", socketPath)
+				},
+			},
+		},
+	}
+}
+
+// Ping проверяет, что демон запущен и отвечает
+func (c *Client) Ping() error {
+	return c.do("GET", "/ping", nil, nil)
+}
+
+// Ban банит пользователя через демон
+func (c *Client) Ban(email string, duration time.Duration, reason string) (*ipban.BanInfo, error) {
+	req := BanRequest{Email: email, DurationSec: int64(duration / time.Second), Reason: reason}
+	var ban ipban.BanInfo
+	if err := c.do("POST", "/bans", req, &ban); err != nil {
+		return nil, err
+	}
+	return &ban, nil
+}
+
+// Unban снимает бан через демон
+func (c *Client) Unban(email string) (*ipban.UnbanResult, error) {
+	var result ipban.UnbanResult
+	if err := c.do("DELETE", "/bans/"+url.PathEscape(email), nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ListBans возвращает активные баны
+func (c *Client) ListBans() (map[string]*ipban.BanInfo, error) {
+	bans := make(map[string]*ipban.BanInfo)
+	if err := c.do("GET", "/bans", nil, &bans); err != nil {
+		return nil, err
+	}
+	return bans, nil
+}
+
+// ShowBan возвращает бан пользователя
+func (c *Client) ShowBan(email string) (*ipban.BanInfo, error) {
+	var ban ipban.BanInfo
+	if err := c.do("GET", "/bans/"+url.PathEscape(email), nil, &ban); err != nil {
+		return nil, err
+	}
+	return &ban, nil
+}
+
+// ShowUser возвращает сведения о пользователе
+func (c *Client) ShowUser(email string) (*ipban.UserReport, error) {
+	var report ipban.UserReport
+	if err := c.do("GET", "/users/"+url.PathEscape(email), nil, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// UserLinks возвращает ссылки подключения клиента; host — адрес сервера в ссылках (пусто — по умолчанию демона)
+func (c *Client) UserLinks(email, host string) (*LinksResult, error) {
+	path := "/users/" + url.PathEscape(email) + "/links"
+	if host != "" {
+		path += "?host=" + url.QueryEscape(host)
+	}
+	var result LinksResult
+	if err := c.do("GET", path, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ExtendUsers сдвигает срок действия клиентов на d (отрицательное — сокращение)
+func (c *Client) ExtendUsers(emails []string, d time.Duration) ([]lifecycle.Result, error) {
+	return c.manageClients("/users/extend", ClientsRequest{Emails: emails, DurationSec: int64(d / time.Second)})
+}
+
+// LimitUsers задаёт лимит трафика клиентов в байтах (0 — без лимита)
+func (c *Client) LimitUsers(emails []string, limitBytes int64) ([]lifecycle.Result, error) {
+	return c.manageClients("/users/limit", ClientsRequest{Emails: emails, LimitBytes: limitBytes})
+}
+
+// RenameUsers переименовывает клиентов
+func (c *Client) RenameUsers(renames []lifecycle.Renaming) ([]lifecycle.Result, error) {
+	return c.manageClients("/users/rename", ClientsRequest{Renames: renames})
+}
+
+// DeleteUsers удаляет клиентов из inbound
+func (c *Client) DeleteUsers(emails []string) ([]lifecycle.Result, error) {
+	return c.manageClients("/users/delete", ClientsRequest{Emails: emails})
+}
+
+// manageClients отправляет запрос управления клиентами и возвращает итог по каждому клиенту
+func (c *Client) manageClients(path string, req ClientsRequest) ([]lifecycle.Result, error) {
+	var results []lifecycle.Result
+	if err := c.do("POST", path, req, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// ListSnapshots возвращает снимки inbound от новых к старым
+func (c *Client) ListSnapshots() ([]snapshot.Snapshot, error) {
+	var list []snapshot.Snapshot
+	if err := c.do("GET", "/snapshots", nil, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// DiffSnapshot сравнивает снимок id с текущим inbound, а если with задан — со снимком with
+func (c *Client) DiffSnapshot(id, with string) ([]string, error) {
+	path := "/snapshots/" + url.PathEscape(id) + "/diff"
+	if with != "" {
+		path += "?with=" + url.QueryEscape(with)
+	}
+	var lines []string
+	if err := c.do("GET", path, nil, &lines); err != nil {
+		return nil, err
+	}
+	return lines, nil
+}
+
+// RestoreSnapshot восстанавливает inbound из снимка id
+func (c *Client) RestoreSnapshot(id string) (*snapshot.Snapshot, error) {
+	var snap snapshot.Snapshot
+	if err := c.do("POST", "/snapshots/"+url.PathEscape(id)+"/restore", nil, &snap); err != nil {
+		return nil, err
+	}
+	return &snap, nil
+}
+
+// SyncFirewall сверяет файрвол демона с активными банами; dryRun — только отчёт
+func (c *Client) SyncFirewall(dryRun bool) (*ipban.FirewallDrift, error) {
+	path := "/firewall/sync"
+	if dryRun {
+		path += "?dry_run=1"
+	}
+	var drift ipban.FirewallDrift
+	if err := c.do("POST", path, nil, &drift); err != nil {
+		return nil, err
+	}
+	return &drift, nil
+}
+
+// do выполняет запрос к демону и декодирует поле obj ответа в out
+func (c *Client) do(method, path string, body interface{}, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("ошибка сериализации запроса: %v", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	// Хост в URL не используется: соединение всегда идёт в unix-сокет
+	req, err := http.NewRequest(method, "http://ipban"+path, reader)
+	if err != nil {
+		return fmt.Errorf("ошибка создания запроса: %v", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		var opErr *net.OpError
+		if errors.As(err, &opErr) && opErr.Op == "dial" {
+			return ErrDaemonUnavailable
+		}
+		return fmt.Errorf("ошибка выполнения запроса к демону: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var response Response
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return fmt.Errorf("ошибка парсинга ответа демона (статус %d): %v", resp.StatusCode, err)
+	}
+	if !response.Success {
+		return errors.New(response.Msg)
+	}
+	if out != nil && len(response.Obj) > 0 {
+		if err := json.Unmarshal(response.Obj, out); err != nil {
+			return fmt.Errorf("ошибка парсинга объекта ответа: %v", err)
+		}
+	}
+	return nil
+}
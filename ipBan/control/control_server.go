@@ -0,0 +1,336 @@
+// Пакет control: управляющее API демона поверх unix-сокета.
+// Сервер принимает команды CLI (ban/unban/bans/users/firewall) и выполняет их в запущенном сервисе,
+// чтобы состояние панели и iptables менялось сразу, а не на следующем цикле проверки.
+package control
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	ipban "ipBanSystem/ipBan/BanService"
+	"ipBanSystem/ipBan/logger/initLogs"
+	"ipBanSystem/ipBan/panel"
+	"ipBanSystem/ipBan/panel/client"
+	"ipBanSystem/ipBan/panel/client/lifecycle"
+	"ipBanSystem/ipBan/panel/links"
+	"ipBanSystem/ipBan/panel/snapshot"
+)
+
+// Response — единый формат ответа управляющего API (как у панели: успех/сообщение/объект)
+type Response struct {
+	Success bool            `json:"success"`
+	Msg     string          `json:"msg"`
+	Obj     json.RawMessage `json:"obj,omitempty"`
+}
+
+// BanRequest — тело запроса ручного бана
+type BanRequest struct {
+	Email string `json:"email"`
+	// DurationSec — длительность бана в секундах (0 — по умолчанию из конфигурации)
+	DurationSec int64  `json:"duration_sec"`
+	Reason      string `json:"reason"`
+}
+
+// LinksResult — ссылки подключения клиента и содержимое подписки
+type LinksResult struct {
+	Links []string `json:"links"`
+	// Subscription — ссылки построчно в base64 (формат сервера подписок 3x-ui)
+	Subscription string `json:"subscription"`
+}
+
+// ClientsRequest — тело запросов управления клиентами (users extend/limit/rename/delete).
+// Операция применяется ко всем Emails (или Renames) одним пакетом; один клиент — пакет из одного.
+type ClientsRequest struct {
+	Emails []string `json:"emails,omitempty"`
+	// DurationSec — сдвиг срока действия в секундах (отрицательный — сокращение)
+	DurationSec int64 `json:"duration_sec,omitempty"`
+	// LimitBytes — лимит трафика в байтах (0 — без лимита)
+	LimitBytes int64                `json:"limit_bytes"`
+	Renames    []lifecycle.Renaming `json:"renames,omitempty"`
+}
+
+// Server обслуживает управляющее API на unix-сокете
+type Server struct {
+	SocketPath string
+	Service    *ipban.IPBanService
+
+	listener net.Listener
+	http     *http.Server
+}
+
+// NewServer создаёт сервер управляющего API для сервиса
+func NewServer(socketPath string, service *ipban.IPBanService) *Server {
+	return &Server{
+		SocketPath: socketPath,
+		Service:    service,
+	}
+}
+
+// Start открывает сокет и начинает обслуживать запросы в фоне.
+// Оставшийся от прошлого запуска файл сокета удаляется.
+func (srv *Server) Start() error {
+	if err := os.MkdirAll(filepath.Dir(srv.SocketPath), 0o755); err != nil {
+		return fmt.Errorf("ошибка создания директории сокета: %v", err)
+	}
+	_ = os.Remove(srv.SocketPath)
+
+	ln, err := net.Listen("unix", srv.SocketPath)
+	if err != nil {
+		return fmt.Errorf("ошибка открытия сокета %s: %v", srv.SocketPath, err)
+	}
+	// Доступ к управлению — только у root
+	if err := os.Chmod(srv.SocketPath, 0o600); err != nil {
+		ln.Close()
+		return fmt.Errorf("ошибка установки прав на сокет: %v", err)
+	}
+
+	srv.listener = ln
+	srv.http = &http.Server{
+		Handler:           srv.routes(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		if err := srv.http.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			initLogs.LogIPBanError("Управляющее API остановлено с ошибкой: %v", err)
+		}
+	}()
+	initLogs.LogIPBanInfo("Управляющее API слушает %s", srv.SocketPath)
+	return nil
+}
+
+// Stop закрывает сокет и удаляет его файл
+func (srv *Server) Stop() {
+	if srv.http == nil {
+		return
+	}
+	srv.http.Close()
+	_ = os.Remove(srv.SocketPath)
+}
+
+// routes регистрирует обработчики управляющего API
+func (srv *Server) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /ping", srv.handlePing)
+	mux.HandleFunc("GET /bans", srv.handleListBans)
+	mux.HandleFunc("GET /bans/{email}", srv.handleShowBan)
+	mux.HandleFunc("POST /bans", srv.handleBan)
+	mux.HandleFunc("DELETE /bans/{email}", srv.handleUnban)
+	mux.HandleFunc("GET /users/{email}", srv.handleShowUser)
+	mux.HandleFunc("GET /users/{email}/links", srv.handleUserLinks)
+	mux.HandleFunc("POST /users/extend", srv.handleClients(srv.extendClients))
+	mux.HandleFunc("POST /users/limit", srv.handleClients(srv.limitClients))
+	mux.HandleFunc("POST /users/rename", srv.handleClients(srv.renameClients))
+	mux.HandleFunc("POST /users/delete", srv.handleClients(srv.deleteClients))
+	mux.HandleFunc("GET /snapshots", srv.handleListSnapshots)
+	mux.HandleFunc("GET /snapshots/{id}/diff", srv.handleDiffSnapshot)
+	mux.HandleFunc("POST /snapshots/{id}/restore", srv.handleRestoreSnapshot)
+	mux.HandleFunc("POST /firewall/sync", srv.handleFirewallSync)
+	return mux
+}
+
+func (srv *Server) handlePing(w http.ResponseWriter, r *http.Request) {
+	writeOK(w, "pong", nil)
+}
+
+func (srv *Server) handleListBans(w http.ResponseWriter, r *http.Request) {
+	writeOK(w, "", srv.Service.ListBans())
+}
+
+func (srv *Server) handleShowBan(w http.ResponseWriter, r *http.Request) {
+	email := r.PathValue("email")
+	ban := srv.Service.GetBan(email)
+	if ban == nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("пользователь %s не забанен", email))
+		return
+	}
+	writeOK(w, "", ban)
+}
+
+func (srv *Server) handleBan(w http.ResponseWriter, r *http.Request) {
+	var req BanRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("некорректное тело запроса: %v", err))
+		return
+	}
+	if req.Email == "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("email обязателен"))
+		return
+	}
+
+	ban, err := srv.Service.ManualBan(req.Email, time.Duration(req.DurationSec)*time.Second, req.Reason)
+	if err != nil {
+		writeError(w, panelErrorStatus(err), err)
+		return
+	}
+	writeOK(w, fmt.Sprintf("пользователь %s забанен", ban.Email), ban)
+}
+
+func (srv *Server) handleUnban(w http.ResponseWriter, r *http.Request) {
+	email := r.PathValue("email")
+	result, err := srv.Service.ManualUnban(email)
+	if err != nil {
+		writeError(w, panelErrorStatus(err), err)
+		return
+	}
+	writeOK(w, fmt.Sprintf("пользователь %s разбанен", email), result)
+}
+
+func (srv *Server) handleShowUser(w http.ResponseWriter, r *http.Request) {
+	writeOK(w, "", srv.Service.UserReport(r.PathValue("email")))
+}
+
+// handleUserLinks строит ссылки подключения клиента; ?host= — адрес сервера (по умолчанию SHARE_LINK_HOST)
+func (srv *Server) handleUserLinks(w http.ResponseWriter, r *http.Request) {
+	host := r.URL.Query().Get("host")
+	if host == "" {
+		host = ipban.SHARE_LINK_HOST
+	}
+	list, err := links.ForEmail(srv.Service.ConfigManager, r.PathValue("email"), host)
+	if err != nil {
+		writeError(w, panelErrorStatus(err), err)
+		return
+	}
+	writeOK(w, "", LinksResult{Links: list, Subscription: links.Subscription(list)})
+}
+
+// handleClients разбирает тело запроса управления клиентами, выполняет операцию и отдаёт итог по каждому клиенту.
+// Частичный сбой — не ошибка запроса: итог с ошибками отдельных клиентов возвращается со статусом 200.
+func (srv *Server) handleClients(op func(req ClientsRequest) ([]lifecycle.Result, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req ClientsRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("некорректное тело запроса: %v", err))
+			return
+		}
+		results, err := op(req)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeOK(w, fmt.Sprintf("выполнено: %d из %d", len(results)-lifecycle.Failed(results), len(results)), results)
+	}
+}
+
+func (srv *Server) extendClients(req ClientsRequest) ([]lifecycle.Result, error) {
+	if len(req.Emails) == 0 || req.DurationSec == 0 {
+		return nil, fmt.Errorf("нужны emails и ненулевой duration_sec")
+	}
+	d := time.Duration(req.DurationSec) * time.Second
+	return srv.Service.ManageClients("Изменение срока действия", func(cm *panel.ConfigManager) []lifecycle.Result {
+		return lifecycle.ExtendMany(cm, req.Emails, d, ipban.PANEL_BATCH_SIZE)
+	}), nil
+}
+
+func (srv *Server) limitClients(req ClientsRequest) ([]lifecycle.Result, error) {
+	if len(req.Emails) == 0 {
+		return nil, fmt.Errorf("нужны emails")
+	}
+	return srv.Service.ManageClients("Изменение лимита трафика", func(cm *panel.ConfigManager) []lifecycle.Result {
+		return lifecycle.SetTrafficLimitMany(cm, req.Emails, req.LimitBytes, ipban.PANEL_BATCH_SIZE)
+	}), nil
+}
+
+func (srv *Server) renameClients(req ClientsRequest) ([]lifecycle.Result, error) {
+	if len(req.Renames) == 0 {
+		return nil, fmt.Errorf("нужны renames")
+	}
+	return srv.Service.ManageClients("Переименование", func(cm *panel.ConfigManager) []lifecycle.Result {
+		return lifecycle.RenameMany(cm, req.Renames, ipban.PANEL_BATCH_SIZE)
+	}), nil
+}
+
+func (srv *Server) deleteClients(req ClientsRequest) ([]lifecycle.Result, error) {
+	if len(req.Emails) == 0 {
+		return nil, fmt.Errorf("нужны emails")
+	}
+	return srv.Service.ManageClients("Удаление клиента", func(cm *panel.ConfigManager) []lifecycle.Result {
+		return lifecycle.DeleteMany(cm, req.Emails)
+	}), nil
+}
+
+func (srv *Server) handleListSnapshots(w http.ResponseWriter, r *http.Request) {
+	list, err := snapshot.List(srv.Service.ConfigManager.SnapshotDir)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeOK(w, "", list)
+}
+
+// handleDiffSnapshot сравнивает снимок с текущим inbound; ?with=<id> — с другим снимком
+func (srv *Server) handleDiffSnapshot(w http.ResponseWriter, r *http.Request) {
+	lines, err := client.DiffSnapshot(srv.Service.ConfigManager, r.PathValue("id"), r.URL.Query().Get("with"))
+	if err != nil {
+		writeError(w, panelErrorStatus(err), err)
+		return
+	}
+	writeOK(w, "", lines)
+}
+
+func (srv *Server) handleRestoreSnapshot(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	snap, err := srv.Service.RestoreSnapshot(id)
+	if err != nil {
+		writeError(w, panelErrorStatus(err), err)
+		return
+	}
+	// Состояние inbound клиенту не нужно: достаточно описания снимка
+	snap.Inbound = nil
+	writeOK(w, fmt.Sprintf("inbound восстановлен из снимка %s", id), snap)
+}
+
+// handleFirewallSync сверяет файрвол с банами; ?dry_run=1 — только отчёт
+func (srv *Server) handleFirewallSync(w http.ResponseWriter, r *http.Request) {
+	apply := r.URL.Query().Get("dry_run") != "1"
+	drift, err := srv.Service.SyncFirewall(apply)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeOK(w, "", drift)
+}
+
+// writeOK отправляет успешный ответ с объектом
+func writeOK(w http.ResponseWriter, msg string, obj interface{}) {
+	resp := Response{Success: true, Msg: msg}
+	if obj != nil {
+		data, err := json.Marshal(obj)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, fmt.Errorf("ошибка сериализации ответа: %v", err))
+			return
+		}
+		resp.Obj = data
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// panelErrorStatus выбирает HTTP-статус по виду ошибки панели
+func panelErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, panel.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, panel.ErrUnauthorized), errors.Is(err, panel.ErrPanelUnavailable):
+		return http.StatusBadGateway
+	case errors.Is(err, panel.ErrConflict):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// writeError отправляет ответ с ошибкой
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, Response{Success: false, Msg: err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, resp Response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
@@ -14,6 +14,7 @@ import (
 type IPActivity struct {
 	Email     string
 	IPAddress string
+	FirstSeen time.Time // Первое появление IP за время хранения счётчиков
 	LastSeen  time.Time
 	Count     int
 }
@@ -135,6 +136,7 @@ func (la *LogAnalyzer) AnalyzeLog() (map[string]*EmailIPStats, error) {
 			la.Stats[email].IPs[ipAddress] = &IPActivity{
 				Email:     email,
 				IPAddress: ipAddress,
+				FirstSeen: timestamp,
 				LastSeen:  timestamp,
 				Count:     1,
 			}
@@ -143,6 +145,9 @@ func (la *LogAnalyzer) AnalyzeLog() (map[string]*EmailIPStats, error) {
 			if timestamp.After(la.Stats[email].IPs[ipAddress].LastSeen) {
 				la.Stats[email].IPs[ipAddress].LastSeen = timestamp
 			}
+			if timestamp.Before(la.Stats[email].IPs[ipAddress].FirstSeen) {
+				la.Stats[email].IPs[ipAddress].FirstSeen = timestamp
+			}
 		}
 
 		// Обновляем общее время последнего обновления
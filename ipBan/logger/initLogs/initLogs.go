@@ -6,12 +6,14 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 )
 
 var (
 	IPBanLogger       *log.Logger
 	BannedUsersLogger *log.Logger
+	ViolationsLogger  *log.Logger
 )
 
 // InitIPBanLogger инициализирует логгер для IP ban в отдельный файл
@@ -51,6 +53,22 @@ func InitBannedUsersLogger(logPath string) error {
 	return nil
 }
 
+// InitViolationsLogger инициализирует журнал нарушений, который читает fail2ban
+func InitViolationsLogger(logPath string) error {
+	logDir := filepath.Dir(logPath)
+	if err := os.MkdirAll(logDir, 0o755); err != nil {
+		return err
+	}
+
+	logFile, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+
+	ViolationsLogger = log.New(logFile, "", log.LstdFlags)
+	return nil
+}
+
 // LogIPBanInfo логирует информационное сообщение о IP ban
 func LogIPBanInfo(format string, v ...interface{}) {
 	if IPBanLogger != nil {
@@ -106,3 +124,12 @@ func LogBannedUser(email string, ipAddresses []string, reason string, expiresAt
 			email, ipAddresses, reason, expiresAt.Format("2006-01-02 15:04:05"))
 	}
 }
+
+// LogViolation записывает нарушение в журнал для fail2ban: "IPBAN violation user=<email> ip=<ip> count=<n>".
+// Пробелы в email заменяются на "_", чтобы строка всегда совпадала с фильтром. Без журнала ничего не пишет.
+func LogViolation(email, ip string, count int) {
+	if ViolationsLogger == nil {
+		return
+	}
+	ViolationsLogger.Printf("IPBAN violation user=%s ip=%s count=%d", strings.Join(strings.Fields(email), "_"), ip, count)
+}
@@ -0,0 +1,180 @@
+package auth_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"ipBanSystem/ipBan/panel"
+	"ipBanSystem/ipBan/panel/auth"
+	"ipBanSystem/ipBan/panel/client"
+	"ipBanSystem/ipBan/panel/fakepanel"
+)
+
+// TestAuth прогоняет проверки входа в панель: 2FA, секрет входа, базовый путь, TLS и сохранение сессии
+func TestAuth(t *testing.T) {
+	t.Run("TOTPVector", testTOTPVector)
+	t.Run("TwoFactorLogin", testTwoFactorLogin)
+	t.Run("LoginSecret", testLoginSecret)
+	t.Run("BasePath", testBasePath)
+	t.Run("TLSVerification", testTLSVerification)
+	t.Run("SessionPersisted", testSessionPersisted)
+}
+
+// newAuthManager создаёт менеджер для панели fake без входа
+func newAuthManager(fake *fakepanel.Server) *panel.ConfigManager {
+	cm := panel.NewConfigManager(fake.PanelURL(), fakepanel.DefaultUsername, fakepanel.DefaultPassword, 1)
+	cm.Relogin = auth.Login
+	cm.RetryBackoff = time.Millisecond
+	cm.MaxRetries = 0
+	return cm
+}
+
+func testTOTPVector(t *testing.T) {
+	// RFC 6238, приложение B: секрет "12345678901234567890", T = 59 с -> 94287082 (6 младших цифр)
+	code, err := auth.TOTPCode("GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", time.Unix(59, 0))
+	if err != nil || code != "287082" {
+		t.Fatalf("TOTPCode: %q, %v", code, err)
+	}
+	if _, err := auth.TOTPCode("not base32!", time.Now()); err == nil {
+		t.Errorf("некорректный секрет должен отклоняться")
+	}
+}
+
+func testTwoFactorLogin(t *testing.T) {
+	fake := fakepanel.New()
+	defer fake.Close()
+	fake.TwoFactorSecret = "JBSWY3DPEHPK3PXP"
+	cm := newAuthManager(fake)
+	if err := auth.Login(cm); !errors.Is(err, panel.ErrUnauthorized) {
+		t.Fatalf("вход без кода 2FA: ожидалась ErrUnauthorized, получено: %v", err)
+	}
+	cm.TwoFactorSecret = "jbsw y3dp ehpk 3pxp"
+	if err := auth.Login(cm); err != nil {
+		t.Fatalf("вход с кодом 2FA: %v", err)
+	}
+}
+
+func testLoginSecret(t *testing.T) {
+	fake := fakepanel.New()
+	defer fake.Close()
+	fake.LoginSecret = "s3cret"
+	cm := newAuthManager(fake)
+	if err := auth.Login(cm); !errors.Is(err, panel.ErrUnauthorized) {
+		t.Fatalf("вход без секрета: ожидалась ErrUnauthorized, получено: %v", err)
+	}
+	cm.LoginSecret = "s3cret"
+	if err := auth.Login(cm); err != nil {
+		t.Fatalf("вход с секретом: %v", err)
+	}
+}
+
+func testBasePath(t *testing.T) {
+	fake := fakepanel.New()
+	defer fake.Close()
+	fake.BasePath = "hidden"
+	id := fake.AddInbound("vless", 443, map[string]interface{}{"id": "11111111-1111-1111-1111-111111111111", "email": "user", "enable": true})
+
+	cm := newAuthManager(fake)
+	cm.PanelURL = panel.JoinBasePath(fake.URL, "")
+	if err := auth.Login(cm); err == nil {
+		t.Fatalf("вход вне секретного пути должен отклоняться")
+	}
+
+	cm.PanelURL = panel.JoinBasePath(fake.URL, "/hidden/")
+	if cm.PanelURL != fake.PanelURL() {
+		t.Fatalf("JoinBasePath: %q, ожидалось %q", cm.PanelURL, fake.PanelURL())
+	}
+	cm.InboundID = id
+	if err := auth.Login(cm); err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	if _, err := client.ByEmail(cm, "user"); err != nil {
+		t.Fatalf("ByEmail через секретный путь: %v", err)
+	}
+}
+
+func testTLSVerification(t *testing.T) {
+	fake := fakepanel.NewTLS()
+	defer fake.Close()
+	sum := sha256.Sum256(fake.Certificate().Raw)
+	pin := hex.EncodeToString(sum[:])
+
+	login := func(opts panel.TLSOptions) error {
+		cm := newAuthManager(fake)
+		if err := cm.ConfigureTLS(opts); err != nil {
+			return err
+		}
+		return auth.Login(cm)
+	}
+
+	if err := login(panel.TLSOptions{}); err == nil {
+		t.Errorf("самоподписанный сертификат не должен приниматься по умолчанию")
+	}
+	if err := login(panel.TLSOptions{InsecureSkipVerify: true}); err != nil {
+		t.Errorf("вход без проверки сертификата: %v", err)
+	}
+	if err := login(panel.TLSOptions{PinnedSHA256: pin}); err != nil {
+		t.Errorf("вход с закреплённым отпечатком: %v", err)
+	}
+	other := sha256.Sum256([]byte("другой сертификат"))
+	if err := login(panel.TLSOptions{PinnedSHA256: hex.EncodeToString(other[:])}); err == nil {
+		t.Errorf("чужой отпечаток должен отклоняться")
+	}
+	if err := login(panel.TLSOptions{PinnedSHA256: "abcd"}); err == nil {
+		t.Errorf("отпечаток неверной длины должен отклоняться")
+	}
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: fake.Certificate().Raw}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := login(panel.TLSOptions{CAFile: caFile}); err != nil {
+		t.Errorf("вход с собственным CA: %v", err)
+	}
+}
+
+func testSessionPersisted(t *testing.T) {
+	fake := fakepanel.New()
+	defer fake.Close()
+	id := fake.AddInbound("vless", 443, map[string]interface{}{"id": "11111111-1111-1111-1111-111111111111", "email": "user", "enable": true})
+	path := filepath.Join(t.TempDir(), "session.json")
+
+	cm := newAuthManager(fake)
+	cm.InboundID, cm.SessionPath = id, path
+	if auth.RestoreSession(cm) {
+		t.Fatalf("сессия восстановлена до первого входа")
+	}
+	if err := auth.Login(cm); err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("файл сессии: %v, %v", info, err)
+	}
+
+	// Перезапуск: новый менеджер работает с сохранённой кукой без входа
+	fake.ResetRequests()
+	restarted := newAuthManager(fake)
+	restarted.InboundID, restarted.SessionPath = id, path
+	if !auth.RestoreSession(restarted) {
+		t.Fatalf("сохранённая сессия не восстановлена")
+	}
+	if _, err := client.ByEmail(restarted, "user"); err != nil {
+		t.Fatalf("ByEmail с восстановленной сессией: %v", err)
+	}
+	if n := fake.Count("POST /login"); n != 0 {
+		t.Errorf("после восстановления сессии выполнено %d входов", n)
+	}
+
+	// Сессия другого пользователя не подставляется
+	other := newAuthManager(fake)
+	other.PanelUser, other.SessionPath = "someone", path
+	if auth.RestoreSession(other) {
+		t.Errorf("сессия восстановлена для другого пользователя")
+	}
+}
@@ -8,7 +8,9 @@ import (
     "io"
     "net/http"
     "strings"
+    "time"
 
+    "ipBanSystem/ipBan/logger/initLogs"
     "ipBanSystem/ipBan/panel"
 )
 
@@ -18,6 +20,10 @@ type LoginRequest struct {
     Username string `json:"username"`
     // Password — пароль пользователя панели x-ui
     Password string `json:"password"`
+    // TwoFactorCode — код TOTP, если в панели включена двухфакторная аутентификация
+    TwoFactorCode string `json:"twoFactorCode,omitempty"`
+    // LoginSecret — секрет входа старых версий 3x-ui
+    LoginSecret string `json:"loginSecret,omitempty"`
 }
 
 // LoginResponse структура для ответа авторизации
@@ -34,8 +40,16 @@ type LoginResponse struct {
 func Login(cm *panel.ConfigManager) error {
     // Формируем тело запроса авторизации
     loginData := LoginRequest{
-        Username: cm.PanelUser,
-        Password: cm.PanelPass,
+        Username:    cm.PanelUser,
+        Password:    cm.PanelPass,
+        LoginSecret: cm.LoginSecret,
+    }
+    if cm.TwoFactorSecret != "" {
+        code, err := TOTPCode(cm.TwoFactorSecret, time.Now())
+        if err != nil {
+            return err
+        }
+        loginData.TwoFactorCode = code
     }
 
     // Сериализуем данные в JSON
@@ -52,9 +66,10 @@ func Login(cm *panel.ConfigManager) error {
     req.Header.Set("Content-Type", "application/json")
 
     // Выполняем запрос через общий HTTP‑клиент
+    // Вход идёт напрямую через HTTP-клиент: общий слой запросов сам вызывает Login при истёкшей сессии
     resp, err := cm.Client.Do(req)
     if err != nil {
-        return fmt.Errorf("ошибка выполнения запроса: %v", err)
+        return &panel.Error{Kind: panel.ErrPanelUnavailable, Op: "POST login", Err: err}
     }
     defer resp.Body.Close()
 
@@ -66,29 +81,47 @@ func Login(cm *panel.ConfigManager) error {
 
     // Проверяем HTTP‑статус
     if resp.StatusCode != http.StatusOK {
-        return fmt.Errorf("некорректный статус ответа: %d, body=%s", resp.StatusCode, string(body))
+        kind := panel.ErrRejected
+        if resp.StatusCode >= 500 {
+            kind = panel.ErrPanelUnavailable
+        }
+        return &panel.Error{Kind: kind, Op: "POST login", Status: resp.StatusCode, Msg: string(body)}
     }
 
     // Парсим ответ панели
     var response LoginResponse
     if err := json.Unmarshal(body, &response); err != nil {
-        return fmt.Errorf("ошибка парсинга JSON: %v", err)
+        return &panel.Error{Kind: panel.ErrPanelUnavailable, Op: "POST login", Msg: "ответ не JSON", Err: err}
     }
 
     // Проверяем флаг успеха
     if !response.Success {
-        return fmt.Errorf("ошибка авторизации: %s", response.Msg)
+        return &panel.Error{Kind: panel.ErrUnauthorized, Op: "POST login", Msg: response.Msg}
     }
 
     // Извлекаем сессионную куку
     for _, cookie := range resp.Cookies() {
         if cookie.Name == "3x-ui" {
             // Сохраняем сериализованную куку в менеджер для дальнейших запросов
-            cm.SessionCookie = cookie.String()
+            cm.SetSessionCookie(cookie.String())
+            // Сохраняем куку на диск, чтобы после перезапуска не входить заново
+            if cm.SessionPath != "" {
+                if err := saveSession(cm, cookie.String(), cookieExpiry(cookie)); err != nil {
+                    initLogs.LogIPBanWarning("Не удалось сохранить сессию панели: %v", err)
+                }
+            }
             return nil
         }
     }
 
     // Если не нашли необходимую куку — считаем это ошибкой
-    return fmt.Errorf("сессионная кука не найдена в ответе")
+    return &panel.Error{Kind: panel.ErrUnauthorized, Op: "POST login", Msg: "сессионная кука не найдена в ответе"}
+}
+
+// cookieExpiry возвращает срок действия куки по Expires или Max-Age (нулевое время — без срока)
+func cookieExpiry(cookie *http.Cookie) time.Time {
+    if cookie.MaxAge > 0 {
+        return time.Now().Add(time.Duration(cookie.MaxAge) * time.Second)
+    }
+    return cookie.Expires
 }
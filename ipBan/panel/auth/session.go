@@ -0,0 +1,81 @@
+// Пакет auth: сохранение сессионной куки на диск, чтобы перезапуск сервиса не требовал нового входа
+// (панель ограничивает частоту входов).
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"ipBanSystem/ipBan/logger/initLogs"
+	"ipBanSystem/ipBan/panel"
+)
+
+// savedSession — содержимое файла сессии
+type savedSession struct {
+	// PanelURL и Username — для какой панели и пользователя получена кука
+	PanelURL string `json:"panelUrl"`
+	Username string `json:"username"`
+	// Cookie — кука в формате ConfigManager.SessionCookie
+	Cookie string `json:"cookie"`
+	// Expires — срок действия куки (нулевое время — сессионная кука без срока)
+	Expires time.Time `json:"expires"`
+	// SavedAt — момент входа
+	SavedAt time.Time `json:"savedAt"`
+}
+
+// RestoreSession загружает сохранённую куку сессии в менеджер.
+// Возвращает false, если файла нет, кука истекла или получена для другой панели/пользователя —
+// тогда нужен обычный вход. Недействительная на стороне панели кука обновится повторным входом при первом запросе
+func RestoreSession(cm *panel.ConfigManager) bool {
+	if cm.SessionPath == "" {
+		return false
+	}
+	data, err := os.ReadFile(cm.SessionPath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			initLogs.LogIPBanWarning("Не удалось прочитать сохранённую сессию панели %s: %v", cm.SessionPath, err)
+		}
+		return false
+	}
+	var s savedSession
+	if err := json.Unmarshal(data, &s); err != nil {
+		initLogs.LogIPBanWarning("Файл сессии панели %s повреждён: %v", cm.SessionPath, err)
+		return false
+	}
+	if s.Cookie == "" || s.PanelURL != cm.PanelURL || s.Username != cm.PanelUser {
+		return false
+	}
+	if !s.Expires.IsZero() && time.Now().After(s.Expires) {
+		return false
+	}
+	cm.SetSessionCookie(s.Cookie)
+	return true
+}
+
+// saveSession записывает куку сессии в файл (права 0600: кука даёт доступ к панели)
+func saveSession(cm *panel.ConfigManager, cookie string, expires time.Time) error {
+	data, err := json.MarshalIndent(savedSession{
+		PanelURL: cm.PanelURL,
+		Username: cm.PanelUser,
+		Cookie:   cookie,
+		Expires:  expires,
+		SavedAt:  time.Now(),
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("ошибка сериализации сессии: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(cm.SessionPath), 0700); err != nil {
+		return fmt.Errorf("ошибка создания каталога сессии: %v", err)
+	}
+	tmp := cm.SessionPath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("ошибка записи сессии: %v", err)
+	}
+	if err := os.Rename(tmp, cm.SessionPath); err != nil {
+		return fmt.Errorf("ошибка сохранения сессии: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+// Пакет auth: код двухфакторной аутентификации (TOTP, RFC 6238) для входа в панель 3x-ui.
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Параметры TOTP панели 3x-ui (как в Google Authenticator)
+const (
+	totpPeriod = 30 * time.Second
+	totpDigits = 6
+)
+
+// TOTPCode возвращает шестизначный код TOTP для секрета в base32 на момент t
+func TOTPCode(secret string, t time.Time) (string, error) {
+	secret = strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(secret), " ", ""))
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.TrimRight(secret, "="))
+	if err != nil {
+		return "", fmt.Errorf("некорректный секрет TOTP (ожидается base32): %w", err)
+	}
+
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(t.Unix()/int64(totpPeriod/time.Second)))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	// Динамическое усечение (RFC 4226, раздел 5.3)
+	offset := sum[len(sum)-1] & 0x0f
+	code := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, code%1000000), nil
+}
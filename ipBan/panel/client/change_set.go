@@ -0,0 +1,220 @@
+// Пакет client: набор изменений клиентов, применяемый одной записью inbound.
+// Цикл проверки копит решения (включить, снять "исчерпано") и сохраняет их разом:
+// одна запись и один перезапуск Xray вместо записи и ресета на каждого клиента.
+package client
+
+import (
+	"errors"
+	"fmt"
+
+	"ipBanSystem/ipBan/logger/initLogs"
+	"ipBanSystem/ipBan/panel"
+)
+
+// Change — изменение одного клиента
+type Change struct {
+	// Selector — какой клиент меняется
+	Selector Selector
+	// Action — описание изменения для журнала ("включение", "снятие 'исчерпано'")
+	Action string
+	// Patch — изменение JSON клиента. Применяется к свежим данным и при повторе должно давать тот же результат
+	Patch func(m map[string]interface{}) error
+}
+
+// ChangeResult — итог изменения одного клиента
+type ChangeResult struct {
+	Change
+	// Changed — клиент изменён (false — уже был в нужном состоянии или ошибка)
+	Changed bool
+	// Err — ошибка: клиент не найден, патч или запись не удались
+	Err error
+}
+
+// ChangeSet копит изменения клиентов для пакетной записи
+type ChangeSet struct {
+	changes []Change
+}
+
+// NewChangeSet создаёт пустой набор изменений
+func NewChangeSet() *ChangeSet {
+	return &ChangeSet{}
+}
+
+// Add добавляет изменение клиента в набор
+func (cs *ChangeSet) Add(sel Selector, action string, patch func(m map[string]interface{}) error) {
+	cs.changes = append(cs.changes, Change{Selector: sel, Action: action, Patch: patch})
+}
+
+// Len возвращает число изменений в наборе
+func (cs *ChangeSet) Len() int {
+	return len(cs.changes)
+}
+
+// Apply применяет изменения и возвращает итог по каждому в порядке добавления.
+// Одиночное изменение уходит через API клиента (PatchClient). Несколько изменений записываются
+// полным обновлением inbound по chunkSize изменений за запись (0 — все одной записью),
+// с проверкой конфликта до записи и сверкой после; жёсткий ресет выполняется один раз в конце.
+func (cs *ChangeSet) Apply(cm *panel.ConfigManager, chunkSize int) []ChangeResult {
+	switch len(cs.changes) {
+	case 0:
+		return nil
+	case 1:
+		c := cs.changes[0]
+		changed := false
+		err := PatchClient(cm, c.Selector, func(m map[string]interface{}) error {
+			before := normalizeJSON(m).(map[string]interface{})
+			if err := c.Patch(m); err != nil {
+				return err
+			}
+			changed = len(changedFields(before, m)) > 0
+			return nil
+		})
+		return []ChangeResult{{Change: c, Changed: changed && err == nil, Err: err}}
+	}
+
+	if chunkSize <= 0 {
+		chunkSize = len(cs.changes)
+	}
+	results := make([]ChangeResult, 0, len(cs.changes))
+	wrote := false
+	for start := 0; start < len(cs.changes); start += chunkSize {
+		chunkResults, w := applyChunk(cm, cs.changes[start:min(start+chunkSize, len(cs.changes))])
+		results = append(results, chunkResults...)
+		wrote = wrote || w
+	}
+
+	if wrote {
+		// Полное обновление inbound Xray может не подхватить — один жёсткий ресет на весь набор
+		if err := HardResetInbound(cm); err != nil {
+			initLogs.LogIPBanError("Изменения клиентов записаны, но жёсткий ресет не удался: %v", err)
+		}
+	}
+	return results
+}
+
+// applyChunk записывает пакет изменений, повторяя его на свежих данных при ErrConflict
+func applyChunk(cm *panel.ConfigManager, chunk []Change) ([]ChangeResult, bool) {
+	var changedEarlier []bool
+	wroteAny := false
+	for attempt := 1; ; attempt++ {
+		results, wrote, err := applyChunkOnce(cm, chunk)
+		// Изменения, записанные прошлой попыткой, на свежих данных уже не видны как изменения
+		for i := range changedEarlier {
+			results[i].Changed = results[i].Changed || (changedEarlier[i] && results[i].Err == nil)
+		}
+		wroteAny = wroteAny || wrote
+		if err == nil {
+			return results, wroteAny
+		}
+		if errors.Is(err, panel.ErrConflict) && attempt < maxTxAttempts {
+			initLogs.LogIPBanWarning("Пакет изменений %d клиентов: %v — повтор на свежих данных (%d/%d)", len(chunk), err, attempt, maxTxAttempts)
+			if wrote {
+				changedEarlier = make([]bool, len(results))
+				for i := range results {
+					changedEarlier[i] = results[i].Changed
+				}
+			}
+			continue
+		}
+		for i := range results {
+			if results[i].Err == nil && results[i].Changed {
+				results[i].Changed = false
+				results[i].Err = err
+			}
+		}
+		return results, wroteAny
+	}
+}
+
+// chunkClient — клиент, затронутый пакетом: версия до патчей и JSON внутри настроек (после патчей)
+type chunkClient struct {
+	before map[string]interface{}
+	m      map[string]interface{}
+}
+
+// applyChunkOnce выполняет одну попытку пакета: чтение, патчи, проверка конфликта, запись, сверка.
+// Ошибка возвращается только для всего пакета; ошибки отдельных клиентов — в results.
+func applyChunkOnce(cm *panel.ConfigManager, chunk []Change) (results []ChangeResult, wrote bool, err error) {
+	results = make([]ChangeResult, len(chunk))
+	for i, c := range chunk {
+		results[i].Change = c
+	}
+	inb, raw, err := loadSettings(cm)
+	if err != nil {
+		for i := range results {
+			results[i].Err = err
+		}
+		return results, false, nil
+	}
+
+	touched := make(map[int]*chunkClient)
+	for i, c := range chunk {
+		idx, m := findClientIndex(raw, c.Selector)
+		if m == nil {
+			results[i].Err = panel.NotFoundf("%s не найден", c.Selector)
+			continue
+		}
+		if touched[idx] == nil {
+			touched[idx] = &chunkClient{before: normalizeJSON(m).(map[string]interface{}), m: m}
+		}
+		prev := normalizeJSON(m).(map[string]interface{})
+		if err := c.Patch(m); err != nil {
+			// Откатываем частично применённый патч, чтобы он не попал в запись
+			for k := range m {
+				delete(m, k)
+			}
+			for k, v := range prev {
+				m[k] = v
+			}
+			results[i].Err = err
+			continue
+		}
+		results[i].Changed = len(changedFields(prev, m)) > 0
+	}
+
+	type written struct {
+		key    string
+		email  string // email до патчей: снимок хранит клиента в прежнем состоянии
+		want   map[string]interface{}
+		fields []string
+	}
+	var writes []written
+	for _, tc := range touched {
+		if fields := changedFields(tc.before, tc.m); len(fields) > 0 {
+			email, _ := tc.before["email"].(string)
+			writes = append(writes, written{key: clientKey(inb.Protocol, tc.m), email: email, want: tc.m, fields: fields})
+		}
+	}
+	if len(writes) == 0 {
+		return results, false, nil
+	}
+
+	others := clientsByKey(inb.Protocol, raw, "")
+	for _, w := range writes {
+		delete(others, w.key)
+	}
+	if err := checkUnchanged(cm, inb, true, "", nil); err != nil {
+		return results, false, err
+	}
+	emails := make([]string, 0, len(writes))
+	for _, w := range writes {
+		emails = append(emails, w.email)
+	}
+	takeSnapshot(cm, inb, fmt.Sprintf("пакет изменений %d клиентов", len(writes)), emails...)
+	if err := saveSettings(cm, inb, raw); err != nil {
+		return results, false, fmt.Errorf("ошибка записи изменений клиентов: %w", err)
+	}
+
+	inbAfter, rawAfter, err := loadSettings(cm)
+	if err != nil {
+		return results, true, fmt.Errorf("изменения записаны, но проверка не удалась: %w", err)
+	}
+	after := clientsByKey(inbAfter.Protocol, rawAfter, "")
+	for _, w := range writes {
+		if err := verifyClient(after, w.key, w.want, w.fields); err != nil {
+			return results, true, err
+		}
+	}
+	warnTouched(cm, after, others)
+	return results, true, nil
+}
@@ -0,0 +1,165 @@
+// Пакет client: изменение отдельного клиента через API клиентов 3x-ui.
+// Панель получает только изменённого клиента (updateClient/addClient/delClient) и сама применяет его в Xray,
+// поэтому изменение не затирает параллельные правки других клиентов и не требует жёсткого ресета inbound.
+// Старые версии панели этого API не имеют (404) — тогда изменения сохраняются полным обновлением inbound.
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"ipBanSystem/ipBan/logger/initLogs"
+	"ipBanSystem/ipBan/panel"
+	"ipBanSystem/ipBan/panel/inbound"
+)
+
+// Selector выбирает клиента inbound: по email (без учёта регистра) или по id
+type Selector struct {
+	Email string
+	ID    string
+}
+
+// MatchEmail выбирает клиента по email
+func MatchEmail(email string) Selector { return Selector{Email: email} }
+
+// MatchID выбирает клиента по id (UUID) или паролю (trojan/shadowsocks)
+func MatchID(id string) Selector { return Selector{ID: id} }
+
+// String описывает клиента для сообщений об ошибках
+func (s Selector) String() string {
+	if s.Email != "" {
+		return "клиент с email " + s.Email
+	}
+	return "клиент с ID " + s.ID
+}
+
+// matches проверяет, подходит ли клиент из настроек inbound
+func (s Selector) matches(m map[string]interface{}) bool {
+	if s.Email != "" {
+		em, _ := m["email"].(string)
+		return strings.EqualFold(em, s.Email)
+	}
+	// ID — учётные данные клиента: UUID (vless/vmess) или пароль (trojan/shadowsocks)
+	id, _ := m["id"].(string)
+	password, _ := m["password"].(string)
+	return id == s.ID || (password != "" && password == s.ID)
+}
+
+// PatchClient находит клиента, применяет к его JSON функцию patch и сохраняет изменение в панели.
+// Меняются только поля, которые трогает patch: неизвестные ключи (subId, flow и т.п.) сохраняются.
+// Изменение транзакционно (см. patchClient): при параллельной правке inbound patch повторяется на свежих данных,
+// поэтому он должен вычислять новые значения из переданного JSON клиента.
+func PatchClient(cm *panel.ConfigManager, sel Selector, patch func(m map[string]interface{}) error) error {
+	return PatchInboundClient(cm, sel, func(_ *inbound.Inbound, m map[string]interface{}) error {
+		return patch(m)
+	})
+}
+
+// PatchInboundClient — PatchClient для патчей, зависящих от inbound (протокол, общие настройки)
+func PatchInboundClient(cm *panel.ConfigManager, sel Selector, patch InboundPatch) error {
+	fullUpdate, err := patchClient(cm, sel, patch)
+	if err != nil {
+		return err
+	}
+	if fullUpdate {
+		// Полное обновление inbound Xray может не подхватить — жёсткий ресет Remark
+		if err := HardResetInbound(cm); err != nil {
+			return fmt.Errorf("обновление прошло, но жёсткий ресет не удался: %w", err)
+		}
+	}
+	return nil
+}
+
+// addClient добавляет клиента в inbound (POST panel/api/inbounds/addClient) или полным обновлением на старой панели
+func addClient(cm *panel.ConfigManager, c map[string]interface{}) error {
+	email, _ := c["email"].(string)
+	takeSnapshot(cm, nil, "добавление клиента "+email, email)
+	if cm.UseClientAPI() {
+		err := cm.Post("panel/api/inbounds/addClient", clientPayload(cm, c), nil)
+		if !clientAPIMissing(err) {
+			return err
+		}
+		disableClientAPI(cm, err)
+	}
+
+	inb, raw, err := loadSettings(cm)
+	if err != nil {
+		return err
+	}
+	clientsAny, _ := raw["clients"].([]interface{})
+	raw["clients"] = append(clientsAny, c)
+	return saveSettings(cm, inb, raw)
+}
+
+// loadSettings получает inbound и разбирает его настройки как произвольный JSON
+func loadSettings(cm *panel.ConfigManager) (*inbound.Inbound, map[string]interface{}, error) {
+	inb, err := inbound.GetInbound(cm)
+	if err != nil {
+		return nil, nil, fmt.Errorf("ошибка получения inbound: %w", err)
+	}
+	var raw map[string]interface{}
+	if err := json.Unmarshal([]byte(inb.Settings), &raw); err != nil {
+		return nil, nil, fmt.Errorf("ошибка парсинга настроек inbound: %w", err)
+	}
+	if _, ok := raw["clients"].([]interface{}); !ok {
+		return nil, nil, fmt.Errorf("поле clients отсутствует или имеет неверный тип")
+	}
+	return inb, raw, nil
+}
+
+// saveSettings записывает настройки в inbound и сохраняет его целиком (для VLESS гарантируя decryption:"none")
+func saveSettings(cm *panel.ConfigManager, inb *inbound.Inbound, raw map[string]interface{}) error {
+	if dec, ok := raw["decryption"].(string); needsDecryptionNone(inb.Protocol) && (!ok || dec != "none") {
+		raw["decryption"] = "none"
+	}
+	settingsJSON, err := json.Marshal(raw)
+	if err != nil {
+		return fmt.Errorf("ошибка сериализации настроек: %w", err)
+	}
+	inb.Settings = string(settingsJSON)
+	return inbound.Update(cm, inb)
+}
+
+// findClient возвращает JSON клиента из массива clients (сам элемент массива, а не копию)
+func findClient(raw map[string]interface{}, sel Selector) map[string]interface{} {
+	_, m := findClientIndex(raw, sel)
+	return m
+}
+
+// findClientIndex возвращает индекс клиента в массиве clients и его JSON (-1 и nil — не найден)
+func findClientIndex(raw map[string]interface{}, sel Selector) (int, map[string]interface{}) {
+	clientsAny, _ := raw["clients"].([]interface{})
+	for i := range clientsAny {
+		if m, ok := clientsAny[i].(map[string]interface{}); ok && sel.matches(m) {
+			return i, m
+		}
+	}
+	return -1, nil
+}
+
+// clientPayload формирует тело запросов addClient/updateClient: {"id": <inbound>, "settings": "{\"clients\":[...]}"}
+func clientPayload(cm *panel.ConfigManager, clients ...interface{}) map[string]interface{} {
+	settings, _ := json.Marshal(map[string]interface{}{"clients": clients})
+	return map[string]interface{}{
+		"id":       cm.InboundID,
+		"settings": string(settings),
+	}
+}
+
+// clientAPIMissing проверяет, что панель не знает маршрута API клиентов (HTTP 404).
+// "Клиент не найден" приходит как success:false с HTTP 200 и сюда не относится.
+func clientAPIMissing(err error) bool {
+	var perr *panel.Error
+	return errors.As(err, &perr) && perr.Status == http.StatusNotFound
+}
+
+// disableClientAPI переключает менеджер на полное обновление inbound
+func disableClientAPI(cm *panel.ConfigManager, err error) {
+	if cm.UseClientAPI() {
+		initLogs.LogIPBanWarning("Панель не поддерживает API клиентов (%v) — изменения клиентов сохраняются полным обновлением inbound", err)
+	}
+	cm.DisableClientAPI()
+}
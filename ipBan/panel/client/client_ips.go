@@ -0,0 +1,38 @@
+// Пакет client: IP-адреса клиента, которые записала панель.
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"ipBanSystem/ipBan/panel"
+)
+
+// ClientIPs возвращает IP клиента через POST panel/api/inbounds/clientIps/<email>.
+// Панель отдаёт строку: JSON-массив адресов (в новых версиях — "IP (время)") или "No IP Record".
+func ClientIPs(cm *panel.ConfigManager, email string) ([]string, error) {
+	var obj string
+	if err := cm.Post("panel/api/inbounds/clientIps/"+url.PathEscape(email), nil, &obj); err != nil {
+		return nil, fmt.Errorf("ошибка получения IP клиента %s: %w", email, err)
+	}
+	obj = strings.TrimSpace(obj)
+	if !strings.HasPrefix(obj, "[") {
+		// "No IP Record" и пустой ответ — адресов нет
+		return nil, nil
+	}
+
+	var entries []string
+	if err := json.Unmarshal([]byte(obj), &entries); err != nil {
+		return nil, fmt.Errorf("ошибка парсинга IP клиента %s: %w", email, err)
+	}
+	ips := make([]string, 0, len(entries))
+	for _, e := range entries {
+		// Отбрасываем отметку времени: "203.0.113.7 (2024-05-01 10:00:00)"
+		if f := strings.Fields(e); len(f) > 0 {
+			ips = append(ips, f[0])
+		}
+	}
+	return ips, nil
+}
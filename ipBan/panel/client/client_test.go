@@ -0,0 +1,272 @@
+package client_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"ipBanSystem/ipBan/panel"
+	"ipBanSystem/ipBan/panel/auth"
+	"ipBanSystem/ipBan/panel/client"
+	"ipBanSystem/ipBan/panel/fakepanel"
+)
+
+// TestClient прогоняет проверки работы с клиентами панели на поддельной панели (fakepanel)
+func TestClient(t *testing.T) {
+	t.Run("LoginRejected", testLoginRejected)
+	t.Run("ReloginOnExpiredSession", testReloginOnExpiredSession)
+	t.Run("RetryUnavailable", testRetryUnavailable)
+	t.Run("PatchKeepsUnknownFields", testPatchKeepsUnknownFields)
+	t.Run("LegacyFallback", testLegacyFallback)
+	t.Run("NotFound", testNotFound)
+	t.Run("AddAndDelete", testAddAndDelete)
+	t.Run("TrafficAndIPs", testTrafficAndIPs)
+	t.Run("EmailWithSpace", testEmailWithSpace)
+	t.Run("Timeout", testTimeout)
+	t.Run("TrojanClients", testTrojanClients)
+	t.Run("ShadowsocksClients", testShadowsocksClients)
+	t.Run("LegacyKeepsProtocolSettings", testLegacyKeepsProtocolSettings)
+}
+
+func testLoginRejected(t *testing.T) {
+	fake := fakepanel.New()
+	defer fake.Close()
+	cm := panel.NewConfigManager(fake.PanelURL(), "admin", "wrong", 1)
+	if err := auth.Login(cm); !errors.Is(err, panel.ErrUnauthorized) {
+		t.Fatalf("ожидалась ErrUnauthorized, получено: %v", err)
+	}
+}
+
+func testReloginOnExpiredSession(t *testing.T) {
+	fake, cm := fakepanel.StartVLESS(t)
+	fake.ExpireSessions()
+	if _, err := client.ByEmail(cm, "user"); err != nil {
+		t.Fatalf("ByEmail после истечения сессии: %v", err)
+	}
+	if fake.Count("POST /login") != 1 {
+		t.Errorf("ожидался один повторный вход: %v", fake.Requests())
+	}
+}
+
+func testRetryUnavailable(t *testing.T) {
+	fake, cm := fakepanel.StartVLESS(t)
+	fake.Fail("GET /panel/api/inbounds/get", 503, 2)
+	if _, err := client.ByEmail(cm, "user"); err != nil {
+		t.Fatalf("ByEmail после двух 503: %v", err)
+	}
+	if n := fake.Count("GET /panel/api/inbounds/get"); n != 3 {
+		t.Errorf("запросов get: %d, ожидалось 3", n)
+	}
+
+	// Неидемпотентный POST после ответа 5xx не повторяется
+	fake.Fail("POST /panel/api/inbounds/updateClient", 503, 1)
+	fake.ResetRequests()
+	if err := client.EnableConfig(cm, "user"); err != nil {
+		t.Fatalf("включение уже включенного клиента не должно писать в панель: %v", err)
+	}
+	if _, err := client.RotateUUID(cm, "user"); !errors.Is(err, panel.ErrPanelUnavailable) {
+		t.Fatalf("ожидалась ErrPanelUnavailable, получено: %v", err)
+	}
+	if n := fake.Count("POST /panel/api/inbounds/updateClient"); n != 1 {
+		t.Errorf("POST updateClient повторён: %d запросов", n)
+	}
+}
+
+func testPatchKeepsUnknownFields(t *testing.T) {
+	fake, cm := fakepanel.StartVLESS(t)
+	newUUID, err := client.RotateUUID(cm, "USER")
+	if err != nil {
+		t.Fatalf("RotateUUID: %v", err)
+	}
+	c := fake.Client(cm.InboundID, "user")
+	if c["id"] != newUUID || c["enable"] != false {
+		t.Errorf("клиент не обновлён: %v", c)
+	}
+	if c["subId"] != "sub1" || c["flow"] != "xtls-rprx-vision" {
+		t.Errorf("потеряны неизвестные поля: %v", c)
+	}
+	if n := fake.Count("POST /panel/api/inbounds/update/"); n != 0 {
+		t.Errorf("API клиента не должен обновлять inbound целиком: %d", n)
+	}
+}
+
+func testLegacyFallback(t *testing.T) {
+	fake, cm := fakepanel.StartVLESS(t)
+	fake.Legacy = true
+	if err := client.ResetDepletedStatus(cm, "user"); err != nil {
+		t.Fatalf("ResetDepletedStatus: %v", err)
+	}
+	if cm.UseClientAPI() {
+		t.Errorf("после 404 менеджер должен перейти на полное обновление")
+	}
+	if c := fake.Client(cm.InboundID, "user"); c["depleted"] != false || c["subId"] != "sub1" {
+		t.Errorf("клиент не обновлён полным обновлением: %v", c)
+	}
+	if fake.Count("POST /panel/api/inbounds/update/") == 0 {
+		t.Errorf("ожидалось полное обновление inbound: %v", fake.Requests())
+	}
+}
+
+func testNotFound(t *testing.T) {
+	_, cm := fakepanel.StartVLESS(t)
+	if err := client.ResetDepletedStatus(cm, "nobody"); !errors.Is(err, panel.ErrNotFound) {
+		t.Fatalf("ожидалась ErrNotFound, получено: %v", err)
+	}
+}
+
+func testAddAndDelete(t *testing.T) {
+	fake, cm := fakepanel.StartVLESS(t)
+	added, err := client.Add(cm, "second", 0, 0)
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if c := fake.Client(cm.InboundID, "second"); c == nil || c["id"] != added.ID {
+		t.Fatalf("клиент не добавлен: %v", fake.Clients(cm.InboundID))
+	}
+	if _, err := client.Add(cm, "SECOND", 0, 0); err == nil {
+		t.Errorf("дубликат email должен отклоняться")
+	}
+	if err := client.Delete(cm, "second"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if c := fake.Client(cm.InboundID, "second"); c != nil {
+		t.Errorf("клиент не удалён: %v", c)
+	}
+	if fake.Client(cm.InboundID, "user") == nil {
+		t.Errorf("удалён не тот клиент")
+	}
+}
+
+func testTrafficAndIPs(t *testing.T) {
+	fake, cm := fakepanel.StartVLESS(t)
+	traffic, err := client.GetTraffic(cm, "user")
+	if err != nil || traffic.Email != "user" {
+		t.Fatalf("GetTraffic: %+v, %v", traffic, err)
+	}
+	if _, err := client.GetTraffic(cm, "nobody"); !errors.Is(err, panel.ErrNotFound) {
+		t.Errorf("ожидалась ErrNotFound для неизвестного клиента, получено: %v", err)
+	}
+
+	ips, err := client.ClientIPs(cm, "user")
+	if err != nil || len(ips) != 0 {
+		t.Fatalf("ClientIPs без записей: %v, %v", ips, err)
+	}
+	fake.SetClientIPs("user", "203.0.113.7 (2024-05-01 10:00:00)", "198.51.100.2")
+	ips, err = client.ClientIPs(cm, "user")
+	if err != nil || len(ips) != 2 || ips[0] != "203.0.113.7" {
+		t.Fatalf("ClientIPs: %v, %v", ips, err)
+	}
+}
+
+// Экранированный путь (пробел в email -> %20) не должен приниматься за перенаправление на страницу входа
+func testEmailWithSpace(t *testing.T) {
+	fake, cm := fakepanel.Start(t, "vless", 443, map[string]interface{}{
+		"id": "22222222-2222-2222-2222-222222222222", "email": "user two", "enable": true, "subId": "sub2",
+	})
+	logins := fake.Count("POST /login")
+
+	traffic, err := client.GetTraffic(cm, "user two")
+	if err != nil || traffic.Email != "user two" {
+		t.Fatalf("GetTraffic: %+v, %v", traffic, err)
+	}
+	fake.SetClientIPs("user two", "203.0.113.8")
+	ips, err := client.ClientIPs(cm, "user two")
+	if err != nil || len(ips) != 1 || ips[0] != "203.0.113.8" {
+		t.Fatalf("ClientIPs: %v, %v", ips, err)
+	}
+	if fake.Count("POST /login") != logins {
+		t.Errorf("ответ на экранированный путь принят за страницу входа: %v", fake.Requests())
+	}
+}
+
+func testTimeout(t *testing.T) {
+	fake, cm := fakepanel.StartVLESS(t)
+	cm.Client.Timeout = 20 * time.Millisecond
+	cm.MaxRetries = 1
+	fake.Latency = 100 * time.Millisecond
+	if _, err := client.ByEmail(cm, "user"); !errors.Is(err, panel.ErrPanelUnavailable) {
+		t.Fatalf("ожидалась ErrPanelUnavailable при таймауте, получено: %v", err)
+	}
+}
+
+func testTrojanClients(t *testing.T) {
+	fake, cm := fakepanel.Start(t, client.ProtocolTrojan, 8443, map[string]interface{}{
+		"password": "old-password", "email": "user", "enable": true, "subId": "sub1",
+	})
+	added, err := client.Add(cm, "second", 0, 0)
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	c := fake.Client(cm.InboundID, "second")
+	if c == nil || c["password"] == "" || c["password"] != added.Credential() {
+		t.Fatalf("клиент trojan не добавлен с паролем: %v", c)
+	}
+	if _, ok := c["id"]; ok {
+		t.Errorf("у клиента trojan не должно быть id: %v", c)
+	}
+
+	password, err := client.AggressiveBanReset(cm, "user")
+	if err != nil {
+		t.Fatalf("AggressiveBanReset: %v", err)
+	}
+	c = fake.Client(cm.InboundID, "user")
+	if password == "old-password" || c["password"] != password || c["enable"] != false {
+		t.Errorf("пароль trojan не ротирован: %v", c)
+	}
+	if _, ok := c["id"]; ok {
+		t.Errorf("сброс не должен добавлять id клиенту trojan: %v", c)
+	}
+	if err := client.EnableConfig(cm, "user"); err != nil {
+		t.Fatalf("EnableConfig по паролю: %v", err)
+	}
+	if fake.Client(cm.InboundID, "user")["enable"] != true {
+		t.Errorf("клиент trojan не включен")
+	}
+}
+
+func testShadowsocksClients(t *testing.T) {
+	fake, cm := fakepanel.Start(t, client.ProtocolShadowsocks, 8443, map[string]interface{}{
+		"password": "b2xkLWtleS0zMi1ieXRlcy1mb3ItdGVzdHMtMDAwMA==", "method": "", "email": "user", "enable": true,
+	})
+	added, err := client.Add(cm, "second", 0, 0)
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if key, err := base64.StdEncoding.DecodeString(added.Password); err != nil || len(key) != 32 {
+		t.Errorf("ключ shadowsocks 2022 должен быть 32 байта в base64: %q", added.Password)
+	}
+	if added.Method != "" {
+		t.Errorf("шифр 2022 задаётся на inbound, у клиента: %q", added.Method)
+	}
+
+	key, err := client.RotateUUID(cm, "user")
+	if err != nil {
+		t.Fatalf("RotateUUID: %v", err)
+	}
+	c := fake.Client(cm.InboundID, "user")
+	if c["password"] != key || c["enable"] != false {
+		t.Errorf("ключ shadowsocks не ротирован: %v", c)
+	}
+	if _, ok := c["id"]; ok {
+		t.Errorf("ротация не должна добавлять id клиенту shadowsocks: %v", c)
+	}
+}
+
+func testLegacyKeepsProtocolSettings(t *testing.T) {
+	fake, cm := fakepanel.Start(t, client.ProtocolTrojan, 8443, map[string]interface{}{
+		"password": "old-password", "email": "user", "enable": true,
+	})
+	fake.Legacy = true
+	if err := client.ResetDepletedStatus(cm, "user"); err != nil {
+		t.Fatalf("ResetDepletedStatus: %v", err)
+	}
+	var settings map[string]interface{}
+	if err := json.Unmarshal([]byte(fake.Inbound(cm.InboundID).Settings), &settings); err != nil {
+		t.Fatalf("настройки inbound: %v", err)
+	}
+	if _, ok := settings["decryption"]; ok {
+		t.Errorf("decryption навязан inbound trojan: %v", settings)
+	}
+}
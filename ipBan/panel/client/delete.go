@@ -0,0 +1,50 @@
+// Пакет client: удаление клиента из inbound.
+package client
+
+import (
+	"fmt"
+	"net/url"
+
+	"ipBanSystem/ipBan/panel"
+)
+
+// Delete удаляет клиента (email) из inbound через POST panel/api/inbounds/<id>/delClient/<ключ клиента>.
+// На старой панели клиент удаляется из массива clients полным обновлением inbound.
+func Delete(cm *panel.ConfigManager, email string) error {
+	inb, raw, err := loadSettings(cm)
+	if err != nil {
+		return err
+	}
+	sel := MatchEmail(email)
+	m := findClient(raw, sel)
+	if m == nil {
+		return panel.NotFoundf("%s не найден", sel)
+	}
+	takeSnapshot(cm, inb, "удаление клиента "+email, email)
+
+	if cm.UseClientAPI() {
+		path := fmt.Sprintf("panel/api/inbounds/%d/delClient/%s", cm.InboundID, url.PathEscape(clientKey(inb.Protocol, m)))
+		err := cm.Post(path, nil, nil)
+		if !clientAPIMissing(err) {
+			if err != nil {
+				return fmt.Errorf("ошибка удаления клиента %s: %w", email, err)
+			}
+			return nil
+		}
+		disableClientAPI(cm, err)
+	}
+
+	clientsAny, _ := raw["clients"].([]interface{})
+	kept := make([]interface{}, 0, len(clientsAny))
+	for _, c := range clientsAny {
+		if m, ok := c.(map[string]interface{}); ok && sel.matches(m) {
+			continue
+		}
+		kept = append(kept, c)
+	}
+	raw["clients"] = kept
+	if err := saveSettings(cm, inb, raw); err != nil {
+		return fmt.Errorf("ошибка удаления клиента %s: %w", email, err)
+	}
+	return HardResetInbound(cm)
+}
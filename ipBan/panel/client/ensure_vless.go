@@ -2,14 +2,10 @@
 package client
 
 import (
-	"bytes"
 	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
 
 	"ipBanSystem/ipBan/panel"
-	"ipBanSystem/ipBan/panel/api"
 	"ipBanSystem/ipBan/panel/inbound"
 )
 
@@ -19,18 +15,18 @@ func EnsureVLESSDecryptionNone(cm *panel.ConfigManager) error {
 	// Загружаем текущий inbound
 	inb, err := inbound.GetInbound(cm)
 	if err != nil {
-		return fmt.Errorf("ошибка получения inbound: %v", err)
+		return fmt.Errorf("ошибка получения inbound: %w", err)
 	}
 
 	// Проверяем, что протокол VLESS — иначе не требуется правка
-	if inb.Protocol != "vless" {
+	if inb.Protocol != ProtocolVLESS {
 		return nil
 	}
 
 	// Парсим настройки inbound как произвольный JSON
 	var raw map[string]interface{}
 	if err = json.Unmarshal([]byte(inb.Settings), &raw); err != nil {
-		return fmt.Errorf("ошибка парсинга настроек inbound: %v", err)
+		return fmt.Errorf("ошибка парсинга настроек inbound: %w", err)
 	}
 
 	// Если decryption уже "none", изменений не требуется
@@ -39,51 +35,19 @@ func EnsureVLESSDecryptionNone(cm *panel.ConfigManager) error {
 	}
 
 	// Устанавливаем требуемое значение
+	takeSnapshot(cm, inb, "decryption=none для VLESS")
 	raw["decryption"] = "none"
 
 	// Сериализуем обратно в строку
 	settingsJSON, err := json.Marshal(raw)
 	if err != nil {
-		return fmt.Errorf("ошибка сериализации настроек: %v", err)
+		return fmt.Errorf("ошибка сериализации настроек: %w", err)
 	}
 	inb.Settings = string(settingsJSON)
 
 	// Обновляем inbound в панели
-	url := fmt.Sprintf("%spanel/api/inbounds/update/%d", cm.PanelURL, cm.InboundID)
-	inbJSON, err := json.Marshal(inb)
-	if err != nil {
-		return fmt.Errorf("ошибка сериализации inbound: %v", err)
-	}
-
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(inbJSON))
-	if err != nil {
-		return fmt.Errorf("ошибка создания запроса: %v", err)
-	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Add("Cookie", cm.SessionCookie)
-
-	resp, err := cm.Client.Do(req)
-	if err != nil {
-		return fmt.Errorf("ошибка выполнения запроса: %v", err)
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return fmt.Errorf("ошибка чтения ответа: %v", err)
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("некорректный статус ответа: %d, body=%s", resp.StatusCode, string(body))
-	}
-
-	var response api.APIResponse
-	if err := json.Unmarshal(body, &response); err != nil {
-		return fmt.Errorf("ошибка парсинга JSON: %v", err)
-	}
-
-	if !response.Success {
-		return fmt.Errorf("ошибка обновления inbound настроек: %s", response.Msg)
+	if err := inbound.Update(cm, inb); err != nil {
+		return fmt.Errorf("ошибка обновления inbound настроек: %w", err)
 	}
 
 	return nil
@@ -13,7 +13,7 @@ import (
 
 // Client структура клиента для x-ui
 type Client struct {
-	// ID — уникальный идентификатор клиента в рамках inbound
+	// ID — уникальный идентификатор клиента в рамках inbound (UUID для vless/vmess; у trojan и shadowsocks пуст)
 	ID string `json:"id"`
 	// InboundID — идентификатор inbound, к которому относится клиент
 	InboundID int `json:"inboundId"`
@@ -33,6 +33,14 @@ type Client struct {
 	ExpiryTime int64 `json:"expiryTime"`
 	// Reset — сброс счётчиков трафика (значение зависит от панели)
 	Reset int `json:"reset"`
+	// Password — учётные данные клиента trojan и shadowsocks (у vless/vmess — ID)
+	Password string `json:"password,omitempty"`
+	// Method — шифр клиента shadowsocks (пусто для шифров 2022, заданных на inbound)
+	Method string `json:"method,omitempty"`
+	// Security — шифрование клиента vmess (например, "auto")
+	Security string `json:"security,omitempty"`
+	// AlterID — alterId клиента vmess (в современных версиях 0)
+	AlterID int `json:"alterId,omitempty"`
 
 	// Дополнительные поля состояния для совместимости с панелью и ProxyMaster
 	Depleted  *bool `json:"depleted,omitempty"`
@@ -50,9 +58,13 @@ func GetSettings(cm *panel.ConfigManager) (*Settings, error) {
 	// Запрашиваем объект inbound для доступа к строке настроек
 	inb, err := inbound.GetInbound(cm)
 	if err != nil {
-		return nil, fmt.Errorf("ошибка получения inbound: %v", err)
+		return nil, fmt.Errorf("ошибка получения inbound: %w", err)
 	}
+	return parseSettings(inb)
+}
 
+// parseSettings декодирует настройки клиентов уже загруженного inbound
+func parseSettings(inb *inbound.Inbound) (*Settings, error) {
 	// Декодируем JSON‑строку настроек inbound в структуру Settings
 	var settings Settings
 	if err := json.Unmarshal([]byte(inb.Settings), &settings); err != nil {
@@ -86,9 +98,17 @@ func GetSettings(cm *panel.ConfigManager) (*Settings, error) {
 			err, len(inb.Settings), preview, idType, idVal,
 		)
 
-		return nil, fmt.Errorf("ошибка парсинга настроек: %v", err)
+		return nil, fmt.Errorf("ошибка парсинга настроек: %w", err)
 	}
 
 	// Возвращаем распарсенные настройки
 	return &settings, nil
 }
+
+// Credential возвращает учётные данные клиента: UUID (vless/vmess) или пароль (trojan/shadowsocks)
+func (c *Client) Credential() string {
+	if c.ID != "" {
+		return c.ID
+	}
+	return c.Password
+}
@@ -2,35 +2,33 @@
 package client
 
 import (
-	"bytes"
-	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
 	"strings"
 	"time"
 
 	"ipBanSystem/ipBan/panel"
-	"ipBanSystem/ipBan/panel/api"
 	"ipBanSystem/ipBan/panel/inbound"
 )
 
 // HardResetInbound выполняет двойное обновление Remark, чтобы панель и Xray заметили изменение:
 // 1) remark -> remark+"-reset"
 // 2) remark+"-reset" -> remark
+// Если второе обновление не удалось, remark возвращается отдельной записью: inbound не остаётся с "-reset".
 func HardResetInbound(cm *panel.ConfigManager) error {
 	inb, err := inbound.GetInbound(cm)
 	if err != nil {
-		return fmt.Errorf("ошибка получения inbound: %v", err)
+		return fmt.Errorf("ошибка получения inbound: %w", err)
 	}
 
 	// Нормализуем базовое имя без суффикса -reset
 	base := strings.TrimSuffix(inb.Remark, "-reset")
 	reset := base + "-reset"
 
+	takeSnapshot(cm, inb, "жёсткий ресет Remark")
+
 	// Первый апдейт: remark -> remark-reset
 	if err := updateInboundRemark(cm, inb, reset); err != nil {
-		return fmt.Errorf("ошибка первого обновления Remark: %v", err)
+		return fmt.Errorf("ошибка первого обновления Remark: %w", err)
 	}
 
 	// Небольшая пауза, чтобы панель/Xray применили изменение
@@ -38,7 +36,10 @@ func HardResetInbound(cm *panel.ConfigManager) error {
 
 	// Второй апдейт: remark-reset -> remark
 	if err := updateInboundRemark(cm, inb, base); err != nil {
-		return fmt.Errorf("ошибка второго обновления Remark: %v", err)
+		if rbErr := rollbackRemark(cm, base); rbErr != nil {
+			return fmt.Errorf("ошибка второго обновления Remark: %w; откат Remark не удался: %v", err, rbErr)
+		}
+		return fmt.Errorf("ошибка второго обновления Remark (Remark возвращён): %w", err)
 	}
 
 	return nil
@@ -49,41 +50,8 @@ func updateInboundRemark(cm *panel.ConfigManager, inb *inbound.Inbound, newRemar
 	inb.Remark = newRemark
 
 	// Сериализуем и отправляем
-	url := fmt.Sprintf("%spanel/api/inbounds/update/%d", cm.PanelURL, cm.InboundID)
-	inbJSON, err := json.Marshal(inb)
-	if err != nil {
-		return fmt.Errorf("ошибка сериализации inbound: %v", err)
-	}
-
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(inbJSON))
-	if err != nil {
-		return fmt.Errorf("ошибка создания запроса: %v", err)
-	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Add("Cookie", cm.SessionCookie)
-
-	resp, err := cm.Client.Do(req)
-	if err != nil {
-		return fmt.Errorf("ошибка выполнения запроса: %v", err)
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return fmt.Errorf("ошибка чтения ответа: %v", err)
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("некорректный статус ответа: %d, body=%s", resp.StatusCode, string(body))
-	}
-
-	var response api.APIResponse
-	if err := json.Unmarshal(body, &response); err != nil {
-		return fmt.Errorf("ошибка парсинга JSON: %v", err)
-	}
-
-	if !response.Success {
-		return fmt.Errorf("ошибка обновления inbound: %s", response.Msg)
+	if err := inbound.Update(cm, inb); err != nil {
+		return fmt.Errorf("ошибка обновления inbound: %w", err)
 	}
 
 	return nil
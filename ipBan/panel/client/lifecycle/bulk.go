@@ -0,0 +1,52 @@
+// Пакет lifecycle: управление жизненным циклом клиентов панели — срок действия, лимит трафика,
+// переименование и удаление, по одному клиенту и пакетом.
+// Пакетные операции изменяют клиентов одним набором изменений (client.ChangeSet): одна запись inbound
+// на batchSize клиентов вместо записи на каждого.
+package lifecycle
+
+import (
+	"ipBanSystem/ipBan/panel"
+	"ipBanSystem/ipBan/panel/client"
+)
+
+// Result — итог операции над одним клиентом
+type Result struct {
+	Email string `json:"email"`
+	// Changed — клиент изменён (false — уже был в нужном состоянии или ошибка)
+	Changed bool `json:"changed"`
+	// Error — текст ошибки (пусто — успех)
+	Error string `json:"error,omitempty"`
+}
+
+// Failed возвращает число клиентов, операция над которыми не удалась
+func Failed(results []Result) int {
+	n := 0
+	for _, r := range results {
+		if r.Error != "" {
+			n++
+		}
+	}
+	return n
+}
+
+// patchMany применяет к клиентам emails патч, построенный patchFor, одним набором изменений
+func patchMany(cm *panel.ConfigManager, emails []string, action string, batchSize int, patchFor func(email string) func(m map[string]interface{}) error) []Result {
+	set := client.NewChangeSet()
+	for _, email := range emails {
+		set.Add(client.MatchEmail(email), action, patchFor(email))
+	}
+	results := make([]Result, 0, len(emails))
+	for _, r := range set.Apply(cm, batchSize) {
+		results = append(results, resultOf(r.Selector.Email, r.Changed, r.Err))
+	}
+	return results
+}
+
+// resultOf формирует итог операции над клиентом
+func resultOf(email string, changed bool, err error) Result {
+	r := Result{Email: email, Changed: changed}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	return r
+}
@@ -0,0 +1,22 @@
+package lifecycle
+
+import (
+	"ipBanSystem/ipBan/panel"
+	"ipBanSystem/ipBan/panel/client"
+)
+
+// Delete удаляет клиента (по email) из inbound
+func Delete(cm *panel.ConfigManager, email string) error {
+	return client.Delete(cm, email)
+}
+
+// DeleteMany удаляет клиентов emails. Каждый клиент удаляется отдельным запросом delClient:
+// на старой панели это полное обновление inbound на каждого клиента.
+func DeleteMany(cm *panel.ConfigManager, emails []string) []Result {
+	results := make([]Result, 0, len(emails))
+	for _, email := range emails {
+		err := client.Delete(cm, email)
+		results = append(results, resultOf(email, err == nil, err))
+	}
+	return results
+}
@@ -0,0 +1,63 @@
+package lifecycle
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"ipBanSystem/ipBan/panel"
+	"ipBanSystem/ipBan/panel/client"
+)
+
+// actionExtend — описание продления для журнала набора изменений
+const actionExtend = "изменение срока действия"
+
+// Extend сдвигает expiryTime клиента (по email) на d: положительное d продлевает, отрицательное — сокращает.
+// База: max(текущий expiryTime, текущее время), поэтому истёкший клиент продлевается от текущего момента.
+func Extend(cm *panel.ConfigManager, email string, d time.Duration) error {
+	return client.PatchClient(cm, client.MatchEmail(email), PatchExtend(d, time.Now()))
+}
+
+// ExtendMany сдвигает срок действия клиентов emails на d одним набором изменений
+func ExtendMany(cm *panel.ConfigManager, emails []string, d time.Duration, batchSize int) []Result {
+	patch := PatchExtend(d, time.Now())
+	return patchMany(cm, emails, actionExtend, batchSize, func(string) func(m map[string]interface{}) error {
+		return patch
+	})
+}
+
+// PatchExtend возвращает патч expiryTime клиента на d от max(expiryTime, now) (для PatchClient и ChangeSet).
+// Сокращение не делает клиента бессрочным: срок не опускается ниже now (клиент истекает сразу),
+// а бессрочного клиента (expiryTime = 0) сократить нельзя.
+func PatchExtend(d time.Duration, now time.Time) func(m map[string]interface{}) error {
+	nowMs := now.UnixMilli()
+	return func(m map[string]interface{}) error {
+		curr := expiryOf(m)
+		if d < 0 && curr == 0 {
+			return fmt.Errorf("у клиента нет срока действия: сокращать нечего")
+		}
+		base := max(curr, nowMs)
+		m["expiryTime"] = max(base+d.Milliseconds(), nowMs)
+		return nil
+	}
+}
+
+// expiryOf читает expiryTime из JSON клиента (число, json.Number или строка; иначе 0)
+func expiryOf(m map[string]interface{}) int64 {
+	switch v := m["expiryTime"].(type) {
+	case float64:
+		return int64(v)
+	case int64:
+		return v
+	case json.Number:
+		if vi, e := v.Int64(); e == nil {
+			return vi
+		}
+	case string:
+		// best-effort: числовая строка
+		if vi, e := json.Number(v).Int64(); e == nil {
+			return vi
+		}
+	}
+	return 0
+}
@@ -0,0 +1,217 @@
+package lifecycle_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"ipBanSystem/ipBan/panel"
+	"ipBanSystem/ipBan/panel/client"
+	"ipBanSystem/ipBan/panel/client/lifecycle"
+	"ipBanSystem/ipBan/panel/fakepanel"
+)
+
+// TestLifecycle прогоняет проверки управления клиентами: срок, лимит трафика, переименование, удаление и пакеты
+func TestLifecycle(t *testing.T) {
+	t.Run("ExtendFromNowWhenExpired", testExtendFromNow)
+	t.Run("ExtendFromExpiry", testExtendFromExpiry)
+	t.Run("Shorten", testShorten)
+	t.Run("TrafficLimit", testTrafficLimit)
+	t.Run("RenameKeepsSubID", testRenameKeepsSubID)
+	t.Run("RenameRejectsDuplicates", testRenameRejectsDuplicates)
+	t.Run("RenameShadowsocks", testRenameShadowsocks)
+	t.Run("BulkOneWrite", testBulkOneWrite)
+	t.Run("DeleteMany", testDeleteMany)
+}
+
+// newLifecyclePanel запускает панель с клиентами user (subId sub1) и second
+func newLifecyclePanel(t *testing.T) (*fakepanel.Server, *panel.ConfigManager) {
+	t.Helper()
+	fake, cm := fakepanel.StartVLESS(t)
+	if _, err := client.Add(cm, "second", 0, 0); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	fake.ResetRequests()
+	return fake, cm
+}
+
+// expiryOf возвращает expiryTime клиента поддельной панели
+func expiryOf(t *testing.T, fake *fakepanel.Server, cm *panel.ConfigManager, email string) time.Time {
+	t.Helper()
+	c := fake.Client(cm.InboundID, email)
+	if c == nil {
+		t.Fatalf("клиент %s не найден", email)
+	}
+	ms, _ := c["expiryTime"].(float64)
+	return time.UnixMilli(int64(ms))
+}
+
+// assertNear проверяет, что время got отличается от want не больше чем на минуту
+func assertNear(t *testing.T, got, want time.Time) {
+	t.Helper()
+	if diff := got.Sub(want); diff < -time.Minute || diff > time.Minute {
+		t.Errorf("срок %v, ожидалось около %v", got, want)
+	}
+}
+
+func testExtendFromNow(t *testing.T) {
+	fake, cm := fakepanel.StartVLESS(t)
+	// Бессрочный (0) и истёкший клиенты продлеваются от текущего момента
+	if err := lifecycle.Extend(cm, "user", 36*time.Hour); err != nil {
+		t.Fatalf("Extend: %v", err)
+	}
+	assertNear(t, expiryOf(t, fake, cm, "user"), time.Now().Add(36*time.Hour))
+}
+
+func testExtendFromExpiry(t *testing.T) {
+	fake, cm := fakepanel.StartVLESS(t)
+	if err := lifecycle.Extend(cm, "user", 10*24*time.Hour); err != nil {
+		t.Fatalf("Extend: %v", err)
+	}
+	if err := lifecycle.Extend(cm, "user", 5*24*time.Hour); err != nil {
+		t.Fatalf("Extend: %v", err)
+	}
+	assertNear(t, expiryOf(t, fake, cm, "user"), time.Now().Add(15*24*time.Hour))
+}
+
+func testShorten(t *testing.T) {
+	fake, cm := fakepanel.StartVLESS(t)
+	if err := lifecycle.Extend(cm, "user", -time.Hour); err == nil {
+		t.Errorf("сокращение бессрочного клиента должно отклоняться")
+	}
+	if err := lifecycle.Extend(cm, "user", 3*24*time.Hour); err != nil {
+		t.Fatalf("Extend: %v", err)
+	}
+	if err := lifecycle.Extend(cm, "user", -24*time.Hour); err != nil {
+		t.Fatalf("сокращение: %v", err)
+	}
+	assertNear(t, expiryOf(t, fake, cm, "user"), time.Now().Add(2*24*time.Hour))
+
+	// Сокращение больше оставшегося срока истекает сейчас, а не делает клиента бессрочным
+	if err := lifecycle.Extend(cm, "user", -10*24*time.Hour); err != nil {
+		t.Fatalf("сокращение: %v", err)
+	}
+	assertNear(t, expiryOf(t, fake, cm, "user"), time.Now())
+}
+
+func testTrafficLimit(t *testing.T) {
+	fake, cm := fakepanel.StartVLESS(t)
+	const limit = 50 << 30
+	if err := lifecycle.SetTrafficLimit(cm, "user", limit); err != nil {
+		t.Fatalf("SetTrafficLimit: %v", err)
+	}
+	if c := fake.Client(cm.InboundID, "user"); c["totalGB"] != float64(limit) || c["subId"] != "sub1" {
+		t.Errorf("лимит не задан или потеряны поля: %v", c)
+	}
+	if traffic, err := client.GetTraffic(cm, "user"); err != nil || traffic.Total != limit {
+		t.Errorf("лимит не виден в статистике: %+v, %v", traffic, err)
+	}
+	if err := lifecycle.SetTrafficLimit(cm, "user", 0); err != nil {
+		t.Fatalf("снятие лимита: %v", err)
+	}
+	if c := fake.Client(cm.InboundID, "user"); c["totalGB"] != float64(0) {
+		t.Errorf("лимит не снят: %v", c)
+	}
+	if err := lifecycle.SetTrafficLimit(cm, "user", -1); err == nil {
+		t.Errorf("отрицательный лимит должен отклоняться")
+	}
+}
+
+func testRenameKeepsSubID(t *testing.T) {
+	fake, cm := fakepanel.StartVLESS(t)
+	fake.AddTraffic(cm.InboundID, "user", 100, 200)
+	if err := lifecycle.Rename(cm, "user", "renamed"); err != nil {
+		t.Fatalf("Rename: %v", err)
+	}
+	if fake.Client(cm.InboundID, "user") != nil {
+		t.Errorf("старый email остался")
+	}
+	c := fake.Client(cm.InboundID, "renamed")
+	if c == nil || c["subId"] != "sub1" || c["id"] != "11111111-1111-1111-1111-111111111111" {
+		t.Fatalf("SubID или UUID изменились при переименовании: %v", c)
+	}
+	if traffic, err := client.GetTraffic(cm, "renamed"); err != nil || traffic.Used() != 300 {
+		t.Errorf("статистика не последовала за переименованием: %+v, %v", traffic, err)
+	}
+	if err := lifecycle.Rename(cm, "nobody", "other"); !errors.Is(err, panel.ErrNotFound) {
+		t.Errorf("ожидалась ErrNotFound, получено: %v", err)
+	}
+}
+
+func testRenameRejectsDuplicates(t *testing.T) {
+	fake, cm := newLifecyclePanel(t)
+	if err := lifecycle.Rename(cm, "user", "SECOND"); err == nil {
+		t.Errorf("занятый email должен отклоняться")
+	}
+	if err := lifecycle.Rename(cm, "user", "USER"); err != nil {
+		t.Errorf("смена регистра своего email: %v", err)
+	}
+
+	results := lifecycle.RenameMany(cm, []lifecycle.Renaming{
+		{From: "USER", To: "same"},
+		{From: "second", To: "Same"},
+	}, 0)
+	if results[0].Error != "" || !results[0].Changed || results[1].Error == "" {
+		t.Errorf("второе переименование в тот же email должно отклоняться: %+v", results)
+	}
+	if fake.Client(cm.InboundID, "same") == nil || fake.Client(cm.InboundID, "second") == nil {
+		t.Errorf("клиенты после пакета: %v", fake.Clients(cm.InboundID))
+	}
+}
+
+func testRenameShadowsocks(t *testing.T) {
+	// У shadowsocks ключ API клиента — email: запрос идёт по старому email
+	fake, cm := fakepanel.Start(t, client.ProtocolShadowsocks, 8443, map[string]interface{}{
+		"password": "b2xkLWtleS0zMi1ieXRlcy1mb3ItdGVzdHMtMDAwMA==", "method": "", "email": "user", "enable": true, "subId": "sub1",
+	})
+	if err := lifecycle.Rename(cm, "user", "renamed"); err != nil {
+		t.Fatalf("Rename: %v", err)
+	}
+	if c := fake.Client(cm.InboundID, "renamed"); c == nil || c["subId"] != "sub1" {
+		t.Errorf("клиент shadowsocks не переименован: %v", fake.Clients(cm.InboundID))
+	}
+	if fake.Count("POST /panel/api/inbounds/updateClient/user") != 1 {
+		t.Errorf("ожидался updateClient по старому email: %v", fake.Requests())
+	}
+}
+
+func testBulkOneWrite(t *testing.T) {
+	fake, cm := newLifecyclePanel(t)
+	results := lifecycle.ExtendMany(cm, []string{"user", "second", "nobody"}, 30*24*time.Hour, 0)
+	if len(results) != 3 || !results[0].Changed || !results[1].Changed || results[2].Error == "" {
+		t.Fatalf("итог пакета: %+v", results)
+	}
+	if lifecycle.Failed(results) != 1 {
+		t.Errorf("Failed: %d", lifecycle.Failed(results))
+	}
+	// Одна запись изменений и два обновления жёсткого ресета Remark
+	if n := fake.Count("POST /panel/api/inbounds/update/"); n != 3 {
+		t.Errorf("обновлений inbound: %d, ожидалось 3 (запись + ресет): %v", n, fake.Requests())
+	}
+	if n := fake.Count("POST /panel/api/inbounds/updateClient/"); n != 0 {
+		t.Errorf("пакет не должен идти поклиентно: %d запросов updateClient", n)
+	}
+	for _, email := range []string{"user", "second"} {
+		assertNear(t, expiryOf(t, fake, cm, email), time.Now().Add(30*24*time.Hour))
+	}
+
+	fake.ResetRequests()
+	results = lifecycle.SetTrafficLimitMany(cm, []string{"user", "second"}, 1<<30, 0)
+	if lifecycle.Failed(results) != 0 || fake.Count("POST /panel/api/inbounds/update/") != 3 {
+		t.Errorf("пакетный лимит: %+v, %v", results, fake.Requests())
+	}
+	if c := fake.Client(cm.InboundID, "user"); c["subId"] != "sub1" || c["flow"] != "xtls-rprx-vision" {
+		t.Errorf("пакет потерял неизвестные поля: %v", c)
+	}
+}
+
+func testDeleteMany(t *testing.T) {
+	fake, cm := newLifecyclePanel(t)
+	results := lifecycle.DeleteMany(cm, []string{"second", "nobody"})
+	if !results[0].Changed || results[1].Error == "" {
+		t.Fatalf("итог удаления: %+v", results)
+	}
+	if fake.Client(cm.InboundID, "second") != nil || fake.Client(cm.InboundID, "user") == nil {
+		t.Errorf("клиенты после удаления: %v", fake.Clients(cm.InboundID))
+	}
+}
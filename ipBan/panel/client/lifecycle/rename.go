@@ -0,0 +1,91 @@
+package lifecycle
+
+import (
+	"fmt"
+	"strings"
+
+	"ipBanSystem/ipBan/panel"
+	"ipBanSystem/ipBan/panel/client"
+)
+
+// actionRename — описание переименования для журнала набора изменений
+const actionRename = "переименование"
+
+// Renaming — переименование клиента From -> To
+type Renaming struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Rename меняет email клиента. SubID, UUID и прочие поля не меняются: ссылка подписки остаётся рабочей,
+// а бан и статистика сервиса следуют за клиентом по SubID (см. IdentityMap).
+func Rename(cm *panel.ConfigManager, from, to string) error {
+	_, errs := rename(cm, []Renaming{{From: from, To: to}}, 0)
+	return errs[0]
+}
+
+// RenameMany переименовывает клиентов одним набором изменений.
+// Новый email не должен совпадать с email другого клиента inbound или с другим новым email.
+func RenameMany(cm *panel.ConfigManager, renames []Renaming, batchSize int) []Result {
+	changed, errs := rename(cm, renames, batchSize)
+	results := make([]Result, len(renames))
+	for i, r := range renames {
+		results[i] = resultOf(r.From, changed[i], errs[i])
+	}
+	return results
+}
+
+// rename выполняет переименования и возвращает по каждому флаг изменения и ошибку
+func rename(cm *panel.ConfigManager, renames []Renaming, batchSize int) ([]bool, []error) {
+	changed := make([]bool, len(renames))
+	errs := make([]error, len(renames))
+	clients, err := client.All(cm)
+	if err != nil {
+		for i := range renames {
+			errs[i] = err
+		}
+		return changed, errs
+	}
+	taken := make(map[string]bool, len(clients))
+	for _, c := range clients {
+		taken[strings.ToLower(c.Email)] = true
+	}
+
+	// Проверяем переименования до записи: ошибочные в набор не попадают
+	set := client.NewChangeSet()
+	var queued []int
+	claimed := make(map[string]bool, len(renames))
+	for i, r := range renames {
+		to := strings.TrimSpace(r.To)
+		if err := checkRename(r.From, to, taken, claimed); err != nil {
+			errs[i] = err
+			continue
+		}
+		claimed[strings.ToLower(to)] = true
+		set.Add(client.MatchEmail(r.From), actionRename, func(m map[string]interface{}) error {
+			m["email"] = to
+			return nil
+		})
+		queued = append(queued, i)
+	}
+	for j, cr := range set.Apply(cm, batchSize) {
+		changed[queued[j]], errs[queued[j]] = cr.Changed, cr.Err
+	}
+	return changed, errs
+}
+
+// checkRename проверяет новый email: не пустой и не занят другим клиентом или другим переименованием.
+// Смена только регистра своего же email допустима.
+func checkRename(from, to string, taken, claimed map[string]bool) error {
+	if to == "" {
+		return fmt.Errorf("новый email для %s не задан", from)
+	}
+	lower := strings.ToLower(to)
+	if claimed[lower] {
+		return fmt.Errorf("email %s указан для нескольких клиентов", to)
+	}
+	if taken[lower] && !strings.EqualFold(from, to) {
+		return fmt.Errorf("email %s уже занят другим клиентом", to)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package lifecycle
+
+import (
+	"fmt"
+
+	"ipBanSystem/ipBan/panel"
+	"ipBanSystem/ipBan/panel/client"
+)
+
+// actionTrafficLimit — описание смены лимита трафика для журнала набора изменений
+const actionTrafficLimit = "изменение лимита трафика"
+
+// SetTrafficLimit задаёт лимит трафика клиента (по email) в байтах; 0 снимает лимит
+func SetTrafficLimit(cm *panel.ConfigManager, email string, limitBytes int64) error {
+	patch, err := PatchTrafficLimit(limitBytes)
+	if err != nil {
+		return err
+	}
+	return client.PatchClient(cm, client.MatchEmail(email), patch)
+}
+
+// SetTrafficLimitMany задаёт лимит трафика клиентов emails одним набором изменений
+func SetTrafficLimitMany(cm *panel.ConfigManager, emails []string, limitBytes int64, batchSize int) []Result {
+	patch, err := PatchTrafficLimit(limitBytes)
+	if err != nil {
+		results := make([]Result, len(emails))
+		for i, email := range emails {
+			results[i] = resultOf(email, false, err)
+		}
+		return results
+	}
+	return patchMany(cm, emails, actionTrafficLimit, batchSize, func(string) func(m map[string]interface{}) error {
+		return patch
+	})
+}
+
+// PatchTrafficLimit возвращает патч лимита трафика (для PatchClient и ChangeSet).
+// Поле totalGB панели 3x-ui, несмотря на имя, хранит лимит в байтах.
+func PatchTrafficLimit(limitBytes int64) (func(m map[string]interface{}) error, error) {
+	if limitBytes < 0 {
+		return nil, fmt.Errorf("лимит трафика не может быть отрицательным: %d", limitBytes)
+	}
+	return func(m map[string]interface{}) error {
+		m["totalGB"] = limitBytes
+		return nil
+	}, nil
+}
@@ -13,7 +13,7 @@ func ByEmail(cm *panel.ConfigManager, email string) (*Client, error) {
     // Получаем все настройки клиентов
     settings, err := GetSettings(cm)
     if err != nil {
-        return nil, fmt.Errorf("ошибка получения настроек клиентов: %v", err)
+        return nil, fmt.Errorf("ошибка получения настроек клиентов: %w", err)
     }
 
     // Проходим по списку и ищем совпадение по email без учета регистра
@@ -22,7 +22,7 @@ func ByEmail(cm *panel.ConfigManager, email string) (*Client, error) {
             return &c, nil
         }
     }
-    return nil, fmt.Errorf("клиент с email %s не найден", email)
+    return nil, panel.NotFoundf("клиент с email %s не найден", email)
 }
 
 // ByID возвращает клиента по ID
@@ -30,7 +30,7 @@ func ByID(cm *panel.ConfigManager, clientID string) (*Client, error) {
     // Получаем все настройки клиентов
     settings, err := GetSettings(cm)
     if err != nil {
-        return nil, fmt.Errorf("ошибка получения настроек клиентов: %v", err)
+        return nil, fmt.Errorf("ошибка получения настроек клиентов: %w", err)
     }
 
     // Ищем в списке клиента с указанным ID
@@ -39,7 +39,7 @@ func ByID(cm *panel.ConfigManager, clientID string) (*Client, error) {
             return &c, nil
         }
     }
-    return nil, fmt.Errorf("клиент с ID %s не найден", clientID)
+    return nil, panel.NotFoundf("клиент с ID %s не найден", clientID)
 }
 
 // All возвращает всех клиентов
@@ -47,7 +47,7 @@ func All(cm *panel.ConfigManager) ([]Client, error) {
     // Загружаем настройки и отдаём массив клиентов
     settings, err := GetSettings(cm)
     if err != nil {
-        return nil, fmt.Errorf("ошибка получения настроек клиентов: %v", err)
+        return nil, fmt.Errorf("ошибка получения настроек клиентов: %w", err)
     }
     return settings.Clients, nil
 }
@@ -57,7 +57,7 @@ func Status(cm *panel.ConfigManager, email string) (bool, error) {
     // Находим клиента и возвращаем его поле Enable
     c, err := ByEmail(cm, email)
     if err != nil {
-        return false, fmt.Errorf("ошибка получения клиента: %v", err)
+        return false, fmt.Errorf("ошибка получения клиента: %w", err)
     }
     return c.Enable, nil
 }
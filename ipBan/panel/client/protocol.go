@@ -0,0 +1,126 @@
+// Пакет client: различия протоколов inbound — поле учётных данных клиента, их генерация и шаблон нового клиента.
+// VLESS и VMess идентифицируют клиента UUID в поле id, Trojan — паролем, Shadowsocks — паролем (ключом)
+// с шифром method; в API клиентов панели ключом служат id, password и email соответственно.
+package client
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"ipBanSystem/ipBan/panel/inbound"
+)
+
+// Протоколы inbound, с клиентами которых работает пакет
+const (
+	ProtocolVLESS       = "vless"
+	ProtocolVMess       = "vmess"
+	ProtocolTrojan      = "trojan"
+	ProtocolShadowsocks = "shadowsocks"
+)
+
+// credentialField возвращает поле клиента с учётными данными: "password" для trojan/shadowsocks, иначе "id"
+func credentialField(protocol string) string {
+	switch protocol {
+	case ProtocolTrojan, ProtocolShadowsocks:
+		return "password"
+	default:
+		return "id"
+	}
+}
+
+// clientKey возвращает ключ клиента в API панели: пароль для trojan, email для shadowsocks, иначе UUID
+func clientKey(protocol string, m map[string]interface{}) string {
+	field := credentialField(protocol)
+	if protocol == ProtocolShadowsocks {
+		field = "email"
+	}
+	key, _ := m[field].(string)
+	return key
+}
+
+// shadowsocksMethod возвращает шифр inbound Shadowsocks из его настроек
+func shadowsocksMethod(inb *inbound.Inbound) string {
+	var settings struct {
+		Method string `json:"method"`
+	}
+	_ = json.Unmarshal([]byte(inb.Settings), &settings)
+	return settings.Method
+}
+
+// shadowsocksKeyLen возвращает длину ключа (байты) для шифров Shadowsocks 2022 и 32 для остальных
+func shadowsocksKeyLen(method string) int {
+	if method == "2022-blake3-aes-128-gcm" {
+		return 16
+	}
+	return 32
+}
+
+// newCredential генерирует учётные данные нового или ротируемого клиента по протоколу inbound:
+// UUID для vless/vmess, случайный пароль для trojan, ключ в base64 нужной шифру длины для shadowsocks
+func newCredential(inb *inbound.Inbound) string {
+	switch inb.Protocol {
+	case ProtocolTrojan:
+		return randomBase64(18, base64.RawURLEncoding)
+	case ProtocolShadowsocks:
+		return randomBase64(shadowsocksKeyLen(shadowsocksMethod(inb)), base64.StdEncoding)
+	default:
+		return uuid.New().String()
+	}
+}
+
+// randomBase64 возвращает n случайных байт в кодировке enc
+func randomBase64(n int, enc *base64.Encoding) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		// crypto/rand на поддерживаемых системах не отказывает; UUID — запасной источник случайности
+		return strings.ReplaceAll(uuid.New().String(), "-", "")
+	}
+	return enc.EncodeToString(b)
+}
+
+// rotateCredential выдаёт клиенту новые учётные данные по протоколу inbound и возвращает их
+func rotateCredential(inb *inbound.Inbound, m map[string]interface{}) string {
+	credential := newCredential(inb)
+	m[credentialField(inb.Protocol)] = credential
+	return credential
+}
+
+// newClientJSON формирует JSON нового клиента в формате протокола inbound
+func newClientJSON(inb *inbound.Inbound, email string, totalGB, expiryTime int64) map[string]interface{} {
+	m := map[string]interface{}{
+		"email":      email,
+		"enable":     true,
+		"limitIp":    0,
+		"totalGB":    totalGB,
+		"expiryTime": expiryTime,
+		"tgId":       "",
+		"subId":      strings.ReplaceAll(uuid.New().String(), "-", "")[:16],
+		"reset":      0,
+	}
+	m[credentialField(inb.Protocol)] = newCredential(inb)
+
+	switch inb.Protocol {
+	case ProtocolVMess:
+		m["security"] = "auto"
+		m["alterId"] = 0
+	case ProtocolShadowsocks:
+		// Шифры 2022 задаются на уровне inbound, у клиента — только ключ
+		method := shadowsocksMethod(inb)
+		if strings.HasPrefix(method, "2022-") {
+			method = ""
+		}
+		m["method"] = method
+	case ProtocolVLESS, ProtocolTrojan:
+		m["flow"] = ""
+	}
+	return m
+}
+
+// needsDecryptionNone сообщает, требует ли протокол decryption:"none" в настройках inbound (только VLESS)
+func needsDecryptionNone(protocol string) bool {
+	return protocol == ProtocolVLESS
+}
@@ -0,0 +1,137 @@
+// Пакет client: снимки inbound перед изменениями, откат прерванных операций и восстановление из снимка.
+package client
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"ipBanSystem/ipBan/logger/initLogs"
+	"ipBanSystem/ipBan/panel"
+	"ipBanSystem/ipBan/panel/inbound"
+	"ipBanSystem/ipBan/panel/snapshot"
+)
+
+// takeSnapshot сохраняет снимок inbound перед операцией op с клиентами emails, которых она касается
+// (cm.SnapshotDir пусто — снимки выключены). inb == nil — inbound читается из панели.
+// Сбой снимка не останавливает изменение: бан важнее истории.
+func takeSnapshot(cm *panel.ConfigManager, inb *inbound.Inbound, op string, emails ...string) *snapshot.Snapshot {
+	if cm.SnapshotDir == "" {
+		return nil
+	}
+	if inb == nil {
+		var err error
+		if inb, err = inbound.GetInbound(cm); err != nil {
+			initLogs.LogIPBanWarning("Снимок inbound перед операцией «%s» не сохранён: %v", op, err)
+			return nil
+		}
+	}
+	snap, err := snapshot.Save(cm.SnapshotDir, cm.SnapshotKeep, inb, op, emails)
+	if err != nil {
+		initLogs.LogIPBanWarning("Снимок inbound перед операцией «%s» не сохранён: %v", op, err)
+		return nil
+	}
+	return snap
+}
+
+// RestoreSnapshot восстанавливает inbound из снимка id: клиентов снимка, транспорт, remark и прочие поля.
+// Клиенты, которых нет в снимке, и счётчики трафика не меняются. Текущее состояние перед восстановлением
+// тоже сохраняется снимком, поэтому восстановление можно отменить.
+func RestoreSnapshot(cm *panel.ConfigManager, id string) (*snapshot.Snapshot, error) {
+	snap, err := loadSnapshot(cm, id)
+	if err != nil {
+		return nil, err
+	}
+	current, err := inbound.GetInbound(cm)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения inbound: %w", err)
+	}
+	restored, touched, err := snap.Merge(current)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка восстановления снимка %s: %w", id, err)
+	}
+	takeSnapshot(cm, current, "восстановление снимка "+id, touched...)
+
+	restored.ID = cm.InboundID
+	restored.Up, restored.Down, restored.ClientStats = current.Up, current.Down, current.ClientStats
+	if err := inbound.Update(cm, restored); err != nil {
+		return nil, fmt.Errorf("ошибка восстановления снимка %s: %w", id, err)
+	}
+	if err := HardResetInbound(cm); err != nil {
+		return snap, fmt.Errorf("снимок %s восстановлен, но жёсткий ресет не удался: %w", id, err)
+	}
+	initLogs.LogIPBanInfo("Inbound %d восстановлен из снимка %s (%s)", cm.InboundID, id, snap.Operation)
+	return snap, nil
+}
+
+// DiffSnapshot сравнивает снимок id со снимком with, а если with пуст — с текущим состоянием inbound
+func DiffSnapshot(cm *panel.ConfigManager, id, with string) ([]string, error) {
+	snap, err := loadSnapshot(cm, id)
+	if err != nil {
+		return nil, err
+	}
+	if with != "" {
+		other, err := loadSnapshot(cm, with)
+		if err != nil {
+			return nil, err
+		}
+		return diffScoped(snap, other.Inbound)
+	}
+	current, err := inbound.GetInbound(cm)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения inbound: %w", err)
+	}
+	return diffScoped(snap, current)
+}
+
+// diffScoped сравнивает снимок с inbound в пределах клиентов снимка
+func diffScoped(snap *snapshot.Snapshot, inb *inbound.Inbound) ([]string, error) {
+	scoped, err := snap.Scope(inb)
+	if err != nil {
+		return nil, err
+	}
+	return snapshot.Diff(snap.Inbound, scoped), nil
+}
+
+// loadSnapshot читает снимок inbound менеджера
+func loadSnapshot(cm *panel.ConfigManager, id string) (*snapshot.Snapshot, error) {
+	if cm.SnapshotDir == "" {
+		return nil, fmt.Errorf("снимки inbound выключены (не задан каталог снимков)")
+	}
+	snap, err := snapshot.Load(cm.SnapshotDir, id)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, panel.NotFoundf("снимок %s не найден", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if snap.Inbound == nil || snap.InboundID != cm.InboundID {
+		return nil, fmt.Errorf("снимок %s снят с inbound %d, а сервис работает с inbound %d", id, snap.InboundID, cm.InboundID)
+	}
+	return snap, nil
+}
+
+// rollbackClient возвращает клиента (sel) к JSON original — откат операции, прерванной между записями
+func rollbackClient(cm *panel.ConfigManager, sel Selector, original map[string]interface{}) error {
+	return PatchClient(cm, sel, func(m map[string]interface{}) error {
+		for k := range m {
+			delete(m, k)
+		}
+		for k, v := range normalizeJSON(original).(map[string]interface{}) {
+			m[k] = v
+		}
+		return nil
+	})
+}
+
+// rollbackRemark возвращает inbound remark — откат жёсткого ресета, прерванного между обновлениями
+func rollbackRemark(cm *panel.ConfigManager, remark string) error {
+	inb, err := inbound.GetInbound(cm)
+	if err != nil {
+		return fmt.Errorf("ошибка получения inbound: %w", err)
+	}
+	if inb.Remark == remark {
+		return nil
+	}
+	return updateInboundRemark(cm, inb, remark)
+}
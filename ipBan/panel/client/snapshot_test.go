@@ -0,0 +1,196 @@
+package client_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"ipBanSystem/ipBan/panel"
+	"ipBanSystem/ipBan/panel/client"
+	"ipBanSystem/ipBan/panel/fakepanel"
+	"ipBanSystem/ipBan/panel/snapshot"
+)
+
+// TestSnapshots прогоняет проверки снимков inbound: снимок перед записью, ротация, откат и восстановление
+func TestSnapshots(t *testing.T) {
+	t.Run("SnapshotBeforeWrite", testSnapshotBeforeWrite)
+	t.Run("OnlyTouchedClients", testSnapshotOnlyTouchedClients)
+	t.Run("KeepLimit", testSnapshotKeepLimit)
+	t.Run("AggressiveResetRollsBack", testAggressiveResetRollsBack)
+	t.Run("DiffAndRestore", testSnapshotDiffAndRestore)
+}
+
+// snapshots возвращает снимки каталога менеджера или завершает проверку
+func snapshots(t *testing.T, cm *panel.ConfigManager) []snapshot.Snapshot {
+	t.Helper()
+	list, err := snapshot.List(cm.SnapshotDir)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	return list
+}
+
+func testSnapshotBeforeWrite(t *testing.T) {
+	_, cm := fakepanel.StartVLESS(t)
+	cm.SnapshotDir = t.TempDir()
+	if err := client.EnableConfig(cm, "user"); err != nil {
+		t.Fatalf("EnableConfig: %v", err)
+	}
+	if n := len(snapshots(t, cm)); n != 0 {
+		t.Errorf("без записи снимок не нужен: %d", n)
+	}
+
+	if err := client.Disable(cm, "user"); err != nil {
+		t.Fatalf("Disable: %v", err)
+	}
+	list := snapshots(t, cm)
+	if len(list) != 1 || !strings.Contains(list[0].Operation, "11111111-1111-1111-1111-111111111111") {
+		t.Fatalf("ожидался один снимок изменения клиента: %+v", list)
+	}
+	snap, err := snapshot.Load(cm.SnapshotDir, list[0].ID)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if !strings.Contains(snap.Inbound.Settings, `"enable":true`) {
+		t.Errorf("снимок должен хранить состояние до изменения: %s", snap.Inbound.Settings)
+	}
+
+	if err := client.Delete(cm, "user"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	list = snapshots(t, cm)
+	if len(list) != 2 || !strings.Contains(list[0].Operation, "удаление") {
+		t.Errorf("последний снимок должен быть перед удалением: %+v", list)
+	}
+}
+
+func testSnapshotOnlyTouchedClients(t *testing.T) {
+	fake, cm := fakepanel.Start(t, "vless", 443,
+		map[string]interface{}{"id": "11111111-1111-1111-1111-111111111111", "email": "user", "enable": true},
+		map[string]interface{}{"id": "22222222-2222-2222-2222-222222222222", "email": "other", "enable": true},
+	)
+	cm.SnapshotDir = t.TempDir()
+	if err := client.Disable(cm, "user"); err != nil {
+		t.Fatalf("Disable: %v", err)
+	}
+	list := snapshots(t, cm)
+	if len(list) != 1 {
+		t.Fatalf("ожидался один снимок: %+v", list)
+	}
+	info, err := os.Stat(filepath.Join(cm.SnapshotDir, list[0].ID+".json"))
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if mode := info.Mode().Perm(); mode != 0o600 {
+		t.Errorf("права файла снимка: %o, ожидалось 600", mode)
+	}
+	snap, err := snapshot.Load(cm.SnapshotDir, list[0].ID)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if !strings.Contains(snap.Inbound.Settings, "11111111") || strings.Contains(snap.Inbound.Settings, "22222222") {
+		t.Errorf("снимок должен хранить только затронутого клиента: %s", snap.Inbound.Settings)
+	}
+
+	// Восстановление снимка возвращает только его клиента: более поздние изменения других клиентов остаются
+	if err := client.Disable(cm, "other"); err != nil {
+		t.Fatalf("Disable: %v", err)
+	}
+	if _, err := client.RestoreSnapshot(cm, list[0].ID); err != nil {
+		t.Fatalf("RestoreSnapshot: %v", err)
+	}
+	if c := fake.Client(cm.InboundID, "user"); c["enable"] != true {
+		t.Errorf("клиент снимка не восстановлен: %v", c)
+	}
+	if c := fake.Client(cm.InboundID, "other"); c == nil || c["enable"] != false {
+		t.Errorf("клиент вне снимка не должен меняться: %v", c)
+	}
+}
+
+func testSnapshotKeepLimit(t *testing.T) {
+	_, cm := fakepanel.StartVLESS(t)
+	cm.SnapshotDir = t.TempDir()
+	cm.SnapshotKeep = 2
+	for i := 0; i < 4; i++ {
+		if _, err := client.RotateUUID(cm, "user"); err != nil {
+			t.Fatalf("RotateUUID: %v", err)
+		}
+	}
+	if n := len(snapshots(t, cm)); n != 2 {
+		t.Errorf("снимков: %d, ожидалось 2", n)
+	}
+}
+
+func testAggressiveResetRollsBack(t *testing.T) {
+	// У shadowsocks ключ API — email: фаза B идёт на updateClient/user-reset
+	fake, cm := fakepanel.Start(t, client.ProtocolShadowsocks, 8443, map[string]interface{}{
+		"password": "b2xkLWtleS0zMi1ieXRlcy1mb3ItdGVzdHMtMDAwMA==", "method": "", "email": "user", "enable": true, "subId": "sub1",
+	})
+	cm.SnapshotDir = t.TempDir()
+	fake.Reject("POST /panel/api/inbounds/updateClient/user-reset", "сбой фазы B", 1)
+
+	_, err := client.AggressiveBanReset(cm, "user")
+	if err == nil || !strings.Contains(err.Error(), "исходное состояние") {
+		t.Fatalf("ожидалась ошибка фазы B с откатом, получено: %v", err)
+	}
+	if fake.Client(cm.InboundID, "user-reset") != nil {
+		t.Fatalf("после отката остался -reset email: %v", fake.Clients(cm.InboundID))
+	}
+	c := fake.Client(cm.InboundID, "user")
+	if c == nil || c["enable"] != true || c["password"] != "b2xkLWtleS0zMi1ieXRlcy1mb3ItdGVzdHMtMDAwMA==" || c["subId"] != "sub1" {
+		t.Errorf("клиент не возвращён в исходное состояние: %v", c)
+	}
+	if _, ok := c["depleted"]; ok {
+		t.Errorf("откат должен убрать depleted: %v", c)
+	}
+}
+
+func testSnapshotDiffAndRestore(t *testing.T) {
+	fake, cm := fakepanel.StartVLESS(t)
+	cm.SnapshotDir = t.TempDir()
+	if _, err := client.AggressiveBanReset(cm, "user"); err != nil {
+		t.Fatalf("AggressiveBanReset: %v", err)
+	}
+	list := snapshots(t, cm)
+	if len(list) == 0 {
+		t.Fatalf("снимки не сохранены")
+	}
+	// Самый старый снимок — до фазы A
+	first := list[len(list)-1].ID
+
+	lines, err := client.DiffSnapshot(cm, first, "")
+	if err != nil {
+		t.Fatalf("DiffSnapshot: %v", err)
+	}
+	diff := strings.Join(lines, "\n")
+	if !strings.Contains(diff, "клиент user: enable: true -> false") || !strings.Contains(diff, "клиент user: id:") {
+		t.Errorf("в отличиях нет отключения и смены UUID:\n%s", diff)
+	}
+
+	if _, err := client.RestoreSnapshot(cm, first); err != nil {
+		t.Fatalf("RestoreSnapshot: %v", err)
+	}
+	c := fake.Client(cm.InboundID, "user")
+	if c["enable"] != true || c["id"] != "11111111-1111-1111-1111-111111111111" {
+		t.Errorf("клиент не восстановлен: %v", c)
+	}
+	if lines, _ := client.DiffSnapshot(cm, first, ""); len(lines) != 0 {
+		t.Errorf("после восстановления inbound должен совпадать со снимком: %v", lines)
+	}
+	saved := false
+	for _, s := range snapshots(t, cm) {
+		saved = saved || s.Operation == "восстановление снимка "+first
+	}
+	if !saved {
+		t.Errorf("состояние до восстановления не сохранено: %+v", snapshots(t, cm))
+	}
+
+	if _, err := client.RestoreSnapshot(cm, "missing"); !errors.Is(err, panel.ErrNotFound) {
+		t.Errorf("ожидалась ErrNotFound, получено: %v", err)
+	}
+	if _, err := client.RestoreSnapshot(cm, "../etc"); err == nil || errors.Is(err, os.ErrNotExist) {
+		t.Errorf("ID с путём должен отклоняться: %v", err)
+	}
+}
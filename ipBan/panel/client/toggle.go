@@ -2,15 +2,9 @@
 package client
 
 import (
-	"bytes"
-	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
 
 	"ipBanSystem/ipBan/panel"
-	"ipBanSystem/ipBan/panel/api"
-	"ipBanSystem/ipBan/panel/inbound"
 )
 
 // Enable включает клиента по email или ID
@@ -52,98 +46,13 @@ func Disable(cm *panel.ConfigManager, emailOrID interface{}) error {
 
 // updateConfig изменяет флаг Enable клиента по ID через JSON‑патч, без пересборки массива
 func updateConfig(cm *panel.ConfigManager, clientID string, enable bool) error {
-	// Получаем inbound
-	inb, err := inbound.GetInbound(cm)
-	if err != nil {
-		return fmt.Errorf("ошибка получения inbound: %v", err)
-	}
-
-	// Парсим настройки inbound как произвольный JSON
-	var raw map[string]interface{}
-	if err = json.Unmarshal([]byte(inb.Settings), &raw); err != nil {
-		return fmt.Errorf("ошибка парсинга исходных настроек inbound: %v", err)
-	}
-
-	// Получаем массив клиентов как []interface{}
-	clientsAny, ok := raw["clients"].([]interface{})
-	if !ok {
-		return fmt.Errorf("поле clients отсутствует или имеет неверный тип")
-	}
-
-	// Находим клиента по id и патчим только поле enable
-	clientFound := false
-	for i := range clientsAny {
-		m, ok := clientsAny[i].(map[string]interface{})
-		if !ok {
-			continue
-		}
-		idVal, _ := m["id"].(string)
-		if idVal == clientID {
-			m["enable"] = enable
-			clientsAny[i] = m
-			clientFound = true
-			break
-		}
-	}
-
-	if !clientFound {
-		return fmt.Errorf("клиент с ID %s не найден", clientID)
-	}
-
-	// Возвращаем обновленный массив клиентов и гарантируем decryption:"none"
-	raw["clients"] = clientsAny
-	if dec, ok := raw["decryption"].(string); !ok || dec != "none" {
-		raw["decryption"] = "none"
-	}
-
-	// Сериализуем обратно в строку settings
-	settingsJSON, err := json.Marshal(raw)
-	if err != nil {
-		return fmt.Errorf("ошибка сериализации настроек: %v", err)
-	}
-	inb.Settings = string(settingsJSON)
-
-	// Готовим запрос на обновление inbound
-	url := fmt.Sprintf("%spanel/api/inbounds/update/%d", cm.PanelURL, cm.InboundID)
-	inbJSON, err := json.Marshal(inb)
-	if err != nil {
-		return fmt.Errorf("ошибка сериализации inbound: %v", err)
-	}
-
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(inbJSON))
-	if err != nil {
-		return fmt.Errorf("ошибка создания запроса: %v", err)
-	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Add("Cookie", cm.SessionCookie)
-
-	resp, err := cm.Client.Do(req)
-	if err != nil {
-		return fmt.Errorf("ошибка выполнения запроса: %v", err)
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return fmt.Errorf("ошибка чтения ответа: %v", err)
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("некорректный статус ответа: %d, body=%s", resp.StatusCode, string(body))
-	}
-
-	var response api.APIResponse
-	if err := json.Unmarshal(body, &response); err != nil {
-		return fmt.Errorf("ошибка парсинга JSON: %v", err)
-	}
-	if !response.Success {
-		return fmt.Errorf("ошибка обновления клиента: %s", response.Msg)
-	}
+	return PatchClient(cm, MatchID(clientID), PatchEnable(enable))
+}
 
-	// Жёсткий ресет
-	if err := HardResetInbound(cm); err != nil {
-		return fmt.Errorf("обновление прошло, но жёсткий ресет не удался: %v", err)
+// PatchEnable возвращает патч поля enable клиента (для PatchClient и ChangeSet)
+func PatchEnable(enable bool) func(m map[string]interface{}) error {
+	return func(m map[string]interface{}) error {
+		m["enable"] = enable
+		return nil
 	}
-
-	return nil
 }
@@ -0,0 +1,56 @@
+// Пакет client: счётчики трафика клиента из панели.
+package client
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"ipBanSystem/ipBan/panel"
+	"ipBanSystem/ipBan/panel/inbound"
+)
+
+// Traffic — запись client_traffics панели: расход и лимиты клиента (см. inbound.ClientStats)
+type Traffic = inbound.ClientStats
+
+// GetTraffic возвращает трафик клиента через GET panel/api/inbounds/getClientTraffics/<email>.
+// На старой панели статистика берётся из clientStats inbound.
+func GetTraffic(cm *panel.ConfigManager, email string) (*Traffic, error) {
+	if cm.UseClientAPI() {
+		var t *Traffic
+		err := cm.Get("panel/api/inbounds/getClientTraffics/"+url.PathEscape(email), &t)
+		if !clientAPIMissing(err) {
+			if err != nil {
+				return nil, fmt.Errorf("ошибка получения трафика клиента %s: %w", email, err)
+			}
+			if t == nil {
+				return nil, panel.NotFoundf("статистика клиента %s не найдена", email)
+			}
+			return t, nil
+		}
+		disableClientAPI(cm, err)
+	}
+
+	inb, err := inbound.GetInbound(cm)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения inbound: %w", err)
+	}
+	if t, ok := inb.StatsByEmail()[strings.ToLower(email)]; ok {
+		return &t, nil
+	}
+	return nil, panel.NotFoundf("статистика клиента %s не найдена", email)
+}
+
+// AllWithTraffic возвращает всех клиентов и их трафик (email в нижнем регистре -> статистика)
+// одним запросом inbound
+func AllWithTraffic(cm *panel.ConfigManager) ([]Client, map[string]Traffic, error) {
+	inb, err := inbound.GetInbound(cm)
+	if err != nil {
+		return nil, nil, fmt.Errorf("ошибка получения inbound: %w", err)
+	}
+	settings, err := parseSettings(inb)
+	if err != nil {
+		return nil, nil, fmt.Errorf("ошибка получения настроек клиентов: %w", err)
+	}
+	return settings.Clients, inb.StatsByEmail(), nil
+}
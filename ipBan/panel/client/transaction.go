@@ -0,0 +1,225 @@
+// Пакет client: транзакционное изменение клиента — чтение, патч, запись и проверка результата.
+// API панели не умеет условную запись, поэтому конфликт ловится двумя перечитываниями:
+// перед записью (не изменился ли inbound с момента чтения) и после неё (сохранился ли именно наш патч).
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+
+	"ipBanSystem/ipBan/logger/initLogs"
+	"ipBanSystem/ipBan/panel"
+	"ipBanSystem/ipBan/panel/inbound"
+)
+
+// maxTxAttempts — сколько раз повторять патч на свежих данных при параллельном изменении inbound
+const maxTxAttempts = 3
+
+// volatileClientFields — поля, которые панель меняет сама при каждой записи клиента (не считаются чужой правкой)
+var volatileClientFields = map[string]bool{"updated_at": true}
+
+// InboundPatch — изменение JSON клиента с доступом к inbound, в котором он находится (протокол, настройки)
+type InboundPatch func(inb *inbound.Inbound, m map[string]interface{}) error
+
+// patchClient — PatchClient без жёсткого ресета.
+// fullUpdate сообщает, что изменение сохранено полным обновлением inbound (старая панель).
+// При ErrConflict патч повторяется на свежих данных до maxTxAttempts раз.
+func patchClient(cm *panel.ConfigManager, sel Selector, patch InboundPatch) (fullUpdate bool, err error) {
+	for attempt := 1; ; attempt++ {
+		fullUpdate, err = patchClientOnce(cm, sel, patch)
+		if err == nil || !errors.Is(err, panel.ErrConflict) || attempt == maxTxAttempts {
+			return fullUpdate, err
+		}
+		initLogs.LogIPBanWarning("%s: %v — повтор на свежих данных (%d/%d)", sel, err, attempt, maxTxAttempts)
+	}
+}
+
+// patchClientOnce выполняет одну попытку: чтение, патч, проверка конфликта, запись, проверка результата
+func patchClientOnce(cm *panel.ConfigManager, sel Selector, patch InboundPatch) (fullUpdate bool, err error) {
+	inb, raw, err := loadSettings(cm)
+	if err != nil {
+		return false, err
+	}
+	m := findClient(raw, sel)
+	if m == nil {
+		return false, panel.NotFoundf("%s не найден", sel)
+	}
+
+	// Ключ клиента берётся до патча: patch может сменить UUID или email
+	key := clientKey(inb.Protocol, m)
+	before := normalizeJSON(m).(map[string]interface{})
+	others := clientsByKey(inb.Protocol, raw, key)
+	if err := patch(inb, m); err != nil {
+		return false, err
+	}
+	changed := changedFields(before, m)
+	if len(changed) == 0 {
+		// Клиент уже в нужном состоянии — запись не нужна
+		return false, nil
+	}
+
+	// Перед записью убеждаемся, что inbound не изменился с момента чтения
+	fullUpdate = !cm.UseClientAPI()
+	if err := checkUnchanged(cm, inb, fullUpdate, key, before); err != nil {
+		return false, err
+	}
+	// inb.Settings ещё не содержит патча: снимок — состояние до изменения
+	email, _ := before["email"].(string)
+	takeSnapshot(cm, inb, "изменение: "+sel.String(), email)
+
+	if !fullUpdate {
+		err := cm.Post("panel/api/inbounds/updateClient/"+url.PathEscape(key), clientPayload(cm, m), nil)
+		if clientAPIMissing(err) {
+			disableClientAPI(cm, err)
+			fullUpdate = true
+			// Для полного обновления проверка строже: сравниваются все настройки
+			if err := checkUnchanged(cm, inb, true, key, before); err != nil {
+				return false, err
+			}
+		} else if err != nil {
+			return false, fmt.Errorf("ошибка обновления клиента: %w", err)
+		}
+	}
+	if fullUpdate {
+		// m лежит внутри raw, поэтому патч уже попал в массив clients
+		if err := saveSettings(cm, inb, raw); err != nil {
+			return true, fmt.Errorf("ошибка обновления клиента: %w", err)
+		}
+	}
+
+	return fullUpdate, verifyPatch(cm, clientKey(inb.Protocol, m), m, changed, others)
+}
+
+// checkUnchanged перечитывает inbound перед записью.
+// Полное обновление (full) затёрло бы любую чужую правку, поэтому сравниваются все настройки;
+// API клиента меняет только одного клиента — сравнивается клиент key.
+func checkUnchanged(cm *panel.ConfigManager, inb *inbound.Inbound, full bool, key string, before map[string]interface{}) error {
+	fresh, err := inbound.GetInbound(cm)
+	if err != nil {
+		return fmt.Errorf("ошибка получения inbound: %w", err)
+	}
+	if full {
+		if fresh.Settings != inb.Settings {
+			return panel.Conflictf("настройки inbound %d изменились до записи", cm.InboundID)
+		}
+		return nil
+	}
+
+	var raw map[string]interface{}
+	if err := json.Unmarshal([]byte(fresh.Settings), &raw); err != nil {
+		return fmt.Errorf("ошибка парсинга настроек inbound: %w", err)
+	}
+	current := clientsByKey(fresh.Protocol, raw, "")[key]
+	if current == nil || len(changedFields(before, current.(map[string]interface{}))) > 0 {
+		return panel.Conflictf("клиент %s изменился до записи", key)
+	}
+	return nil
+}
+
+// verifyPatch перечитывает inbound после записи и проверяет, что изменились только поля changed нужного клиента.
+// Потерянный патч — ErrConflict (запись затёрта параллельной); изменения прочих клиентов и полей
+// после нашей записи принадлежат кому-то другому и только логируются.
+func verifyPatch(cm *panel.ConfigManager, key string, want map[string]interface{}, changed []string, others map[string]interface{}) error {
+	inb, raw, err := loadSettings(cm)
+	if err != nil {
+		return fmt.Errorf("изменение записано, но проверка не удалась: %w", err)
+	}
+	after := clientsByKey(inb.Protocol, raw, "")
+	if err := verifyClient(after, key, want, changed); err != nil {
+		return err
+	}
+	warnTouched(cm, after, others)
+	return nil
+}
+
+// verifyClient сверяет клиента key из перечитанных настроек (after) с записанной версией want
+func verifyClient(after map[string]interface{}, key string, want map[string]interface{}, changed []string) error {
+	got, _ := after[key].(map[string]interface{})
+	if got == nil {
+		return panel.Conflictf("клиент %s отсутствует после записи", key)
+	}
+
+	wantNorm := normalizeJSON(want).(map[string]interface{})
+	var lost []string
+	for _, f := range changed {
+		if !reflect.DeepEqual(got[f], wantNorm[f]) {
+			lost = append(lost, f)
+		}
+	}
+	if len(lost) > 0 {
+		return panel.Conflictf("после записи у клиента %s не сохранились поля: %s", key, strings.Join(lost, ", "))
+	}
+
+	if extra := changedFields(wantNorm, got); len(extra) > 0 {
+		initLogs.LogIPBanWarning("Клиент %s: после записи изменены посторонние поля: %s", key, strings.Join(extra, ", "))
+	}
+	return nil
+}
+
+// warnTouched логирует клиентов из others, изменённых кем-то другим (сравнение с перечитанными настройками after)
+func warnTouched(cm *panel.ConfigManager, after, others map[string]interface{}) {
+	var touched []string
+	for k, c := range others {
+		if !reflect.DeepEqual(after[k], c) {
+			touched = append(touched, k)
+		}
+	}
+	if len(touched) > 0 {
+		sort.Strings(touched)
+		initLogs.LogIPBanWarning("Inbound %d: параллельно изменены другие клиенты: %s", cm.InboundID, strings.Join(touched, ", "))
+	}
+}
+
+// clientsByKey возвращает нормализованный JSON клиентов по ключу API (clientKey), кроме клиента skip
+func clientsByKey(protocol string, raw map[string]interface{}, skip string) map[string]interface{} {
+	clientsAny, _ := raw["clients"].([]interface{})
+	byKey := make(map[string]interface{}, len(clientsAny))
+	for _, c := range clientsAny {
+		m, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if k := clientKey(protocol, m); k != skip {
+			byKey[k] = normalizeJSON(m)
+		}
+	}
+	return byKey
+}
+
+// changedFields перечисляет поля, различающиеся у двух версий клиента (без volatileClientFields)
+func changedFields(before, after map[string]interface{}) []string {
+	b := normalizeJSON(before).(map[string]interface{})
+	a := normalizeJSON(after).(map[string]interface{})
+	var fields []string
+	for k, v := range a {
+		if !volatileClientFields[k] && !reflect.DeepEqual(b[k], v) {
+			fields = append(fields, k)
+		}
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok && !volatileClientFields[k] {
+			fields = append(fields, k)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// normalizeJSON приводит значение к виду после json.Unmarshal (int64 -> float64, *bool -> bool и т.п.),
+// чтобы сравнение патча с прочитанными данными не зависело от Go-типов
+func normalizeJSON(v interface{}) interface{} {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var out interface{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return v
+	}
+	return out
+}
@@ -22,6 +22,23 @@ type Config struct {
 	PanelPass string
 	// InboundID — идентификатор inbound, с которым работает сервис
 	InboundID int
+
+	// Необязательные параметры
+
+	// PanelBasePath — секретный базовый путь веб-интерфейса панели (PANEL_BASE_PATH), добавляется к PanelURL
+	PanelBasePath string
+	// PanelTOTPSecret — секрет TOTP (base32) двухфакторной аутентификации панели (PANEL_TOTP_SECRET)
+	PanelTOTPSecret string
+	// PanelLoginSecret — статический секрет входа старых версий 3x-ui (PANEL_LOGIN_SECRET)
+	PanelLoginSecret string
+	// PanelCAFile — PEM-файл CA для проверки сертификата панели (PANEL_CA_FILE)
+	PanelCAFile string
+	// PanelCertSHA256 — закреплённый SHA-256 отпечаток сертификата панели (PANEL_CERT_SHA256)
+	PanelCertSHA256 string
+	// PanelInsecureSkipVerify — не проверять сертификат панели (PANEL_INSECURE_SKIP_VERIFY)
+	PanelInsecureSkipVerify bool
+	// PanelSessionPath — файл для сохранения сессии панели между перезапусками (PANEL_SESSION_PATH)
+	PanelSessionPath string
 }
 
 // MustLoad загружает .env (если есть) и читает необходимые переменные окружения.
@@ -39,6 +56,14 @@ func MustLoad() Config {
 		PanelUser: mustGet("PANEL_USER"),
 		PanelPass: mustGet("PANEL_PASS"),
 		InboundID: mustGetInt("INBOUND_ID"),
+
+		PanelBasePath:           os.Getenv("PANEL_BASE_PATH"),
+		PanelTOTPSecret:         os.Getenv("PANEL_TOTP_SECRET"),
+		PanelLoginSecret:        os.Getenv("PANEL_LOGIN_SECRET"),
+		PanelCAFile:             os.Getenv("PANEL_CA_FILE"),
+		PanelCertSHA256:         os.Getenv("PANEL_CERT_SHA256"),
+		PanelInsecureSkipVerify: getBool("PANEL_INSECURE_SKIP_VERIFY"),
+		PanelSessionPath:        os.Getenv("PANEL_SESSION_PATH"),
 	}
 }
 
@@ -64,3 +89,16 @@ func mustGetInt(key string) int {
 	}
 	return v
 }
+
+// getBool читает необязательную логическую переменную (пусто — false) или завершает приложение при ошибке
+func getBool(key string) bool {
+	vStr := strings.TrimSpace(os.Getenv(key))
+	if vStr == "" {
+		return false
+	}
+	v, err := strconv.ParseBool(vStr)
+	if err != nil {
+		log.Fatalf("Переменная окружения %s должна быть true или false, текущее значение: %q", key, vStr)
+	}
+	return v
+}
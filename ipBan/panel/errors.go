@@ -0,0 +1,71 @@
+// Ошибки запросов к панели: вид ошибки проверяется через errors.Is(err, panel.ErrNotFound) и т.д.
+package panel
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Виды ошибок панели
+var (
+	// ErrNotFound — объект (inbound, клиент) не найден
+	ErrNotFound = errors.New("не найдено")
+	// ErrUnauthorized — сессия недействительна и повторный вход не удался (или неверные логин/пароль)
+	ErrUnauthorized = errors.New("нет авторизации в панели")
+	// ErrPanelUnavailable — панель не отвечает, отвечает ошибкой 5xx или не JSON
+	ErrPanelUnavailable = errors.New("панель недоступна")
+	// ErrRejected — панель отклонила запрос (success:false по иной причине)
+	ErrRejected = errors.New("панель отклонила запрос")
+	// ErrConflict — inbound изменён параллельно (другой операцией или администратором), изменение не подтвердилось
+	ErrConflict = errors.New("параллельное изменение inbound")
+)
+
+// Error — ошибка запроса к панели с видом (Kind) и подробностями
+type Error struct {
+	// Kind — вид ошибки: ErrNotFound, ErrUnauthorized, ErrPanelUnavailable, ErrRejected или ErrConflict
+	Kind error
+	// Op — запрос, например "GET panel/api/inbounds/get/1" (пусто для ошибок вне HTTP-запроса)
+	Op string
+	// Status — HTTP-статус ответа (0 — ответа не было)
+	Status int
+	// Msg — сообщение панели или описание ошибки
+	Msg string
+	// Err — исходная ошибка (сеть, JSON), если есть
+	Err error
+}
+
+// Error возвращает текст вида "панель недоступна: GET panel/api/inbounds/list: connection refused"
+func (e *Error) Error() string {
+	s := e.Kind.Error()
+	if e.Op != "" {
+		s += ": " + e.Op
+	}
+	if e.Status != 0 {
+		s += fmt.Sprintf(" (HTTP %d)", e.Status)
+	}
+	if e.Msg != "" {
+		s += ": " + e.Msg
+	}
+	if e.Err != nil {
+		s += ": " + e.Err.Error()
+	}
+	return s
+}
+
+// Unwrap позволяет errors.Is сравнивать и с видом ошибки, и с исходной ошибкой
+func (e *Error) Unwrap() []error {
+	if e.Err == nil {
+		return []error{e.Kind}
+	}
+	return []error{e.Kind, e.Err}
+}
+
+// NotFoundf создаёт ошибку ErrNotFound с описанием (для поиска клиентов и inbound вне HTTP-запроса)
+func NotFoundf(format string, v ...interface{}) error {
+	return &Error{Kind: ErrNotFound, Msg: fmt.Sprintf(format, v...)}
+}
+
+// Conflictf создаёт ошибку ErrConflict с описанием
+func Conflictf(format string, v ...interface{}) error {
+	return &Error{Kind: ErrConflict, Msg: fmt.Sprintf(format, v...)}
+}
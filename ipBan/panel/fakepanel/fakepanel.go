@@ -0,0 +1,325 @@
+// Пакет fakepanel: поддельная панель 3x-ui внутри процесса (httptest) для сквозных проверок
+// пакетов panel/* и IPBanService без живой панели.
+// Реализует вход (/login) с сессионной кукой, inbounds get/update/list и API отдельных клиентов;
+// сбои и задержки задаются из проверки (Fail, Reject, Latency, ExpireSessions).
+package fakepanel
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"ipBanSystem/ipBan/panel"
+	"ipBanSystem/ipBan/panel/auth"
+)
+
+// Учётные данные администратора по умолчанию
+const (
+	DefaultUsername = "admin"
+	DefaultPassword = "admin"
+)
+
+// sessionCookie — имя сессионной куки 3x-ui
+const sessionCookie = "3x-ui"
+
+// Server — поддельная панель
+type Server struct {
+	*httptest.Server
+
+	// Username и Password — учётные данные для /login
+	Username string
+	Password string
+	// Legacy — старая панель без API отдельных клиентов: addClient, updateClient, delClient
+	// и getClientTraffics отвечают 404
+	Legacy bool
+	// Latency — задержка перед каждым ответом
+	Latency time.Duration
+	// BasePath — секретный базовый путь веб-интерфейса ("secret"): запросы вне него получают 404
+	BasePath string
+	// TwoFactorSecret — секрет TOTP: вход требует действующий twoFactorCode (пусто — без 2FA)
+	TwoFactorSecret string
+	// LoginSecret — секрет входа старых панелей: вход требует совпадающий loginSecret (пусто — не требуется)
+	LoginSecret string
+
+	mu       sync.Mutex
+	inbounds map[int]*Inbound
+	clientIP map[string][]string // email (нижний регистр) -> IP, которые панель отдаёт в clientIps
+	sessions map[string]bool
+	failures []*failure
+	requests []string
+	nextID   int
+}
+
+// failure — заданный сбой запросов с путём по префиксу
+type failure struct {
+	method string
+	prefix string
+	status int    // HTTP-статус ответа (0 — ответ success:false с msg)
+	msg    string // сообщение для success:false
+	times  int    // сколько раз сработать (<= 0 — всегда)
+}
+
+// New запускает поддельную панель с учётными данными по умолчанию
+func New() *Server {
+	s := newServer()
+	s.Server = httptest.NewServer(s.routes())
+	return s
+}
+
+// NewTLS запускает поддельную панель по HTTPS с самоподписанным сертификатом (см. Certificate)
+func NewTLS() *Server {
+	s := newServer()
+	s.Server = httptest.NewTLSServer(s.routes())
+	return s
+}
+
+// newServer создаёт панель без запущенного HTTP-сервера
+func newServer() *Server {
+	return &Server{
+		Username: DefaultUsername,
+		Password: DefaultPassword,
+		inbounds: make(map[int]*Inbound),
+		clientIP: make(map[string][]string),
+		sessions: make(map[string]bool),
+		nextID:   1,
+	}
+}
+
+// Session входит в панель с учётными данными по умолчанию и возвращает менеджер inbound id
+// (повторный вход при истёкшей сессии, повторы без долгих пауз). Журнал запросов очищается.
+func (s *Server) Session(t testing.TB, id int) *panel.ConfigManager {
+	t.Helper()
+	cm := panel.NewConfigManager(s.PanelURL(), DefaultUsername, DefaultPassword, id)
+	cm.Relogin = auth.Login
+	cm.RetryBackoff = time.Millisecond
+	if err := auth.Login(cm); err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	s.ResetRequests()
+	return cm
+}
+
+// Start запускает поддельную панель с одним inbound протокола protocol на порту port, закрывает её
+// по завершении проверки и возвращает авторизованный менеджер этого inbound (см. Session)
+func Start(t testing.TB, protocol string, port int, clients ...map[string]interface{}) (*Server, *panel.ConfigManager) {
+	t.Helper()
+	s := New()
+	t.Cleanup(s.Close)
+	return s, s.Session(t, s.AddInbound(protocol, port, clients...))
+}
+
+// StartVLESS запускает панель (см. Start) с одним клиентом vless: email "user", subId "sub1"
+func StartVLESS(t testing.TB) (*Server, *panel.ConfigManager) {
+	t.Helper()
+	return Start(t, "vless", 443, map[string]interface{}{
+		"id": "11111111-1111-1111-1111-111111111111", "email": "user", "enable": true, "subId": "sub1", "flow": "xtls-rprx-vision",
+	})
+}
+
+// PanelURL возвращает базовый адрес панели в формате PANEL_URL (со слэшем в конце, с BasePath)
+func (s *Server) PanelURL() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if base := strings.Trim(s.BasePath, "/"); base != "" {
+		return s.URL + "/" + base + "/"
+	}
+	return s.URL + "/"
+}
+
+// Fail задаёт ответ HTTP status на запросы "METHOD путь" с префиксом prefix (например, "GET /panel/api/inbounds/get").
+// times — сколько запросов затронуть (<= 0 — все).
+func (s *Server) Fail(prefix string, status, times int) {
+	s.addFailure(prefix, &failure{status: status, times: times})
+}
+
+// Reject задаёт ответ success:false с сообщением msg на запросы с префиксом prefix
+func (s *Server) Reject(prefix, msg string, times int) {
+	s.addFailure(prefix, &failure{msg: msg, times: times})
+}
+
+func (s *Server) addFailure(prefix string, f *failure) {
+	f.method, f.prefix, _ = strings.Cut(prefix, " ")
+	if f.prefix == "" {
+		f.method, f.prefix = "", f.method
+	}
+	s.mu.Lock()
+	s.failures = append(s.failures, f)
+	s.mu.Unlock()
+}
+
+// ExpireSessions завершает все сессии: следующий запрос к API получит 401 и потребует повторного входа
+func (s *Server) ExpireSessions() {
+	s.mu.Lock()
+	s.sessions = make(map[string]bool)
+	s.mu.Unlock()
+}
+
+// Requests возвращает журнал запросов в виде "METHOD путь"
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+// Count возвращает число запросов, начинающихся с prefix ("POST /panel/api/inbounds/update")
+func (s *Server) Count(prefix string) int {
+	n := 0
+	for _, r := range s.Requests() {
+		if strings.HasPrefix(r, prefix) {
+			n++
+		}
+	}
+	return n
+}
+
+// ResetRequests очищает журнал запросов
+func (s *Server) ResetRequests() {
+	s.mu.Lock()
+	s.requests = nil
+	s.mu.Unlock()
+}
+
+// routes регистрирует обработчики панели
+func (s *Server) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /login", s.handleLogin)
+	mux.HandleFunc("GET /panel/api/inbounds/list", s.handleList)
+	mux.HandleFunc("GET /panel/api/inbounds/get/{id}", s.handleGet)
+	mux.HandleFunc("POST /panel/api/inbounds/update/{id}", s.handleUpdate)
+	mux.HandleFunc("POST /panel/api/inbounds/addClient", s.clientAPI(s.handleAddClient))
+	mux.HandleFunc("POST /panel/api/inbounds/updateClient/{clientId}", s.clientAPI(s.handleUpdateClient))
+	mux.HandleFunc("POST /panel/api/inbounds/{id}/delClient/{clientId}", s.clientAPI(s.handleDelClient))
+	mux.HandleFunc("GET /panel/api/inbounds/getClientTraffics/{email}", s.clientAPI(s.handleClientTraffics))
+	mux.HandleFunc("POST /panel/api/inbounds/clientIps/{email}", s.handleClientIPs)
+	return s.middleware(mux)
+}
+
+// middleware ведёт журнал запросов, добавляет задержку, применяет заданные сбои и проверяет сессию
+func (s *Server) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		if base := strings.Trim(s.BasePath, "/"); base != "" {
+			// Панель с секретным путём не отвечает на запросы вне него
+			rest, ok := strings.CutPrefix(r.URL.Path, "/"+base)
+			if !ok || !strings.HasPrefix(rest, "/") {
+				s.mu.Unlock()
+				http.NotFound(w, r)
+				return
+			}
+			r.URL.Path = rest
+		}
+		s.requests = append(s.requests, r.Method+" "+r.URL.Path)
+		f := s.takeFailureLocked(r)
+		authorized := true
+		if strings.HasPrefix(r.URL.Path, "/panel/") {
+			c, err := r.Cookie(sessionCookie)
+			authorized = err == nil && s.sessions[c.Value]
+		}
+		latency := s.Latency
+		s.mu.Unlock()
+
+		if latency > 0 {
+			time.Sleep(latency)
+		}
+		switch {
+		case f != nil && f.status != 0:
+			http.Error(w, http.StatusText(f.status), f.status)
+		case f != nil:
+			writeMsg(w, false, f.msg)
+		case !authorized:
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// takeFailureLocked находит сбой для запроса и уменьшает его счётчик. Вызывается под s.mu
+func (s *Server) takeFailureLocked(r *http.Request) *failure {
+	for i, f := range s.failures {
+		if (f.method != "" && f.method != r.Method) || !strings.HasPrefix(r.URL.Path, f.prefix) {
+			continue
+		}
+		if f.times > 0 {
+			f.times--
+			if f.times == 0 {
+				s.failures = append(s.failures[:i], s.failures[i+1:]...)
+			}
+		}
+		return f
+	}
+	return nil
+}
+
+// clientAPI отвечает 404 на API отдельных клиентов в режиме старой панели
+func (s *Server) clientAPI(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		legacy := s.Legacy
+		s.mu.Unlock()
+		if legacy {
+			http.NotFound(w, r)
+			return
+		}
+		h(w, r)
+	}
+}
+
+// handleLogin проверяет учётные данные (JSON или форма) и выдаёт сессионную куку
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	var creds struct {
+		Username      string `json:"username"`
+		Password      string `json:"password"`
+		TwoFactorCode string `json:"twoFactorCode"`
+		LoginSecret   string `json:"loginSecret"`
+	}
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		if !decodeBody(w, r, &creds) {
+			return
+		}
+	} else {
+		creds.Username, creds.Password = r.FormValue("username"), r.FormValue("password")
+		creds.TwoFactorCode, creds.LoginSecret = r.FormValue("twoFactorCode"), r.FormValue("loginSecret")
+	}
+
+	s.mu.Lock()
+	ok := creds.Username == s.Username && creds.Password == s.Password &&
+		(s.LoginSecret == "" || creds.LoginSecret == s.LoginSecret) &&
+		(s.TwoFactorSecret == "" || validTOTP(s.TwoFactorSecret, creds.TwoFactorCode))
+	token := ""
+	if ok {
+		token = newToken()
+		s.sessions[token] = true
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		writeMsg(w, false, "Invalid username or password")
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: token, Path: "/", HttpOnly: true})
+	writeMsg(w, true, "Login Successfully")
+}
+
+// validTOTP проверяет код двухфакторной аутентификации (текущий или предыдущий 30-секундный шаг)
+func validTOTP(secret, code string) bool {
+	now := time.Now()
+	for _, t := range []time.Time{now, now.Add(-30 * time.Second)} {
+		if want, err := auth.TOTPCode(secret, t); err == nil && code == want {
+			return true
+		}
+	}
+	return false
+}
+
+// newToken генерирует значение сессионной куки
+func newToken() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
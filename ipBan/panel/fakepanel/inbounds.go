@@ -0,0 +1,440 @@
+package fakepanel
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Inbound — inbound поддельной панели (поля как в ответе 3x-ui)
+type Inbound struct {
+	ID             int             `json:"id"`
+	Up             int64           `json:"up"`
+	Down           int64           `json:"down"`
+	Total          int64           `json:"total"`
+	Remark         string          `json:"remark"`
+	Enable         bool            `json:"enable"`
+	ExpiryTime     int64           `json:"expiryTime"`
+	Listen         string          `json:"listen"`
+	Port           int             `json:"port"`
+	Protocol       string          `json:"protocol"`
+	Settings       string          `json:"settings"`
+	StreamSettings string          `json:"streamSettings"`
+	Tag            string          `json:"tag"`
+	Sniffing       string          `json:"sniffing"`
+	ClientStats    []ClientTraffic `json:"clientStats"`
+}
+
+// ClientTraffic — запись client_traffics панели
+type ClientTraffic struct {
+	ID         int    `json:"id"`
+	InboundID  int    `json:"inboundId"`
+	Enable     bool   `json:"enable"`
+	Email      string `json:"email"`
+	Up         int64  `json:"up"`
+	Down       int64  `json:"down"`
+	ExpiryTime int64  `json:"expiryTime"`
+	Total      int64  `json:"total"`
+	Reset      int    `json:"reset"`
+}
+
+// AddInbound добавляет inbound с клиентами (JSON-объекты клиентов, как в settings.clients) и возвращает его ID
+func (s *Server) AddInbound(protocol string, port int, clients ...map[string]interface{}) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := s.nextID
+	s.nextID++
+	list := make([]interface{}, len(clients))
+	for i, c := range clients {
+		list[i] = c
+	}
+	// Настройки inbound в формате протокола: decryption только у vless, шифр — у shadowsocks
+	raw := map[string]interface{}{"clients": list}
+	switch protocol {
+	case "vless":
+		raw["decryption"] = "none"
+	case "shadowsocks":
+		raw["method"] = "2022-blake3-aes-256-gcm"
+		raw["password"] = "c2VydmVyLWtleS0zMi1ieXRlcy1mb3ItdGVzdHMtMDE="
+		raw["network"] = "tcp,udp"
+	}
+	settings, _ := json.Marshal(raw)
+	inb := &Inbound{
+		ID:             id,
+		Remark:         fmt.Sprintf("inbound-%d", port),
+		Enable:         true,
+		Port:           port,
+		Protocol:       protocol,
+		Settings:       string(settings),
+		StreamSettings: "{}",
+		Tag:            fmt.Sprintf("inbound-%d", port),
+		Sniffing:       "{}",
+	}
+	s.inbounds[id] = inb
+	syncStats(inb)
+	return id
+}
+
+// Inbound возвращает копию inbound (nil — нет такого)
+func (s *Server) Inbound(id int) *Inbound {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	inb, ok := s.inbounds[id]
+	if !ok {
+		return nil
+	}
+	c := *inb
+	c.ClientStats = append([]ClientTraffic(nil), inb.ClientStats...)
+	return &c
+}
+
+// Clients возвращает клиентов inbound (копии JSON-объектов)
+func (s *Server) Clients(id int) []map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	inb, ok := s.inbounds[id]
+	if !ok {
+		return nil
+	}
+	_, clients := parseClients(inb)
+	return clients
+}
+
+// Client возвращает клиента inbound по email без учёта регистра (nil — нет такого)
+func (s *Server) Client(id int, email string) map[string]interface{} {
+	for _, c := range s.Clients(id) {
+		if em, _ := c["email"].(string); strings.EqualFold(em, email) {
+			return c
+		}
+	}
+	return nil
+}
+
+// SetStreamSettings задаёт streamSettings inbound (JSON-строка, как в панели)
+func (s *Server) SetStreamSettings(id int, streamSettings string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if inb, ok := s.inbounds[id]; ok {
+		inb.StreamSettings = streamSettings
+	}
+}
+
+// SetClientIPs задаёт IP клиента, которые панель отдаёт в clientIps
+func (s *Server) SetClientIPs(email string, ips ...string) {
+	s.mu.Lock()
+	s.clientIP[strings.ToLower(email)] = ips
+	s.mu.Unlock()
+}
+
+// AddTraffic добавляет клиенту трафик в статистику inbound (как если бы клиент его израсходовал)
+func (s *Server) AddTraffic(id int, email string, up, down int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	inb, ok := s.inbounds[id]
+	if !ok {
+		return
+	}
+	for i := range inb.ClientStats {
+		if strings.EqualFold(inb.ClientStats[i].Email, email) {
+			inb.ClientStats[i].Up += up
+			inb.ClientStats[i].Down += down
+		}
+	}
+}
+
+// handleList отдаёт все inbound
+func (s *Server) handleList(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	list := make([]*Inbound, 0, len(s.inbounds))
+	for id := 1; id < s.nextID; id++ {
+		if inb, ok := s.inbounds[id]; ok {
+			list = append(list, inb)
+		}
+	}
+	data, _ := json.Marshal(list)
+	s.mu.Unlock()
+	writeObj(w, json.RawMessage(data))
+}
+
+// handleGet отдаёт inbound по ID
+func (s *Server) handleGet(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	inb, ok := s.inbounds[pathID(r)]
+	var data []byte
+	if ok {
+		data, _ = json.Marshal(inb)
+	}
+	s.mu.Unlock()
+	if !ok {
+		writeMsg(w, false, "record not found")
+		return
+	}
+	writeObj(w, json.RawMessage(data))
+}
+
+// handleUpdate заменяет inbound целиком (ID и статистика сохраняются, статистика сверяется с клиентами)
+func (s *Server) handleUpdate(w http.ResponseWriter, r *http.Request) {
+	var in Inbound
+	if !decodeBody(w, r, &in) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	inb, ok := s.inbounds[pathID(r)]
+	if !ok {
+		writeMsg(w, false, "record not found")
+		return
+	}
+	in.ID, in.ClientStats = inb.ID, inb.ClientStats
+	*inb = in
+	syncStats(inb)
+	writeMsg(w, true, "Update Successfully")
+}
+
+// clientRequest — тело addClient/updateClient: {"id": <inbound>, "settings": "{\"clients\":[...]}"}
+type clientRequest struct {
+	ID       int    `json:"id"`
+	Settings string `json:"settings"`
+}
+
+// readClientRequest разбирает тело запроса API клиентов и находит inbound. Вызывается под s.mu
+func (s *Server) readClientRequest(w http.ResponseWriter, r *http.Request) (*Inbound, []map[string]interface{}, bool) {
+	var req clientRequest
+	if !decodeBody(w, r, &req) {
+		return nil, nil, false
+	}
+	var settings struct {
+		Clients []map[string]interface{} `json:"clients"`
+	}
+	if err := json.Unmarshal([]byte(req.Settings), &settings); err != nil || len(settings.Clients) == 0 {
+		writeMsg(w, false, "invalid settings")
+		return nil, nil, false
+	}
+	inb, ok := s.inbounds[req.ID]
+	if !ok {
+		writeMsg(w, false, "record not found")
+		return nil, nil, false
+	}
+	return inb, settings.Clients, true
+}
+
+// handleAddClient добавляет клиентов в inbound (email должен быть уникален)
+func (s *Server) handleAddClient(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	inb, added, ok := s.readClientRequest(w, r)
+	if !ok {
+		return
+	}
+	raw, clients := parseClients(inb)
+	now := time.Now().UnixMilli()
+	for _, c := range added {
+		if email, _ := c["email"].(string); findEmail(clients, email, -1) >= 0 {
+			writeMsg(w, false, "Duplicate email: "+email)
+			return
+		}
+		c["created_at"], c["updated_at"] = now, now
+		clients = append(clients, c)
+	}
+	storeClients(inb, raw, clients)
+	writeMsg(w, true, "Client(s) added Successfully")
+}
+
+// handleUpdateClient заменяет клиента с ключом clientId (UUID, пароль trojan или email shadowsocks)
+func (s *Server) handleUpdateClient(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	inb, updated, ok := s.readClientRequest(w, r)
+	if !ok {
+		return
+	}
+	raw, clients := parseClients(inb)
+	idx := findKey(inb.Protocol, clients, r.PathValue("clientId"))
+	if idx < 0 {
+		writeMsg(w, false, "Client Not Found")
+		return
+	}
+	c := updated[0]
+	if email, _ := c["email"].(string); findEmail(clients, email, idx) >= 0 {
+		writeMsg(w, false, "Duplicate email: "+email)
+		return
+	}
+	if created, ok := clients[idx]["created_at"]; ok {
+		c["created_at"] = created
+	}
+	c["updated_at"] = time.Now().UnixMilli()
+
+	// Статистика следует за сменой email
+	oldEmail, _ := clients[idx]["email"].(string)
+	newEmail, _ := c["email"].(string)
+	for i := range inb.ClientStats {
+		if inb.ClientStats[i].Email == oldEmail {
+			inb.ClientStats[i].Email = newEmail
+		}
+	}
+	clients[idx] = c
+	storeClients(inb, raw, clients)
+	writeMsg(w, true, "Client updated Successfully")
+}
+
+// handleDelClient удаляет клиента с ключом clientId из inbound id
+func (s *Server) handleDelClient(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	inb, ok := s.inbounds[pathID(r)]
+	if !ok {
+		writeMsg(w, false, "record not found")
+		return
+	}
+	raw, clients := parseClients(inb)
+	idx := findKey(inb.Protocol, clients, r.PathValue("clientId"))
+	if idx < 0 {
+		writeMsg(w, false, "Client Not Found")
+		return
+	}
+	clients = append(clients[:idx], clients[idx+1:]...)
+	storeClients(inb, raw, clients)
+	writeMsg(w, true, "Client deleted Successfully")
+}
+
+// handleClientTraffics отдаёт статистику клиента по email (obj: null — нет записи)
+func (s *Server) handleClientTraffics(w http.ResponseWriter, r *http.Request) {
+	email := r.PathValue("email")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, inb := range s.inbounds {
+		for _, t := range inb.ClientStats {
+			if t.Email == email {
+				writeObj(w, t)
+				return
+			}
+		}
+	}
+	writeObj(w, nil)
+}
+
+// handleClientIPs отдаёт IP клиента строкой с JSON-массивом или "No IP Record"
+func (s *Server) handleClientIPs(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	ips := s.clientIP[strings.ToLower(r.PathValue("email"))]
+	s.mu.Unlock()
+	if len(ips) == 0 {
+		writeObj(w, "No IP Record")
+		return
+	}
+	data, _ := json.Marshal(ips)
+	writeObj(w, string(data))
+}
+
+// parseClients разбирает settings inbound: весь JSON и массив клиентов
+func parseClients(inb *Inbound) (map[string]interface{}, []map[string]interface{}) {
+	var raw map[string]interface{}
+	if err := json.Unmarshal([]byte(inb.Settings), &raw); err != nil || raw == nil {
+		raw = make(map[string]interface{})
+	}
+	var clients []map[string]interface{}
+	list, _ := raw["clients"].([]interface{})
+	for _, c := range list {
+		if m, ok := c.(map[string]interface{}); ok {
+			clients = append(clients, m)
+		}
+	}
+	return raw, clients
+}
+
+// storeClients записывает клиентов обратно в settings и сверяет статистику
+func storeClients(inb *Inbound, raw map[string]interface{}, clients []map[string]interface{}) {
+	list := make([]interface{}, len(clients))
+	for i, c := range clients {
+		list[i] = c
+	}
+	raw["clients"] = list
+	data, _ := json.Marshal(raw)
+	inb.Settings = string(data)
+	syncStats(inb)
+}
+
+// syncStats приводит статистику inbound к списку клиентов: новые email получают запись, удалённые — теряют
+func syncStats(inb *Inbound) {
+	_, clients := parseClients(inb)
+	existing := make(map[string]ClientTraffic, len(inb.ClientStats))
+	for _, t := range inb.ClientStats {
+		existing[t.Email] = t
+	}
+	stats := make([]ClientTraffic, 0, len(clients))
+	for i, c := range clients {
+		email, _ := c["email"].(string)
+		t, ok := existing[email]
+		if !ok {
+			t = ClientTraffic{ID: inb.ID*1000 + i + 1, InboundID: inb.ID, Email: email}
+		}
+		t.Enable, _ = c["enable"].(bool)
+		// Лимит и срок записи статистики следуют за клиентом (totalGB в байтах, как в 3x-ui)
+		total, _ := c["totalGB"].(float64)
+		expiry, _ := c["expiryTime"].(float64)
+		t.Total, t.ExpiryTime = int64(total), int64(expiry)
+		stats = append(stats, t)
+	}
+	inb.ClientStats = stats
+}
+
+// findKey ищет клиента по ключу API: пароль для trojan, email для shadowsocks, иначе UUID
+func findKey(protocol string, clients []map[string]interface{}, key string) int {
+	field := "id"
+	switch protocol {
+	case "trojan":
+		field = "password"
+	case "shadowsocks":
+		field = "email"
+	}
+	for i, c := range clients {
+		if v, _ := c[field].(string); v == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// findEmail ищет клиента с email (без учёта регистра), пропуская индекс skip
+func findEmail(clients []map[string]interface{}, email string, skip int) int {
+	for i, c := range clients {
+		if em, _ := c["email"].(string); i != skip && strings.EqualFold(em, email) {
+			return i
+		}
+	}
+	return -1
+}
+
+// pathID возвращает числовой параметр {id} пути (0 — некорректный)
+func pathID(r *http.Request) int {
+	id, _ := strconv.Atoi(r.PathValue("id"))
+	return id
+}
+
+// decodeBody разбирает JSON-тело запроса; при ошибке отвечает success:false
+func decodeBody(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		writeMsg(w, false, "invalid request: "+err.Error())
+		return false
+	}
+	return true
+}
+
+// writeMsg отвечает {"success": ok, "msg": msg}
+func writeMsg(w http.ResponseWriter, ok bool, msg string) {
+	writeJSON(w, map[string]interface{}{"success": ok, "msg": msg, "obj": nil})
+}
+
+// writeObj отвечает {"success": true, "obj": obj}
+func writeObj(w http.ResponseWriter, obj interface{}) {
+	writeJSON(w, map[string]interface{}{"success": true, "msg": "", "obj": obj})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,53 @@
+// Пакет inbound: счётчики трафика клиентов inbound (clientStats) и их производные — расход, лимит, срок.
+package inbound
+
+import (
+	"strings"
+	"time"
+)
+
+// ClientStats — запись client_traffics панели: расход и лимиты клиента
+type ClientStats struct {
+	// ID — идентификатор записи статистики
+	ID int `json:"id"`
+	// InboundID — inbound, к которому относится клиент
+	InboundID int `json:"inboundId"`
+	// Enable — включён ли клиент по учёту панели (панель снимает флаг по лимиту трафика и сроку)
+	Enable bool `json:"enable"`
+	// Email — email клиента
+	Email string `json:"email"`
+	// Up — исходящий трафик (байты)
+	Up int64 `json:"up"`
+	// Down — входящий трафик (байты)
+	Down int64 `json:"down"`
+	// ExpiryTime — время истечения (unix, миллисекунды; 0 — бессрочно, отрицательное — отсчёт с первого подключения)
+	ExpiryTime int64 `json:"expiryTime"`
+	// Total — лимит трафика (байты; 0 — без лимита)
+	Total int64 `json:"total"`
+	// Reset — период автосброса трафика (дни)
+	Reset int `json:"reset"`
+}
+
+// Used возвращает израсходованный трафик (байты)
+func (c *ClientStats) Used() int64 {
+	return c.Up + c.Down
+}
+
+// Depleted сообщает, что клиент израсходовал лимит трафика
+func (c *ClientStats) Depleted() bool {
+	return c.Total > 0 && c.Used() >= c.Total
+}
+
+// Expired сообщает, что срок клиента истёк к моменту now
+func (c *ClientStats) Expired(now time.Time) bool {
+	return c.ExpiryTime > 0 && now.UnixMilli() >= c.ExpiryTime
+}
+
+// StatsByEmail возвращает статистику клиентов inbound по email в нижнем регистре
+func (inb *Inbound) StatsByEmail() map[string]ClientStats {
+	stats := make(map[string]ClientStats, len(inb.ClientStats))
+	for _, c := range inb.ClientStats {
+		stats[strings.ToLower(c.Email)] = c
+	}
+	return stats
+}
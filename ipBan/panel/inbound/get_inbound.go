@@ -3,10 +3,7 @@
 package inbound
 
 import (
-	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
 
 	"ipBanSystem/ipBan/panel"
 )
@@ -41,63 +38,16 @@ type Inbound struct {
 	Tag string `json:"tag"`
 	// Sniffing — строка JSON с параметрами сниффинга
 	Sniffing string `json:"sniffing"`
-	// ClientStats — статистика по клиентам; тип зависит от настроек панели
-	ClientStats interface{} `json:"clientStats"`
-}
-
-// GetInboundInfo структура для ответа с информацией о inbound
-type GetInboundInfo struct {
-	// Success — флаг успешности ответа панели
-	Success bool `json:"success"`
-	// Msg — сообщение об ошибке/успехе, предоставляемое панелью
-	Msg string `json:"msg"`
-	// Obj — полезная нагрузка с объектом inbound
-	Obj Inbound `json:"obj"`
+	// ClientStats — счётчики трафика клиентов (записи client_traffics панели)
+	ClientStats []ClientStats `json:"clientStats"`
 }
 
 // GetInbound получает объект inbound
 func GetInbound(cm *panel.ConfigManager) (*Inbound, error) {
-	// Формируем URL запроса к ресурсу inbound по ID
-	url := fmt.Sprintf("%spanel/api/inbounds/get/%d", cm.PanelURL, cm.InboundID)
-
-	// Создаём GET‑запрос
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, fmt.Errorf("ошибка создания запроса: %v", err)
-	}
-
-	// Добавляем сессионную куку для авторизованного доступа
-	req.Header.Add("Cookie", cm.SessionCookie)
-
-	// Выполняем запрос через общий HTTP‑клиент
-	resp, err := cm.Client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("ошибка выполнения запроса: %v", err)
-	}
-	defer resp.Body.Close()
-
-	// Читаем тело ответа
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("ошибка чтения ответа: %v", err)
+	// Запрашиваем inbound по ID менеджера через общий слой запросов (сессия, повторы, типизированные ошибки)
+	var inb Inbound
+	if err := cm.Get(fmt.Sprintf("panel/api/inbounds/get/%d", cm.InboundID), &inb); err != nil {
+		return nil, fmt.Errorf("ошибка получения inbound %d: %w", cm.InboundID, err)
 	}
-
-	// Проверяем HTTP‑статус
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("некорректный статус ответа: %d, body=%s", resp.StatusCode, string(body))
-	}
-
-	// Парсим JSON‑ответ панели
-	var response GetInboundInfo
-	if err := json.Unmarshal(body, &response); err != nil {
-		return nil, fmt.Errorf("ошибка парсинга JSON: %v", err)
-	}
-
-	// Проверяем флаг успеха — панель вернула корректный объект
-	if !response.Success {
-		return nil, fmt.Errorf("ошибка получения inbound: %s", response.Msg)
-	}
-
-	// Возвращаем объект inbound
-	return &response.Obj, nil
+	return &inb, nil
 }
@@ -0,0 +1,17 @@
+// ListInbounds получает список всех inbound панели x-ui.
+package inbound
+
+import (
+	"fmt"
+
+	"ipBanSystem/ipBan/panel"
+)
+
+// ListInbounds получает все inbound панели (не только тот, с которым работает менеджер)
+func ListInbounds(cm *panel.ConfigManager) ([]Inbound, error) {
+	var inbounds []Inbound
+	if err := cm.Get("panel/api/inbounds/list", &inbounds); err != nil {
+		return nil, fmt.Errorf("ошибка получения списка inbound: %w", err)
+	}
+	return inbounds, nil
+}
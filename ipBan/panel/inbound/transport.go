@@ -0,0 +1,39 @@
+// TransportProtocols определяет протоколы транспортного уровня (tcp/udp), на которых слушает inbound.
+package inbound
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// TransportProtocols возвращает протоколы L4, по которым клиенты подключаются к порту inbound:
+// kcp и quic работают поверх UDP, shadowsocks — по списку settings.network ("tcp,udp"), остальные — TCP
+func (i *Inbound) TransportProtocols() []string {
+	if i.Protocol == "shadowsocks" {
+		var settings struct {
+			Network string `json:"network"`
+		}
+		if json.Unmarshal([]byte(i.Settings), &settings) == nil && settings.Network != "" {
+			var protos []string
+			for _, p := range strings.Split(settings.Network, ",") {
+				if p = strings.TrimSpace(p); p == "tcp" || p == "udp" {
+					protos = append(protos, p)
+				}
+			}
+			if len(protos) > 0 {
+				return protos
+			}
+		}
+	}
+
+	var stream struct {
+		Network string `json:"network"`
+	}
+	_ = json.Unmarshal([]byte(i.StreamSettings), &stream)
+	switch stream.Network {
+	case "kcp", "quic":
+		return []string{"udp"}
+	default:
+		return []string{"tcp"}
+	}
+}
@@ -0,0 +1,23 @@
+// Update сохраняет объект inbound в панели x-ui.
+package inbound
+
+import (
+	"fmt"
+
+	"ipBanSystem/ipBan/panel"
+)
+
+// Update отправляет изменённый inbound в панель (POST panel/api/inbounds/update/<id>).
+// Запрос заменяет inbound целиком, поэтому его можно безопасно повторить после сбоя.
+func Update(cm *panel.ConfigManager, inb *Inbound) error {
+	req := &panel.Request{
+		Method:     "POST",
+		Path:       fmt.Sprintf("panel/api/inbounds/update/%d", cm.InboundID),
+		Body:       inb,
+		Idempotent: true,
+	}
+	if err := cm.Do(req, nil); err != nil {
+		return fmt.Errorf("ошибка обновления inbound %d: %w", cm.InboundID, err)
+	}
+	return nil
+}
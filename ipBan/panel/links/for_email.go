@@ -0,0 +1,39 @@
+// Пакет links: ссылки клиента по email — inbound и клиент загружаются из панели.
+package links
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"ipBanSystem/ipBan/panel"
+	"ipBanSystem/ipBan/panel/client"
+	"ipBanSystem/ipBan/panel/inbound"
+)
+
+// ForEmail возвращает ссылки подключения клиента email.
+// host — адрес сервера в ссылках; пустой — адрес прослушивания inbound или хост панели (PanelURL)
+func ForEmail(cm *panel.ConfigManager, email, host string) ([]string, error) {
+	inb, err := inbound.GetInbound(cm)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка получения inbound: %w", err)
+	}
+	var settings client.Settings
+	if err := json.Unmarshal([]byte(inb.Settings), &settings); err != nil {
+		return nil, fmt.Errorf("ошибка парсинга настроек inbound: %w", err)
+	}
+	for i := range settings.Clients {
+		c := &settings.Clients[i]
+		if !strings.EqualFold(c.Email, email) {
+			continue
+		}
+		if host == "" && (inb.Listen == "" || inb.Listen == "0.0.0.0" || inb.Listen == "::") {
+			if u, err := url.Parse(cm.PanelURL); err == nil {
+				host = u.Hostname()
+			}
+		}
+		return Build(inb, c, host)
+	}
+	return nil, panel.NotFoundf("клиент с email %s не найден", email)
+}
@@ -0,0 +1,196 @@
+// Пакет links: ссылки подключения клиентов (vless://, vmess://, trojan://, ss://) и содержимое подписки
+// по inbound и клиенту — в формате, который генерирует панель 3x-ui.
+package links
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"ipBanSystem/ipBan/panel/client"
+	"ipBanSystem/ipBan/panel/inbound"
+)
+
+// Build возвращает ссылки подключения клиента c к inbound inb.
+// host — адрес сервера в ссылке (домен или IP); пустой — адрес прослушивания inbound.
+// Если у inbound заданы внешние адреса (externalProxy), возвращается по ссылке на каждый.
+func Build(inb *inbound.Inbound, c *client.Client, host string) ([]string, error) {
+	st, err := parseStream(inb.StreamSettings)
+	if err != nil {
+		return nil, err
+	}
+	if host == "" && inb.Listen != "" && inb.Listen != "0.0.0.0" && inb.Listen != "::" {
+		host = inb.Listen
+	}
+
+	targets := []externalProxy{{ForceTLS: "same", Dest: host, Port: inb.Port}}
+	if proxies := st.externalProxies(); len(proxies) > 0 {
+		targets = proxies
+	}
+
+	var links []string
+	for _, target := range targets {
+		if target.Dest == "" {
+			return nil, fmt.Errorf("не задан адрес сервера для ссылки inbound %d", inb.ID)
+		}
+		security := st.Security
+		if target.ForceTLS == "tls" || target.ForceTLS == "none" {
+			security = target.ForceTLS
+		}
+		remark := joinRemark(inb.Remark, c.Email, target.Remark)
+
+		var link string
+		switch inb.Protocol {
+		case client.ProtocolVLESS:
+			params := merge(st.transportParams(), st.securityParams(security, c.Flow))
+			params["encryption"] = vlessEncryption(inb)
+			link = uriLink("vless", c.ID, target, params, remark)
+		case client.ProtocolTrojan:
+			params := merge(st.transportParams(), st.securityParams(security, c.Flow))
+			link = uriLink("trojan", c.Password, target, params, remark)
+		case client.ProtocolShadowsocks:
+			params := merge(st.transportParams(), st.securityParams(security, ""))
+			// Панель пишет security в ссылку ss только для TLS
+			if params["security"] == "none" {
+				delete(params, "security")
+			}
+			userinfo, err := shadowsocksUserinfo(inb, c)
+			if err != nil {
+				return nil, err
+			}
+			link = uriLink("ss", userinfo, target, params, remark)
+		case client.ProtocolVMess:
+			link = vmessLink(st, security, c, target, remark)
+		default:
+			return nil, fmt.Errorf("протокол %q не поддерживает ссылки подключения", inb.Protocol)
+		}
+		links = append(links, link)
+	}
+	return links, nil
+}
+
+// Subscription возвращает содержимое подписки: ссылки построчно в base64, как отдаёт сервер подписок 3x-ui
+func Subscription(links []string) string {
+	return base64.StdEncoding.EncodeToString([]byte(strings.Join(links, "\n")))
+}
+
+// uriLink собирает ссылку scheme://userinfo@dest:port?params#remark; пустые параметры опускаются
+func uriLink(scheme, userinfo string, target externalProxy, params map[string]string, remark string) string {
+	q := url.Values{}
+	for k, v := range params {
+		if v != "" {
+			q.Set(k, v)
+		}
+	}
+	u := url.URL{
+		Scheme:   scheme,
+		User:     url.User(userinfo),
+		Host:     net.JoinHostPort(target.Dest, strconv.Itoa(target.Port)),
+		RawQuery: q.Encode(),
+		Fragment: remark,
+	}
+	return u.String()
+}
+
+// vmessLink собирает vmess://<base64 JSON> (формат v2rayN, как в панели)
+func vmessLink(st *stream, security string, c *client.Client, target externalProxy, remark string) string {
+	obj := map[string]interface{}{
+		"v":    "2",
+		"ps":   remark,
+		"add":  target.Dest,
+		"port": target.Port,
+		"id":   c.ID,
+		"scy":  c.Security,
+		"net":  st.Network,
+		"type": "none",
+		"tls":  security,
+	}
+	transport := st.transportParams()
+	switch st.Network {
+	case "tcp":
+		if transport["headerType"] == "http" {
+			obj["type"], obj["path"], obj["host"] = "http", transport["path"], transport["host"]
+		}
+	case "kcp":
+		obj["type"], obj["path"] = transport["headerType"], transport["seed"]
+	case "ws", "httpupgrade", "xhttp":
+		obj["path"], obj["host"] = transport["path"], transport["host"]
+		if st.Network == "xhttp" {
+			obj["mode"] = transport["mode"]
+		}
+	case "grpc":
+		obj["path"], obj["authority"] = transport["serviceName"], transport["authority"]
+		if transport["mode"] == "multi" {
+			obj["type"] = "multi"
+		}
+	}
+	if security == "tls" {
+		sec := st.securityParams(security, "")
+		for _, k := range []string{"alpn", "sni", "fp"} {
+			if sec[k] != "" {
+				obj[k] = sec[k]
+			}
+		}
+		if sec["allowInsecure"] != "" {
+			obj["allowInsecure"] = true
+		}
+	}
+	data, _ := json.MarshalIndent(obj, "", "  ")
+	return "vmess://" + base64.StdEncoding.EncodeToString(data)
+}
+
+// shadowsocksUserinfo возвращает base64("method:password"); для шифров 2022 с ключами клиентов —
+// base64("method:ключ inbound:ключ клиента")
+func shadowsocksUserinfo(inb *inbound.Inbound, c *client.Client) (string, error) {
+	var settings struct {
+		Method   string `json:"method"`
+		Password string `json:"password"`
+	}
+	if err := json.Unmarshal([]byte(inb.Settings), &settings); err != nil {
+		return "", fmt.Errorf("ошибка парсинга настроек inbound: %w", err)
+	}
+	method := settings.Method
+	if c.Method != "" {
+		method = c.Method
+	}
+	secret := method + ":" + c.Password
+	if strings.HasPrefix(method, "2022-") && settings.Password != "" {
+		secret = method + ":" + settings.Password + ":" + c.Password
+	}
+	return base64.StdEncoding.EncodeToString([]byte(secret)), nil
+}
+
+// vlessEncryption возвращает параметр encryption ссылки vless (поле encryption настроек, по умолчанию "none")
+func vlessEncryption(inb *inbound.Inbound) string {
+	var settings struct {
+		Encryption string `json:"encryption"`
+	}
+	_ = json.Unmarshal([]byte(inb.Settings), &settings)
+	if settings.Encryption == "" {
+		return "none"
+	}
+	return settings.Encryption
+}
+
+// joinRemark собирает имя ссылки как панель: метка inbound, email и метка внешнего адреса через "-"
+func joinRemark(parts ...string) string {
+	var nonEmpty []string
+	for _, p := range parts {
+		if p != "" {
+			nonEmpty = append(nonEmpty, p)
+		}
+	}
+	return strings.Join(nonEmpty, "-")
+}
+
+// merge объединяет параметры ссылки (ключи second перекрывают first)
+func merge(first, second map[string]string) map[string]string {
+	for k, v := range second {
+		first[k] = v
+	}
+	return first
+}
@@ -0,0 +1,249 @@
+package links_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"testing"
+
+	"ipBanSystem/ipBan/panel/client"
+	"ipBanSystem/ipBan/panel/fakepanel"
+	"ipBanSystem/ipBan/panel/inbound"
+	"ipBanSystem/ipBan/panel/links"
+)
+
+// TestLinks сверяет ссылки подключения со ссылками, которые генерирует панель 3x-ui
+func TestLinks(t *testing.T) {
+	t.Run("VLESSRealityTCP", testLinkVLESSReality)
+	t.Run("VLESSWebSocketTLS", testLinkVLESSWebSocket)
+	t.Run("TrojanGRPC", testLinkTrojanGRPC)
+	t.Run("VMessWebSocket", testLinkVMess)
+	t.Run("Shadowsocks2022", testLinkShadowsocks)
+	t.Run("ExternalProxy", testLinkExternalProxy)
+	t.Run("Subscription", testLinkSubscription)
+	t.Run("ForEmail", testLinkForEmail)
+}
+
+const (
+	linkHost = "vpn.example.com"
+	linkUUID = "3f2a1c9e-8b7d-4e6f-a5c4-1b2d3e4f5a6b"
+)
+
+// wsTLSStream — streamSettings inbound WebSocket с TLS
+const wsTLSStream = `{"network":"ws","security":"tls","externalProxy":[],
+	"tlsSettings":{"serverName":"cdn.example.com","minVersion":"1.2","maxVersion":"1.3","alpn":["h2","http/1.1"],
+		"certificates":[{"certificateFile":"/root/cert.crt","keyFile":"/root/cert.key"}],
+		"settings":{"allowInsecure":false,"fingerprint":"firefox"}},
+	"wsSettings":{"acceptProxyProtocol":false,"path":"/ws","headers":{"Host":"cdn.example.com"}}}`
+
+// buildOne строит ссылки и проверяет, что ссылка одна
+func buildOne(t *testing.T, inb *inbound.Inbound, c *client.Client) string {
+	t.Helper()
+	list, err := links.Build(inb, c, linkHost)
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	if len(list) != 1 {
+		t.Fatalf("ожидалась одна ссылка: %v", list)
+	}
+	return list[0]
+}
+
+// assertSameLink сравнивает ссылки по смыслу: порядок параметров, пустые параметры
+// и кодировка base64 (стандартная или URL-safe, с дополнением или без) не важны
+func assertSameLink(t *testing.T, got, want string) {
+	t.Helper()
+	if g, w := describeLink(t, got), describeLink(t, want); g != w {
+		t.Errorf("ссылка не совпадает с панелью:\n получено: %s\n ожидалось: %s\n (%s)\n (%s)", got, want, g, w)
+	}
+}
+
+// describeLink приводит ссылку к каноническому описанию для сравнения
+func describeLink(t *testing.T, link string) string {
+	t.Helper()
+	if payload, ok := strings.CutPrefix(link, "vmess://"); ok {
+		var obj map[string]interface{}
+		if err := json.Unmarshal(decodeBase64(t, payload), &obj); err != nil {
+			t.Fatalf("vmess JSON: %v", err)
+		}
+		var fields []string
+		for k, v := range obj {
+			if s := fmt.Sprint(v); s != "" {
+				fields = append(fields, k+"="+s)
+			}
+		}
+		return "vmess " + sortedJoin(fields)
+	}
+
+	u, err := url.Parse(link)
+	if err != nil {
+		t.Fatalf("url.Parse(%q): %v", link, err)
+	}
+	user := u.User.Username()
+	if u.Scheme == "ss" {
+		user = string(decodeBase64(t, user))
+	}
+	var params []string
+	for k, vs := range u.Query() {
+		for _, v := range vs {
+			if v != "" {
+				params = append(params, k+"="+v)
+			}
+		}
+	}
+	return fmt.Sprintf("%s %s@%s %s #%s", u.Scheme, user, u.Host, sortedJoin(params), u.Fragment)
+}
+
+// decodeBase64 декодирует base64 в любом из вариантов кодировки
+func decodeBase64(t *testing.T, s string) []byte {
+	t.Helper()
+	s = strings.TrimRight(s, "=")
+	for _, enc := range []*base64.Encoding{base64.RawStdEncoding, base64.RawURLEncoding} {
+		if data, err := enc.DecodeString(s); err == nil {
+			return data
+		}
+	}
+	t.Fatalf("не base64: %q", s)
+	return nil
+}
+
+// sortedJoin соединяет строки в порядке сортировки
+func sortedJoin(items []string) string {
+	sort.Strings(items)
+	return strings.Join(items, "&")
+}
+
+func testLinkVLESSReality(t *testing.T) {
+	inb := &inbound.Inbound{
+		ID: 1, Port: 443, Protocol: client.ProtocolVLESS, Remark: "reality",
+		Settings: `{"clients":[],"decryption":"none","fallbacks":[]}`,
+		StreamSettings: `{"network":"tcp","security":"reality","externalProxy":[],
+			"realitySettings":{"show":false,"xver":0,"dest":"yahoo.com:443","serverNames":["yahoo.com","www.yahoo.com"],
+				"privateKey":"yBQnPZtI3Ln6OeGQx2gVuJr6FPWV7eQzBvQNwEsFz0Y","shortIds":["2f5ee1a7","9c"],
+				"settings":{"publicKey":"Tq8Xr3mJ8mN6aUGp5o0YQgRk1pJ7l2c4wE9sB0hX2Ww","fingerprint":"chrome","serverName":"","spiderX":"/"}},
+			"tcpSettings":{"acceptProxyProtocol":false,"header":{"type":"none"}}}`,
+	}
+	c := &client.Client{ID: linkUUID, Email: "alice", Flow: "xtls-rprx-vision"}
+	assertSameLink(t, buildOne(t, inb, c),
+		"vless://"+linkUUID+"@vpn.example.com:443?type=tcp&encryption=none&security=reality"+
+			"&pbk=Tq8Xr3mJ8mN6aUGp5o0YQgRk1pJ7l2c4wE9sB0hX2Ww&fp=chrome&sni=yahoo.com&sid=2f5ee1a7&spx=%2F"+
+			"&flow=xtls-rprx-vision#reality-alice")
+}
+
+func testLinkVLESSWebSocket(t *testing.T) {
+	inb := &inbound.Inbound{
+		ID: 2, Port: 8443, Protocol: client.ProtocolVLESS, Remark: "ws",
+		Settings:       `{"clients":[],"decryption":"none"}`,
+		StreamSettings: wsTLSStream,
+	}
+	// flow задаётся только для TCP: у WebSocket он не попадает в ссылку
+	c := &client.Client{ID: linkUUID, Email: "alice", Flow: "xtls-rprx-vision"}
+	assertSameLink(t, buildOne(t, inb, c),
+		"vless://"+linkUUID+"@vpn.example.com:8443?type=ws&encryption=none&path=%2Fws&host=cdn.example.com"+
+			"&security=tls&fp=firefox&alpn=h2%2Chttp%2F1.1&sni=cdn.example.com#ws-alice")
+}
+
+func testLinkTrojanGRPC(t *testing.T) {
+	inb := &inbound.Inbound{
+		ID: 3, Port: 2053, Protocol: client.ProtocolTrojan, Remark: "grpc",
+		Settings: `{"clients":[],"fallbacks":[]}`,
+		StreamSettings: `{"network":"grpc","security":"tls",
+			"tlsSettings":{"serverName":"vpn.example.com","alpn":[],"settings":{"allowInsecure":true,"fingerprint":""}},
+			"grpcSettings":{"serviceName":"tun","authority":"","multiMode":true}}`,
+	}
+	c := &client.Client{Password: "p4ss-w0rd", Email: "bob"}
+	assertSameLink(t, buildOne(t, inb, c),
+		"trojan://p4ss-w0rd@vpn.example.com:2053?type=grpc&serviceName=tun&mode=multi&security=tls"+
+			"&sni=vpn.example.com&allowInsecure=1#grpc-bob")
+}
+
+func testLinkVMess(t *testing.T) {
+	inb := &inbound.Inbound{
+		ID: 4, Port: 80, Protocol: client.ProtocolVMess, Remark: "vm",
+		Settings:       `{"clients":[]}`,
+		StreamSettings: `{"network":"ws","security":"none","wsSettings":{"path":"/vm","headers":{}}}`,
+	}
+	c := &client.Client{ID: linkUUID, Email: "carol", Security: "auto"}
+	panelJSON := `{
+  "v": "2",
+  "ps": "vm-carol",
+  "add": "vpn.example.com",
+  "port": 80,
+  "id": "` + linkUUID + `",
+  "scy": "auto",
+  "net": "ws",
+  "type": "none",
+  "tls": "none",
+  "path": "/vm",
+  "host": ""
+}`
+	assertSameLink(t, buildOne(t, inb, c), "vmess://"+base64.StdEncoding.EncodeToString([]byte(panelJSON)))
+}
+
+func testLinkShadowsocks(t *testing.T) {
+	inb := &inbound.Inbound{
+		ID: 5, Port: 8388, Protocol: client.ProtocolShadowsocks, Remark: "ss",
+		Settings: `{"method":"2022-blake3-aes-256-gcm","password":"c2VydmVyLWtleS0zMi1ieXRlcy1mb3ItdGVzdHMtMDE=",
+			"network":"tcp,udp","clients":[]}`,
+		StreamSettings: `{"network":"tcp","security":"none","tcpSettings":{"header":{"type":"none"}}}`,
+	}
+	c := &client.Client{Password: "Y2xpZW50LWtleS0zMi1ieXRlcy1mb3ItdGVzdHMtMDI=", Email: "dave"}
+	secret := "2022-blake3-aes-256-gcm:c2VydmVyLWtleS0zMi1ieXRlcy1mb3ItdGVzdHMtMDE=:Y2xpZW50LWtleS0zMi1ieXRlcy1mb3ItdGVzdHMtMDI="
+	assertSameLink(t, buildOne(t, inb, c),
+		"ss://"+base64.RawURLEncoding.EncodeToString([]byte(secret))+"@vpn.example.com:8388?type=tcp#ss-dave")
+
+	// Классический шифр: method:password без ключа inbound
+	inb.Settings = `{"method":"chacha20-ietf-poly1305","clients":[]}`
+	c = &client.Client{Password: "secret", Method: "chacha20-ietf-poly1305", Email: "dave"}
+	assertSameLink(t, buildOne(t, inb, c),
+		"ss://"+base64.StdEncoding.EncodeToString([]byte("chacha20-ietf-poly1305:secret"))+"@vpn.example.com:8388?type=tcp#ss-dave")
+}
+
+func testLinkExternalProxy(t *testing.T) {
+	inb := &inbound.Inbound{
+		ID: 6, Port: 8443, Protocol: client.ProtocolVLESS, Remark: "ws",
+		Settings: `{"clients":[],"decryption":"none"}`,
+		StreamSettings: strings.Replace(wsTLSStream, `"externalProxy":[]`,
+			`"externalProxy":[{"forceTls":"same","dest":"edge.example.net","port":443,"remark":""},
+				{"forceTls":"none","dest":"203.0.113.10","port":80,"remark":"plain"}]`, 1),
+	}
+	c := &client.Client{ID: linkUUID, Email: "alice"}
+	list, err := links.Build(inb, c, linkHost)
+	if err != nil || len(list) != 2 {
+		t.Fatalf("Build: %v, %v", list, err)
+	}
+	assertSameLink(t, list[0],
+		"vless://"+linkUUID+"@edge.example.net:443?type=ws&encryption=none&path=%2Fws&host=cdn.example.com"+
+			"&security=tls&fp=firefox&alpn=h2%2Chttp%2F1.1&sni=cdn.example.com#ws-alice")
+	assertSameLink(t, list[1],
+		"vless://"+linkUUID+"@203.0.113.10:80?type=ws&encryption=none&path=%2Fws&host=cdn.example.com"+
+			"&security=none#ws-alice-plain")
+}
+
+func testLinkSubscription(t *testing.T) {
+	sub := links.Subscription([]string{"vless://a@h:1#x", "trojan://b@h:2#y"})
+	data, err := base64.StdEncoding.DecodeString(sub)
+	if err != nil || string(data) != "vless://a@h:1#x\ntrojan://b@h:2#y" {
+		t.Fatalf("подписка: %q, %v", data, err)
+	}
+}
+
+func testLinkForEmail(t *testing.T) {
+	fake, cm := fakepanel.StartVLESS(t)
+	fake.SetStreamSettings(cm.InboundID, wsTLSStream)
+	list, err := links.ForEmail(cm, "USER", "")
+	if err != nil || len(list) != 1 {
+		t.Fatalf("ForEmail: %v, %v", list, err)
+	}
+	panelHost, _ := url.Parse(fake.URL)
+	if !strings.Contains(list[0], "@"+panelHost.Hostname()+":443?") ||
+		!strings.HasPrefix(list[0], "vless://11111111-1111-1111-1111-111111111111@") {
+		t.Errorf("ссылка не на адрес панели и порт inbound: %s", list[0])
+	}
+	if _, err := links.ForEmail(cm, "nobody", linkHost); err == nil {
+		t.Errorf("ожидалась ошибка для неизвестного клиента")
+	}
+}
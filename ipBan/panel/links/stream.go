@@ -0,0 +1,180 @@
+// Пакет links: параметры транспорта и шифрования из streamSettings inbound — общая часть ссылок всех протоколов.
+// Ключи параметров совпадают со ссылками, которые генерирует панель 3x-ui.
+package links
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// stream — разобранные streamSettings inbound
+type stream struct {
+	raw      map[string]interface{}
+	Network  string
+	Security string
+}
+
+// externalProxy — внешний адрес inbound (streamSettings.externalProxy): CDN, проброс порта и т. п.
+type externalProxy struct {
+	// ForceTLS — "same" (как у inbound), "tls" или "none"
+	ForceTLS string `json:"forceTls"`
+	Dest     string `json:"dest"`
+	Port     int    `json:"port"`
+	Remark   string `json:"remark"`
+}
+
+// parseStream разбирает строку streamSettings
+func parseStream(settings string) (*stream, error) {
+	raw := make(map[string]interface{})
+	if strings.TrimSpace(settings) != "" {
+		if err := json.Unmarshal([]byte(settings), &raw); err != nil {
+			return nil, fmt.Errorf("ошибка парсинга streamSettings: %w", err)
+		}
+	}
+	s := &stream{raw: raw}
+	s.Network, _ = raw["network"].(string)
+	if s.Network == "" {
+		s.Network = "tcp"
+	}
+	s.Security, _ = raw["security"].(string)
+	if s.Security == "" {
+		s.Security = "none"
+	}
+	return s, nil
+}
+
+// externalProxies возвращает внешние адреса inbound (пусто — ссылки строятся на адрес сервера)
+func (s *stream) externalProxies() []externalProxy {
+	data, err := json.Marshal(s.raw["externalProxy"])
+	if err != nil {
+		return nil
+	}
+	var proxies []externalProxy
+	_ = json.Unmarshal(data, &proxies)
+	return proxies
+}
+
+// transportParams возвращает параметры транспорта для ссылок vless/trojan/shadowsocks
+func (s *stream) transportParams() map[string]string {
+	params := map[string]string{"type": s.Network}
+	switch s.Network {
+	case "tcp":
+		header := object(object(s.raw, "tcpSettings"), "header")
+		if str(header, "type") == "http" {
+			request := object(header, "request")
+			if paths, ok := request["path"].([]interface{}); ok && len(paths) > 0 {
+				params["path"], _ = paths[0].(string)
+			}
+			params["host"] = searchHost(object(request, "headers"))
+			params["headerType"] = "http"
+		}
+	case "kcp":
+		kcp := object(s.raw, "kcpSettings")
+		params["headerType"] = str(object(kcp, "header"), "type")
+		params["seed"] = str(kcp, "seed")
+	case "ws", "httpupgrade", "xhttp":
+		settings := object(s.raw, s.Network+"Settings")
+		params["path"] = str(settings, "path")
+		params["host"] = str(settings, "host")
+		if params["host"] == "" {
+			params["host"] = searchHost(object(settings, "headers"))
+		}
+		if s.Network == "xhttp" {
+			params["mode"] = str(settings, "mode")
+		}
+	case "grpc":
+		grpc := object(s.raw, "grpcSettings")
+		params["serviceName"] = str(grpc, "serviceName")
+		params["authority"] = str(grpc, "authority")
+		if multi, _ := grpc["multiMode"].(bool); multi {
+			params["mode"] = "multi"
+		}
+	}
+	return params
+}
+
+// securityParams возвращает параметры TLS/Reality; security — итоговое шифрование ссылки
+// (может отличаться от inbound для внешнего адреса с forceTls), flow — flow клиента
+func (s *stream) securityParams(security, flow string) map[string]string {
+	params := map[string]string{"security": security}
+	switch security {
+	case "tls":
+		tls := object(s.raw, "tlsSettings")
+		if alpn := strings.Join(stringList(tls["alpn"]), ","); alpn != "" {
+			params["alpn"] = alpn
+		}
+		params["sni"] = str(tls, "serverName")
+		settings := object(tls, "settings")
+		params["fp"] = str(settings, "fingerprint")
+		if insecure, _ := settings["allowInsecure"].(bool); insecure {
+			params["allowInsecure"] = "1"
+		}
+	case "reality":
+		reality := object(s.raw, "realitySettings")
+		settings := object(reality, "settings")
+		// Панель выбирает случайные serverName и shortId; для воспроизводимых ссылок берётся первый
+		if names := stringList(reality["serverNames"]); len(names) > 0 {
+			params["sni"] = names[0]
+		}
+		if ids := stringList(reality["shortIds"]); len(ids) > 0 {
+			params["sid"] = ids[0]
+		}
+		params["pbk"] = str(settings, "publicKey")
+		params["fp"] = str(settings, "fingerprint")
+		params["spx"] = str(settings, "spiderX")
+		params["pqv"] = str(settings, "mldsa65Verify")
+	default:
+		params["security"] = "none"
+	}
+	// flow имеет смысл только для TCP с TLS или Reality
+	if (security == "tls" || security == "reality") && s.Network == "tcp" && flow != "" {
+		params["flow"] = flow
+	}
+	return params
+}
+
+// object возвращает вложенный JSON-объект (nil — нет такого)
+func object(m map[string]interface{}, key string) map[string]interface{} {
+	v, _ := m[key].(map[string]interface{})
+	return v
+}
+
+// str возвращает строковое поле JSON-объекта (пусто — нет такого)
+func str(m map[string]interface{}, key string) string {
+	v, _ := m[key].(string)
+	return v
+}
+
+// stringList возвращает строки JSON-массива; строка через запятую (формат старых панелей) разбивается
+func stringList(v interface{}) []string {
+	var out []string
+	switch list := v.(type) {
+	case []interface{}:
+		for _, item := range list {
+			if s, ok := item.(string); ok && s != "" {
+				out = append(out, s)
+			}
+		}
+	case string:
+		for _, s := range strings.Split(list, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				out = append(out, s)
+			}
+		}
+	}
+	return out
+}
+
+// searchHost ищет заголовок Host (без учёта регистра); значение может быть строкой или массивом строк
+func searchHost(headers map[string]interface{}) string {
+	for k, v := range headers {
+		if !strings.EqualFold(k, "host") {
+			continue
+		}
+		if hosts := stringList(v); len(hosts) > 0 {
+			return hosts[0]
+		}
+	}
+	return ""
+}
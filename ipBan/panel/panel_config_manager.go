@@ -4,6 +4,8 @@ package panel
 
 import (
 	"net/http"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -15,12 +17,33 @@ type ConfigManager struct {
 	PanelUser string
 	// PanelPass — пароль пользователя панели для авторизации
 	PanelPass string
+	// TwoFactorSecret — секрет TOTP (base32) для кода двухфакторной аутентификации при входе; пусто — без 2FA
+	TwoFactorSecret string
+	// LoginSecret — статический секрет входа старых версий 3x-ui (поле loginSecret); пусто — не отправлять
+	LoginSecret string
+	// SessionPath — файл, в котором сохраняется кука сессии между перезапусками; пусто — не сохранять
+	SessionPath string
+	// SnapshotDir — каталог снимков inbound, которые сохраняются перед каждым изменением; пусто — без снимков
+	SnapshotDir string
+	// SnapshotKeep — сколько последних снимков хранить в SnapshotDir (0 — без ограничения)
+	SnapshotKeep int
 	// InboundID — идентификатор inbound, для которого выполняются операции
 	InboundID int
 	// Client — общий HTTP‑клиент с таймаутом, через который выполняются запросы
 	Client *http.Client
-	// SessionCookie — сериализованная кука сессии (например, "3x-ui=..."), добавляется к запросам
+	// SessionCookie — сериализованная кука сессии (например, "3x-ui=..."), добавляется к запросам.
+	// Меняется при повторном входе — читать и писать через GetSessionCookie / SetSessionCookie.
 	SessionCookie string
+	// Relogin — вход в панель (auth.Login); вызывается при истёкшей сессии. nil — без повторного входа
+	Relogin func(cm *ConfigManager) error
+	// MaxRetries — сколько раз повторять запрос, если панель недоступна
+	MaxRetries int
+	// RetryBackoff — пауза перед первым повтором (удваивается с каждым следующим)
+	RetryBackoff time.Duration
+
+	legacyClientAPI atomic.Bool  // Панель без API отдельных клиентов (см. UseClientAPI)
+	sessionMutex    sync.RWMutex // Защищает SessionCookie
+	loginMutex      sync.Mutex   // Не даёт нескольким запросам входить в панель одновременно
 }
 
 // NewConfigManager создает новый менеджер конфигураций
@@ -36,5 +59,32 @@ func NewConfigManager(panelURL, panelUser, panelPass string, inboundID int) *Con
 		Client: &http.Client{
 			Timeout: 30 * time.Second,
 		},
+		MaxRetries:   3,
+		RetryBackoff: time.Second,
 	}
 }
+
+// GetSessionCookie возвращает текущую куку сессии
+func (cm *ConfigManager) GetSessionCookie() string {
+	cm.sessionMutex.RLock()
+	defer cm.sessionMutex.RUnlock()
+	return cm.SessionCookie
+}
+
+// SetSessionCookie сохраняет куку сессии после входа
+func (cm *ConfigManager) SetSessionCookie(cookie string) {
+	cm.sessionMutex.Lock()
+	defer cm.sessionMutex.Unlock()
+	cm.SessionCookie = cookie
+}
+
+// UseClientAPI сообщает, поддерживает ли панель API отдельных клиентов (addClient, updateClient, delClient).
+// Старые версии 3x-ui его не имеют — тогда клиенты меняются полным обновлением inbound.
+func (cm *ConfigManager) UseClientAPI() bool {
+	return !cm.legacyClientAPI.Load()
+}
+
+// DisableClientAPI переключает менеджер на полное обновление inbound (панель ответила 404 на API клиента)
+func (cm *ConfigManager) DisableClientAPI() {
+	cm.legacyClientAPI.Store(true)
+}
@@ -0,0 +1,218 @@
+// Общий слой запросов к API панели: кука сессии, повторный вход, повторы с backoff и типизированные ошибки.
+// Все функции пакетов inbound и client ходят в панель только через ConfigManager.Do / Get / Post.
+package panel
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"ipBanSystem/ipBan/logger/initLogs"
+)
+
+// Request — запрос к API панели
+type Request struct {
+	// Method — HTTP-метод ("GET", "POST")
+	Method string
+	// Path — путь относительно PanelURL, например "panel/api/inbounds/list"
+	Path string
+	// Body — тело запроса: сериализуется в JSON ([]byte отправляется как есть); nil — без тела
+	Body interface{}
+	// Idempotent — запрос можно безопасно повторить после сбоя сети или 5xx
+	// (неидемпотентные повторяются, только если соединение не было установлено)
+	Idempotent bool
+}
+
+// envelope — стандартный ответ API панели
+type envelope struct {
+	Success bool            `json:"success"`
+	Msg     string          `json:"msg"`
+	Obj     json.RawMessage `json:"obj"`
+}
+
+// Get выполняет идемпотентный GET и декодирует obj ответа в out (nil — не декодировать)
+func (cm *ConfigManager) Get(path string, out interface{}) error {
+	return cm.Do(&Request{Method: http.MethodGet, Path: path, Idempotent: true}, out)
+}
+
+// Post выполняет POST с JSON-телом и декодирует obj ответа в out (nil — не декодировать).
+// Запрос считается неидемпотентным; для идемпотентных POST используйте Do с Idempotent: true.
+func (cm *ConfigManager) Post(path string, body, out interface{}) error {
+	return cm.Do(&Request{Method: http.MethodPost, Path: path, Body: body}, out)
+}
+
+// Do выполняет запрос к API панели.
+// Истёкшая сессия (401/403, перенаправление на страницу входа, success:false с сообщением о входе)
+// приводит к повторному входу через Relogin и одному повтору запроса.
+// Сбои сети и ответы 5xx повторяются до MaxRetries раз с удвоением паузы RetryBackoff.
+// Ошибки возвращаются как *Error с видом ErrNotFound, ErrUnauthorized, ErrPanelUnavailable или ErrRejected.
+func (cm *ConfigManager) Do(req *Request, out interface{}) error {
+	var payload []byte
+	switch body := req.Body.(type) {
+	case nil:
+	case []byte:
+		payload = body
+	default:
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("ошибка сериализации запроса %s: %v", req.Path, err)
+		}
+		payload = data
+	}
+
+	op := req.Method + " " + req.Path
+	relogged := false
+	for attempt := 0; ; attempt++ {
+		cookie := cm.GetSessionCookie()
+		err := cm.doOnce(req, op, payload, cookie, out)
+		if err == nil {
+			return nil
+		}
+
+		var perr *Error
+		if !errors.As(err, &perr) {
+			return err
+		}
+		switch {
+		case perr.Kind == ErrUnauthorized && !relogged && cm.Relogin != nil:
+			// Сессия истекла или панель перезапущена: входим заново и повторяем запрос один раз
+			relogged = true
+			if lerr := cm.relogin(cookie); lerr != nil {
+				return lerr
+			}
+			attempt--
+			continue
+		case perr.Kind == ErrPanelUnavailable && attempt < cm.MaxRetries && (req.Idempotent || notSent(perr.Err)):
+			delay := cm.RetryBackoff << attempt
+			initLogs.LogIPBanWarning("Панель недоступна (%s), повтор %d/%d через %v: %v", op, attempt+1, cm.MaxRetries, delay, err)
+			time.Sleep(delay)
+			continue
+		}
+		return err
+	}
+}
+
+// doOnce выполняет одну попытку запроса и разбирает ответ
+func (cm *ConfigManager) doOnce(req *Request, op string, payload []byte, cookie string, out interface{}) error {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	httpReq, err := http.NewRequest(req.Method, cm.PanelURL+req.Path, body)
+	if err != nil {
+		return fmt.Errorf("ошибка создания запроса %s: %v", op, err)
+	}
+	if payload != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	// Заголовок X-Requested-With заставляет 3x-ui отвечать 401, а не перенаправлением на страницу входа
+	httpReq.Header.Set("X-Requested-With", "XMLHttpRequest")
+	httpReq.Header.Set("Accept", "application/json")
+	if cookie != "" {
+		httpReq.Header.Add("Cookie", cookie)
+	}
+
+	resp, err := cm.Client.Do(httpReq)
+	if err != nil {
+		return &Error{Kind: ErrPanelUnavailable, Op: op, Err: err}
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return &Error{Kind: ErrPanelUnavailable, Op: op, Status: resp.StatusCode, Msg: "ошибка чтения ответа", Err: err}
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return &Error{Kind: ErrUnauthorized, Op: op, Status: resp.StatusCode, Msg: preview(data)}
+	case resp.StatusCode == http.StatusNotFound:
+		return &Error{Kind: ErrNotFound, Op: op, Status: resp.StatusCode, Msg: preview(data)}
+	case resp.StatusCode >= 500:
+		return &Error{Kind: ErrPanelUnavailable, Op: op, Status: resp.StatusCode, Msg: preview(data)}
+	case resp.StatusCode != http.StatusOK:
+		return &Error{Kind: ErrRejected, Op: op, Status: resp.StatusCode, Msg: preview(data)}
+	}
+
+	// Старые версии 3x-ui перенаправляют запрос без сессии на страницу входа (HTML со статусом 200)
+	if isLoginPage(resp, req.Path, data) {
+		return &Error{Kind: ErrUnauthorized, Op: op, Status: resp.StatusCode, Msg: "перенаправление на страницу входа"}
+	}
+
+	var env envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return &Error{Kind: ErrPanelUnavailable, Op: op, Status: resp.StatusCode, Msg: "ответ не JSON: " + preview(data), Err: err}
+	}
+	if !env.Success {
+		return &Error{Kind: classifyMsg(env.Msg), Op: op, Status: resp.StatusCode, Msg: env.Msg}
+	}
+	if out != nil && len(env.Obj) > 0 && string(env.Obj) != "null" {
+		if err := json.Unmarshal(env.Obj, out); err != nil {
+			return fmt.Errorf("ошибка парсинга ответа %s: %v", op, err)
+		}
+	}
+	return nil
+}
+
+// relogin выполняет повторный вход, если кука не была обновлена другим запросом, пока этот ждал
+func (cm *ConfigManager) relogin(staleCookie string) error {
+	cm.loginMutex.Lock()
+	defer cm.loginMutex.Unlock()
+	if current := cm.GetSessionCookie(); current != staleCookie && current != "" {
+		return nil
+	}
+	initLogs.LogIPBanWarning("Сессия панели недействительна, выполняется повторный вход")
+	if err := cm.Relogin(cm); err != nil {
+		return err
+	}
+	initLogs.LogIPBanInfo("Повторный вход в панель выполнен")
+	return nil
+}
+
+// isLoginPage определяет, что вместо ответа API пришла HTML-страница (перенаправление на вход).
+// path передаётся в экранированном виде (url.PathEscape для email и ключей клиентов),
+// поэтому сравнивается с экранированным путём итогового запроса, а не с декодированным URL.Path.
+func isLoginPage(resp *http.Response, path string, data []byte) bool {
+	if resp.Request != nil && resp.Request.URL != nil && !strings.HasSuffix(resp.Request.URL.EscapedPath(), path) {
+		return true
+	}
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		return true
+	}
+	return bytes.HasPrefix(bytes.TrimSpace(data), []byte("<"))
+}
+
+// classifyMsg определяет вид ошибки по сообщению панели при success:false
+func classifyMsg(msg string) error {
+	lower := strings.ToLower(msg)
+	switch {
+	case strings.Contains(lower, "login") || strings.Contains(lower, "session") || strings.Contains(msg, "登录"):
+		return ErrUnauthorized
+	case strings.Contains(lower, "not found") || strings.Contains(lower, "not exist"):
+		return ErrNotFound
+	default:
+		return ErrRejected
+	}
+}
+
+// notSent сообщает, что запрос не ушёл в панель (соединение не установлено) — такой запрос можно повторить
+func notSent(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// preview укорачивает тело ответа для текста ошибки
+func preview(data []byte) string {
+	const maxPreview = 200
+	s := strings.TrimSpace(string(data))
+	if len(s) > maxPreview {
+		s = s[:maxPreview] + "..."
+	}
+	return s
+}
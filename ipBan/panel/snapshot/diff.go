@@ -0,0 +1,131 @@
+package snapshot
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"ipBanSystem/ipBan/panel/inbound"
+)
+
+// Diff описывает отличия inbound to от from построчно: поля inbound, общие настройки и клиенты (по email).
+// Пустой результат — состояния совпадают.
+func Diff(from, to *inbound.Inbound) []string {
+	var lines []string
+	field := func(name string, a, b interface{}) {
+		if fmt.Sprint(a) != fmt.Sprint(b) {
+			lines = append(lines, fmt.Sprintf("~ %s: %v -> %v", name, a, b))
+		}
+	}
+	field("remark", from.Remark, to.Remark)
+	field("enable", from.Enable, to.Enable)
+	field("listen", from.Listen, to.Listen)
+	field("port", from.Port, to.Port)
+	field("protocol", from.Protocol, to.Protocol)
+	field("expiryTime", from.ExpiryTime, to.ExpiryTime)
+	field("total", from.Total, to.Total)
+	if !sameJSON(from.StreamSettings, to.StreamSettings) {
+		lines = append(lines, "~ streamSettings изменены")
+	}
+	if !sameJSON(from.Sniffing, to.Sniffing) {
+		lines = append(lines, "~ sniffing изменены")
+	}
+
+	fromSettings, fromClients := splitSettings(from.Settings)
+	toSettings, toClients := splitSettings(to.Settings)
+	lines = append(lines, diffObjects("settings.", fromSettings, toSettings)...)
+
+	emails := make(map[string]bool)
+	for k := range fromClients {
+		emails[k] = true
+	}
+	for k := range toClients {
+		emails[k] = true
+	}
+	sorted := make([]string, 0, len(emails))
+	for k := range emails {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+	for _, email := range sorted {
+		a, b := fromClients[email], toClients[email]
+		switch {
+		case a == nil:
+			lines = append(lines, "+ клиент "+email)
+		case b == nil:
+			lines = append(lines, "- клиент "+email)
+		default:
+			for _, d := range diffObjects("", a, b) {
+				lines = append(lines, fmt.Sprintf("~ клиент %s: %s", email, strings.TrimPrefix(d, "~ ")))
+			}
+		}
+	}
+	return lines
+}
+
+// splitSettings разбирает settings inbound на общие поля и клиентов по email (нижний регистр)
+func splitSettings(settings string) (map[string]interface{}, map[string]map[string]interface{}) {
+	var raw map[string]interface{}
+	_ = json.Unmarshal([]byte(settings), &raw)
+	clients := make(map[string]map[string]interface{})
+	list, _ := raw["clients"].([]interface{})
+	for _, c := range list {
+		if m, ok := c.(map[string]interface{}); ok {
+			email, _ := m["email"].(string)
+			clients[strings.ToLower(email)] = m
+		}
+	}
+	delete(raw, "clients")
+	return raw, clients
+}
+
+// diffObjects сравнивает поля двух JSON-объектов (значения — в JSON-записи), ключи по алфавиту
+func diffObjects(prefix string, a, b map[string]interface{}) []string {
+	keys := make(map[string]bool)
+	for k := range a {
+		keys[k] = true
+	}
+	for k := range b {
+		keys[k] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	var lines []string
+	for _, k := range sorted {
+		va, oka := a[k]
+		vb, okb := b[k]
+		ja, jb := jsonValue(va, oka), jsonValue(vb, okb)
+		if ja != jb {
+			lines = append(lines, fmt.Sprintf("~ %s%s: %s -> %s", prefix, k, ja, jb))
+		}
+	}
+	return lines
+}
+
+// jsonValue записывает значение поля для сравнения и вывода ("<нет>" — поля нет)
+func jsonValue(v interface{}, ok bool) string {
+	if !ok {
+		return "<нет>"
+	}
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+// sameJSON сравнивает строки JSON по содержимому (без учёта форматирования и порядка ключей)
+func sameJSON(a, b string) bool {
+	if a == b {
+		return true
+	}
+	var va, vb interface{}
+	if json.Unmarshal([]byte(a), &va) != nil || json.Unmarshal([]byte(b), &vb) != nil {
+		return false
+	}
+	ja, _ := json.Marshal(va)
+	jb, _ := json.Marshal(vb)
+	return string(ja) == string(jb)
+}
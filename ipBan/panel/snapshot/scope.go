@@ -0,0 +1,157 @@
+package snapshot
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"ipBanSystem/ipBan/panel/inbound"
+)
+
+// Only возвращает копию inbound, в которой из клиентов (settings.clients и статистика) остались только emails
+// (без учёта регистра). Поля inbound и общие настройки сохраняются полностью.
+func Only(inb *inbound.Inbound, emails []string) (*inbound.Inbound, error) {
+	keep := make(map[string]bool, len(emails))
+	for _, e := range emails {
+		keep[strings.ToLower(e)] = true
+	}
+	raw, clients, err := parseSettings(inb.Settings)
+	if err != nil {
+		return nil, err
+	}
+	kept := make([]interface{}, 0, len(emails))
+	for _, m := range clients {
+		if keep[clientEmail(m)] {
+			kept = append(kept, m)
+		}
+	}
+	raw["clients"] = kept
+	settings, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка сериализации настроек inbound: %v", err)
+	}
+
+	scoped := *inb
+	scoped.Settings = string(settings)
+	scoped.ClientStats = nil
+	for _, c := range inb.ClientStats {
+		if keep[strings.ToLower(c.Email)] {
+			scoped.ClientStats = append(scoped.ClientStats, c)
+		}
+	}
+	return &scoped, nil
+}
+
+// Scope приводит inbound к охвату снимка: для снимка затронутых клиентов — только его клиенты,
+// для снимка всего inbound (прежний формат) — inbound без изменений. Нужен для сравнения со снимком.
+func (s *Snapshot) Scope(inb *inbound.Inbound) (*inbound.Inbound, error) {
+	if !s.Partial {
+		return inb, nil
+	}
+	return Only(inb, s.Clients)
+}
+
+// Merge возвращает inbound для восстановления снимка поверх current: поля и общие настройки — из снимка,
+// клиенты снимка — из снимка (клиент из Clients, которого нет в снимке, удаляется), остальные клиенты — из current.
+// Клиент current с тем же ключом (id/password), что у клиента снимка, считается им же: так откатывается смена email.
+// Снимок всего inbound (прежний формат) возвращается целиком. Вторым значением возвращаются email клиентов
+// current, которых меняет восстановление, и клиентов снимка.
+func (s *Snapshot) Merge(current *inbound.Inbound) (*inbound.Inbound, []string, error) {
+	restored := *s.Inbound
+	_, clients, err := parseSettings(current.Settings)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !s.Partial {
+		emails := make([]string, 0, len(clients))
+		for _, m := range clients {
+			emails = append(emails, clientEmail(m))
+		}
+		return &restored, emails, nil
+	}
+	raw, saved, err := parseSettings(s.Inbound.Settings)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	listed := make(map[string]bool, len(s.Clients))
+	for _, e := range s.Clients {
+		listed[strings.ToLower(e)] = true
+	}
+	byEmail := make(map[string]map[string]interface{}, len(saved))
+	byKey := make(map[string]map[string]interface{}, len(saved))
+	for _, m := range saved {
+		byEmail[clientEmail(m)] = m
+		if key := clientCredential(m); key != "" {
+			byKey[key] = m
+		}
+	}
+
+	touched := append([]string(nil), s.Clients...)
+	used := make(map[string]bool, len(saved))
+	merged := make([]interface{}, 0, len(clients)+len(saved))
+	for _, m := range clients {
+		email := clientEmail(m)
+		want := byEmail[email]
+		if want == nil {
+			want = byKey[clientCredential(m)]
+		}
+		if want != nil && !listed[email] {
+			touched = append(touched, email)
+		}
+		switch {
+		case want != nil && !used[clientEmail(want)]:
+			used[clientEmail(want)] = true
+			merged = append(merged, want)
+		case want != nil || listed[email]:
+			// Клиент снимка уже восстановлен или клиента не было до операции
+		default:
+			merged = append(merged, m)
+		}
+	}
+	for _, m := range saved {
+		if !used[clientEmail(m)] {
+			merged = append(merged, m)
+		}
+	}
+	raw["clients"] = merged
+	settings, err := json.Marshal(raw)
+	if err != nil {
+		return nil, nil, fmt.Errorf("ошибка сериализации настроек inbound: %v", err)
+	}
+	restored.Settings = string(settings)
+	return &restored, touched, nil
+}
+
+// parseSettings разбирает settings inbound на JSON-объект и список клиентов
+func parseSettings(settings string) (map[string]interface{}, []map[string]interface{}, error) {
+	raw := make(map[string]interface{})
+	if settings != "" {
+		if err := json.Unmarshal([]byte(settings), &raw); err != nil {
+			return nil, nil, fmt.Errorf("ошибка парсинга настроек inbound: %v", err)
+		}
+	}
+	var clients []map[string]interface{}
+	list, _ := raw["clients"].([]interface{})
+	for _, c := range list {
+		if m, ok := c.(map[string]interface{}); ok {
+			clients = append(clients, m)
+		}
+	}
+	return raw, clients, nil
+}
+
+// clientEmail возвращает email клиента в нижнем регистре
+func clientEmail(m map[string]interface{}) string {
+	email, _ := m["email"].(string)
+	return strings.ToLower(email)
+}
+
+// clientCredential возвращает ключ клиента: UUID (vless/vmess) или пароль (trojan/shadowsocks)
+func clientCredential(m map[string]interface{}) string {
+	if id, _ := m["id"].(string); id != "" {
+		return id
+	}
+	password, _ := m["password"].(string)
+	return password
+}
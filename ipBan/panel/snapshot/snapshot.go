@@ -0,0 +1,149 @@
+// Пакет snapshot: история снимков inbound на диске.
+// Перед каждым изменением панели сохраняется снимок JSON inbound с меткой времени и описанием операции:
+// поля inbound и только клиенты, которых касается операция. По снимку администратор может сравнить
+// и восстановить прежнее состояние этих клиентов, не трогая остальных.
+package snapshot
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"ipBanSystem/ipBan/panel/inbound"
+)
+
+// idLayout — формат метки времени в ID снимка: ID сортируются по времени как строки
+const idLayout = "20060102-150405.000000000"
+
+// Snapshot — снимок inbound перед изменением
+type Snapshot struct {
+	// ID — имя снимка (метка времени и inbound), оно же имя файла без .json
+	ID string `json:"id"`
+	// InboundID — inbound, с которого снят снимок
+	InboundID int `json:"inbound_id"`
+	// Operation — операция, перед которой снят снимок
+	Operation string `json:"operation"`
+	// TakenAt — время снимка
+	TakenAt time.Time `json:"taken_at"`
+	// Partial — снимок хранит только клиентов Clients (false — весь inbound, снимки прежнего формата)
+	Partial bool `json:"partial,omitempty"`
+	// Clients — email клиентов, затронутых операцией (нижний регистр; пусто — операция не меняет клиентов)
+	Clients []string `json:"clients,omitempty"`
+	// Inbound — состояние inbound (в списке снимков не заполняется)
+	Inbound *inbound.Inbound `json:"inbound,omitempty"`
+}
+
+// Save сохраняет снимок inbound перед операцией op в каталог dir: поля inbound и клиентов emails,
+// которых касается операция. В каталоге остаётся не больше keep последних снимков (keep <= 0 — без ограничения).
+func Save(dir string, keep int, inb *inbound.Inbound, op string, emails []string) (*Snapshot, error) {
+	clients := make([]string, 0, len(emails))
+	for _, e := range emails {
+		clients = append(clients, strings.ToLower(e))
+	}
+	// Снимок хранит копию: вызывающий может менять inb после сохранения
+	scoped, err := Only(inb, clients)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	snap := &Snapshot{
+		ID:        fmt.Sprintf("%s-%d", now.Format(idLayout), inb.ID),
+		InboundID: inb.ID,
+		Operation: op,
+		TakenAt:   now,
+		Partial:   true,
+		Clients:   clients,
+		Inbound:   scoped,
+	}
+	data, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("ошибка сериализации снимка: %v", err)
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("ошибка создания каталога снимков: %v", err)
+	}
+	path := filepath.Join(dir, snap.ID+".json")
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return nil, fmt.Errorf("ошибка записи снимка: %v", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return nil, fmt.Errorf("ошибка сохранения снимка: %v", err)
+	}
+
+	if keep > 0 {
+		prune(dir, keep)
+	}
+	return snap, nil
+}
+
+// List возвращает снимки каталога dir без состояния inbound, от новых к старым
+func List(dir string) ([]Snapshot, error) {
+	ids, err := listIDs(dir)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]Snapshot, 0, len(ids))
+	for i := len(ids) - 1; i >= 0; i-- {
+		snap, err := Load(dir, ids[i])
+		if err != nil {
+			return nil, err
+		}
+		snap.Inbound = nil
+		list = append(list, *snap)
+	}
+	return list, nil
+}
+
+// Load читает снимок id из каталога dir
+func Load(dir, id string) (*Snapshot, error) {
+	if id == "" || filepath.Base(id) != id || strings.HasPrefix(id, ".") {
+		return nil, fmt.Errorf("некорректный ID снимка: %q", id)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, id+".json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("снимок %s не найден: %w", id, os.ErrNotExist)
+		}
+		return nil, fmt.Errorf("ошибка чтения снимка %s: %v", id, err)
+	}
+	var snap Snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("снимок %s повреждён: %v", id, err)
+	}
+	return &snap, nil
+}
+
+// listIDs возвращает ID снимков каталога от старых к новым (нет каталога — пустой список)
+func listIDs(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("ошибка чтения каталога снимков: %v", err)
+	}
+	var ids []string
+	for _, e := range entries {
+		if id, ok := strings.CutSuffix(e.Name(), ".json"); ok && !e.IsDir() {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// prune удаляет самые старые снимки сверх keep
+func prune(dir string, keep int) {
+	ids, err := listIDs(dir)
+	if err != nil {
+		return
+	}
+	for i := 0; i < len(ids)-keep; i++ {
+		_ = os.Remove(filepath.Join(dir, ids[i]+".json"))
+	}
+}
@@ -0,0 +1,96 @@
+// Настройки TLS соединения с панелью: собственный CA, закреплённый отпечаток сертификата
+// или отключение проверки (для панелей на самоподписанных сертификатах).
+package panel
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// TLSOptions — параметры проверки сертификата панели
+type TLSOptions struct {
+	// CAFile — PEM-файл с сертификатами CA, которым доверять вместо системных
+	CAFile string
+	// PinnedSHA256 — SHA-256 отпечаток сертификата панели (hex, двоеточия допускаются).
+	// При заданном отпечатке цепочка не проверяется: принимается только сертификат с этим отпечатком
+	PinnedSHA256 string
+	// InsecureSkipVerify — не проверять сертификат панели вовсе
+	InsecureSkipVerify bool
+}
+
+// Empty сообщает, что настройки не заданы (используется проверка по системным CA)
+func (o TLSOptions) Empty() bool {
+	return o.CAFile == "" && o.PinnedSHA256 == "" && !o.InsecureSkipVerify
+}
+
+// ConfigureTLS применяет параметры TLS к HTTP-клиенту менеджера
+func (cm *ConfigManager) ConfigureTLS(opts TLSOptions) error {
+	if opts.Empty() {
+		return nil
+	}
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if opts.CAFile != "" {
+		data, err := os.ReadFile(opts.CAFile)
+		if err != nil {
+			return fmt.Errorf("ошибка чтения CA панели %s: %w", opts.CAFile, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return fmt.Errorf("в файле %s нет сертификатов PEM", opts.CAFile)
+		}
+		config.RootCAs = pool
+	}
+
+	switch {
+	case opts.PinnedSHA256 != "":
+		pin, err := parseFingerprint(opts.PinnedSHA256)
+		if err != nil {
+			return err
+		}
+		// Стандартная проверка цепочки отключается, сертификат сверяется с отпечатком
+		config.InsecureSkipVerify = true
+		config.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 {
+				return errors.New("панель не предъявила сертификат")
+			}
+			if sum := sha256.Sum256(rawCerts[0]); hex.EncodeToString(sum[:]) != pin {
+				return fmt.Errorf("отпечаток сертификата панели %x не совпадает с закреплённым", sum)
+			}
+			return nil
+		}
+	case opts.InsecureSkipVerify:
+		config.InsecureSkipVerify = true
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = config
+	cm.Client.Transport = transport
+	return nil
+}
+
+// parseFingerprint приводит отпечаток SHA-256 к нижнему регистру без двоеточий и проверяет длину
+func parseFingerprint(s string) (string, error) {
+	pin := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(s), ":", ""))
+	if b, err := hex.DecodeString(pin); err != nil || len(b) != sha256.Size {
+		return "", fmt.Errorf("некорректный отпечаток SHA-256 сертификата панели: %q", s)
+	}
+	return pin, nil
+}
+
+// JoinBasePath добавляет к адресу панели секретный базовый путь веб-интерфейса ("/secret/" или "secret").
+// Результат оканчивается слешем, как того требует PanelURL
+func JoinBasePath(panelURL, basePath string) string {
+	url := strings.TrimRight(panelURL, "/") + "/"
+	if base := strings.Trim(basePath, "/"); base != "" {
+		url += base + "/"
+	}
+	return url
+}
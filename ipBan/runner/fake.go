@@ -0,0 +1,172 @@
+package runner
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Call — одна выполненная команда
+type Call struct {
+	Name  string
+	Args  []string
+	Stdin string
+}
+
+// String возвращает команду одной строкой: "iptables -A IPBAN -s 203.0.113.7 -j DROP"
+func (c Call) String() string {
+	return CommandLine(c.Name, c.Args...)
+}
+
+// RecordingRunner запоминает команды и не выполняет их: каждая команда «успешна» с пустым выводом
+type RecordingRunner struct {
+	calls []Call
+	mutex sync.Mutex
+}
+
+// NewRecordingRunner создаёт пустой записывающий фейк
+func NewRecordingRunner() *RecordingRunner {
+	return &RecordingRunner{}
+}
+
+// Run записывает команду
+func (r *RecordingRunner) Run(stdin []byte, name string, args ...string) ([]byte, []byte, error) {
+	r.record(stdin, name, args)
+	return nil, nil, nil
+}
+
+// record добавляет команду в журнал
+func (r *RecordingRunner) record(stdin []byte, name string, args []string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.calls = append(r.calls, Call{Name: name, Args: append([]string(nil), args...), Stdin: string(stdin)})
+}
+
+// Calls возвращает копию журнала команд в порядке выполнения
+func (r *RecordingRunner) Calls() []Call {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]Call(nil), r.calls...)
+}
+
+// Commands возвращает журнал команд строками
+func (r *RecordingRunner) Commands() []string {
+	calls := r.Calls()
+	lines := make([]string, len(calls))
+	for n, c := range calls {
+		lines[n] = c.String()
+	}
+	return lines
+}
+
+// Count возвращает число выполненных команд, начинающихся с prefix
+func (r *RecordingRunner) Count(prefix string) int {
+	count := 0
+	for _, line := range r.Commands() {
+		if strings.HasPrefix(line, prefix) {
+			count++
+		}
+	}
+	return count
+}
+
+// Reset очищает журнал
+func (r *RecordingRunner) Reset() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.calls = nil
+}
+
+// Response — заготовленный результат команды
+type Response struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int   // Ненулевой код превращается в *ExitError
+	Err      error // Ошибка запуска (например, утилита не установлена); важнее ExitCode
+}
+
+// scriptRule — правило ScriptedRunner: префикс команды и ответ
+type scriptRule struct {
+	prefix    string
+	response  Response
+	remaining int // Сколько раз ещё сработает правило (-1 — без ограничений)
+}
+
+// ScriptedRunner отвечает на команды заготовленными ответами и записывает их (как RecordingRunner).
+// Правила проверяются в порядке добавления, срабатывает первое подходящее по префиксу строки команды.
+// Команда без правила получает Default; при Strict — ошибку «неожиданная команда».
+type ScriptedRunner struct {
+	RecordingRunner
+	Default Response
+	Strict  bool
+
+	rules []*scriptRule
+	rmu   sync.Mutex
+}
+
+// NewScriptedRunner создаёт фейк с заготовленными ответами
+func NewScriptedRunner() *ScriptedRunner {
+	return &ScriptedRunner{}
+}
+
+// On задаёт ответ на все команды, начинающиеся с prefix
+func (s *ScriptedRunner) On(prefix string, response Response) *ScriptedRunner {
+	return s.add(prefix, response, -1)
+}
+
+// Once задаёт ответ на одну (следующую) команду, начинающуюся с prefix
+func (s *ScriptedRunner) Once(prefix string, response Response) *ScriptedRunner {
+	return s.add(prefix, response, 1)
+}
+
+// Fail — сокращение для ответа с ненулевым кодом выхода и текстом ошибки
+func (s *ScriptedRunner) Fail(prefix string, exitCode int, stderr string) *ScriptedRunner {
+	return s.On(prefix, Response{ExitCode: exitCode, Stderr: stderr})
+}
+
+// add добавляет правило
+func (s *ScriptedRunner) add(prefix string, response Response, times int) *ScriptedRunner {
+	s.rmu.Lock()
+	defer s.rmu.Unlock()
+	s.rules = append(s.rules, &scriptRule{prefix: prefix, response: response, remaining: times})
+	return s
+}
+
+// Run записывает команду и возвращает заготовленный ответ
+func (s *ScriptedRunner) Run(stdin []byte, name string, args ...string) ([]byte, []byte, error) {
+	s.record(stdin, name, args)
+	line := CommandLine(name, args...)
+
+	response, matched := s.match(line)
+	if !matched && s.Strict {
+		return nil, nil, fmt.Errorf("неожиданная команда: %s", line)
+	}
+	if !matched {
+		response = s.Default
+	}
+
+	var err error
+	switch {
+	case response.Err != nil:
+		err = response.Err
+	case response.ExitCode != 0:
+		err = &ExitError{Code: response.ExitCode}
+	}
+	return []byte(response.Stdout), []byte(response.Stderr), err
+}
+
+// match находит первое действующее правило для строки команды
+func (s *ScriptedRunner) match(line string) (Response, bool) {
+	s.rmu.Lock()
+	defer s.rmu.Unlock()
+	for _, rule := range s.rules {
+		if rule.remaining == 0 || !strings.HasPrefix(line, rule.prefix) {
+			continue
+		}
+		if rule.remaining > 0 {
+			rule.remaining--
+		}
+		return rule.response, true
+	}
+	return Response{}, false
+}
@@ -0,0 +1,72 @@
+// Пакет runner: запуск внешних команд (iptables, ipset, nft, systemctl, go build) через подменяемый интерфейс.
+// Файрвол и установщик вызывают команды только через CommandRunner,
+// поэтому их логику можно проверить без root, iptables и systemd (см. RecordingRunner и ScriptedRunner).
+package runner
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// CommandRunner выполняет внешнюю команду
+type CommandRunner interface {
+	// Run выполняет команду name с аргументами args; stdin передаётся на стандартный ввод (nil — без ввода).
+	// Возвращает stdout и stderr раздельно; err != nil при ошибке запуска или ненулевом коде выхода.
+	Run(stdin []byte, name string, args ...string) (stdout, stderr []byte, err error)
+}
+
+// ExecRunner — настоящий запуск процессов через os/exec
+type ExecRunner struct{}
+
+// Run запускает процесс и дожидается его завершения
+func (ExecRunner) Run(stdin []byte, name string, args ...string) ([]byte, []byte, error) {
+	cmd := exec.Command(name, args...)
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	return stdout.Bytes(), stderr.Bytes(), err
+}
+
+// Combined выполняет команду и возвращает stdout и stderr одним блоком (аналог exec.Cmd.CombinedOutput)
+func Combined(r CommandRunner, name string, args ...string) ([]byte, error) {
+	return CombinedInput(r, nil, name, args...)
+}
+
+// CombinedInput — Combined со стандартным вводом
+func CombinedInput(r CommandRunner, stdin []byte, name string, args ...string) ([]byte, error) {
+	stdout, stderr, err := r.Run(stdin, name, args...)
+	return append(stdout, stderr...), err
+}
+
+// Output выполняет команду и возвращает только stdout (аналог exec.Cmd.Output)
+func Output(r CommandRunner, name string, args ...string) ([]byte, error) {
+	stdout, _, err := r.Run(nil, name, args...)
+	return stdout, err
+}
+
+// Check выполняет команду, отбрасывая вывод: nil означает нулевой код выхода (например, iptables -C)
+func Check(r CommandRunner, name string, args ...string) error {
+	_, _, err := r.Run(nil, name, args...)
+	return err
+}
+
+// CommandLine склеивает команду в строку для логов и сопоставления в фейках: "iptables -S IPBAN"
+func CommandLine(name string, args ...string) string {
+	return strings.TrimSpace(name + " " + strings.Join(args, " "))
+}
+
+// ExitError — ненулевой код выхода команды (возвращается фейками; ExecRunner возвращает *exec.ExitError)
+type ExitError struct {
+	Code int
+}
+
+// Error возвращает текст в формате os/exec
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
@@ -1,11 +1,18 @@
 package main
 
 import (
+	"os"
+
 	"ipBanSystem/app"
 	"ipBanSystem/flags"
 )
 
 func main() {
+	// административные подкоманды (ban/unban/bans/users/firewall) работают через демон или напрямую с хранилищем
+	if flags.HandleCommand(os.Args[1:]) {
+		return
+	}
+
 	cfg := flags.Flags()
 
 	// единый диспетчер служебных флагов (install/uninstall/reinstall)